@@ -0,0 +1,31 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/clientcredentials"
+)
+
+// NewOAuth2RoundTripper returns a round-tripper that adds an `Authorization: Bearer <token>` header to
+// the requests using a token obtained from the given token URL with the OAuth2 client credentials flow.
+// The token is cached and a new one is requested once it expires. The given context is used for the
+// token requests and the requests are sent with the given transport (or http.DefaultTransport if nil).
+func NewOAuth2RoundTripper(ctx context.Context, tokenURL, clientID, clientSecret string, scopes []string, base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	cfg := &clientcredentials.Config{
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		TokenURL:     tokenURL,
+		Scopes:       scopes,
+	}
+
+	return &oauth2.Transport{
+		Source: cfg.TokenSource(ctx), // reuses the token until it expires
+		Base:   base,
+	}
+}
@@ -0,0 +1,70 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/gkatanacio/multisource-downloader/download"
+)
+
+func Test_NewOAuth2RoundTripper(t *testing.T) {
+	var tokenRequests atomic.Int32
+	tokenSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		clientID, clientSecret, _ := r.BasicAuth()
+		if clientID != "client-id" || clientSecret != "client-secret" || r.FormValue("grant_type") != "client_credentials" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		assert.Equal(t, "downloads:read", r.FormValue("scope"))
+
+		n := tokenRequests.Add(1)
+		expiresIn := 3600
+		if n == 1 {
+			expiresIn = 1 // already considered expired given the expiry leeway of the oauth2 package
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"access_token":"token-%d","token_type":"Bearer","expires_in":%d}`, n, expiresIn)
+	}))
+	defer tokenSrv.Close()
+
+	content := bytes.Repeat([]byte("0123456789"), 100)
+
+	var mu sync.Mutex
+	receivedTokens := make(map[string]int)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		receivedTokens[r.Header.Get("Authorization")]++
+		mu.Unlock()
+
+		http.ServeContent(w, r, "digits.txt", time.Time{}, bytes.NewReader(content))
+	}))
+	defer srv.Close()
+
+	downloadService := download.NewService(download.Options{
+		Connections:  2,
+		Timeout:      3,
+		Quiet:        true,
+		DestFilePath: filepath.Join(t.TempDir(), "digits.txt"),
+		WrapTransport: func(base http.RoundTripper) http.RoundTripper {
+			return NewOAuth2RoundTripper(context.Background(), tokenSrv.URL, "client-id", "client-secret", []string{"downloads:read"}, base)
+		},
+	}, download.GetMD5Hash)
+
+	err := downloadService.Download([]string{srv.URL + "/digits.txt"})
+	assert.NoError(t, err)
+
+	// the expired first token is only used for the HEAD request and then refreshed once
+	assert.Equal(t, int32(2), tokenRequests.Load())
+	assert.Equal(t, map[string]int{"Bearer token-1": 1, "Bearer token-2": 2}, receivedTokens)
+}
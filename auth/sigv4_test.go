@@ -0,0 +1,58 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_sigV4RoundTripper_RoundTrip(t *testing.T) {
+	signingTime := time.Date(2024, 4, 12, 10, 0, 0, 0, time.UTC)
+
+	var receivedReq *http.Request
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		receivedReq = r
+	}))
+	defer srv.Close()
+
+	rt := NewSigV4RoundTripper("ap-southeast-1", "s3", "AKIDEXAMPLE", "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY", nil).(*sigV4RoundTripper)
+	rt.now = func() time.Time { return signingTime }
+
+	req, err := http.NewRequest(http.MethodGet, srv.URL+"/bucket/a.txt", nil)
+	assert.NoError(t, err)
+	req.Header.Set("Range", "bytes=0-9")
+
+	resp, err := (&http.Client{Transport: rt}).Do(req)
+	assert.NoError(t, err)
+	resp.Body.Close()
+
+	assert.Empty(t, req.Header.Get("Authorization"), "original request should not be modified")
+
+	// re-sign an equivalent request independently and compare the resulting signature
+	expectedReq, err := http.NewRequest(http.MethodGet, srv.URL+"/bucket/a.txt", nil)
+	assert.NoError(t, err)
+	expectedReq.Header.Set("Range", "bytes=0-9")
+	expectedReq.Header.Set("X-Amz-Content-Sha256", emptyPayloadHash)
+
+	err = v4.NewSigner().SignHTTP(
+		context.Background(),
+		aws.Credentials{AccessKeyID: "AKIDEXAMPLE", SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY"},
+		expectedReq,
+		emptyPayloadHash,
+		"s3",
+		"ap-southeast-1",
+		signingTime,
+	)
+	assert.NoError(t, err)
+
+	assert.Contains(t, receivedReq.Header.Get("Authorization"), "Credential=AKIDEXAMPLE/20240412/ap-southeast-1/s3/aws4_request")
+	assert.Equal(t, expectedReq.Header.Get("Authorization"), receivedReq.Header.Get("Authorization"))
+	assert.Equal(t, "20240412T100000Z", receivedReq.Header.Get("X-Amz-Date"))
+	assert.Equal(t, emptyPayloadHash, receivedReq.Header.Get("X-Amz-Content-Sha256"))
+}
@@ -0,0 +1,75 @@
+// Package backoff provides the jitter strategies for retry delays described in the AWS Architecture
+// Blog post "Exponential Backoff And Jitter".
+package backoff
+
+import (
+	"math/rand/v2"
+	"sync"
+	"time"
+)
+
+// Backoff determines how long to wait before the retry with the given attempt number (starting at 0)
+// where base is the initial delay and cap is the maximum delay.
+type Backoff interface {
+	Next(attempt int, base, cap time.Duration) time.Duration
+}
+
+// FullJitter waits for a random delay between 0 and the exponential backoff.
+type FullJitter struct{}
+
+// Next implements Backoff.
+func (FullJitter) Next(attempt int, base, cap time.Duration) time.Duration {
+	return randomBetween(0, exponential(attempt, base, cap))
+}
+
+// EqualJitter waits for half of the exponential backoff plus a random delay up to the other half.
+type EqualJitter struct{}
+
+// Next implements Backoff.
+func (EqualJitter) Next(attempt int, base, cap time.Duration) time.Duration {
+	half := exponential(attempt, base, cap) / 2
+	return half + randomBetween(0, half)
+}
+
+// DecorrelatedJitter waits for a random delay between base and three times the previous delay
+// (up to cap) instead of growing exponentially with the attempt number. The previous delay starts
+// over at base on attempt 0. It must be used by pointer since it keeps the previous delay.
+type DecorrelatedJitter struct {
+	mu    sync.Mutex
+	sleep time.Duration
+}
+
+// Next implements Backoff.
+func (dj *DecorrelatedJitter) Next(attempt int, base, cap time.Duration) time.Duration {
+	dj.mu.Lock()
+	defer dj.mu.Unlock()
+
+	if attempt == 0 || dj.sleep < base {
+		dj.sleep = base
+	}
+	dj.sleep = min(cap, randomBetween(base, dj.sleep*3))
+
+	return dj.sleep
+}
+
+// exponential returns base * 2^attempt capped at cap (without overflowing).
+func exponential(attempt int, base, cap time.Duration) time.Duration {
+	if attempt < 0 {
+		attempt = 0
+	}
+
+	if attempt >= 63 || base > cap>>attempt {
+		return cap
+	}
+
+	return base << attempt
+}
+
+// randomBetween returns a random duration in [lo, hi].
+func randomBetween(lo, hi time.Duration) time.Duration {
+	if hi <= lo {
+		return lo
+	}
+
+	return lo + time.Duration(rand.Int64N(int64(hi-lo)+1))
+}
@@ -0,0 +1,88 @@
+package backoff_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/gkatanacio/multisource-downloader/backoff"
+)
+
+const (
+	base    = 100 * time.Millisecond
+	cap     = 10 * time.Second
+	samples = 10000
+)
+
+func Test_FullJitter_Next(t *testing.T) {
+	for attempt := 0; attempt < 12; attempt++ {
+		upper := min(cap, base*(1<<attempt))
+
+		for range samples / 10 {
+			delay := backoff.FullJitter{}.Next(attempt, base, cap)
+			assert.GreaterOrEqual(t, delay, time.Duration(0))
+			assert.LessOrEqual(t, delay, upper)
+		}
+	}
+}
+
+func Test_FullJitter_Next_LargeAttemptDoesNotOverflow(t *testing.T) {
+	for _, attempt := range []int{62, 63, 64, 1000} {
+		delay := backoff.FullJitter{}.Next(attempt, base, cap)
+		assert.GreaterOrEqual(t, delay, time.Duration(0))
+		assert.LessOrEqual(t, delay, cap)
+	}
+}
+
+func Test_EqualJitter_Next(t *testing.T) {
+	for attempt := 0; attempt < 12; attempt++ {
+		upper := min(cap, base*(1<<attempt))
+
+		for range samples / 10 {
+			delay := backoff.EqualJitter{}.Next(attempt, base, cap)
+			assert.GreaterOrEqual(t, delay, upper/2)
+			assert.LessOrEqual(t, delay, upper)
+		}
+	}
+}
+
+func Test_DecorrelatedJitter_Next(t *testing.T) {
+	dj := &backoff.DecorrelatedJitter{}
+
+	var fullJitterSum, decorrelatedSum time.Duration
+	var fullJitterBelowBase int
+	for i := range samples {
+		attempt := i % 5 // sequences of 5 retries
+
+		fullJitterDelay := backoff.FullJitter{}.Next(attempt, base, cap)
+		fullJitterSum += fullJitterDelay
+		if fullJitterDelay < base {
+			fullJitterBelowBase++
+		}
+
+		delay := dj.Next(attempt, base, cap)
+		assert.GreaterOrEqual(t, delay, base)
+		assert.LessOrEqual(t, delay, cap)
+		decorrelatedSum += delay
+	}
+
+	// full jitter often goes below base while decorrelated jitter never does
+	assert.Greater(t, fullJitterBelowBase, samples/10)
+
+	// the expected means are 310ms for full jitter and about 690ms for decorrelated jitter
+	fullJitterMean := fullJitterSum / samples
+	decorrelatedMean := decorrelatedSum / samples
+	assert.Greater(t, decorrelatedMean, fullJitterMean*3/2)
+}
+
+func Test_DecorrelatedJitter_Next_StartsOverOnFirstAttempt(t *testing.T) {
+	dj := &backoff.DecorrelatedJitter{}
+
+	for attempt := 0; attempt < 20; attempt++ {
+		dj.Next(attempt, base, cap)
+	}
+
+	// the first attempt is at most 3 times base regardless of the previous delays
+	assert.LessOrEqual(t, dj.Next(0, base, cap), 3*base)
+}
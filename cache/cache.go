@@ -0,0 +1,179 @@
+// Package cache provides a disk cache for HTTP responses which honours Cache-Control max-age.
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Entry represents a cached response.
+type Entry struct {
+	ETag          string        `json:"etag"`
+	ContentType   string        `json:"content_type"`
+	ContentLength int64         `json:"content_length"`
+	AcceptRanges  bool          `json:"accept_ranges"`
+	MetalinkURL   string        `json:"metalink_url,omitempty"`
+	StoredAt      time.Time     `json:"stored_at"`
+	MaxAge        time.Duration `json:"max_age"`
+	Body          []byte        `json:"-"` // stored separately from the metadata
+}
+
+// Fresh returns true if the entry is still within its max-age at the given time.
+func (e *Entry) Fresh(now time.Time) bool {
+	return now.Before(e.StoredAt.Add(e.MaxAge))
+}
+
+// DiskCache stores the cached responses (metadata and content) in a directory keyed by request.
+type DiskCache struct {
+	dir string
+	now func() time.Time
+}
+
+// NewDiskCache returns a cache which stores its entries in the given directory
+// (created on the first write if it does not exist).
+func NewDiskCache(dir string) *DiskCache {
+	return &DiskCache{dir: dir, now: time.Now}
+}
+
+// Key returns the cache key of the request for the given URL and (optional) range.
+func Key(url, rangeHeader string) string {
+	if len(rangeHeader) == 0 {
+		return url
+	}
+
+	return url + " " + rangeHeader
+}
+
+// Get returns the entry stored for the given key regardless of its freshness.
+// A fs.ErrNotExist error is returned if there is no such entry.
+func (dc *DiskCache) Get(key string) (*Entry, error) {
+	metaPath, bodyPath := dc.paths(key)
+
+	b, err := os.ReadFile(metaPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var entry Entry
+	if err := json.Unmarshal(b, &entry); err != nil {
+		return nil, err
+	}
+
+	if entry.Body, err = os.ReadFile(bodyPath); errors.Is(err, fs.ErrNotExist) {
+		entry.Body = nil // metadata only (e.g., HEAD responses)
+	} else if err != nil {
+		return nil, err
+	}
+
+	return &entry, nil
+}
+
+// Put stores the entry for the given key, setting its StoredAt to the current time. The body is
+// written before the metadata so that a partially written entry is never considered valid.
+func (dc *DiskCache) Put(key string, entry *Entry) error {
+	if err := os.MkdirAll(dc.dir, 0755); err != nil {
+		return err
+	}
+
+	entry.StoredAt = dc.now()
+	metaPath, bodyPath := dc.paths(key)
+
+	if entry.Body != nil {
+		if err := writeFileAtomically(bodyPath, entry.Body); err != nil {
+			return err
+		}
+	} else if err := os.Remove(bodyPath); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	b, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	return writeFileAtomically(metaPath, b)
+}
+
+// Touch marks the entry for the given key as just stored with the given max-age (e.g., after
+// being revalidated with the server).
+func (dc *DiskCache) Touch(key string, maxAge time.Duration) error {
+	entry, err := dc.Get(key)
+	if err != nil {
+		return err
+	}
+
+	entry.MaxAge = maxAge
+	return dc.Put(key, entry)
+}
+
+// Lookup returns the entry stored for the given key along with whether it is still fresh.
+func (dc *DiskCache) Lookup(key string) (*Entry, bool, error) {
+	entry, err := dc.Get(key)
+	if err != nil {
+		return nil, false, err
+	}
+
+	return entry, entry.Fresh(dc.now()), nil
+}
+
+// paths returns the paths of the metadata and body files of the given key.
+func (dc *DiskCache) paths(key string) (string, string) {
+	name := fmt.Sprintf("%x", sha256.Sum256([]byte(key)))
+	return filepath.Join(dc.dir, name+".json"), filepath.Join(dc.dir, name+".body")
+}
+
+// writeFileAtomically writes the contents to a temporary file which is then renamed to the given path.
+// The temporary file has a unique name so that concurrent writers (e.g., other processes sharing the
+// cache directory) do not overwrite each other's temporary file before the rename.
+func writeFileAtomically(filePath string, b []byte) error {
+	f, err := os.CreateTemp(filepath.Dir(filePath), filepath.Base(filePath)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name()) // no-op once renamed
+
+	_, err = f.Write(b)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(f.Name(), 0644) // rather than the 0600 of CreateTemp
+	}
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(f.Name(), filePath)
+}
+
+// MaxAge parses the max-age directive of the given Cache-Control header value. False is returned if
+// the response must not be cached (i.e., no max-age, `no-store` or `no-cache`).
+func MaxAge(cacheControl string) (time.Duration, bool) {
+	var maxAge time.Duration
+	var found bool
+
+	for _, directive := range strings.Split(cacheControl, ",") {
+		directive = strings.ToLower(strings.TrimSpace(directive))
+
+		switch {
+		case directive == "no-store" || directive == "no-cache":
+			return 0, false
+		case strings.HasPrefix(directive, "max-age="):
+			seconds, err := strconv.Atoi(strings.TrimPrefix(directive, "max-age="))
+			if err != nil || seconds <= 0 {
+				return 0, false
+			}
+			maxAge, found = time.Duration(seconds)*time.Second, true
+		}
+	}
+
+	return maxAge, found
+}
@@ -0,0 +1,125 @@
+package cache
+
+import (
+	"bytes"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_DiskCache(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	dc := NewDiskCache(t.TempDir())
+	dc.now = func() time.Time { return now }
+
+	headKey := Key("http://example.com/a.txt", "")
+	rangeKey := Key("http://example.com/a.txt", "bytes=0-9")
+	assert.NotEqual(t, headKey, rangeKey)
+
+	_, _, err := dc.Lookup(headKey)
+	assert.Error(t, err) // miss
+
+	assert.NoError(t, dc.Put(headKey, &Entry{ETag: "abc", ContentType: "text/plain", ContentLength: 100, AcceptRanges: true, MaxAge: time.Minute}))
+	assert.NoError(t, dc.Put(rangeKey, &Entry{ETag: "abc", Body: []byte("0123456789"), MaxAge: time.Minute}))
+
+	entry, fresh, err := dc.Lookup(headKey)
+	assert.NoError(t, err)
+	assert.True(t, fresh)
+	assert.Equal(t, "abc", entry.ETag)
+	assert.Equal(t, int64(100), entry.ContentLength)
+	assert.Nil(t, entry.Body)
+
+	entry, fresh, err = dc.Lookup(rangeKey)
+	assert.NoError(t, err)
+	assert.True(t, fresh)
+	assert.Equal(t, []byte("0123456789"), entry.Body)
+
+	// expired entries are still returned for revalidation
+	now = now.Add(2 * time.Minute)
+	entry, fresh, err = dc.Lookup(rangeKey)
+	assert.NoError(t, err)
+	assert.False(t, fresh)
+	assert.Equal(t, []byte("0123456789"), entry.Body)
+
+	assert.NoError(t, dc.Touch(rangeKey, time.Minute))
+	entry, fresh, err = dc.Lookup(rangeKey)
+	assert.NoError(t, err)
+	assert.True(t, fresh)
+	assert.Equal(t, []byte("0123456789"), entry.Body)
+}
+
+func Test_DiskCache_ConcurrentPut(t *testing.T) {
+	dir := t.TempDir()
+	key := Key("http://example.com/a.txt", "bytes=0-9")
+
+	// e.g., two services sharing the cache directory
+	var wg sync.WaitGroup
+	for i := range 2 {
+		dc := NewDiskCache(dir)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 50 {
+				assert.NoError(t, dc.Put(key, &Entry{ETag: "abc", Body: bytes.Repeat([]byte{byte('0' + i)}, 1<<16), MaxAge: time.Minute}))
+			}
+		}()
+	}
+	wg.Wait()
+
+	entry, _, err := NewDiskCache(dir).Lookup(key)
+	assert.NoError(t, err)
+	assert.Len(t, entry.Body, 1<<16)
+
+	// no temporary files are left behind
+	entries, err := os.ReadDir(dir)
+	assert.NoError(t, err)
+	assert.Len(t, entries, 2) // the metadata and the body
+}
+
+func Test_MaxAge(t *testing.T) {
+	testCases := map[string]struct {
+		cacheControl   string
+		expectedMaxAge time.Duration
+		expectedOk     bool
+	}{
+		"max-age": {
+			cacheControl:   "max-age=60",
+			expectedMaxAge: time.Minute,
+			expectedOk:     true,
+		},
+		"with other directives": {
+			cacheControl:   "public, Max-Age=3600, must-revalidate",
+			expectedMaxAge: time.Hour,
+			expectedOk:     true,
+		},
+		"no max-age": {
+			cacheControl: "public",
+		},
+		"empty": {
+			cacheControl: "",
+		},
+		"zero max-age": {
+			cacheControl: "max-age=0",
+		},
+		"invalid max-age": {
+			cacheControl: "max-age=soon",
+		},
+		"no-store": {
+			cacheControl: "no-store, max-age=60",
+		},
+		"no-cache": {
+			cacheControl: "max-age=60, no-cache",
+		},
+	}
+
+	for scenario, tc := range testCases {
+		t.Run(scenario, func(t *testing.T) {
+			maxAge, ok := MaxAge(tc.cacheControl)
+			assert.Equal(t, tc.expectedOk, ok)
+			assert.Equal(t, tc.expectedMaxAge, maxAge)
+		})
+	}
+}
@@ -0,0 +1,107 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"github.com/gkatanacio/multisource-downloader/download"
+)
+
+var (
+	benchOpts        download.Options
+	benchSrcFlags    sourceFlags
+	benchUrlFile     string
+	benchSampleBytes int64
+	benchJSON        bool
+)
+
+// benchListing represents a row of the bench output.
+type benchListing struct {
+	download.BenchmarkResult
+	Error string `json:"error,omitempty"`
+}
+
+var benchCmd = &cobra.Command{
+	Use:          "bench [space-delimited URLs]",
+	Short:        "Measure the throughput of each of the sources without saving the file.",
+	Example:      "./msdl bench --sample-bytes 16777216 http://source1.com/a.txt http://source2.com/a.txt",
+	SilenceUsage: true,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if len(benchUrlFile) > 0 {
+			return nil // URLs can come from the file alone
+		}
+		return cobra.MinimumNArgs(1)(cmd, args)
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(benchUrlFile) > 0 {
+			fileUrls, err := readUrlFile(benchUrlFile)
+			if err != nil {
+				return err
+			}
+			args = append(args, fileUrls...)
+		}
+
+		if err := benchSrcFlags.apply(&benchOpts); err != nil {
+			return err
+		}
+		calculateETag, err := benchSrcFlags.eTagCalculator()
+		if err != nil {
+			return err
+		}
+
+		downloadService := download.NewService(benchOpts, calculateETag)
+		results, err := downloadService.Benchmark(cmd.Context(), args, benchSampleBytes)
+		if err != nil {
+			return err
+		}
+
+		var healthy bool
+		listings := make([]benchListing, 0, len(results))
+		for _, result := range results {
+			listing := benchListing{BenchmarkResult: result}
+			if result.Err == nil {
+				healthy = true
+			} else {
+				listing.Error = result.Err.Error()
+			}
+			listings = append(listings, listing)
+		}
+
+		if benchJSON {
+			enc := json.NewEncoder(cmd.OutOrStdout())
+			enc.SetIndent("", "  ")
+			if err := enc.Encode(listings); err != nil {
+				return err
+			}
+		} else {
+			tw := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+			fmt.Fprintln(tw, "URL\tTHROUGHPUT\tP95-LATENCY\tERROR-RATE\tERROR")
+			for _, l := range listings {
+				fmt.Fprintf(tw, "%s\t%.2fMiB/s\t%.1fms\t%.0f%%\t%s\n", l.URL, l.AvgBytesPerSec/(1<<20), l.P95LatencyMs, l.ErrorRate*100, l.Error)
+			}
+			if err := tw.Flush(); err != nil {
+				return err
+			}
+		}
+
+		if !healthy {
+			return errors.New("all sources are unhealthy")
+		}
+
+		return nil
+	},
+}
+
+func init() {
+	addConnectionFlags(benchCmd, &benchOpts)
+	addSourceFlags(benchCmd, &benchOpts, &benchSrcFlags)
+	benchCmd.Flags().StringVar(&benchUrlFile, "url-file", "", "file listing the source URLs (one per line)")
+	benchCmd.Flags().Int64Var(&benchSampleBytes, "sample-bytes", 8<<20, "number of bytes downloaded from each source")
+	benchCmd.Flags().BoolVar(&benchJSON, "json", false, "print the results as JSON")
+
+	rootCmd.AddCommand(benchCmd)
+}
@@ -0,0 +1,71 @@
+package cmd
+
+import (
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// sourceUrlSchemes are suggested when completing the source URLs.
+var sourceUrlSchemes = []string{"http://", "https://", "file://"}
+
+// hashAlgorithms are suggested when completing the flags taking a hash algorithm.
+var hashAlgorithms = []string{"md5", "sha256", "sha512"}
+
+var completionCmd = &cobra.Command{
+	Use:   "completion [bash|zsh|fish]",
+	Short: "Generate the shell completion script for bash, zsh or fish.",
+	Long: `Generate the shell completion script for bash, zsh or fish, which suggests the URL schemes
+of the sources and the values of the flags with a fixed set of values (e.g., --hash-file-algorithm).`,
+	Example:               "source <(./msdl completion bash)",
+	SilenceUsage:          true,
+	DisableFlagsInUseLine: true,
+	ValidArgs:             []string{"bash", "zsh", "fish"},
+	Args:                  cobra.MatchAll(cobra.ExactArgs(1), cobra.OnlyValidArgs),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		out := cmd.OutOrStdout()
+
+		switch args[0] {
+		case "zsh":
+			return cmd.Root().GenZshCompletion(out)
+		case "fish":
+			return cmd.Root().GenFishCompletion(out, true)
+		default:
+			return cmd.Root().GenBashCompletionV2(out, true)
+		}
+	},
+}
+
+// completeSourceUrls suggests the URL schemes of the sources until one has been typed.
+func completeSourceUrls(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if strings.Contains(toComplete, "://") {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	var schemes []string
+	for _, scheme := range sourceUrlSchemes {
+		if strings.HasPrefix(scheme, toComplete) {
+			schemes = append(schemes, scheme)
+		}
+	}
+
+	return schemes, cobra.ShellCompDirectiveNoSpace | cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeChecksum suggests the algorithm prefixes of --checksum (i.e., `algorithm:`).
+func completeChecksum(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	prefixes := make([]string, len(hashAlgorithms))
+	for i, algorithm := range hashAlgorithms {
+		prefixes[i] = algorithm + ":"
+	}
+
+	return prefixes, cobra.ShellCompDirectiveNoSpace | cobra.ShellCompDirectiveNoFileComp
+}
+
+func init() {
+	for _, cmd := range []*cobra.Command{rootCmd, resumeCmd, verifyCmd, listSourcesCmd, benchCmd} {
+		cmd.ValidArgsFunction = completeSourceUrls
+	}
+
+	rootCmd.AddCommand(completionCmd)
+}
@@ -0,0 +1,96 @@
+package cmd
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_GenBashCompletionV2(t *testing.T) {
+	var script bytes.Buffer
+	assert.NoError(t, rootCmd.GenBashCompletionV2(&script, true))
+
+	// the script delegates to the custom completion logic of the commands
+	assert.Contains(t, script.String(), "__msdl_get_completion_results")
+	assert.Contains(t, script.String(), "__complete")
+}
+
+func Test_Completion(t *testing.T) {
+	testCases := map[string]struct {
+		args     []string
+		expected []string
+		excluded []string
+	}{
+		"source URL schemes": {
+			args:     []string{"__complete", ""},
+			expected: []string{"http://", "https://", "file://"},
+		},
+		"source URL schemes by prefix": {
+			args:     []string{"__complete", "https"},
+			expected: []string{"https://"},
+			excluded: []string{"file://"},
+		},
+		"source URL schemes of subcommand": {
+			args:     []string{"__complete", "list-sources", ""},
+			expected: []string{"http://", "https://", "file://"},
+		},
+		"typed source URL": {
+			args:     []string{"__complete", "http://source1.com/"},
+			excluded: []string{"http://", "https://"},
+		},
+		"hash file algorithm": {
+			args:     []string{"__complete", "--hash-file-algorithm", ""},
+			expected: []string{"md5", "sha256", "sha512"},
+		},
+		"checksum algorithm": {
+			args:     []string{"__complete", "--checksum", ""},
+			expected: []string{"md5:", "sha256:", "sha512:"},
+		},
+		"shells": {
+			args:     []string{"__complete", "completion", ""},
+			expected: []string{"bash", "zsh", "fish"},
+		},
+	}
+
+	for scenario, tc := range testCases {
+		t.Run(scenario, func(t *testing.T) {
+			var out bytes.Buffer
+			rootCmd.SetOut(&out)
+			rootCmd.SetArgs(tc.args)
+			t.Cleanup(func() {
+				rootCmd.SetOut(nil)
+				rootCmd.SetArgs(nil)
+			})
+
+			assert.NoError(t, rootCmd.Execute())
+			for _, suggestion := range tc.expected {
+				assert.Contains(t, out.String(), suggestion+"\n")
+			}
+			for _, suggestion := range tc.excluded {
+				assert.NotContains(t, out.String(), suggestion+"\n")
+			}
+		})
+	}
+}
+
+func Test_CompletionCmd(t *testing.T) {
+	for shell, header := range map[string]string{
+		"bash": "# bash completion V2 for msdl",
+		"zsh":  "#compdef msdl",
+		"fish": "# fish completion for msdl",
+	} {
+		t.Run(shell, func(t *testing.T) {
+			var out bytes.Buffer
+			rootCmd.SetOut(&out)
+			rootCmd.SetArgs([]string{"completion", shell})
+			t.Cleanup(func() {
+				rootCmd.SetOut(nil)
+				rootCmd.SetArgs(nil)
+			})
+
+			assert.NoError(t, rootCmd.Execute())
+			assert.Contains(t, out.String(), header)
+		})
+	}
+}
@@ -0,0 +1,60 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/gkatanacio/multisource-downloader/download"
+)
+
+var configInitForce bool
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Manage the config file with the persistent settings.",
+}
+
+var configInitCmd = &cobra.Command{
+	Use:          "init",
+	Short:        "Write a config file with the default settings (to ~/.config/msdl/config.yaml unless --config is given).",
+	Example:      "./msdl config init",
+	SilenceUsage: true,
+	Args:         cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		path := configPath
+		if len(path) == 0 {
+			var err error
+			if path, err = download.DefaultConfigPath(); err != nil {
+				return err
+			}
+		}
+
+		if _, err := os.Stat(path); err == nil && !configInitForce {
+			return fmt.Errorf("%w: %s (use --force to overwrite it)", download.ErrFileAlreadyExists, path)
+		}
+
+		// same as the defaults of the flags
+		config := download.Config{
+			Options: download.Options{
+				Connections:       5,
+				Timeout:           10,
+				HashFileAlgorithm: "sha256",
+			},
+		}
+		if err := config.Save(path); err != nil {
+			return err
+		}
+
+		cmd.Println("Config written to", path)
+		return nil
+	},
+}
+
+func init() {
+	configInitCmd.Flags().BoolVar(&configInitForce, "force", false, "overwrite the config file if it already exists")
+
+	configCmd.AddCommand(configInitCmd)
+	rootCmd.AddCommand(configCmd)
+}
@@ -0,0 +1,147 @@
+package cmd
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"github.com/gkatanacio/multisource-downloader/cache"
+	"github.com/gkatanacio/multisource-downloader/download"
+)
+
+var (
+	listSourcesOpts     download.Options
+	listSourcesSrcFlags sourceFlags
+	listSourcesUrlFile  string
+	listSourcesJSON     bool
+	listSourcesCache    string
+)
+
+// sourceListing represents a row of the list-sources output.
+type sourceListing struct {
+	URL         string  `json:"url"`
+	Status      string  `json:"status"`
+	Size        int64   `json:"size"`
+	ContentType string  `json:"content_type"`
+	ETag        string  `json:"etag"`
+	LatencyMs   float64 `json:"latency_ms"`
+	Error       string  `json:"error,omitempty"`
+}
+
+var listSourcesCmd = &cobra.Command{
+	Use:          "list-sources [space-delimited URLs]",
+	Short:        "Inspect the file metadata reported by each of the sources.",
+	Example:      "./msdl list-sources --json http://source1.com/a.txt http://source2.com/a.txt",
+	SilenceUsage: true,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if len(listSourcesUrlFile) > 0 {
+			return nil // URLs can come from the file alone
+		}
+		return cobra.MinimumNArgs(1)(cmd, args)
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(listSourcesUrlFile) > 0 {
+			fileUrls, err := readUrlFile(listSourcesUrlFile)
+			if err != nil {
+				return err
+			}
+			args = append(args, fileUrls...)
+		}
+
+		if len(listSourcesCache) > 0 {
+			listSourcesOpts.Cache = cache.NewDiskCache(listSourcesCache)
+		}
+
+		if err := listSourcesSrcFlags.apply(&listSourcesOpts); err != nil {
+			return err
+		}
+		calculateETag, err := listSourcesSrcFlags.eTagCalculator()
+		if err != nil {
+			return err
+		}
+
+		downloadService := download.NewService(listSourcesOpts, calculateETag)
+		infos := downloadService.ValidateSources(cmd.Context(), args)
+
+		var healthy bool
+		listings := make([]sourceListing, 0, len(infos))
+		for _, info := range infos {
+			listing := sourceListing{
+				URL:         info.URL,
+				Status:      "ok",
+				Size:        info.Size,
+				ContentType: info.ContentType,
+				ETag:        info.ETag,
+				LatencyMs:   float64(info.Latency.Microseconds()) / 1000,
+			}
+			if info.Healthy() {
+				healthy = true
+			} else {
+				listing.Status = "error"
+				listing.Error = info.Err.Error()
+			}
+			listings = append(listings, listing)
+		}
+
+		if listSourcesJSON {
+			enc := json.NewEncoder(cmd.OutOrStdout())
+			enc.SetIndent("", "  ")
+			if err := enc.Encode(listings); err != nil {
+				return err
+			}
+		} else {
+			tw := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+			fmt.Fprintln(tw, "URL\tSTATUS\tSIZE\tCONTENT-TYPE\tETAG\tLATENCY\tERROR")
+			for _, l := range listings {
+				fmt.Fprintf(tw, "%s\t%s\t%d\t%s\t%s\t%.1fms\t%s\n", l.URL, l.Status, l.Size, l.ContentType, l.ETag, l.LatencyMs, l.Error)
+			}
+			if err := tw.Flush(); err != nil {
+				return err
+			}
+		}
+
+		if !healthy {
+			return errors.New("all sources are unhealthy")
+		}
+
+		return nil
+	},
+}
+
+// readUrlFile returns the URLs listed in the given file (one per line). Blank lines and
+// lines starting with `#` are ignored.
+func readUrlFile(filePath string) ([]string, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var urls []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+		urls = append(urls, line)
+	}
+
+	return urls, scanner.Err()
+}
+
+func init() {
+	addConnectionFlags(listSourcesCmd, &listSourcesOpts)
+	addSourceFlags(listSourcesCmd, &listSourcesOpts, &listSourcesSrcFlags)
+	listSourcesCmd.Flags().StringVar(&listSourcesUrlFile, "url-file", "", "file listing the source URLs (one per line)")
+	listSourcesCmd.Flags().StringVar(&listSourcesCache, "cache-dir", "", "directory for caching responses within their Cache-Control max-age")
+	listSourcesCmd.Flags().BoolVar(&listSourcesJSON, "json", false, "print the results as JSON")
+
+	rootCmd.AddCommand(listSourcesCmd)
+}
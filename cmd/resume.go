@@ -0,0 +1,48 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+
+	"github.com/gkatanacio/multisource-downloader/download"
+)
+
+var (
+	resumeOpts     download.Options
+	resumeSrcFlags sourceFlags
+)
+
+var resumeCmd = &cobra.Command{
+	Use:          "resume [space-delimited URLs]",
+	Short:        "Resume an interrupted download using its .download and .download.state files.",
+	Example:      "./msdl resume -f destfile.txt http://source1.com/a.txt http://source2.com/a.txt",
+	SilenceUsage: true,
+	Args: func(cmd *cobra.Command, args []string) error {
+		return cobra.MinimumNArgs(1)(cmd, args)
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		resumeOpts.Resume = true
+
+		if err := resumeSrcFlags.apply(&resumeOpts); err != nil {
+			return err
+		}
+		calculateETag, err := resumeSrcFlags.eTagCalculator()
+		if err != nil {
+			return err
+		}
+
+		downloadService := download.NewService(resumeOpts, calculateETag)
+		return downloadService.Download(args)
+	},
+}
+
+func init() {
+	addConnectionFlags(resumeCmd, &resumeOpts)
+	addSourceFlags(resumeCmd, &resumeOpts, &resumeSrcFlags)
+	resumeCmd.Flags().BoolVar(&resumeOpts.CheckETag, "etag", false, "check ETag match (using the --etag-algorithm hash of downloaded file) if available")
+	resumeCmd.Flags().BoolVar(&resumeOpts.AllowPartialSources, "allow-partial-sources", false, "proceed with the healthy sources instead of failing if any of the sources is unhealthy")
+	resumeCmd.Flags().StringVarP(&resumeOpts.DestFilePath, "file", "f", "", "destination file path of the interrupted download")
+
+	resumeCmd.MarkFlagRequired("file")
+
+	rootCmd.AddCommand(resumeCmd)
+}
@@ -1,14 +1,45 @@
 package cmd
 
 import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
 	"os"
+	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 
+	"github.com/gkatanacio/multisource-downloader/auth"
+	"github.com/gkatanacio/multisource-downloader/cache"
 	"github.com/gkatanacio/multisource-downloader/download"
+	"github.com/gkatanacio/multisource-downloader/github"
+	"github.com/gkatanacio/multisource-downloader/mirrordisc"
+	"github.com/gkatanacio/multisource-downloader/mock"
+	"github.com/gkatanacio/multisource-downloader/torrent"
 )
 
-var downloadOpts download.Options
+var (
+	downloadOpts download.Options
+	outputDir    string
+	srcFlags     sourceFlags
+	urlTemplates []string
+	templateVars []string
+	mirrorDNS    string
+	cacheDir     string
+	checksum     string
+	recordDir    string
+	replayDir    string
+	torrentPath  string
+	maxSize      string
+	ghRelease    string
+	ghToken      string
+
+	configPath    string
+	configProfile string
+)
 
 var rootCmd = &cobra.Command{
 	Use:          "msdl [space-delimited URLs]",
@@ -16,14 +47,157 @@ var rootCmd = &cobra.Command{
 	Example:      "./msdl -c 8 -t 10 --etag -f destfile.txt http://source1.com/a.txt http://source2.com/a.txt http://source3.com/a.txt",
 	SilenceUsage: true,
 	Args: func(cmd *cobra.Command, args []string) error {
+		if len(urlTemplates) > 0 || len(mirrorDNS) > 0 || len(downloadOpts.SourceAPIURL) > 0 || len(ghRelease) > 0 {
+			return nil // URLs can come from the templates, mirror discovery, the source API or the GitHub release alone
+		}
 		return cobra.MinimumNArgs(1)(cmd, args)
 	},
 	RunE: func(cmd *cobra.Command, args []string) error {
-		downloadService := download.NewService(downloadOpts, download.GetMD5Hash)
-		return downloadService.Download(args)
+		templateUrls, err := expandUrlTemplates(urlTemplates, templateVars)
+		if err != nil {
+			return err
+		}
+		args = append(args, templateUrls...)
+
+		if len(mirrorDNS) > 0 {
+			mirrors, err := mirrordisc.LookupMirrors(cmd.Context(), mirrorDNS)
+			if err != nil {
+				return err
+			}
+			args = append(mirrors, args...)
+		}
+
+		if len(ghRelease) > 0 {
+			owner, repo, tag, assetName, err := parseGithubRelease(ghRelease)
+			if err != nil {
+				return err
+			}
+			assetURL, err := github.ResolveAssetURL(cmd.Context(), owner, repo, tag, assetName, ghToken)
+			if err != nil {
+				return fmt.Errorf("failed to resolve --github-release: %w", err)
+			}
+			args = append([]string{assetURL}, args...)
+		}
+
+		if len(args) == 0 && len(downloadOpts.SourceAPIURL) == 0 {
+			return download.ErrNoSourceUrls
+		}
+
+		if len(outputDir) > 0 {
+			if len(args) == 0 {
+				return errors.New("--output-dir requires a source URL to derive the file name from (use --file with --source-api-url alone)")
+			}
+			destFilePath, err := download.DestFilePathFromURL(outputDir, args[0])
+			if err != nil {
+				return err
+			}
+			downloadOpts.DestFilePath = destFilePath
+		}
+		if len(downloadOpts.DestFilePath) == 0 {
+			// not marked as required flags since the config (e.g., MSDL_DEST_FILE with --config env) can set it
+			return errors.New("--file or --output-dir is required unless the config sets the destination file")
+		}
+
+		if cmd.Flags().Changed("connections") {
+			downloadOpts.AutoConnections = false // explicit value takes precedence
+		}
+		downloadOpts.ResolveAutoConnections(len(args))
+
+		if downloadOpts.IfNoneMatch == ifNoneMatchFromHashFile {
+			// the ETags of the sources are MD5 hashes regardless of --hash-file-algorithm
+			eTag, err := download.ReadHashFile(downloadOpts.DestFilePath, eTagHashFileAlgorithm)
+			if err != nil {
+				return fmt.Errorf("failed to read ETag for --if-none-match (written with --write-hash-file --hash-file-algorithm %s): %w", eTagHashFileAlgorithm, err)
+			}
+			downloadOpts.IfNoneMatch = eTag
+		}
+
+		if len(maxSize) > 0 {
+			if downloadOpts.MaxFileSize, err = download.ParseByteSize(maxSize); err != nil {
+				return fmt.Errorf("invalid --max-size: %w", err)
+			}
+		}
+
+		if err := srcFlags.apply(&downloadOpts); err != nil {
+			return err
+		}
+		calculateETag, err := srcFlags.eTagCalculator()
+		if err != nil {
+			return err
+		}
+
+		if len(torrentPath) > 0 {
+			pieceLength, pieceHashes, err := torrent.ParseTorrentHashes(torrentPath)
+			if err != nil {
+				return fmt.Errorf("failed to read piece hashes for --torrent: %w", err)
+			}
+
+			downloadOpts.PieceSize = pieceLength
+			downloadOpts.PieceHashes = make([]string, len(pieceHashes))
+			for i, h := range pieceHashes {
+				downloadOpts.PieceHashes[i] = fmt.Sprintf("%x", h)
+			}
+			downloadOpts.PieceHashAlgorithm = "sha1"
+		}
+
+		if len(downloadOpts.SigstoreBundleURL) > 0 {
+			downloadOpts.SigstoreVerify = true
+		}
+
+		// the recording wraps the transport configured by the flags (e.g., --timeout-connect and --aws-region)
+		if len(recordDir) > 0 {
+			wrap := downloadOpts.WrapTransport
+			downloadOpts.WrapTransport = func(base http.RoundTripper) http.RoundTripper {
+				if wrap != nil {
+					base = wrap(base)
+				}
+				return mock.NewRecorder(recordDir, base)
+			}
+		} else if len(replayDir) > 0 {
+			downloadOpts.RoundTripper = mock.NewReplayer(replayDir)
+			clearImplicitGranularTimeouts(cmd, &downloadOpts)
+		}
+
+		var checksumAlgorithm, checksumHex string
+		if len(checksum) > 0 {
+			// fail early rather than after the whole file has been downloaded
+			if checksumAlgorithm, checksumHex, err = download.ParseChecksum(checksum); err != nil {
+				return err
+			}
+		}
+
+		if len(cacheDir) > 0 {
+			downloadOpts.Cache = cache.NewDiskCache(cacheDir)
+		}
+
+		downloadService := download.NewService(downloadOpts, calculateETag)
+		err = downloadService.Download(args)
+		if errors.Is(err, download.ErrNotModified) {
+			cmd.Println("File not modified:", downloadOpts.DestFilePath)
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if len(checksum) > 0 {
+			return download.VerifyFileChecksum(downloadOpts.DestFilePath, checksumAlgorithm, checksumHex)
+		}
+
+		return nil
 	},
 }
 
+// ifNoneMatchFromHashFile is the value of --if-none-match when specified without a value, in which case
+// the ETag is read from the MD5 hash file written by a previous run with --write-hash-file.
+const ifNoneMatchFromHashFile = "hash-file"
+
+// eTagHashFileAlgorithm is the algorithm of the hash file which --if-none-match reads the ETag from.
+const eTagHashFileAlgorithm = "md5"
+
+// configFromEnv is the value of --config for taking the options from the environment variables instead of a file.
+const configFromEnv = "env"
+
 func Execute() {
 	err := rootCmd.Execute()
 	if err != nil {
@@ -31,12 +205,295 @@ func Execute() {
 	}
 }
 
+// expandUrlTemplates expands each of the URL templates using the given `key=value` variables.
+// If a variable is specified more than once, the last value is used.
+func expandUrlTemplates(templates, keyValues []string) ([]string, error) {
+	vars := make(map[string]string)
+	for _, kv := range keyValues {
+		key, value, ok := strings.Cut(kv, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid template variable %q (expected key=value)", kv)
+		}
+		vars[key] = value
+	}
+
+	var urls []string
+	for _, template := range templates {
+		url, err := download.TemplateExpand(template, vars)
+		if err != nil {
+			return nil, err
+		}
+		urls = append(urls, url)
+	}
+
+	return urls, nil
+}
+
+// parseSourceHeaders parses the `url:Key:Value` headers into headers keyed by source URL. Since the
+// URL itself contains colons, the key and value are those after the last two colons. If a header is
+// specified more than once for the same URL, the last value is used.
+func parseSourceHeaders(urlKeyValues []string) (map[string]map[string]string, error) {
+	headers := make(map[string]map[string]string)
+	for _, ukv := range urlKeyValues {
+		urlKey, value, _ := cutLast(ukv, ":")
+		url, key, ok := cutLast(urlKey, ":")
+		if !ok || len(url) == 0 || len(key) == 0 {
+			return nil, fmt.Errorf("invalid source header %q (expected url:Key:Value)", ukv)
+		}
+
+		if headers[url] == nil {
+			headers[url] = make(map[string]string)
+		}
+		headers[url][key] = value
+	}
+
+	return headers, nil
+}
+
+// parseGithubRelease parses the `owner/repo@tag/asset` release asset. Since tags may contain slashes
+// whereas asset names cannot, the asset name is the part after the last slash.
+func parseGithubRelease(spec string) (owner, repo, tag, assetName string, err error) {
+	ownerRepo, tagAsset, _ := strings.Cut(spec, "@")
+	owner, repo, _ = strings.Cut(ownerRepo, "/")
+	tag, assetName, _ = cutLast(tagAsset, "/")
+	if len(owner) == 0 || len(repo) == 0 || len(tag) == 0 || len(assetName) == 0 || strings.Contains(repo, "/") {
+		return "", "", "", "", fmt.Errorf("invalid GitHub release %q (expected owner/repo@tag/asset)", spec)
+	}
+
+	return owner, repo, tag, assetName, nil
+}
+
+// cutLast is the same as strings.Cut but around the last instance of sep.
+func cutLast(s, sep string) (before, after string, found bool) {
+	if i := strings.LastIndex(s, sep); i >= 0 {
+		return s[:i], s[i+len(sep):], true
+	}
+	return s, "", false
+}
+
+// addConnectionFlags registers the connection-related flags shared across commands.
+func addConnectionFlags(cmd *cobra.Command, opts *download.Options) {
+	cmd.Flags().UintVarP(&opts.Connections, "connections", "c", 5, "max number of concurrent connections")
+	cmd.Flags().UintVarP(&opts.Timeout, "timeout", "t", 10, "timeout for each connection in seconds")
+	cmd.Flags().DurationVar(&opts.DialTimeout, "timeout-connect", 0, "timeout for establishing TCP connections (defaults to --timeout)")
+	cmd.Flags().DurationVar(&opts.TLSHandshakeTimeout, "timeout-tls", 0, "timeout for TLS handshakes (defaults to --timeout)")
+	cmd.Flags().DurationVar(&opts.ResponseHeaderTimeout, "timeout-response", 0, "timeout for receiving the response headers (defaults to --timeout)")
+	cmd.Flags().BoolVarP(&opts.Quiet, "quiet", "q", false, "disable logging to stdout")
+	cmd.Flags().BoolVarP(&opts.Verbose, "verbose", "v", false, "log HTTP request and response headers to stderr (ignored in quiet mode)")
+	cmd.Flags().BoolVar(&opts.LooseContentTypeMatch, "loose-content-type", false, "only match the media types of the Content-Type from the sources (ignoring charset and other parameters) instead of requiring identical ones")
+
+	cmd.PreRunE = func(cmd *cobra.Command, args []string) error {
+		if err := applyConfig(cmd, opts); err != nil {
+			return err
+		}
+		resolveGranularTimeouts(cmd, opts)
+		return nil
+	}
+}
+
+// sourceFlags represents the flags for accessing the sources (e.g., credentials) which are shared across
+// the commands making requests to the sources.
+type sourceFlags struct {
+	headers       []string
+	aws           awsOptions
+	eTagAlgorithm string
+}
+
+// awsOptions represents the credentials used for signing requests with AWS Signature Version 4.
+type awsOptions struct {
+	region          string
+	accessKeyId     string
+	secretAccessKey string
+}
+
+// addSourceFlags registers the flags for accessing the sources shared across commands.
+func addSourceFlags(cmd *cobra.Command, opts *download.Options, sf *sourceFlags) {
+	cmd.Flags().StringVar(&opts.Proxy, "proxy", "", "URL of the HTTP(S) or SOCKS5 proxy for the requests to the sources (defaults to the HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables)")
+	cmd.Flags().StringVar(&opts.UserAgent, "user-agent", "", "User-Agent header of the requests to the sources")
+	cmd.Flags().StringArrayVar(&sf.headers, "source-header", nil, "url:Key:Value header sent to the source with the given URL only (repeatable)")
+	cmd.Flags().StringVar(&sf.eTagAlgorithm, "etag-algorithm", "md5", "hash algorithm of the ETags of the sources (md5, sha256 or sha512)")
+	cmd.Flags().StringVar(&sf.aws.region, "aws-region", "", "AWS region for signing S3 requests with Signature Version 4")
+	cmd.Flags().StringVar(&sf.aws.accessKeyId, "aws-access-key-id", "", "AWS access key ID for signing S3 requests")
+	cmd.Flags().StringVar(&sf.aws.secretAccessKey, "aws-secret-access-key", "", "AWS secret access key for signing S3 requests")
+
+	cmd.RegisterFlagCompletionFunc("etag-algorithm", cobra.FixedCompletions(hashAlgorithms, cobra.ShellCompDirectiveNoFileComp))
+	cmd.MarkFlagsRequiredTogether("aws-region", "aws-access-key-id", "aws-secret-access-key")
+}
+
+// apply sets the per-source headers and the request signing given by the flags on the options. The
+// signing wraps the transport configured by the options (e.g., with --proxy) rather than replacing it.
+func (sf *sourceFlags) apply(opts *download.Options) error {
+	if len(sf.headers) > 0 {
+		headers, err := parseSourceHeaders(sf.headers)
+		if err != nil {
+			return err
+		}
+		opts.PerSourceHeaders = headers
+	}
+
+	if len(sf.aws.region) > 0 {
+		aws := sf.aws
+		opts.WrapTransport = func(base http.RoundTripper) http.RoundTripper {
+			return auth.NewSigV4RoundTripper(aws.region, "s3", aws.accessKeyId, aws.secretAccessKey, base)
+		}
+	}
+
+	return nil
+}
+
+// eTagCalculator returns the calculator for the ETags of the sources given by --etag-algorithm.
+func (sf *sourceFlags) eTagCalculator() (download.ETagCalculator, error) {
+	calculateETag, err := download.HashCalculatorFor(sf.eTagAlgorithm)
+	if err != nil {
+		return nil, fmt.Errorf("invalid --etag-algorithm: %w", err)
+	}
+
+	return calculateETag, nil
+}
+
+// applyConfig applies the config file given by --config (or the default one if existing) and the
+// profile given by --profile to the options. With `--config env`, the options are instead taken from
+// the `MSDL_*` environment variables (see download.OptionsFromEnv). The flags which are explicitly
+// given take precedence.
+func applyConfig(cmd *cobra.Command, opts *download.Options) error {
+	var config download.Config
+	if configPath == configFromEnv {
+		envOpts, err := download.OptionsFromEnv()
+		if err != nil {
+			return err
+		}
+		config.Options = envOpts
+	} else {
+		path := configPath
+		if len(path) == 0 {
+			defaultPath, err := download.DefaultConfigPath()
+			if err != nil {
+				return err
+			}
+			if _, err := os.Stat(defaultPath); errors.Is(err, fs.ErrNotExist) && len(configProfile) == 0 {
+				return nil // the default config file is optional
+			}
+			path = defaultPath
+		}
+
+		if err := config.Load(path); err != nil {
+			return err
+		}
+	}
+
+	// remember the values of the explicitly given flags so that they can be reapplied over the config
+	changed := make(map[*pflag.Flag][]string)
+	cmd.Flags().Visit(func(f *pflag.Flag) {
+		if sv, ok := f.Value.(pflag.SliceValue); ok {
+			changed[f] = sv.GetSlice()
+		} else {
+			changed[f] = []string{f.Value.String()}
+		}
+	})
+
+	resolved, err := config.Resolve(*opts, configProfile)
+	if err != nil {
+		return err
+	}
+	*opts = resolved
+
+	for f, values := range changed {
+		if sv, ok := f.Value.(pflag.SliceValue); ok {
+			err = sv.Replace(values)
+		} else {
+			err = f.Value.Set(values[0])
+		}
+		if err != nil {
+			return fmt.Errorf("failed to reapply --%s over the config: %w", f.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// resolveGranularTimeouts sets the granular timeouts which are neither explicitly given nor set by
+// the config to the value of --timeout so that the latter keeps covering each phase of the requests
+// on its own.
+func resolveGranularTimeouts(cmd *cobra.Command, opts *download.Options) {
+	timeout := time.Duration(opts.Timeout) * time.Second
+
+	for name, value := range granularTimeouts(opts) {
+		if !cmd.Flags().Changed(name) && *value == 0 {
+			*value = timeout
+		}
+	}
+}
+
+// clearImplicitGranularTimeouts resets the granular timeouts which were not explicitly given, e.g., for
+// a custom RoundTripper to which they do not apply so that only the explicit ones are warned about.
+func clearImplicitGranularTimeouts(cmd *cobra.Command, opts *download.Options) {
+	for name, value := range granularTimeouts(opts) {
+		if !cmd.Flags().Changed(name) {
+			*value = 0
+		}
+	}
+}
+
+// granularTimeouts returns the granular timeouts of the options keyed by the names of their flags.
+func granularTimeouts(opts *download.Options) map[string]*time.Duration {
+	return map[string]*time.Duration{
+		"timeout-connect":  &opts.DialTimeout,
+		"timeout-tls":      &opts.TLSHandshakeTimeout,
+		"timeout-response": &opts.ResponseHeaderTimeout,
+	}
+}
+
 func init() {
-	rootCmd.Flags().UintVarP(&downloadOpts.Connections, "connections", "c", 5, "max number of concurrent connections")
-	rootCmd.Flags().UintVarP(&downloadOpts.Timeout, "timeout", "t", 10, "timeout for each connection in seconds")
-	rootCmd.Flags().BoolVar(&downloadOpts.CheckETag, "etag", false, "check ETag match (using MD5 hash of downloaded file) if available")
-	rootCmd.Flags().BoolVarP(&downloadOpts.Quiet, "quiet", "q", false, "disable logging to stdout")
+	rootCmd.PersistentFlags().StringVar(&configPath, "config", "", "path of the YAML config file (defaults to ~/.config/msdl/config.yaml if existing) or \"env\" for the MSDL_* environment variables")
+	rootCmd.PersistentFlags().StringVar(&configProfile, "profile", "", "name of the config profile overriding the base config")
+
+	addConnectionFlags(rootCmd, &downloadOpts)
+	addSourceFlags(rootCmd, &downloadOpts, &srcFlags)
+	rootCmd.Flags().BoolVarP(&downloadOpts.AutoConnections, "connections-auto", "C", false, "set max number of concurrent connections based on the number of URLs (ignored if --connections is set)")
+	rootCmd.Flags().UintVar(&downloadOpts.ConnectionsMultiplier, "connections-multiplier", 2, "number of connections per URL for --connections-auto")
+	rootCmd.Flags().BoolVar(&downloadOpts.CheckETag, "etag", false, "check ETag match (using the --etag-algorithm hash of downloaded file) if available")
+	rootCmd.Flags().BoolVar(&downloadOpts.AllowPartialSources, "allow-partial-sources", false, "proceed with the healthy sources instead of failing if any of the sources is unhealthy")
+	rootCmd.Flags().BoolVar(&downloadOpts.WriteHashFile, "write-hash-file", false, "write the hash of the downloaded file to a sidecar file (e.g., destfile.txt.sha256)")
+	rootCmd.Flags().StringVar(&downloadOpts.HashFileAlgorithm, "hash-file-algorithm", "sha256", "hash algorithm for --write-hash-file (md5, sha256 or sha512)")
+	rootCmd.Flags().StringVar(&downloadOpts.IfNoneMatch, "if-none-match", "", "skip the download if the ETag of the file is unchanged (read from the --write-hash-file output with --hash-file-algorithm md5 if no value is given)")
+	rootCmd.Flags().Lookup("if-none-match").NoOptDefVal = ifNoneMatchFromHashFile
+	rootCmd.Flags().BoolVar(&downloadOpts.SkipIfUnmodified, "skip-if-unmodified", false, "skip the download if the destination file exists and the source reports no modification since (via If-Modified-Since)")
+	rootCmd.Flags().BoolVarP(&downloadOpts.NoClobber, "no-clobber", "n", false, "fail instead of overwriting an existing destination file")
+	rootCmd.Flags().StringVar(&downloadOpts.ManifestPath, "manifest", "", "path of the JSON manifest recording the provenance of the download")
+	rootCmd.Flags().Int64Var(&downloadOpts.ChunkBytes, "chunk-bytes", 0, "size of each chunk in bytes (0 means the file size divided by --connections)")
+	rootCmd.Flags().BoolVar(&downloadOpts.BenchmarkBeforeDownload, "benchmark", false, "download a sample from each source first and rank the sources by throughput instead of latency")
+	rootCmd.Flags().BoolVar(&downloadOpts.UseFlock, "flock", false, "lock destfile.lock while downloading so that concurrent downloads of the same file do not race")
+	rootCmd.Flags().DurationVar(&downloadOpts.LockTimeout, "lock-timeout", 0, "how long to wait for the lock of --flock before failing (e.g., 30s)")
+	rootCmd.Flags().Int64Var(&downloadOpts.MaxMemoryUsageBytes, "max-memory", 0, "max bytes of memory used by the in-flight chunks (reduces the chunk size or the connections if needed)")
+	rootCmd.Flags().StringVar(&maxSize, "max-size", "", "max size of the file (e.g., 500MB or 2GiB) beyond which the download is rejected")
+	rootCmd.Flags().Int64Var(&downloadOpts.MinChunkBytes, "min-chunk-bytes", 0, "file size in bytes below which the file is downloaded with a single request instead of chunks")
+	rootCmd.Flags().BoolVar(&downloadOpts.AcceptFullResponseFallback, "accept-full-response", false, "extract the chunks from the whole file sent by sources ignoring the Range header instead of failing")
+	rootCmd.Flags().DurationVar(&downloadOpts.ChunkStallTimeout, "chunk-stall-timeout", 0, "retry a chunk from another source if none of its bytes are received for this long (e.g., 30s)")
+	rootCmd.Flags().BoolVar(&downloadOpts.PreallocateFile, "preallocate", false, "preallocate the whole file size before downloading to reduce fragmentation")
+	rootCmd.Flags().BoolVar(&downloadOpts.AppendMode, "append", false, "append to the destination file instead of overwriting it")
 	rootCmd.Flags().StringVarP(&downloadOpts.DestFilePath, "file", "f", "", "destination file path")
+	rootCmd.Flags().StringVarP(&outputDir, "output-dir", "d", "", "destination directory (file name is derived from the first URL)")
+
+	rootCmd.Flags().StringArrayVar(&urlTemplates, "url-template", nil, "source URL template with {KEY} placeholders (repeatable)")
+	rootCmd.Flags().StringArrayVar(&templateVars, "template-var", nil, "KEY=value variable for --url-template (repeatable)")
+	rootCmd.Flags().StringVar(&downloadOpts.ValidateContentType, "validate-content-type", "", "expected content type of the downloaded file (e.g., application/gzip) as detected from its first bytes")
+	rootCmd.Flags().StringVar(&checksum, "checksum", "", "expected hash of the downloaded file in the algorithm:hexdigest format (e.g., sha256:abc123...)")
+	rootCmd.Flags().StringVar(&downloadOpts.SigstoreBundleURL, "sigstore-bundle", "", "URL or path of the cosign bundle to verify the downloaded file and its transparency log entry against")
+	rootCmd.Flags().StringVar(&downloadOpts.SigstorePublicKeyPath, "sigstore-key", "", "path of the cosign public key for --sigstore-bundle")
+	rootCmd.Flags().StringVar(&torrentPath, "torrent", "", "path of a single-file .torrent whose SHA-1 piece hashes the downloaded pieces are verified against")
+	rootCmd.Flags().StringVar(&cacheDir, "cache-dir", "", "directory for caching responses within their Cache-Control max-age")
+	rootCmd.Flags().StringVar(&downloadOpts.SourceAPIURL, "source-api-url", "", "URL of a JSON API listing the source URLs (i.e., {\"urls\": [...]}) to prepend to the given ones")
+	rootCmd.Flags().StringVar(&ghRelease, "github-release", "", "GitHub release asset as owner/repo@tag/asset (tag may be latest) whose download URL is prepended to the source URLs")
+	rootCmd.Flags().StringVar(&ghToken, "github-token", "", "GitHub token for resolving --github-release via the GitHub API")
+	rootCmd.Flags().StringVar(&mirrorDNS, "mirror-dns", "", "domain whose TXT records list mirror URLs to use as sources (e.g., _mirrors.example.com)")
+	rootCmd.Flags().BoolVar(&downloadOpts.AutoDiscoverMirrors, "mirror-metalink", false, "add the mirrors listed in metalink documents advertised by the sources via Link headers")
+	rootCmd.Flags().UintVar(&downloadOpts.MaxDiscoveredMirrors, "mirror-metalink-max", 10, "max number of mirrors added by --mirror-metalink")
+	rootCmd.Flags().StringVar(&recordDir, "record-dir", "", "directory to record the HTTP requests and responses to as JSON fixtures")
+	rootCmd.Flags().StringVar(&replayDir, "replay-dir", "", "directory of JSON fixtures (from --record-dir) to replay instead of making HTTP requests")
+
+	rootCmd.RegisterFlagCompletionFunc("hash-file-algorithm", cobra.FixedCompletions(hashAlgorithms, cobra.ShellCompDirectiveNoFileComp))
+	rootCmd.RegisterFlagCompletionFunc("checksum", completeChecksum)
 
-	rootCmd.MarkFlagRequired("file")
+	rootCmd.MarkFlagsMutuallyExclusive("file", "output-dir")
+	rootCmd.MarkFlagsMutuallyExclusive("record-dir", "replay-dir")
 }
@@ -0,0 +1,282 @@
+package cmd
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/spf13/pflag"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/gkatanacio/multisource-downloader/download"
+)
+
+// executeRootCmd runs the root command (or the subcommand given by the args) and returns its output. The
+// flags of all commands are reset to their defaults afterwards since their values are kept in package variables across executions.
+func executeRootCmd(t *testing.T, args ...string) (string, error) {
+	t.Helper()
+
+	var out bytes.Buffer
+	rootCmd.SetOut(&out)
+	rootCmd.SetArgs(args)
+	defer func() {
+		rootCmd.SetOut(nil)
+		rootCmd.SetArgs(nil)
+		// the options set from the flags by RunE rather than bound to them
+		for _, opts := range []*download.Options{&downloadOpts, &resumeOpts, &verifyOpts, &listSourcesOpts, &benchOpts} {
+			opts.PerSourceHeaders = nil
+			opts.WrapTransport = nil
+			opts.RoundTripper = nil
+		}
+		for _, cmd := range append(rootCmd.Commands(), rootCmd) {
+			cmd.Flags().VisitAll(func(f *pflag.Flag) {
+				if sv, ok := f.Value.(pflag.SliceValue); ok {
+					sv.Replace(nil)
+				} else {
+					f.Value.Set(f.DefValue)
+				}
+				f.Changed = false
+			})
+		}
+	}()
+
+	err := rootCmd.Execute()
+	return out.String(), err
+}
+
+func Test_RootCmd_IfNoneMatchFromHashFile(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir()) // no default config file
+	t.Setenv("HOME", t.TempDir())
+
+	content := bytes.Repeat([]byte("0123456789"), 100)
+	var getRequests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			getRequests.Add(1)
+		}
+		w.Header().Set("ETag", fmt.Sprintf(`"%x"`, md5.Sum(content)))
+		http.ServeContent(w, r, "digits.txt", time.Time{}, bytes.NewReader(content))
+	}))
+	t.Cleanup(srv.Close)
+
+	destFilePath := filepath.Join(t.TempDir(), "digits.txt")
+
+	_, err := executeRootCmd(t, "-q", "-f", destFilePath, "--write-hash-file", "--hash-file-algorithm", "md5", srv.URL+"/digits.txt")
+	assert.NoError(t, err)
+	assert.FileExists(t, destFilePath+".md5")
+	downloads := getRequests.Load()
+	assert.Positive(t, downloads)
+
+	// the ETag read from the hash file matches so the file is not downloaded again
+	out, err := executeRootCmd(t, "-q", "-f", destFilePath, "--if-none-match", srv.URL+"/digits.txt")
+	assert.NoError(t, err)
+	assert.Contains(t, out, "File not modified: "+destFilePath)
+	assert.Equal(t, downloads, getRequests.Load())
+
+	downloaded, err := os.ReadFile(destFilePath)
+	assert.NoError(t, err)
+	assert.Equal(t, content, downloaded)
+}
+
+func Test_VerifyCmd_SourceFlags(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir()) // no default config file
+	t.Setenv("HOME", t.TempDir())
+
+	content := bytes.Repeat([]byte("0123456789"), 100)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Token") != "secret" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		w.Header().Set("ETag", fmt.Sprintf(`"%x"`, sha256.Sum256(content)))
+		http.ServeContent(w, r, "digits.txt", time.Time{}, bytes.NewReader(content))
+	}))
+	t.Cleanup(srv.Close)
+
+	sourceURL := srv.URL + "/digits.txt"
+	filePath := filepath.Join(t.TempDir(), "digits.txt")
+	assert.NoError(t, os.WriteFile(filePath, content, 0o644))
+
+	testCases := map[string]struct {
+		args                []string
+		expectedErrContains string
+	}{
+		"source header and etag algorithm": {
+			args: []string{"--source-header", sourceURL + ":X-Token:secret", "--etag-algorithm", "sha256"},
+		},
+		"missing source header": {
+			args:                []string{"--etag-algorithm", "sha256"},
+			expectedErrContains: "received 403 response",
+		},
+		"default etag algorithm": {
+			args:                []string{"--source-header", sourceURL + ":X-Token:secret"},
+			expectedErrContains: "mismatch",
+		},
+		"invalid etag algorithm": {
+			args:                []string{"--etag-algorithm", "crc32"},
+			expectedErrContains: "invalid --etag-algorithm",
+		},
+	}
+
+	for scenario, tc := range testCases {
+		t.Run(scenario, func(t *testing.T) {
+
+			args := append([]string{"verify", "-q", "-f", filePath}, tc.args...)
+			_, err := executeRootCmd(t, append(args, sourceURL)...)
+			if len(tc.expectedErrContains) > 0 {
+				assert.ErrorContains(t, err, tc.expectedErrContains)
+				return
+			}
+
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func Test_ResumeCmd_SourceFlags(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir()) // no default config file
+	t.Setenv("HOME", t.TempDir())
+
+	content := bytes.Repeat([]byte("0123456789"), 100)
+	eTag := fmt.Sprintf("%x", sha256.Sum256(content))
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Token") != "secret" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		w.Header().Set("ETag", fmt.Sprintf(`"%s"`, eTag))
+		http.ServeContent(w, r, "digits.txt", time.Time{}, bytes.NewReader(content))
+	}))
+	t.Cleanup(srv.Close)
+
+	// the interrupted download completed the first of the two chunks
+	destFilePath := filepath.Join(t.TempDir(), "digits.txt")
+	partial := append(bytes.Clone(content[:500]), make([]byte, 500)...)
+	assert.NoError(t, os.WriteFile(destFilePath+".download", partial, 0o644))
+	state := fmt.Sprintf(`{"size": %d, "etag": %q, "chunk_size": 500, "completed_chunks": [0]}`, len(content), eTag)
+	assert.NoError(t, os.WriteFile(destFilePath+".download.state", []byte(state), 0o644))
+
+	sourceURL := srv.URL + "/digits.txt"
+	_, err := executeRootCmd(t, "resume", "-q", "-f", destFilePath, "--etag", "--etag-algorithm", "sha256", "--source-header", sourceURL+":X-Token:secret", sourceURL)
+	assert.NoError(t, err)
+
+	downloaded, err := os.ReadFile(destFilePath)
+	assert.NoError(t, err)
+	assert.Equal(t, content, downloaded)
+}
+
+func Test_ListSourcesCmd_SourceFlags(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir()) // no default config file
+	t.Setenv("HOME", t.TempDir())
+
+	content := bytes.Repeat([]byte("0123456789"), 100)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Token") != "secret" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		http.ServeContent(w, r, "digits.txt", time.Time{}, bytes.NewReader(content))
+	}))
+	t.Cleanup(srv.Close)
+
+	sourceURL := srv.URL + "/digits.txt"
+	out, err := executeRootCmd(t, "list-sources", "--json", "--source-header", sourceURL+":X-Token:secret", sourceURL)
+	assert.NoError(t, err)
+	assert.Contains(t, out, `"status": "ok"`)
+}
+
+func Test_BenchCmd_SourceFlags(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir()) // no default config file
+	t.Setenv("HOME", t.TempDir())
+
+	content := bytes.Repeat([]byte("0123456789"), 100)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Token") != "secret" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		http.ServeContent(w, r, "digits.txt", time.Time{}, bytes.NewReader(content))
+	}))
+	t.Cleanup(srv.Close)
+
+	sourceURL := srv.URL + "/digits.txt"
+	out, err := executeRootCmd(t, "bench", "--json", "--sample-bytes", "500", "--source-header", sourceURL+":X-Token:secret", sourceURL)
+	assert.NoError(t, err)
+	assert.NotContains(t, out, `"error"`)
+}
+
+// captureStdout returns what the given function prints to stdout (e.g., the logs of the service).
+func captureStdout(t *testing.T, f func()) string {
+	t.Helper()
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+
+	captured := make(chan []byte)
+	go func() {
+		b, _ := io.ReadAll(r)
+		captured <- b
+	}()
+
+	f()
+	w.Close()
+	return string(<-captured)
+}
+
+func Test_RootCmd_ReplayDir_GranularTimeouts(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir()) // no default config file
+	t.Setenv("HOME", t.TempDir())
+
+	content := bytes.Repeat([]byte("0123456789"), 100)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "digits.txt", time.Time{}, bytes.NewReader(content))
+	}))
+	t.Cleanup(srv.Close)
+
+	sourceURL := srv.URL + "/digits.txt"
+	fixturesDir := t.TempDir()
+	_, err := executeRootCmd(t, "-q", "-c", "1", "-f", filepath.Join(t.TempDir(), "digits.txt"), "--record-dir", fixturesDir, sourceURL)
+	assert.NoError(t, err)
+
+	const warning = "do not apply to a custom RoundTripper"
+
+	testCases := map[string]struct {
+		args            []string
+		expectedWarning bool
+	}{
+		"timeouts defaulting to --timeout": {},
+		"explicit timeout": {
+			args:            []string{"--timeout-connect", "2s"},
+			expectedWarning: true,
+		},
+	}
+
+	for scenario, tc := range testCases {
+		t.Run(scenario, func(t *testing.T) {
+			destFilePath := filepath.Join(t.TempDir(), "digits.txt")
+			out := captureStdout(t, func() {
+				args := append([]string{"-c", "1", "-f", destFilePath, "--replay-dir", fixturesDir}, tc.args...)
+				_, err := executeRootCmd(t, append(args, sourceURL)...)
+				assert.NoError(t, err)
+			})
+
+			assert.Equal(t, tc.expectedWarning, strings.Contains(out, warning))
+			assert.FileExists(t, destFilePath)
+		})
+	}
+}
@@ -0,0 +1,44 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+
+	"github.com/gkatanacio/multisource-downloader/download"
+)
+
+var (
+	verifyOpts     download.Options
+	verifySrcFlags sourceFlags
+)
+
+var verifyCmd = &cobra.Command{
+	Use:          "verify [space-delimited URLs]",
+	Short:        "Verify an already downloaded file against the ETag reported by the sources.",
+	Example:      "./msdl verify -f destfile.txt http://source1.com/a.txt http://source2.com/a.txt",
+	SilenceUsage: true,
+	Args: func(cmd *cobra.Command, args []string) error {
+		return cobra.MinimumNArgs(1)(cmd, args)
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := verifySrcFlags.apply(&verifyOpts); err != nil {
+			return err
+		}
+		calculateETag, err := verifySrcFlags.eTagCalculator()
+		if err != nil {
+			return err
+		}
+
+		downloadService := download.NewService(verifyOpts, calculateETag)
+		return downloadService.Verify(cmd.Context(), verifyOpts.DestFilePath, args)
+	},
+}
+
+func init() {
+	addConnectionFlags(verifyCmd, &verifyOpts)
+	addSourceFlags(verifyCmd, &verifyOpts, &verifySrcFlags)
+	verifyCmd.Flags().StringVarP(&verifyOpts.DestFilePath, "file", "f", "", "path of the file to verify")
+
+	verifyCmd.MarkFlagRequired("file")
+
+	rootCmd.AddCommand(verifyCmd)
+}
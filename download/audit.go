@@ -0,0 +1,80 @@
+package download
+
+import (
+	"encoding/json"
+	"os"
+	"os/user"
+	"sync"
+	"time"
+)
+
+// AuditRecord is the line appended to the audit log for each completed download.
+type AuditRecord struct {
+	Timestamp   time.Time `json:"timestamp"`
+	DestFile    string    `json:"dest_file"`
+	SourcesUsed []string  `json:"sources_used"`
+	Bytes       int64     `json:"bytes"`
+	DurationMs  int64     `json:"duration_ms"`
+	ETag        string    `json:"etag"`
+	Verified    bool      `json:"verified"`
+	ProcessUser string    `json:"process_user"`
+}
+
+// auditLogMu serializes the writes to the audit logs of all services in the process.
+var auditLogMu sync.Mutex
+
+// buildAuditRecord returns the audit record of a download of the given file metadata.
+// Only the sources which delivered chunks are considered as used.
+func buildAuditRecord(destFile string, fileMetadata fileMetadata, startedAt time.Time, stats *sourceStatsCollector, verified bool) *AuditRecord {
+	sourcesUsed := []string{}
+	for _, ss := range stats.downloadStats().Sources {
+		if ss.ChunksDelivered > 0 {
+			sourcesUsed = append(sourcesUsed, ss.URL)
+		}
+	}
+
+	return &AuditRecord{
+		Timestamp:   time.Now().UTC(),
+		DestFile:    destFile,
+		SourcesUsed: sourcesUsed,
+		Bytes:       fileMetadata.size,
+		DurationMs:  time.Since(startedAt).Milliseconds(),
+		ETag:        fileMetadata.eTag,
+		Verified:    verified,
+		ProcessUser: processUser(),
+	}
+}
+
+// appendAuditRecord appends the record as a single JSON line to the given audit log file.
+// The file is opened in append mode and written with a single write so that lines from
+// other processes are never interleaved.
+func appendAuditRecord(filePath string, record *AuditRecord) error {
+	b, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	auditLogMu.Lock()
+	defer auditLogMu.Unlock()
+
+	file, err := os.OpenFile(filePath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+
+	if _, err := file.Write(append(b, '\n')); err != nil {
+		file.Close()
+		return err
+	}
+
+	return file.Close()
+}
+
+// processUser returns the name of the user running the process.
+func processUser() string {
+	if u, err := user.Current(); err == nil {
+		return u.Username
+	}
+
+	return os.Getenv("USER")
+}
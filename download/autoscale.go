@@ -0,0 +1,177 @@
+package download
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+const (
+	defaultAutoScaleInterval = time.Second
+	dominantErrorShare       = 0.5  // share of the recent errors above which a source gets fewer chunks
+	lowErrorRate             = 0.05 // rate of the recent chunk attempts below which a connection is added
+	minSourceWeight          = 1.0 / 16
+	sourceWeightRecovery     = 1.0 / 8 // added to the weight of a source without recent errors
+)
+
+// connectionScaler adjusts the share of the chunks assigned to each source and the number of
+// concurrent connections based on the chunk errors since the previous evaluation (see AutoScaleConnections).
+type connectionScaler struct {
+	mu      sync.Mutex
+	urls    []string
+	weights []float64 // relative share of the chunks initially assigned to each source
+	credits []float64 // for smooth weighted round-robin
+	seen    map[string]SourceStats
+
+	tokens   chan struct{} // one per allowed connection
+	limit    int
+	maxLimit int
+}
+
+// newConnectionScaler returns a connectionScaler which initially divides the chunks evenly among the
+// given sources and allows the given number of connections (up to maxLimit).
+func newConnectionScaler(sourceUrls []string, limit, maxLimit int) *connectionScaler {
+	limit = max(limit, 1)
+	maxLimit = max(maxLimit, limit)
+
+	cs := &connectionScaler{
+		urls:     sourceUrls,
+		weights:  make([]float64, len(sourceUrls)),
+		credits:  make([]float64, len(sourceUrls)),
+		seen:     make(map[string]SourceStats),
+		tokens:   make(chan struct{}, maxLimit),
+		limit:    limit,
+		maxLimit: maxLimit,
+	}
+	for i := range cs.weights {
+		cs.weights[i] = 1
+	}
+	for range limit {
+		cs.tokens <- struct{}{}
+	}
+
+	return cs
+}
+
+// acquire blocks until a connection is available (or the context is done).
+func (cs *connectionScaler) acquire(ctx context.Context) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-cs.tokens:
+		return nil
+	}
+}
+
+// release makes the connection available again.
+func (cs *connectionScaler) release() {
+	cs.tokens <- struct{}{}
+}
+
+// pickSource returns the index of the source to initially assign the next chunk to, which is
+// spread over the sources in proportion to their weights.
+func (cs *connectionScaler) pickSource() int {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	var total float64
+	picked := 0
+	for i, weight := range cs.weights {
+		cs.credits[i] += weight
+		total += weight
+		if cs.credits[i] > cs.credits[picked] {
+			picked = i
+		}
+	}
+	cs.credits[picked] -= total
+
+	return picked
+}
+
+// Select implements SourceSelector by picking the sources in proportion to their weights (regardless
+// of the chunk index and the available sources since the health of the picked source is checked afterwards).
+func (cs *connectionScaler) Select(_ int, _ []string) string {
+	return cs.urls[cs.pickSource()]
+}
+
+// rebalance evaluates the chunk attempts of each source since the previous evaluation given the
+// current stats. A source with more than half of the errors has its weight halved while the weight
+// of a source without errors slowly recovers (back up to 1). A connection is added if the error rate is low.
+// This returns the indices of the sources whose weight was reduced and whether a connection was added.
+func (cs *connectionScaler) rebalance(stats *DownloadStats) ([]int, bool) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	current := make(map[string]SourceStats, len(stats.Sources))
+	for _, ss := range stats.Sources {
+		current[ss.URL] = ss
+	}
+
+	attempts := make([]int, len(cs.urls))
+	errs := make([]int, len(cs.urls))
+	var totalAttempts, totalErrs int
+	for i, url := range cs.urls {
+		errs[i] = current[url].Errors - cs.seen[url].Errors
+		attempts[i] = current[url].ChunksDelivered - cs.seen[url].ChunksDelivered + errs[i]
+		totalAttempts += attempts[i]
+		totalErrs += errs[i]
+		cs.seen[url] = current[url]
+	}
+	if totalAttempts == 0 {
+		return nil, false
+	}
+
+	var reduced []int
+	for i := range cs.weights {
+		if len(cs.weights) > 1 && float64(errs[i]) > dominantErrorShare*float64(totalErrs) {
+			if weight := max(cs.weights[i]/2, minSourceWeight); weight < cs.weights[i] {
+				cs.weights[i] = weight
+				reduced = append(reduced, i)
+			}
+		} else if errs[i] == 0 && attempts[i] > 0 {
+			cs.weights[i] = math.Min(cs.weights[i]+sourceWeightRecovery, 1)
+		}
+	}
+
+	if float64(totalErrs) >= lowErrorRate*float64(totalAttempts) || cs.limit >= cs.maxLimit {
+		return reduced, false
+	}
+
+	cs.limit++
+	cs.tokens <- struct{}{}
+
+	return reduced, true
+}
+
+// monitorConnections rebalances the scaler at the configured interval until the context is done.
+func (s *Service) monitorConnections(ctx context.Context, sourceUrls []string, stats *sourceStatsCollector, scaler *connectionScaler) {
+	ticker := time.NewTicker(s.autoScaleInterval())
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		reduced, added := scaler.rebalance(stats.downloadStats())
+		for _, i := range reduced {
+			s.logln(fmt.Sprintf("warning: assigning fewer chunks to %s due to its share of the errors", sourceUrls[i]))
+		}
+		if added {
+			s.logln(fmt.Sprintf("increased concurrent connections to %d", scaler.limit))
+		}
+	}
+}
+
+// autoScaleInterval returns the configured interval for AutoScaleConnections or its default.
+func (s *Service) autoScaleInterval() time.Duration {
+	if s.opts.AutoScaleInterval > 0 {
+		return s.opts.AutoScaleInterval
+	}
+
+	return defaultAutoScaleInterval
+}
@@ -0,0 +1,110 @@
+package download
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_connectionScaler_rebalance(t *testing.T) {
+	urls := []string{"http://a", "http://b", "http://c"}
+	snapshot := func(chunks, errs [3]int) *DownloadStats {
+		stats := &DownloadStats{}
+		for i, url := range urls {
+			stats.Sources = append(stats.Sources, SourceStats{URL: url, ChunksDelivered: chunks[i], Errors: errs[i]})
+		}
+		return stats
+	}
+
+	cs := newConnectionScaler(urls, 2, 3)
+
+	// b is responsible for most errors so it gets fewer chunks
+	reduced, added := cs.rebalance(snapshot([3]int{10, 5, 10}, [3]int{1, 5, 0}))
+	assert.Equal(t, []int{1}, reduced)
+	assert.False(t, added)
+	assert.Equal(t, []float64{1, 0.5, 1}, cs.weights)
+
+	picks := make([]int, len(urls))
+	for range 50 {
+		picks[cs.pickSource()]++
+	}
+	assert.Equal(t, []int{20, 10, 20}, picks)
+
+	// errors spread evenly reduce no source
+	reduced, added = cs.rebalance(snapshot([3]int{20, 10, 20}, [3]int{2, 6, 1}))
+	assert.Empty(t, reduced)
+	assert.False(t, added)
+
+	// a low error rate lets the weights recover and adds connections up to the max
+	reduced, added = cs.rebalance(snapshot([3]int{40, 30, 40}, [3]int{2, 6, 1}))
+	assert.Empty(t, reduced)
+	assert.True(t, added)
+	assert.Equal(t, []float64{1, 0.625, 1}, cs.weights)
+	assert.Equal(t, 3, cs.limit)
+
+	_, added = cs.rebalance(snapshot([3]int{60, 50, 60}, [3]int{2, 6, 1}))
+	assert.False(t, added)
+	assert.Len(t, cs.tokens, 3)
+}
+
+func Test_Service_Download_AutoScaleConnections(t *testing.T) {
+	content := bytes.Repeat([]byte("0123456789"), 200)
+
+	newSrv := func(flaky bool) *httptest.Server {
+		var requests atomic.Int64
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == http.MethodGet {
+				time.Sleep(2 * time.Millisecond)
+				if flaky && requests.Add(1)%2 == 0 {
+					w.WriteHeader(http.StatusServiceUnavailable)
+					return
+				}
+			}
+			http.ServeContent(w, r, "digits.txt", time.Time{}, bytes.NewReader(content))
+		}))
+	}
+
+	reliableSrv, flakySrv := newSrv(false), newSrv(true)
+	defer reliableSrv.Close()
+	defer flakySrv.Close()
+
+	flakyShare := func(autoScale bool) float64 {
+		destFilePath := filepath.Join(t.TempDir(), "digits.txt")
+		s := NewService(Options{
+			Connections:          2,
+			Timeout:              3,
+			Quiet:                true,
+			DestFilePath:         destFilePath,
+			ChunkBytes:           20,
+			AutoScaleConnections: autoScale,
+			AutoScaleInterval:    5 * time.Millisecond,
+		}, nil)
+
+		err := s.downloadWithContext(context.Background(), []string{reliableSrv.URL + "/digits.txt", flakySrv.URL + "/digits.txt"})
+		assert.NoError(t, err)
+
+		downloaded, err := os.ReadFile(destFilePath)
+		assert.NoError(t, err)
+		assert.Equal(t, content, downloaded)
+
+		var flakyChunks, totalChunks int
+		for _, ss := range s.LastDownloadStats().Sources {
+			totalChunks += ss.ChunksDelivered
+			if ss.URL == flakySrv.URL+"/digits.txt" {
+				flakyChunks = ss.ChunksDelivered
+			}
+		}
+		return float64(flakyChunks) / float64(totalChunks)
+	}
+
+	// the flaky source delivers about a quarter of the chunks when they are assigned evenly
+	assert.Less(t, flakyShare(true), flakyShare(false)/2)
+}
@@ -0,0 +1,165 @@
+package download
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"slices"
+	"sync"
+	"time"
+)
+
+// benchmarkRequests is the number of (sequential) chunk requests the sample of each source is split into.
+const benchmarkRequests = 8
+
+// BenchmarkResult represents the measured performance of a source (or the error if it is unhealthy).
+type BenchmarkResult struct {
+	URL            string  `json:"url"`
+	AvgBytesPerSec float64 `json:"avg_bytes_per_sec"` // of the successful requests
+	P95LatencyMs   float64 `json:"p95_latency_ms"`    // of the successful requests
+	ErrorRate      float64 `json:"error_rate"`        // ratio of failed requests (1 if the source is unhealthy)
+	Err            error   `json:"-"`                 // if the source is unhealthy or all requests failed
+}
+
+// Benchmark downloads up to the given number of bytes from the start of the file of each of the given
+// sources concurrently (discarding the data) and returns their measured performance in order of
+// descending throughput. Each sample is fetched with several sequential chunk requests so that the
+// latency percentile can be computed. Like ValidateSources, unhealthy sources do not cause a failure.
+func (s *Service) Benchmark(ctx context.Context, sourceUrls []string, sampleBytes int64) ([]BenchmarkResult, error) {
+	if len(sourceUrls) == 0 {
+		return nil, ErrNoSourceUrls
+	}
+	if sampleBytes <= 0 {
+		return nil, ErrInvalidSampleBytes
+	}
+
+	results := make([]BenchmarkResult, len(sourceUrls))
+
+	var wg sync.WaitGroup
+	for i, url := range sourceUrls {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = s.benchmarkSource(ctx, url, sampleBytes)
+		}()
+	}
+	wg.Wait()
+
+	slices.SortStableFunc(results, func(a, b BenchmarkResult) int {
+		switch {
+		case a.AvgBytesPerSec > b.AvgBytesPerSec:
+			return -1
+		case a.AvgBytesPerSec < b.AvgBytesPerSec:
+			return 1
+		default:
+			return 0
+		}
+	})
+
+	return results, nil
+}
+
+// benchmarkSource downloads the sample from the source with the given URL and measures its performance.
+func (s *Service) benchmarkSource(ctx context.Context, url string, sampleBytes int64) BenchmarkResult {
+	result := BenchmarkResult{URL: url, ErrorRate: 1}
+
+	url, err := NormalizeURL(url)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	result.URL = url
+
+	sfm, err := s.fetchFileMetadata(ctx, url)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+
+	size := sampleBytes
+	if sfm.size >= 0 {
+		size = min(size, sfm.size)
+	}
+	if size == 0 {
+		result.ErrorRate = 0 // nothing to measure
+		return result
+	}
+	chunkSize := max((size+benchmarkRequests-1)/benchmarkRequests, 1)
+
+	var latencies []time.Duration
+	var requests, downloaded int64
+	var elapsed time.Duration
+	for offset := int64(0); offset < size; offset += chunkSize {
+		limit := min(offset+chunkSize, size)
+		requests++
+
+		start := time.Now()
+		if _, err = s.fetchChunk(ctx, url, offset, limit, nil); err != nil {
+			if ctx.Err() != nil {
+				break
+			}
+			continue
+		}
+		latency := time.Since(start)
+
+		latencies = append(latencies, latency)
+		downloaded += limit - offset
+		elapsed += latency
+	}
+
+	if len(latencies) == 0 {
+		result.Err = err
+		return result
+	}
+
+	result.ErrorRate = float64(requests-int64(len(latencies))) / float64(requests)
+	result.AvgBytesPerSec = float64(downloaded) / elapsed.Seconds()
+	result.P95LatencyMs = float64(percentile(latencies, 0.95).Microseconds()) / 1000
+
+	return result
+}
+
+// percentile returns the given percentile (between 0 and 1) of the durations using the nearest-rank method.
+func percentile(durations []time.Duration, p float64) time.Duration {
+	sorted := slices.Clone(durations)
+	slices.Sort(sorted)
+
+	rank := int(math.Ceil(p * float64(len(sorted))))
+	return sorted[max(rank-1, 0)]
+}
+
+// defaultBenchmarkSampleBytes is the sample size of BenchmarkBeforeDownload unless BenchmarkSampleBytes is set.
+const defaultBenchmarkSampleBytes = 1 << 20
+
+// benchmarkThroughput measures the throughput of each of the given sources (see BenchmarkBeforeDownload)
+// by downloading a sample of the file with a single chunk request each, concurrently.
+func (s *Service) benchmarkThroughput(ctx context.Context, srcFileMetas []sourceFileMetadata) {
+	sampleBytes := s.opts.BenchmarkSampleBytes
+	if sampleBytes <= 0 {
+		sampleBytes = defaultBenchmarkSampleBytes
+	}
+
+	var wg sync.WaitGroup
+	for i := range srcFileMetas {
+		sfm := &srcFileMetas[i]
+		if sfm.size <= 0 {
+			continue // nothing to sample (or unknown length)
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			limit := min(sampleBytes, sfm.size)
+			start := time.Now()
+			if _, err := s.fetchChunk(ctx, sfm.url, 0, limit, nil); err != nil {
+				s.logln(fmt.Sprintf("warning: failed to benchmark source %s: %v", sfm.url, err))
+				return
+			}
+
+			sfm.bytesPerSec = float64(limit) / time.Since(start).Seconds()
+			s.logDebug(fmt.Sprintf("benchmarked source %s at %.0f bytes/s", sfm.url, sfm.bytesPerSec))
+		}()
+	}
+	wg.Wait()
+}
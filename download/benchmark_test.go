@@ -0,0 +1,140 @@
+package download_test
+
+import (
+	"context"
+	"net/http"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/gkatanacio/multisource-downloader/download"
+)
+
+func Test_Service_Benchmark(t *testing.T) {
+	content := readFixture(t, "dummy.png")
+
+	newDelayedServer := func(delay time.Duration) string {
+		return newTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == http.MethodGet {
+				time.Sleep(delay)
+			}
+			serveContent("dummy.png", content)(w, r)
+		})).URL + "/dummy.png"
+	}
+
+	// fails every other chunk request
+	var requests atomic.Int32
+	flakyUrl := newTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet && requests.Add(1)%2 == 0 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		serveContent("dummy.png", content)(w, r)
+	})).URL + "/dummy.png"
+
+	slowUrl := newDelayedServer(20 * time.Millisecond)
+	fastUrl := newDelayedServer(time.Millisecond)
+	deadUrl := newTestServer(t, http.NotFoundHandler()).URL + "/dummy.png"
+
+	downloadService := download.NewService(download.Options{
+		Connections: 4,
+		Timeout:     3,
+		Quiet:       true,
+	}, download.GetMD5Hash)
+
+	results, err := downloadService.Benchmark(context.Background(), []string{slowUrl, deadUrl, flakyUrl, fastUrl}, 4000)
+	assert.NoError(t, err)
+	assert.Len(t, results, 4)
+
+	byUrl := make(map[string]download.BenchmarkResult)
+	for _, r := range results {
+		byUrl[r.URL] = r
+	}
+
+	// sorted by throughput (the flaky source has no delay but only half of the requests succeed)
+	assert.Equal(t, deadUrl, results[3].URL)
+	assert.Greater(t, byUrl[fastUrl].AvgBytesPerSec, byUrl[slowUrl].AvgBytesPerSec)
+	for i := 1; i < len(results); i++ {
+		assert.GreaterOrEqual(t, results[i-1].AvgBytesPerSec, results[i].AvgBytesPerSec)
+	}
+
+	assert.NoError(t, byUrl[slowUrl].Err)
+	assert.Zero(t, byUrl[slowUrl].ErrorRate)
+	assert.GreaterOrEqual(t, byUrl[slowUrl].P95LatencyMs, float64(20))
+	assert.Less(t, byUrl[fastUrl].P95LatencyMs, byUrl[slowUrl].P95LatencyMs)
+
+	assert.NoError(t, byUrl[flakyUrl].Err)
+	assert.Equal(t, 0.5, byUrl[flakyUrl].ErrorRate)
+
+	assert.Error(t, byUrl[deadUrl].Err)
+	assert.Equal(t, float64(1), byUrl[deadUrl].ErrorRate)
+	assert.Zero(t, byUrl[deadUrl].AvgBytesPerSec)
+}
+
+func Test_Service_Benchmark_InvalidArgs(t *testing.T) {
+	downloadService := download.NewService(download.Options{Connections: 1, Timeout: 3, Quiet: true}, download.GetMD5Hash)
+
+	_, err := downloadService.Benchmark(context.Background(), nil, 1024)
+	assert.ErrorIs(t, err, download.ErrNoSourceUrls)
+
+	_, err = downloadService.Benchmark(context.Background(), []string{"http://localhost/a.txt"}, 0)
+	assert.ErrorIs(t, err, download.ErrInvalidSampleBytes)
+}
+
+func Test_Service_Download_BenchmarkBeforeDownload(t *testing.T) {
+	content := readFixture(t, "dummy.png")
+
+	// the low-latency source has the lower throughput so that the rankings differ
+	lowLatency := newTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			time.Sleep(100 * time.Millisecond)
+		}
+		serveContent("dummy.png", content)(w, r)
+	})).URL + "/dummy.png"
+	highThroughput := newTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead {
+			time.Sleep(100 * time.Millisecond)
+		}
+		serveContent("dummy.png", content)(w, r)
+	})).URL + "/dummy.png"
+
+	testCases := map[string]struct {
+		benchmark      bool
+		expectedSource string
+	}{
+		"ranked by latency": {
+			benchmark:      false,
+			expectedSource: lowLatency,
+		},
+		"ranked by throughput": {
+			benchmark:      true,
+			expectedSource: highThroughput,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			var firstSource atomic.Value
+			downloadService := download.NewService(download.Options{
+				Connections:             1,
+				Timeout:                 3,
+				Quiet:                   true,
+				DestFilePath:            filepath.Join(t.TempDir(), "dummy.png"),
+				BenchmarkBeforeDownload: tc.benchmark,
+				BenchmarkSampleBytes:    1000,
+				OnChunkStart: func(chunkIndex int, source string, offset, size int64) {
+					if chunkIndex == 0 {
+						firstSource.Store(source)
+					}
+				},
+			}, download.GetMD5Hash)
+
+			err := downloadService.Download([]string{lowLatency, highThroughput})
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectedSource, firstSource.Load())
+		})
+	}
+}
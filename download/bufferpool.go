@@ -0,0 +1,76 @@
+package download
+
+import (
+	"context"
+	"sync"
+)
+
+// chunkBuffer is a reusable buffer which a chunk can be read into.
+type chunkBuffer struct {
+	b []byte
+}
+
+// bytes returns the buffer to read the chunk into or nil if there is no buffer.
+func (cb *chunkBuffer) bytes() []byte {
+	if cb == nil {
+		return nil
+	}
+
+	return cb.b
+}
+
+// chunkBufferPool reuses the buffers of the chunks once they have been written so that
+// the chunks do not have to be allocated individually. A nil pool does no pooling at all.
+type chunkBufferPool struct {
+	pool      sync.Pool
+	chunkSize int64
+}
+
+// newChunkBufferPool returns a pool of buffers for chunks of the given size.
+func newChunkBufferPool(chunkSize int64) *chunkBufferPool {
+	cbp := &chunkBufferPool{chunkSize: chunkSize}
+	cbp.pool.New = func() any {
+		return &chunkBuffer{b: make([]byte, chunkSize)}
+	}
+
+	return cbp
+}
+
+// get returns a buffer for a chunk of the given size (at most the chunk size of the pool).
+func (cbp *chunkBufferPool) get(size int64) *chunkBuffer {
+	if cbp == nil {
+		return nil
+	}
+
+	cb := cbp.pool.Get().(*chunkBuffer)
+	cb.b = cb.b[:size]
+
+	return cb
+}
+
+// put returns the buffer to the pool. The chunk read into it must no longer be used afterwards.
+func (cbp *chunkBufferPool) put(cb *chunkBuffer) {
+	if cbp == nil || cb == nil {
+		return
+	}
+
+	cb.b = cb.b[:cbp.chunkSize]
+	cbp.pool.Put(cb)
+}
+
+// bufferedFetcher can be implemented by a Fetcher which is able to read a range into a given buffer.
+type bufferedFetcher interface {
+	// GetRangeInto is the same as GetRange but reads the bytes into the given buffer whose
+	// length is exactly the size of the range.
+	GetRangeInto(ctx context.Context, url string, start, end int64, buf []byte) ([]byte, error)
+}
+
+// getRangeInto retrieves the range from the fetcher into the given buffer if there is one and the
+// fetcher supports it. Otherwise, the fetcher allocates the returned bytes as usual.
+func getRangeInto(ctx context.Context, fetcher Fetcher, url string, start, end int64, buf []byte) ([]byte, error) {
+	if bf, ok := fetcher.(bufferedFetcher); ok && buf != nil {
+		return bf.GetRangeInto(ctx, url, start, end, buf)
+	}
+
+	return fetcher.GetRange(ctx, url, start, end)
+}
@@ -0,0 +1,49 @@
+package download
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// ParseChecksum parses a checksum in the `algorithm:hexdigest` format (e.g., `sha256:abc123...`)
+// and returns the algorithm and the (lowercased) hex digest.
+func ParseChecksum(checksum string) (string, string, error) {
+	algorithm, expectedHex, ok := strings.Cut(checksum, ":")
+	if !ok || len(expectedHex) == 0 {
+		return "", "", fmt.Errorf("invalid checksum %q (expected algorithm:hexdigest)", checksum)
+	}
+
+	algorithm = strings.ToLower(algorithm)
+	if _, err := HashCalculatorFor(algorithm); err != nil {
+		return "", "", err
+	}
+
+	return algorithm, strings.ToLower(expectedHex), nil
+}
+
+// VerifyFileChecksum calculates the hash of the given file with the given algorithm (md5, sha256
+// or sha512) and returns ErrChecksumMismatch if it does not match the expected hex digest.
+func VerifyFileChecksum(filePath, algorithm, expectedHex string) error {
+	calculateHash, err := HashCalculatorFor(algorithm)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Open(filePath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	hash, err := calculateHash(file)
+	if err != nil {
+		return err
+	}
+
+	if !strings.EqualFold(hash, expectedHex) {
+		return fmt.Errorf("%w: expected %s %s but got %s", ErrChecksumMismatch, algorithm, expectedHex, hash)
+	}
+
+	return nil
+}
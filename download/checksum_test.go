@@ -0,0 +1,107 @@
+package download_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/gkatanacio/multisource-downloader/download"
+)
+
+func Test_VerifyFileChecksum(t *testing.T) {
+	content := []byte("the quick brown fox jumps over the lazy dog")
+	filePath := writeTempFile(t, "fox.txt", content)
+
+	md5Hash, _ := download.GetMD5Hash(bytes.NewReader(content))
+	sha256Hash, _ := download.GetSHA256Hash(bytes.NewReader(content))
+	sha512Hash, _ := download.GetSHA512Hash(bytes.NewReader(content))
+
+	testCases := map[string]struct {
+		algorithm   string
+		expectedHex string
+		specificErr error
+	}{
+		"md5": {
+			algorithm:   "md5",
+			expectedHex: md5Hash,
+		},
+		"sha256": {
+			algorithm:   "sha256",
+			expectedHex: sha256Hash,
+		},
+		"sha512": {
+			algorithm:   "sha512",
+			expectedHex: sha512Hash,
+		},
+		"uppercase digest": {
+			algorithm:   "sha256",
+			expectedHex: strings.ToUpper(sha256Hash),
+		},
+		"mismatch": {
+			algorithm:   "sha256",
+			expectedHex: md5Hash,
+			specificErr: download.ErrChecksumMismatch,
+		},
+		"unsupported algorithm": {
+			algorithm:   "crc32",
+			expectedHex: "414fa339",
+			specificErr: download.ErrUnsupportedHashAlgorithm,
+		},
+	}
+
+	for scenario, tc := range testCases {
+		t.Run(scenario, func(t *testing.T) {
+			err := download.VerifyFileChecksum(filePath, tc.algorithm, tc.expectedHex)
+			assert.ErrorIs(t, err, tc.specificErr)
+		})
+	}
+}
+
+func Test_ParseChecksum(t *testing.T) {
+	testCases := map[string]struct {
+		checksum          string
+		expectedAlgorithm string
+		expectedHex       string
+		expectErr         bool
+	}{
+		"valid": {
+			checksum:          "sha256:ABC123",
+			expectedAlgorithm: "sha256",
+			expectedHex:       "abc123",
+		},
+		"uppercase algorithm": {
+			checksum:          "MD5:abc123",
+			expectedAlgorithm: "md5",
+			expectedHex:       "abc123",
+		},
+		"missing algorithm": {
+			checksum:  "abc123",
+			expectErr: true,
+		},
+		"missing digest": {
+			checksum:  "sha256:",
+			expectErr: true,
+		},
+		"unsupported algorithm": {
+			checksum:  "crc32:abc123",
+			expectErr: true,
+		},
+	}
+
+	for scenario, tc := range testCases {
+		t.Run(scenario, func(t *testing.T) {
+			algorithm, expectedHex, err := download.ParseChecksum(tc.checksum)
+
+			if tc.expectErr {
+				assert.Error(t, err)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectedAlgorithm, algorithm)
+			assert.Equal(t, tc.expectedHex, expectedHex)
+		})
+	}
+}
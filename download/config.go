@@ -0,0 +1,139 @@
+package download
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config represents the persistent settings (e.g., `~/.config/msdl/config.yaml`) consisting of the
+// base options, the default credentials of the sources and any named profiles overriding the base.
+type Config struct {
+	Options `yaml:",inline"`
+
+	// DefaultSourceCredentials are used for the hosts which have no SourceCredentials of their own.
+	DefaultSourceCredentials map[string]SourceCredentials `yaml:"default_source_credentials,omitempty"`
+
+	Profiles map[string]Options `yaml:"profiles,omitempty"`
+}
+
+// SourceCredentials represents how the requests to the sources of a host are authenticated
+// (i.e., either basic authentication or a bearer token).
+type SourceCredentials struct {
+	Username    string `yaml:"username,omitempty"`
+	Password    string `yaml:"password,omitempty"`
+	BearerToken string `yaml:"bearer_token,omitempty"`
+}
+
+// DefaultConfigPath returns the path of the config file in the config directory of the user
+// (i.e., `~/.config/msdl/config.yaml`).
+func DefaultConfigPath() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(home, ".config", "msdl", "config.yaml"), nil
+}
+
+// Load reads the config from the YAML file at the given path.
+func (c *Config) Load(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	var config Config
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return fmt.Errorf("failed to parse config %s: %w", path, err)
+	}
+
+	*c = config
+	return nil
+}
+
+// Save writes the config to the YAML file at the given path (creating its directory if needed).
+// The file is only readable by the user since it may contain credentials.
+func (c *Config) Save(path string) error {
+	data, err := yaml.Marshal(c)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, data, 0600)
+}
+
+// Resolve returns the given options where the non-zero fields of the base options and then of the
+// profile with the given name (if not empty) override the given ones. The default credentials are
+// added for the hosts which have none. Like WithOptions, boolean fields can therefore only be enabled.
+// ErrUnknownProfile is returned if there is no such profile.
+func (c *Config) Resolve(opts Options, profile string) (Options, error) {
+	opts = mergeOptions(opts, c.Options)
+
+	if len(profile) > 0 {
+		profileOpts, ok := c.Profiles[profile]
+		if !ok {
+			return Options{}, fmt.Errorf("%w: %s", ErrUnknownProfile, profile)
+		}
+		opts = mergeOptions(opts, profileOpts)
+	}
+
+	if len(c.DefaultSourceCredentials) > 0 {
+		credentials := make(map[string]SourceCredentials, len(opts.SourceCredentials)+len(c.DefaultSourceCredentials))
+		for host, creds := range c.DefaultSourceCredentials {
+			credentials[host] = creds
+		}
+		for host, creds := range opts.SourceCredentials {
+			credentials[host] = creds
+		}
+		opts.SourceCredentials = credentials
+	}
+
+	return opts, nil
+}
+
+// credentialsRoundTripper authenticates the requests based on the credentials of their hosts.
+type credentialsRoundTripper struct {
+	next        http.RoundTripper
+	credentials map[string]SourceCredentials // keyed by host
+}
+
+// newCredentialsRoundTripper returns a round-tripper adding the given credentials to the requests
+// which it passes on to the given transport (or http.DefaultTransport if nil).
+func newCredentialsRoundTripper(next http.RoundTripper, credentials map[string]SourceCredentials) *credentialsRoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+
+	return &credentialsRoundTripper{
+		next:        next,
+		credentials: credentials,
+	}
+}
+
+// RoundTrip implements http.RoundTripper.
+func (rt *credentialsRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	creds, ok := rt.credentials[req.URL.Host]
+	if !ok {
+		creds, ok = rt.credentials[req.URL.Hostname()]
+	}
+	if !ok || len(req.Header.Get("Authorization")) > 0 {
+		return rt.next.RoundTrip(req)
+	}
+
+	req = req.Clone(req.Context()) // round-trippers must not modify the given request
+	if len(creds.BearerToken) > 0 {
+		req.Header.Set("Authorization", "Bearer "+creds.BearerToken)
+	} else {
+		req.SetBasicAuth(creds.Username, creds.Password)
+	}
+
+	return rt.next.RoundTrip(req)
+}
@@ -0,0 +1,156 @@
+package download_test
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/gkatanacio/multisource-downloader/download"
+)
+
+func Test_Config_SaveLoad(t *testing.T) {
+	config := download.Config{
+		Options: download.Options{
+			Connections:       8,
+			Timeout:           30,
+			CheckETag:         true,
+			DialTimeout:       2 * time.Second,
+			PieceHashes:       []string{"abc", "def"},
+			RoundTripper:      http.DefaultTransport, // not persisted
+			SourceCredentials: map[string]download.SourceCredentials{"a.example.com": {BearerToken: "token"}},
+		},
+		DefaultSourceCredentials: map[string]download.SourceCredentials{
+			"b.example.com:8080": {Username: "user", Password: "secret"},
+		},
+		Profiles: map[string]download.Options{
+			"fast": {Connections: 32, AutoScaleConnections: true},
+		},
+	}
+
+	path := filepath.Join(t.TempDir(), "msdl", "config.yaml")
+	assert.NoError(t, config.Save(path))
+
+	fileInfo, err := os.Stat(path)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), fileInfo.Mode().Perm())
+
+	data, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), "dial_timeout: 2s")
+	assert.NotContains(t, string(data), "round_tripper")
+
+	var loaded download.Config
+	assert.NoError(t, loaded.Load(path))
+
+	config.RoundTripper = nil
+	assert.Equal(t, config, loaded)
+}
+
+func Test_Config_Load_Invalid(t *testing.T) {
+	path := writeTempFile(t, "config.yaml", []byte("connections: many\n"))
+
+	var config download.Config
+	err := config.Load(path)
+	assert.ErrorContains(t, err, "failed to parse config")
+}
+
+func Test_Config_Resolve(t *testing.T) {
+	config := download.Config{
+		Options: download.Options{
+			Connections: 8,
+			CheckETag:   true,
+		},
+		DefaultSourceCredentials: map[string]download.SourceCredentials{
+			"a.example.com": {Username: "default"},
+			"b.example.com": {Username: "default"},
+		},
+		Profiles: map[string]download.Options{
+			"fast": {
+				Connections:       32,
+				SourceCredentials: map[string]download.SourceCredentials{"a.example.com": {Username: "fast"}},
+			},
+		},
+	}
+
+	testCases := map[string]struct {
+		profile     string
+		expected    download.Options
+		specificErr error
+	}{
+		"base": {
+			expected: download.Options{
+				Connections: 8,
+				Timeout:     10,
+				CheckETag:   true,
+				SourceCredentials: map[string]download.SourceCredentials{
+					"a.example.com": {Username: "default"},
+					"b.example.com": {Username: "default"},
+				},
+			},
+		},
+		"profile": {
+			profile: "fast",
+			expected: download.Options{
+				Connections: 32,
+				Timeout:     10,
+				CheckETag:   true,
+				SourceCredentials: map[string]download.SourceCredentials{
+					"a.example.com": {Username: "fast"},
+					"b.example.com": {Username: "default"},
+				},
+			},
+		},
+		"unknown profile": {
+			profile:     "slow",
+			specificErr: download.ErrUnknownProfile,
+		},
+	}
+
+	for scenario, tc := range testCases {
+		t.Run(scenario, func(t *testing.T) {
+			opts, err := config.Resolve(download.Options{Connections: 5, Timeout: 10}, tc.profile)
+			if tc.specificErr != nil {
+				assert.ErrorIs(t, err, tc.specificErr)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, opts)
+		})
+	}
+}
+
+func Test_Service_Download_SourceCredentials(t *testing.T) {
+	content := readFixture(t, "dummy.txt")
+
+	srv := newTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if username, password, ok := r.BasicAuth(); !ok || username != "user" || password != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		serveContent("dummy.txt", content)(w, r)
+	}))
+
+	destFilePath := filepath.Join(t.TempDir(), "dummy.txt")
+	downloadService := download.NewService(download.Options{
+		Connections:  2,
+		Timeout:      3,
+		Quiet:        true,
+		DestFilePath: destFilePath,
+		SourceCredentials: map[string]download.SourceCredentials{
+			strings.TrimPrefix(srv.URL, "http://"): {Username: "user", Password: "secret"},
+		},
+	}, download.GetMD5Hash)
+
+	err := downloadService.Download([]string{srv.URL + "/dummy.txt"})
+	assert.NoError(t, err)
+
+	downloaded, err := os.ReadFile(destFilePath)
+	assert.NoError(t, err)
+	assert.Equal(t, content, downloaded)
+}
@@ -0,0 +1,53 @@
+package download
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+)
+
+// sniffBytes is the number of bytes considered by http.DetectContentType.
+const sniffBytes = 512
+
+// genericContentType is what http.DetectContentType returns if none of its signatures match.
+const genericContentType = "application/octet-stream"
+
+// contentTypeAliases maps the media types returned by http.DetectContentType to their registered
+// equivalents so that either can be expected.
+var contentTypeAliases = map[string]string{
+	"application/x-gzip": "application/gzip",
+}
+
+// validateContentType checks that the content type detected from the first bytes of the file matches
+// the expected one. If the detection is inconclusive (e.g., for formats without a known signature),
+// the content type reported by the sources must match instead. ErrContentTypeMismatch is returned
+// otherwise (e.g., for an HTML error page served in place of a binary).
+func validateContentType(filePath, sourceContentType, expected string) error {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	head := make([]byte, sniffBytes)
+	n, err := io.ReadFull(file, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return err
+	}
+
+	detected := http.DetectContentType(head[:n])
+	if alias, ok := contentTypeAliases[detected]; ok {
+		detected = alias
+	}
+
+	if contentTypesMatch(detected, expected, false) {
+		return nil
+	}
+	if detected == genericContentType && contentTypesMatch(sourceContentType, expected, false) {
+		return nil
+	}
+
+	return fmt.Errorf("%w: expected %s but detected %s (reported as %s)", ErrContentTypeMismatch, expected, detected, sourceContentType)
+}
@@ -0,0 +1,53 @@
+package download
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// newDialContext returns the function establishing the TCP connections of the HTTP transport based
+// on DialTimeout, ForceIPv4, ForceIPv6, SocketMark and SocketPriority.
+func newDialContext(opts Options) func(ctx context.Context, network, address string) (net.Conn, error) {
+	socketControl := newSocketControl(opts)
+	dialer := &net.Dialer{
+		Timeout:   opts.DialTimeout,
+		KeepAlive: 30 * time.Second, // same as http.DefaultTransport
+		Control:   socketControl,
+	}
+
+	var ipVersion string
+	switch {
+	case opts.ForceIPv4 && opts.ForceIPv6:
+		return func(context.Context, string, string) (net.Conn, error) {
+			return nil, ErrConflictingIPVersions
+		}
+	case opts.ForceIPv4:
+		ipVersion = "4"
+	case opts.ForceIPv6:
+		ipVersion = "6"
+	default:
+		return dialer.DialContext
+	}
+
+	// the pure Go resolver only looks up the records of the requested IP version (i.e., A or AAAA)
+	dialer.Resolver = &net.Resolver{PreferGo: true}
+
+	// guards against any address of the other IP version (e.g., IPv4-mapped IPv6 addresses) slipping through
+	dialer.Control = func(network, address string, c syscall.RawConn) error {
+		if !strings.HasSuffix(network, ipVersion) {
+			return fmt.Errorf("%w: %s (IPv%s only)", ErrWrongIPVersion, address, ipVersion)
+		}
+		if socketControl != nil {
+			return socketControl(network, address, c)
+		}
+		return nil
+	}
+
+	return func(ctx context.Context, network, address string) (net.Conn, error) {
+		return dialer.DialContext(ctx, strings.TrimRight(network, "46")+ipVersion, address)
+	}
+}
@@ -0,0 +1,117 @@
+package download
+
+import (
+	"io"
+	"os"
+	"runtime"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// ByteRange represents the bytes of a file from Start (inclusive) up to End (exclusive).
+type ByteRange struct {
+	Start int64
+	End   int64
+}
+
+// Diff compares the two files and returns the byte ranges (in ascending order) where they differ, e.g.,
+// for telling whether a download failing the ETag check has a few corrupt bytes or is wrong entirely.
+// The corresponding blocks of the given size are compared concurrently (with up to as many blocks in
+// memory as there are CPUs) and contiguous differing bytes are merged into a single range, also across
+// blocks. If the files have different sizes, the bytes beyond the end of the smaller one differ.
+func Diff(fileA, fileB string, blockSize int64) ([]ByteRange, error) {
+	if blockSize <= 0 {
+		return nil, ErrInvalidBlockSize
+	}
+
+	a, sizeA, err := openWithSize(fileA)
+	if err != nil {
+		return nil, err
+	}
+	defer a.Close()
+
+	b, sizeB, err := openWithSize(fileB)
+	if err != nil {
+		return nil, err
+	}
+	defer b.Close()
+
+	commonSize := min(sizeA, sizeB)
+	blockDiffs := make([][]ByteRange, (commonSize+blockSize-1)/blockSize)
+
+	var eg errgroup.Group
+	eg.SetLimit(runtime.NumCPU())
+	for i := range blockDiffs {
+		eg.Go(func() error {
+			offset := int64(i) * blockSize
+			diffs, err := diffBlock(a, b, offset, min(offset+blockSize, commonSize))
+			blockDiffs[i] = diffs
+			return err
+		})
+	}
+	if err := eg.Wait(); err != nil {
+		return nil, err
+	}
+
+	var ranges []ByteRange
+	for _, diffs := range blockDiffs {
+		ranges = appendByteRanges(ranges, diffs...)
+	}
+	if sizeA != sizeB {
+		ranges = appendByteRanges(ranges, ByteRange{Start: commonSize, End: max(sizeA, sizeB)})
+	}
+
+	return ranges, nil
+}
+
+// openWithSize opens the file at the given path and returns it along with its size.
+func openWithSize(path string) (*os.File, int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	fileInfo, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, 0, err
+	}
+
+	return f, fileInfo.Size(), nil
+}
+
+// diffBlock returns the ranges where the bytes of the two readers differ between the given offsets.
+func diffBlock(a, b io.ReaderAt, start, end int64) ([]ByteRange, error) {
+	bufA := make([]byte, end-start)
+	bufB := make([]byte, end-start)
+	if _, err := a.ReadAt(bufA, start); err != nil {
+		return nil, err
+	}
+	if _, err := b.ReadAt(bufB, start); err != nil {
+		return nil, err
+	}
+
+	var ranges []ByteRange
+	for i := range bufA {
+		if bufA[i] != bufB[i] {
+			offset := start + int64(i)
+			ranges = appendByteRanges(ranges, ByteRange{Start: offset, End: offset + 1})
+		}
+	}
+
+	return ranges, nil
+}
+
+// appendByteRanges appends the given ranges (in ascending order and after the existing ones) to the
+// existing ones, merging each range into the previous one if they are contiguous.
+func appendByteRanges(ranges []ByteRange, more ...ByteRange) []ByteRange {
+	for _, r := range more {
+		if last := len(ranges) - 1; last >= 0 && ranges[last].End == r.Start {
+			ranges[last].End = r.End
+			continue
+		}
+		ranges = append(ranges, r)
+	}
+
+	return ranges
+}
@@ -0,0 +1,86 @@
+package download_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/gkatanacio/multisource-downloader/download"
+)
+
+func Test_Diff(t *testing.T) {
+	content := readFixture(t, "dummy.png") // 5000 bytes
+
+	corrupt := func(ranges ...download.ByteRange) []byte {
+		corrupted := bytes.Clone(content)
+		for _, r := range ranges {
+			for i := r.Start; i < r.End; i++ {
+				corrupted[i] ^= 0xff
+			}
+		}
+		return corrupted
+	}
+
+	testCases := map[string]struct {
+		other     []byte
+		blockSize int64
+		expected  []download.ByteRange
+	}{
+		"identical": {
+			other:     content,
+			blockSize: 1024,
+		},
+		"single byte": {
+			other:     corrupt(download.ByteRange{Start: 42, End: 43}),
+			blockSize: 1024,
+			expected:  []download.ByteRange{{Start: 42, End: 43}},
+		},
+		"separate ranges": {
+			other:     corrupt(download.ByteRange{Start: 0, End: 10}, download.ByteRange{Start: 2000, End: 2500}, download.ByteRange{Start: 4990, End: 5000}),
+			blockSize: 1024,
+			expected:  []download.ByteRange{{Start: 0, End: 10}, {Start: 2000, End: 2500}, {Start: 4990, End: 5000}},
+		},
+		"merged across blocks": {
+			other:     corrupt(download.ByteRange{Start: 900, End: 1100}, download.ByteRange{Start: 1100, End: 3100}),
+			blockSize: 1000,
+			expected:  []download.ByteRange{{Start: 900, End: 3100}},
+		},
+		"whole file": {
+			other:     corrupt(download.ByteRange{Start: 0, End: 5000}),
+			blockSize: 333,
+			expected:  []download.ByteRange{{Start: 0, End: 5000}},
+		},
+		"truncated": {
+			other:     content[:4000],
+			blockSize: 1024,
+			expected:  []download.ByteRange{{Start: 4000, End: 5000}},
+		},
+		"longer and corrupt at the end": {
+			other:     append(corrupt(download.ByteRange{Start: 4999, End: 5000}), "extra"...),
+			blockSize: 1024,
+			expected:  []download.ByteRange{{Start: 4999, End: 5005}},
+		},
+	}
+
+	for scenario, tc := range testCases {
+		t.Run(scenario, func(t *testing.T) {
+			fileA := writeTempFile(t, "a.png", content)
+			fileB := writeTempFile(t, "b.png", tc.other)
+
+			ranges, err := download.Diff(fileA, fileB, tc.blockSize)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, ranges)
+		})
+	}
+}
+
+func Test_Diff_Invalid(t *testing.T) {
+	fileA := writeTempFile(t, "a.txt", []byte("0123456789"))
+
+	_, err := download.Diff(fileA, fileA, 0)
+	assert.ErrorIs(t, err, download.ErrInvalidBlockSize)
+
+	_, err = download.Diff(fileA, fileA+".missing", 1024)
+	assert.Error(t, err)
+}
@@ -0,0 +1,173 @@
+package download
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// batchFile represents a file of a batch download along with its destination path.
+type batchFile struct {
+	url          string
+	destFilePath string
+}
+
+// DownloadDirectory downloads the files listed by the HTML directory index page (e.g., Apache or nginx
+// autoindex) at the given URL into the destination directory. Only the links below the index URL are
+// followed, where subdirectory index pages (i.e., links ending with a slash) are followed up to
+// DirectoryDepth levels deep and recreated within the destination directory. If given, the filter
+// selects the files to download by their path relative to the index URL (e.g., `sub/a.txt`).
+func (s *Service) DownloadDirectory(ctx context.Context, indexURL string, destDir string, filter func(filename string) bool) error {
+	base, err := url.Parse(indexURL)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidSourceUrl, err)
+	}
+	if !strings.HasSuffix(base.Path, "/") {
+		base.Path += "/"
+	}
+
+	var files []batchFile
+	visited := make(map[string]bool)
+
+	var walk func(pageURL *url.URL, depth uint) error
+	walk = func(pageURL *url.URL, depth uint) error {
+		visited[pageURL.String()] = true
+
+		links, err := s.fetchIndexLinks(ctx, pageURL)
+		if err != nil {
+			return err
+		}
+
+		for _, link := range links {
+			rel, ok := relativeIndexPath(base, link)
+			if !ok {
+				continue
+			}
+
+			if strings.HasSuffix(link.Path, "/") {
+				if depth < s.opts.DirectoryDepth && !visited[link.String()] {
+					if err := walk(link, depth+1); err != nil {
+						return err
+					}
+				}
+				continue
+			}
+
+			if filter != nil && !filter(rel) {
+				continue
+			}
+
+			files = append(files, batchFile{
+				url:          link.String(),
+				destFilePath: filepath.Join(destDir, filepath.FromSlash(rel)),
+			})
+		}
+
+		return nil
+	}
+
+	if err := walk(base, 0); err != nil {
+		return err
+	}
+
+	return s.downloadBatch(ctx, files)
+}
+
+// fetchIndexLinks returns the (resolved) targets of the anchor tags within the index page at the given URL,
+// which is requested with the UserAgent and the PerSourceHeaders of the page like the sources.
+func (s *Service) fetchIndexLinks(ctx context.Context, pageURL *url.URL) ([]*url.URL, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, pageURL.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	s.setSourceHeaders(req, pageURL.String())
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		io.Copy(io.Discard, resp.Body)
+		return nil, statusError(resp, pageURL.String())
+	}
+
+	doc, err := html.Parse(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse index page %s: %w", pageURL, err)
+	}
+
+	var links []*url.URL
+	var visit func(n *html.Node)
+	visit = func(n *html.Node) {
+		if n.Type == html.ElementNode && n.Data == "a" {
+			for _, attr := range n.Attr {
+				if attr.Key != "href" {
+					continue
+				}
+				if link, err := pageURL.Parse(attr.Val); err == nil {
+					link.Fragment = ""
+					links = append(links, link)
+				}
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			visit(c)
+		}
+	}
+	visit(doc)
+
+	return links, nil
+}
+
+// relativeIndexPath returns the unescaped path of the link relative to the base index URL if the
+// link refers to a file or subdirectory below it. Links with a query (e.g., the sorting links of
+// autoindex pages), links to parent directories and links to other hosts are not below the base.
+func relativeIndexPath(base, link *url.URL) (string, bool) {
+	if link.Scheme != base.Scheme || link.Host != base.Host || len(link.RawQuery) > 0 {
+		return "", false
+	}
+
+	rel, ok := strings.CutPrefix(link.EscapedPath(), base.EscapedPath())
+	if !ok || len(rel) == 0 {
+		return "", false
+	}
+
+	// the segments are unescaped individually so that an escaped slash cannot escape the destination directory
+	segments := strings.Split(strings.TrimSuffix(rel, "/"), "/")
+	for i, segment := range segments {
+		unescaped, err := url.PathUnescape(segment)
+		if err != nil || len(unescaped) == 0 || unescaped == "." || unescaped == ".." || strings.ContainsAny(unescaped, `/\`) {
+			return "", false
+		}
+		segments[i] = unescaped
+	}
+
+	return path.Join(segments...), true
+}
+
+// downloadBatch downloads each of the given files from its single source URL in turn (each of them
+// concurrently in chunks as usual), creating the parent directories of the destination files as needed.
+func (s *Service) downloadBatch(ctx context.Context, files []batchFile) error {
+	for _, file := range files {
+		if err := os.MkdirAll(filepath.Dir(file.destFilePath), 0755); err != nil {
+			return err
+		}
+
+		fileService := s.WithOptions(Options{DestFilePath: file.destFilePath})
+		if err := fileService.downloadWithContext(ctx, []string{file.url}); err != nil {
+			return fmt.Errorf("failed to download %s: %w", file.url, err)
+		}
+	}
+
+	return nil
+}
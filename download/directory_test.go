@@ -0,0 +1,177 @@
+package download_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/gkatanacio/multisource-downloader/download"
+)
+
+// newDirectoryServer returns a server serving minimal autoindex-like listings of the given files
+// (keyed by path) for every directory, with the usual sorting and parent directory links.
+func newDirectoryServer(t *testing.T, files map[string][]byte) string {
+	t.Helper()
+
+	return newTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if content, ok := files[r.URL.Path]; ok {
+			serveContentWithETag(filepath.Base(r.URL.Path), content)(w, r)
+			return
+		}
+
+		if !strings.HasSuffix(r.URL.Path, "/") {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		entries := make(map[string]bool)
+		for p := range files {
+			if rest, ok := strings.CutPrefix(p, r.URL.Path); ok {
+				name, _, isDir := strings.Cut(rest, "/")
+				if isDir {
+					name += "/"
+				}
+				entries[name] = true
+			}
+		}
+
+		if len(entries) == 0 {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		var sb strings.Builder
+		sb.WriteString(`<html><body><h1>Index</h1><a href="?C=N;O=D">Name</a><a href="../">Parent Directory</a><a href="https://example.com/">elsewhere</a><pre>`)
+		for name := range entries {
+			fmt.Fprintf(&sb, `<a href="%s">%s</a>`+"\n", name, name)
+		}
+		sb.WriteString(`</pre></body></html>`)
+
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(sb.String()))
+	})).URL
+}
+
+func Test_Service_DownloadDirectory(t *testing.T) {
+	content := readFixture(t, "dummy.txt")
+	files := map[string][]byte{
+		"/pub/a.txt":           content,
+		"/pub/b.log":           content[:100],
+		"/pub/sub/c.txt":       content[100:],
+		"/pub/sub/deep/d.txt":  content[:10],
+		"/outside/secret.txt":  content,
+		"/pub/sub/deep/e.skip": content,
+	}
+
+	testCases := map[string]struct {
+		depth    uint
+		filter   func(filename string) bool
+		expected map[string][]byte
+	}{
+		"index page only": {
+			depth: 0,
+			expected: map[string][]byte{
+				"a.txt": content,
+				"b.log": content[:100],
+			},
+		},
+		"one level of subdirectories": {
+			depth: 1,
+			expected: map[string][]byte{
+				"a.txt":     content,
+				"b.log":     content[:100],
+				"sub/c.txt": content[100:],
+			},
+		},
+		"all levels with filter": {
+			depth:  5,
+			filter: func(filename string) bool { return strings.HasSuffix(filename, ".txt") },
+			expected: map[string][]byte{
+				"a.txt":          content,
+				"sub/c.txt":      content[100:],
+				"sub/deep/d.txt": content[:10],
+			},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			baseURL := newDirectoryServer(t, files)
+			destDir := t.TempDir()
+
+			downloadService := download.NewService(download.Options{
+				Connections:    4,
+				Timeout:        3,
+				CheckETag:      true,
+				Quiet:          true,
+				DirectoryDepth: tc.depth,
+			}, download.GetMD5Hash)
+
+			err := downloadService.DownloadDirectory(context.Background(), baseURL+"/pub/", destDir, tc.filter)
+			assert.NoError(t, err)
+
+			downloaded := make(map[string][]byte)
+			filepath.WalkDir(destDir, func(p string, d os.DirEntry, err error) error {
+				if err == nil && !d.IsDir() {
+					rel, _ := filepath.Rel(destDir, p)
+					downloaded[filepath.ToSlash(rel)], _ = os.ReadFile(p)
+				}
+				return nil
+			})
+			assert.Equal(t, tc.expected, downloaded)
+		})
+	}
+}
+
+func Test_Service_DownloadDirectory_IndexNotFound(t *testing.T) {
+	baseURL := newDirectoryServer(t, nil)
+
+	downloadService := download.NewService(download.Options{Connections: 2, Timeout: 3, Quiet: true}, nil)
+
+	err := downloadService.DownloadDirectory(context.Background(), baseURL+"/missing", t.TempDir(), nil)
+	assert.ErrorContains(t, err, "received 404 response")
+}
+
+func Test_Service_DownloadDirectory_SourceHeaders(t *testing.T) {
+	content := readFixture(t, "dummy.txt")
+	files := map[string][]byte{"/pub/a.txt": content}
+	dirSrvURL := newDirectoryServer(t, files)
+
+	// the index page is only listed for requests with its token and the configured User-Agent
+	var indexUserAgent, indexAuthorization string
+	proxy := newTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/pub/" {
+			indexUserAgent, indexAuthorization = r.UserAgent(), r.Header.Get("Authorization")
+			if indexAuthorization != "Bearer index-token" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+		}
+		http.Redirect(w, r, dirSrvURL+r.URL.Path, http.StatusTemporaryRedirect)
+	}))
+	indexURL := proxy.URL + "/pub/"
+
+	destDir := t.TempDir()
+	downloadService := download.NewService(download.Options{
+		Connections:      2,
+		Timeout:          3,
+		Quiet:            true,
+		UserAgent:        "msdl-test/1.0",
+		PerSourceHeaders: map[string]map[string]string{indexURL: {"Authorization": "Bearer index-token"}},
+	}, nil)
+
+	err := downloadService.DownloadDirectory(context.Background(), indexURL, destDir, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "msdl-test/1.0", indexUserAgent)
+	assert.Equal(t, "Bearer index-token", indexAuthorization)
+
+	downloaded, err := os.ReadFile(filepath.Join(destDir, "a.txt"))
+	assert.NoError(t, err)
+	assert.Equal(t, content, downloaded)
+}
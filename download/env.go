@@ -0,0 +1,119 @@
+package download
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+)
+
+// envDestFilePath is the only required environment variable of OptionsFromEnv.
+const envDestFilePath = "MSDL_DEST_FILE"
+
+// OptionsFromEnv returns the options given by the `MSDL_*` environment variables (e.g., for containerised
+// deployments), where those which are not set (or empty) keep their defaults, i.e., 5 connections and
+// a 10-second timeout:
+//
+//	MSDL_DEST_FILE              DestFilePath (required)
+//	MSDL_CONNECTIONS            Connections
+//	MSDL_TIMEOUT                Timeout (in seconds)
+//	MSDL_CHECK_ETAG             CheckETag
+//	MSDL_PROXY                  Proxy
+//	MSDL_USER_AGENT             UserAgent
+//	MSDL_MAX_RETRIES            HeadRetryAttempts
+//	MSDL_CHUNK_BYTES            ChunkBytes
+//	MSDL_CHUNK_STALL_TIMEOUT    ChunkStallTimeout (e.g., `30s`)
+//	MSDL_ALLOW_PARTIAL_SOURCES  AllowPartialSources
+//	MSDL_HASH_FILE_ALGORITHM    HashFileAlgorithm
+//	MSDL_QUIET                  Quiet
+//	MSDL_VERBOSE                Verbose
+//
+// ErrMissingEnvVar is returned if MSDL_DEST_FILE is not set and ErrInvalidEnvVar if a value cannot be parsed.
+func OptionsFromEnv() (Options, error) {
+	opts := Options{
+		Connections: 5,
+		Timeout:     10,
+	}
+
+	vars := []struct {
+		name  string
+		parse func(string) error
+	}{
+		{envDestFilePath, stringEnv(&opts.DestFilePath)},
+		{"MSDL_CONNECTIONS", uintEnv(&opts.Connections)},
+		{"MSDL_TIMEOUT", uintEnv(&opts.Timeout)},
+		{"MSDL_CHECK_ETAG", boolEnv(&opts.CheckETag)},
+		{"MSDL_PROXY", stringEnv(&opts.Proxy)},
+		{"MSDL_USER_AGENT", stringEnv(&opts.UserAgent)},
+		{"MSDL_MAX_RETRIES", uintEnv(&opts.HeadRetryAttempts)},
+		{"MSDL_CHUNK_BYTES", int64Env(&opts.ChunkBytes)},
+		{"MSDL_CHUNK_STALL_TIMEOUT", durationEnv(&opts.ChunkStallTimeout)},
+		{"MSDL_ALLOW_PARTIAL_SOURCES", boolEnv(&opts.AllowPartialSources)},
+		{"MSDL_HASH_FILE_ALGORITHM", stringEnv(&opts.HashFileAlgorithm)},
+		{"MSDL_QUIET", boolEnv(&opts.Quiet)},
+		{"MSDL_VERBOSE", boolEnv(&opts.Verbose)},
+	}
+
+	for _, v := range vars {
+		value := os.Getenv(v.name)
+		if len(value) == 0 {
+			continue
+		}
+		if err := v.parse(value); err != nil {
+			return Options{}, fmt.Errorf("%w: %s: %w", ErrInvalidEnvVar, v.name, err)
+		}
+	}
+
+	if len(opts.DestFilePath) == 0 {
+		return Options{}, fmt.Errorf("%w: %s", ErrMissingEnvVar, envDestFilePath)
+	}
+
+	return opts, nil
+}
+
+// NewServiceFromEnv creates a Service from the options given by the environment variables (see OptionsFromEnv)
+// which uses MD5 hashes for ETag checking.
+func NewServiceFromEnv() (*Service, error) {
+	opts, err := OptionsFromEnv()
+	if err != nil {
+		return nil, err
+	}
+
+	return NewService(opts, GetMD5Hash), nil
+}
+
+func stringEnv(p *string) func(string) error {
+	return func(value string) error {
+		*p = value
+		return nil
+	}
+}
+
+func uintEnv(p *uint) func(string) error {
+	return func(value string) error {
+		n, err := strconv.ParseUint(value, 10, 0)
+		*p = uint(n)
+		return err
+	}
+}
+
+func int64Env(p *int64) func(string) error {
+	return func(value string) (err error) {
+		*p, err = strconv.ParseInt(value, 10, 64)
+		return err
+	}
+}
+
+func boolEnv(p *bool) func(string) error {
+	return func(value string) (err error) {
+		*p, err = strconv.ParseBool(value)
+		return err
+	}
+}
+
+func durationEnv(p *time.Duration) func(string) error {
+	return func(value string) (err error) {
+		*p, err = time.ParseDuration(value)
+		return err
+	}
+}
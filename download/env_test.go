@@ -0,0 +1,137 @@
+package download_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/gkatanacio/multisource-downloader/download"
+)
+
+// envVars are cleared before each test so that the environment of the test process does not leak in.
+var envVars = []string{
+	"MSDL_DEST_FILE", "MSDL_CONNECTIONS", "MSDL_TIMEOUT", "MSDL_CHECK_ETAG", "MSDL_PROXY", "MSDL_USER_AGENT",
+	"MSDL_MAX_RETRIES", "MSDL_CHUNK_BYTES", "MSDL_CHUNK_STALL_TIMEOUT", "MSDL_ALLOW_PARTIAL_SOURCES",
+	"MSDL_HASH_FILE_ALGORITHM", "MSDL_QUIET", "MSDL_VERBOSE",
+}
+
+func clearEnv(t *testing.T) {
+	for _, name := range envVars {
+		t.Setenv(name, "")
+	}
+}
+
+func Test_OptionsFromEnv(t *testing.T) {
+	testCases := map[string]struct {
+		env         map[string]string
+		expected    download.Options
+		specificErr error
+	}{
+		"defaults": {
+			env: map[string]string{"MSDL_DEST_FILE": "/tmp/a.txt"},
+			expected: download.Options{
+				Connections:  5,
+				Timeout:      10,
+				DestFilePath: "/tmp/a.txt",
+			},
+		},
+		"all set": {
+			env: map[string]string{
+				"MSDL_DEST_FILE":             "/tmp/a.txt",
+				"MSDL_CONNECTIONS":           "8",
+				"MSDL_TIMEOUT":               "30",
+				"MSDL_CHECK_ETAG":            "true",
+				"MSDL_PROXY":                 "http://proxy.example.com:3128",
+				"MSDL_USER_AGENT":            "msdl/1.0",
+				"MSDL_MAX_RETRIES":           "3",
+				"MSDL_CHUNK_BYTES":           "1048576",
+				"MSDL_CHUNK_STALL_TIMEOUT":   "15s",
+				"MSDL_ALLOW_PARTIAL_SOURCES": "1",
+				"MSDL_HASH_FILE_ALGORITHM":   "sha512",
+				"MSDL_QUIET":                 "true",
+				"MSDL_VERBOSE":               "false",
+			},
+			expected: download.Options{
+				Connections:         8,
+				Timeout:             30,
+				CheckETag:           true,
+				DestFilePath:        "/tmp/a.txt",
+				Proxy:               "http://proxy.example.com:3128",
+				UserAgent:           "msdl/1.0",
+				HeadRetryAttempts:   3,
+				ChunkBytes:          1048576,
+				ChunkStallTimeout:   15 * time.Second,
+				AllowPartialSources: true,
+				HashFileAlgorithm:   "sha512",
+				Quiet:               true,
+			},
+		},
+		"empty values are ignored": {
+			env: map[string]string{
+				"MSDL_DEST_FILE":   "/tmp/a.txt",
+				"MSDL_CONNECTIONS": "",
+			},
+			expected: download.Options{
+				Connections:  5,
+				Timeout:      10,
+				DestFilePath: "/tmp/a.txt",
+			},
+		},
+		"missing dest file": {
+			env:         map[string]string{"MSDL_CONNECTIONS": "8"},
+			specificErr: download.ErrMissingEnvVar,
+		},
+		"invalid uint": {
+			env: map[string]string{
+				"MSDL_DEST_FILE":   "/tmp/a.txt",
+				"MSDL_CONNECTIONS": "-1",
+			},
+			specificErr: download.ErrInvalidEnvVar,
+		},
+		"invalid bool": {
+			env: map[string]string{
+				"MSDL_DEST_FILE":  "/tmp/a.txt",
+				"MSDL_CHECK_ETAG": "yes please",
+			},
+			specificErr: download.ErrInvalidEnvVar,
+		},
+		"invalid duration": {
+			env: map[string]string{
+				"MSDL_DEST_FILE":           "/tmp/a.txt",
+				"MSDL_CHUNK_STALL_TIMEOUT": "15",
+			},
+			specificErr: download.ErrInvalidEnvVar,
+		},
+	}
+
+	for scenario, tc := range testCases {
+		t.Run(scenario, func(t *testing.T) {
+			clearEnv(t)
+			for name, value := range tc.env {
+				t.Setenv(name, value)
+			}
+
+			opts, err := download.OptionsFromEnv()
+			if tc.specificErr != nil {
+				assert.ErrorIs(t, err, tc.specificErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, opts)
+		})
+	}
+}
+
+func Test_NewServiceFromEnv(t *testing.T) {
+	clearEnv(t)
+
+	_, err := download.NewServiceFromEnv()
+	assert.ErrorIs(t, err, download.ErrMissingEnvVar)
+
+	t.Setenv("MSDL_DEST_FILE", "/tmp/a.txt")
+
+	downloadService, err := download.NewServiceFromEnv()
+	assert.NoError(t, err)
+	assert.NotNil(t, downloadService)
+}
@@ -0,0 +1,148 @@
+package download
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// SourceAttempt represents a single attempt of downloading a chunk from a source.
+type SourceAttempt struct {
+	URL     string
+	Err     error
+	Attempt int
+}
+
+// ChunkError is returned when a chunk could not be downloaded from any of the sources.
+// It unwraps to ErrFailedChunkDownloadAllSources.
+type ChunkError struct {
+	ChunkIndex   int
+	Offset       int64
+	Size         int64
+	TriedSources []SourceAttempt
+}
+
+func (ce ChunkError) Error() string {
+	var attempts []string
+	for _, sa := range ce.TriedSources {
+		attempts = append(attempts, fmt.Sprintf("attempt %d from %s: %v", sa.Attempt, sa.URL, sa.Err))
+	}
+
+	return fmt.Sprintf("%v (chunk %d, offset %d, size %d): %s",
+		ErrFailedChunkDownloadAllSources, ce.ChunkIndex, ce.Offset, ce.Size, strings.Join(attempts, "; "))
+}
+
+func (ce ChunkError) Unwrap() error {
+	return ErrFailedChunkDownloadAllSources
+}
+
+// SourceError represents the failure of a single source.
+type SourceError struct {
+	URL string
+	Err error
+}
+
+func (se SourceError) Error() string {
+	return fmt.Sprintf("%s: %v", se.URL, se.Err)
+}
+
+func (se SourceError) Unwrap() error {
+	return se.Err
+}
+
+// MultiSourceError is returned when fetching the file metadata failed for all of the sources (or for
+// any of them unless AllowPartialSources is set). It lists every failed source and unwraps to their errors.
+type MultiSourceError struct {
+	Errors []SourceError
+}
+
+func (mse MultiSourceError) Error() string {
+	var failures []string
+	for _, se := range mse.Errors {
+		failures = append(failures, se.Error())
+	}
+
+	return fmt.Sprintf("%d source(s) failed: %s", len(mse.Errors), strings.Join(failures, "; "))
+}
+
+func (mse MultiSourceError) Unwrap() []error {
+	errs := make([]error, len(mse.Errors))
+	for i, se := range mse.Errors {
+		errs[i] = se
+	}
+
+	return errs
+}
+
+// PieceVerificationError is returned when a piece of the file still does not match its expected hash
+// after being downloaded again. It unwraps to ErrPieceHashMismatch.
+type PieceVerificationError struct {
+	PieceIndex int
+	Expected   string
+	Got        string
+}
+
+func (pve PieceVerificationError) Error() string {
+	return fmt.Sprintf("%v (piece %d): expected %s but got %s", ErrPieceHashMismatch, pve.PieceIndex, pve.Expected, pve.Got)
+}
+
+func (pve PieceVerificationError) Unwrap() error {
+	return ErrPieceHashMismatch
+}
+
+// FileTooLargeError is returned when the file exceeds MaxFileSize, where Size is -1 for a file of unknown
+// length whose download was aborted once more than Limit bytes were received. It unwraps to ErrFileTooLarge.
+type FileTooLargeError struct {
+	Size  int64
+	Limit int64
+}
+
+func (ftle FileTooLargeError) Error() string {
+	if ftle.Size == -1 {
+		return fmt.Sprintf("%v: more than %d bytes received", ErrFileTooLarge, ftle.Limit)
+	}
+	return fmt.Sprintf("%v: %d bytes exceed %d bytes", ErrFileTooLarge, ftle.Size, ftle.Limit)
+}
+
+func (ftle FileTooLargeError) Unwrap() error {
+	return ErrFileTooLarge
+}
+
+// unexpectedStatusError is returned when a source responds with an unexpected HTTP status code.
+type unexpectedStatusError struct {
+	statusCode int
+	url        string
+}
+
+func (use unexpectedStatusError) Error() string {
+	return fmt.Sprintf("received %d response from %s", use.statusCode, use.url)
+}
+
+// errorType returns a short classification of the error, suitable as a metric label.
+func errorType(err error) string {
+	var statusErr unexpectedStatusError
+	var netErr net.Error
+
+	switch {
+	case errors.As(err, &statusErr):
+		return fmt.Sprintf("status_%d", statusErr.statusCode)
+	case errors.Is(err, ErrContentRangeMismatch):
+		return "content_range_mismatch"
+	case errors.Is(err, ErrTruncatedResponse):
+		return "truncated_response"
+	case errors.Is(err, context.Canceled):
+		return "canceled"
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		return "timeout"
+	default:
+		return "other"
+	}
+}
+
+// truncatedResponseError returns an ErrTruncatedResponse error for a response body from the given URL
+// which does not have the expected size.
+func truncatedResponseError(url string, received int, expected int64) error {
+	return fmt.Errorf("%w: received %d of %d bytes from %s", ErrTruncatedResponse, received, expected, url)
+}
@@ -0,0 +1,376 @@
+package download
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gkatanacio/multisource-downloader/cache"
+)
+
+// Fetcher represents the transport used for retrieving file metadata and contents from sources.
+type Fetcher interface {
+	// Head returns the metadata of the file from the given source URL.
+	Head(ctx context.Context, url string) (*HeadResult, error)
+	// GetRange returns the bytes of the file from the given source URL within the range
+	// where start is inclusive and end is exclusive.
+	GetRange(ctx context.Context, url string, start, end int64) ([]byte, error)
+}
+
+// HeadResult represents the file metadata returned by a Fetcher.
+type HeadResult struct {
+	ContentLength int64 // -1 if unknown
+	ContentType   string
+	ETag          string
+	AcceptRanges  bool
+	MetalinkURL   string // of the metalink document advertised by the source (if any)
+}
+
+// RetryAfterError can be returned by a Fetcher when the source requested a delay before retrying.
+type RetryAfterError struct {
+	Delay time.Duration
+	Err   error
+}
+
+func (rae RetryAfterError) Error() string {
+	return fmt.Sprintf("%v (retry after %s)", rae.Err, rae.Delay)
+}
+
+func (rae RetryAfterError) Unwrap() error {
+	return rae.Err
+}
+
+// httpFetcher is the default Fetcher which uses HEAD and ranged GET requests.
+type httpFetcher struct {
+	client       *http.Client
+	rangeStyle   RangeStyle
+	ifNoneMatch  string
+	cache        *cache.DiskCache
+	headers      map[string]map[string]string // keyed by source URL
+	stallTimeout time.Duration                // for receiving the next bytes of a chunk (0 means none)
+	userAgent    string                       // overrides the default one of the client if set
+
+	acceptFullResponse bool             // extracts the range from 200 responses with the whole file
+	onFullResponse     func(url string) // called on the first such response of each source
+	fullResponseURLs   sync.Map         // of the sources which sent such a response
+}
+
+// Head implements Fetcher.
+func (hf *httpFetcher) Head(ctx context.Context, url string) (*HeadResult, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	hf.setSourceHeaders(req, url)
+
+	// the cache is bypassed for conditional downloads since ErrNotModified is expected on a match
+	// and for health probes since they are meant to reach the source
+	cacheKey := cache.Key(url, "")
+	var cached *cache.Entry
+	if len(hf.ifNoneMatch) == 0 && !bypassCacheFromContext(ctx) {
+		var fresh bool
+		if cached, fresh = hf.lookupCache(cacheKey); fresh {
+			return headResultFromCache(cached), nil
+		}
+	}
+
+	if len(hf.ifNoneMatch) > 0 {
+		req.Header.Set("If-None-Match", fmt.Sprintf(`"%s"`, hf.ifNoneMatch))
+	} else if cached != nil && len(cached.ETag) > 0 {
+		req.Header.Set("If-None-Match", fmt.Sprintf(`"%s"`, cached.ETag))
+	}
+
+	resp, err := hf.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified {
+		if cached != nil {
+			hf.revalidateCache(cacheKey, resp)
+			return headResultFromCache(cached), nil
+		}
+		return nil, fmt.Errorf("%w: %s", ErrNotModified, url)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, statusError(resp, url)
+	}
+
+	acceptRanges := resp.Header.Get("Accept-Ranges")
+
+	headResult := &HeadResult{
+		ContentLength: resp.ContentLength,
+		ContentType:   resp.Header.Get("Content-Type"),
+		ETag:          strings.Trim(resp.Header.Get("ETag"), `"`),
+		AcceptRanges:  len(acceptRanges) > 0 && acceptRanges != "none",
+		MetalinkURL:   metalinkURLFromHeader(resp.Request.URL, resp.Header.Values("Link")),
+	}
+
+	hf.storeCache(cacheKey, resp, &cache.Entry{
+		ETag:          headResult.ETag,
+		ContentType:   headResult.ContentType,
+		ContentLength: headResult.ContentLength,
+		AcceptRanges:  headResult.AcceptRanges,
+		MetalinkURL:   headResult.MetalinkURL,
+	})
+
+	return headResult, nil
+}
+
+// GetRange implements Fetcher.
+func (hf *httpFetcher) GetRange(ctx context.Context, url string, start, end int64) ([]byte, error) {
+	return hf.GetRangeInto(ctx, url, start, end, nil)
+}
+
+// GetRangeInto implements bufferedFetcher. The response body is read into the given buffer
+// unless it is nil.
+func (hf *httpFetcher) GetRangeInto(ctx context.Context, url string, start, end int64, buf []byte) ([]byte, error) {
+	var cancelStalled context.CancelCauseFunc
+	if hf.stallTimeout > 0 {
+		ctx, cancelStalled = context.WithCancelCause(ctx)
+		defer cancelStalled(nil)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	hf.setSourceHeaders(req, url)
+
+	rangeEnd := end - 1 // HTTP ranges are inclusive by default
+	if hf.rangeStyle == RangeStyleExclusive {
+		rangeEnd = end
+	}
+	req.Header.Set("Range", FormatRangeHeader(start, rangeEnd))
+
+	// the cache is bypassed when resuming since the chunks must come from the same version of the file
+	cacheKey := cache.Key(url, req.Header.Get("Range"))
+	var cached *cache.Entry
+	ifRange, resuming := ifRangeFromContext(ctx)
+	if resuming {
+		req.Header.Set("If-Range", fmt.Sprintf(`"%s"`, ifRange))
+	} else {
+		var fresh bool
+		if cached, fresh = hf.lookupCache(cacheKey); fresh && cached.Body != nil {
+			return cached.Body, nil
+		}
+		if cached != nil && cached.Body != nil && len(cached.ETag) > 0 {
+			req.Header.Set("If-None-Match", fmt.Sprintf(`"%s"`, cached.ETag))
+		}
+	}
+
+	resp, err := hf.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified && cached != nil && cached.Body != nil {
+		hf.revalidateCache(cacheKey, resp)
+		return cached.Body, nil
+	}
+
+	// the whole file is sent instead of the range if it no longer matches the If-Range ETag
+	if resuming && resp.StatusCode == http.StatusOK {
+		return nil, fmt.Errorf("%w: %s", ErrResourceChangedDuringResume, url)
+	}
+
+	fullResponse := resp.StatusCode == http.StatusOK && hf.acceptFullResponse
+	if resp.StatusCode != http.StatusPartialContent && !fullResponse {
+		return nil, statusError(resp, url)
+	}
+
+	if !fullResponse {
+		// guard against buggy servers returning a different range than what was requested
+		respRangeStart, respRangeEnd, err := parseContentRange(resp.Header.Get("Content-Range"))
+		if err != nil || respRangeStart != start || respRangeEnd != rangeEnd {
+			return nil, fmt.Errorf("%w: requested %d-%d from %s", ErrContentRangeMismatch, start, rangeEnd, url)
+		}
+	}
+
+	var bodyReader io.Reader = resp.Body
+	if cancelStalled != nil {
+		stallDetector := newStallDetector(resp.Body, hf.stallTimeout, cancelStalled)
+		defer stallDetector.stop()
+		bodyReader = stallDetector
+	}
+
+	if fullResponse {
+		hf.warnFullResponse(url)
+
+		// the range is extracted from the whole file (without reading beyond it)
+		if _, err := io.CopyN(io.Discard, bodyReader, start); errors.Is(err, io.EOF) {
+			return nil, truncatedResponseError(url, 0, end-start)
+		} else if err != nil {
+			return nil, err
+		}
+		bodyReader = io.LimitReader(bodyReader, end-start)
+	}
+
+	body, err := readBody(bodyReader, buf)
+	if err != nil && errors.Is(context.Cause(ctx), ErrChunkStalled) {
+		return nil, fmt.Errorf("%w: no bytes received from %s within %s", ErrChunkStalled, url, hf.stallTimeout)
+	}
+	if errors.Is(err, io.ErrUnexpectedEOF) {
+		return nil, truncatedResponseError(url, len(body), end-start)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// checked here as well so that truncated responses are never cached
+	if int64(len(body)) != end-start {
+		return nil, truncatedResponseError(url, len(body), end-start)
+	}
+
+	hf.storeCache(cacheKey, resp, &cache.Entry{
+		ETag: strings.Trim(resp.Header.Get("ETag"), `"`),
+		Body: body,
+	})
+
+	return body, nil
+}
+
+// warnFullResponse calls onFullResponse if the source with the given URL did not send the whole file before.
+func (hf *httpFetcher) warnFullResponse(url string) {
+	if _, warned := hf.fullResponseURLs.LoadOrStore(url, true); !warned && hf.onFullResponse != nil {
+		hf.onFullResponse(url)
+	}
+}
+
+// setSourceHeaders sets the User-Agent and the custom headers of the source with the given URL on the
+// request (before the headers set by the fetcher itself, like Range, which therefore take precedence).
+func (hf *httpFetcher) setSourceHeaders(req *http.Request, url string) {
+	setSourceHeaders(req, url, hf.userAgent, hf.headers)
+}
+
+// setSourceHeaders is the same as httpFetcher.setSourceHeaders but for requests made by the service
+// outside of the fetcher (e.g., for index pages).
+func (s *Service) setSourceHeaders(req *http.Request, url string) {
+	setSourceHeaders(req, url, s.opts.UserAgent, s.opts.PerSourceHeaders)
+}
+
+// setSourceHeaders sets the given User-Agent (if any) and the headers of the given URL on the request.
+func setSourceHeaders(req *http.Request, url, userAgent string, headers map[string]map[string]string) {
+	if len(userAgent) > 0 {
+		req.Header.Set("User-Agent", userAgent)
+	}
+	for key, value := range headers[url] {
+		req.Header.Set(key, value)
+	}
+}
+
+// ifRangeKey is the context key for the ETag sent as If-Range when resuming a download.
+type ifRangeKey struct{}
+
+// withIfRange returns a context making httpFetcher send the given ETag as If-Range so that
+// a file which changed since the download was interrupted is detected at the chunk level.
+func withIfRange(ctx context.Context, eTag string) context.Context {
+	return context.WithValue(ctx, ifRangeKey{}, eTag)
+}
+
+// ifRangeFromContext returns the ETag set by withIfRange if any.
+func ifRangeFromContext(ctx context.Context) (string, bool) {
+	eTag, ok := ctx.Value(ifRangeKey{}).(string)
+	return eTag, ok
+}
+
+// bypassCacheKey is the context key for bypassing the response cache.
+type bypassCacheKey struct{}
+
+// withBypassCache returns a context making httpFetcher send HEAD requests to the source even if
+// a fresh response is cached, e.g., for probing the health of the source.
+func withBypassCache(ctx context.Context) context.Context {
+	return context.WithValue(ctx, bypassCacheKey{}, true)
+}
+
+// bypassCacheFromContext returns true if the context was returned by withBypassCache.
+func bypassCacheFromContext(ctx context.Context) bool {
+	bypass, _ := ctx.Value(bypassCacheKey{}).(bool)
+	return bypass
+}
+
+// readBody reads the whole body into the given buffer (which must be exactly the size of the
+// expected body) or into a new byte slice if there is no buffer.
+func readBody(body io.Reader, buf []byte) ([]byte, error) {
+	if buf == nil {
+		return io.ReadAll(body)
+	}
+
+	n, err := io.ReadFull(body, buf)
+	if errors.Is(err, io.EOF) {
+		err = io.ErrUnexpectedEOF // no bytes at all is still a truncated body
+	}
+
+	return buf[:n], err
+}
+
+// statusError returns the error for the unexpected status of the given response which is
+// a RetryAfterError if the source requested a delay before retrying.
+func statusError(resp *http.Response, url string) error {
+	err := unexpectedStatusError{statusCode: resp.StatusCode, url: url}
+
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable {
+		if retryAfter, ok := parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()); ok {
+			return RetryAfterError{Delay: retryAfter, Err: err}
+		}
+	}
+
+	return err
+}
+
+// lookupCache returns the cached entry for the given key (nil if there is none or caching is
+// disabled) along with whether it is still fresh. The cache is best-effort so errors are ignored.
+func (hf *httpFetcher) lookupCache(key string) (*cache.Entry, bool) {
+	if hf.cache == nil {
+		return nil, false
+	}
+
+	entry, fresh, err := hf.cache.Lookup(key)
+	if err != nil {
+		return nil, false
+	}
+
+	return entry, fresh
+}
+
+// storeCache stores the entry for the given key if the response allows caching based on its
+// Cache-Control header.
+func (hf *httpFetcher) storeCache(key string, resp *http.Response, entry *cache.Entry) {
+	if hf.cache == nil {
+		return
+	}
+
+	maxAge, ok := cache.MaxAge(resp.Header.Get("Cache-Control"))
+	if !ok {
+		return
+	}
+
+	entry.MaxAge = maxAge
+	hf.cache.Put(key, entry) // best-effort
+}
+
+// revalidateCache refreshes the entry for the given key after the server confirmed
+// it is unchanged (i.e., 304 Not Modified).
+func (hf *httpFetcher) revalidateCache(key string, resp *http.Response) {
+	maxAge, _ := cache.MaxAge(resp.Header.Get("Cache-Control")) // stale again right away if none
+	hf.cache.Touch(key, maxAge)                                 // best-effort
+}
+
+// headResultFromCache returns the HeadResult of the given cached HEAD response.
+func headResultFromCache(entry *cache.Entry) *HeadResult {
+	return &HeadResult{
+		ContentLength: entry.ContentLength,
+		ContentType:   entry.ContentType,
+		ETag:          entry.ETag,
+		AcceptRanges:  entry.AcceptRanges,
+		MetalinkURL:   entry.MetalinkURL,
+	}
+}
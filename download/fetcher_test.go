@@ -0,0 +1,93 @@
+package download_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/gkatanacio/multisource-downloader/download"
+)
+
+// mockFetcher serves canned content for any URL and records the calls made to it.
+type mockFetcher struct {
+	content []byte
+	eTag    string
+
+	mu    sync.Mutex
+	calls []string
+}
+
+func (mf *mockFetcher) Head(ctx context.Context, url string) (*download.HeadResult, error) {
+	mf.record(fmt.Sprintf("HEAD %s", url))
+
+	return &download.HeadResult{
+		ContentLength: int64(len(mf.content)),
+		ContentType:   "text/plain",
+		ETag:          mf.eTag,
+		AcceptRanges:  true,
+	}, nil
+}
+
+func (mf *mockFetcher) GetRange(ctx context.Context, url string, start, end int64) ([]byte, error) {
+	mf.record(fmt.Sprintf("GET %s %d-%d", url, start, end))
+
+	return mf.content[start:end], nil
+}
+
+func (mf *mockFetcher) record(call string) {
+	mf.mu.Lock()
+	defer mf.mu.Unlock()
+
+	mf.calls = append(mf.calls, call)
+}
+
+// recordedCalls returns the calls made so far in sorted order.
+func (mf *mockFetcher) recordedCalls() []string {
+	mf.mu.Lock()
+	defer mf.mu.Unlock()
+
+	calls := append([]string(nil), mf.calls...)
+	sort.Strings(calls)
+
+	return calls
+}
+
+func Test_Service_Download_CustomFetcher(t *testing.T) {
+	fetcher := &mockFetcher{
+		content: []byte("0123456789"),
+		eTag:    "781e5e245d69b566979b86e28d23f2c7", // MD5 of content
+	}
+
+	destFilePath := filepath.Join(t.TempDir(), "digits.txt")
+	downloadService := download.NewService(download.Options{
+		Connections:  2,
+		CheckETag:    true,
+		Quiet:        true,
+		DestFilePath: destFilePath,
+		Fetcher:      fetcher,
+	}, download.GetMD5Hash)
+
+	err := downloadService.Download([]string{"mock://source1/digits.txt", "mock://source2/digits.txt"})
+	assert.NoError(t, err)
+
+	calls := fetcher.recordedCalls()
+	assert.Len(t, calls, 4)
+	assert.Contains(t, calls, "HEAD mock://source1/digits.txt")
+	assert.Contains(t, calls, "HEAD mock://source2/digits.txt")
+	assert.Subset(t, []string{
+		"GET mock://source1/digits.txt 0-5",
+		"GET mock://source2/digits.txt 0-5",
+		"GET mock://source1/digits.txt 5-10",
+		"GET mock://source2/digits.txt 5-10",
+	}, calls[:2])
+
+	downloaded, err := os.ReadFile(destFilePath)
+	assert.NoError(t, err)
+	assert.Equal(t, fetcher.content, downloaded)
+}
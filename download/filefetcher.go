@@ -0,0 +1,135 @@
+package download
+
+import (
+	"context"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+)
+
+// schemeFile is the URL scheme of sources which are local files.
+const schemeFile = "file"
+
+// fileFetcher is the Fetcher for local files referred to by `file://` URLs.
+type fileFetcher struct{}
+
+// Head implements Fetcher. The ETag is the MD5 hash of the file since local files have none.
+func (ff fileFetcher) Head(ctx context.Context, sourceUrl string) (*HeadResult, error) {
+	file, err := openFileURL(sourceUrl)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	fileInfo, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	eTag, err := GetMD5Hash(file)
+	if err != nil {
+		return nil, err
+	}
+
+	contentType, err := detectFileContentType(file)
+	if err != nil {
+		return nil, err
+	}
+
+	return &HeadResult{
+		ContentLength: fileInfo.Size(),
+		ContentType:   contentType,
+		ETag:          eTag,
+		AcceptRanges:  true,
+	}, nil
+}
+
+// GetRange implements Fetcher.
+func (ff fileFetcher) GetRange(ctx context.Context, sourceUrl string, start, end int64) ([]byte, error) {
+	return ff.GetRangeInto(ctx, sourceUrl, start, end, make([]byte, end-start))
+}
+
+// GetRangeInto implements bufferedFetcher.
+func (ff fileFetcher) GetRangeInto(ctx context.Context, sourceUrl string, start, end int64, buf []byte) ([]byte, error) {
+	file, err := openFileURL(sourceUrl)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	n, err := io.ReadFull(io.NewSectionReader(file, start, end-start), buf)
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return nil, truncatedResponseError(sourceUrl, n, end-start)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return buf, nil
+}
+
+// openFileURL opens the local file referred to by the given `file://` URL.
+func openFileURL(sourceUrl string) (*os.File, error) {
+	u, err := url.Parse(sourceUrl)
+	if err != nil {
+		return nil, err
+	}
+
+	return os.Open(filepath.FromSlash(u.Path))
+}
+
+// detectFileContentType returns the content type of the file the same way as http.ServeContent
+// does so that local files match the same files served over HTTP.
+func detectFileContentType(file *os.File) (string, error) {
+	if contentType := mime.TypeByExtension(filepath.Ext(file.Name())); len(contentType) > 0 {
+		return contentType, nil
+	}
+
+	buf := make([]byte, 512)
+	n, err := file.ReadAt(buf, 0)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", err
+	}
+
+	return http.DetectContentType(buf[:n]), nil
+}
+
+// schemeFetcher dispatches to the Fetcher registered for the scheme of each source URL (see
+// RegisterScheme) and falls back to the default Fetcher for any other scheme.
+type schemeFetcher struct {
+	fetchers map[string]Fetcher
+	fallback Fetcher
+}
+
+// Head implements Fetcher.
+func (sf *schemeFetcher) Head(ctx context.Context, sourceUrl string) (*HeadResult, error) {
+	return sf.fetcherFor(sourceUrl).Head(ctx, sourceUrl)
+}
+
+// GetRange implements Fetcher.
+func (sf *schemeFetcher) GetRange(ctx context.Context, sourceUrl string, start, end int64) ([]byte, error) {
+	return sf.fetcherFor(sourceUrl).GetRange(ctx, sourceUrl, start, end)
+}
+
+// GetRangeInto implements bufferedFetcher.
+func (sf *schemeFetcher) GetRangeInto(ctx context.Context, sourceUrl string, start, end int64, buf []byte) ([]byte, error) {
+	return getRangeInto(ctx, sf.fetcherFor(sourceUrl), sourceUrl, start, end, buf)
+}
+
+// fetcherFor returns the Fetcher for the scheme of the given source URL.
+func (sf *schemeFetcher) fetcherFor(sourceUrl string) Fetcher {
+	u, err := url.Parse(sourceUrl)
+	if err != nil {
+		return sf.fallback // let the default Fetcher report the invalid URL
+	}
+
+	if fetcher, ok := sf.fetchers[u.Scheme]; ok {
+		return fetcher
+	}
+
+	return sf.fallback
+}
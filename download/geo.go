@@ -0,0 +1,61 @@
+package download
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+
+	"github.com/gkatanacio/multisource-downloader/geo"
+)
+
+// newGeoScorer returns the scorer for the distance to the sources based on the GeoIP database
+// in the options. A nil scorer (i.e., no geo scoring) is returned if there is no database.
+func newGeoScorer(opts Options) *geo.Scorer {
+	if len(opts.GeoDBPath) == 0 {
+		return nil
+	}
+
+	db, err := geo.OpenDatabase(opts.GeoDBPath)
+	if err != nil {
+		printErr(fmt.Errorf("geo scoring disabled: %w", err))
+		return nil
+	}
+
+	localIP := net.ParseIP(opts.GeoLocalIP)
+	if localIP == nil {
+		if localIP, err = geo.LocalIP(); err != nil {
+			printErr(fmt.Errorf("geo scoring disabled: %w", err))
+			return nil
+		}
+	}
+
+	scorer, err := geo.NewScorer(db, localIP)
+	if err != nil {
+		printErr(fmt.Errorf("geo scoring disabled: %w", err))
+		return nil
+	}
+
+	return scorer
+}
+
+// sourceDistanceKm returns the distance in km from the local host to the host of the given source
+// URL or -1 if it is unknown (e.g., no geo scoring or a local file source).
+func (s *Service) sourceDistanceKm(ctx context.Context, sourceUrl string) float64 {
+	if s.geoScorer == nil {
+		return -1
+	}
+
+	u, err := url.Parse(sourceUrl)
+	if err != nil || len(u.Hostname()) == 0 {
+		return -1
+	}
+
+	distanceKm, err := s.geoScorer.DistanceKm(ctx, u.Hostname())
+	if err != nil {
+		s.logln(fmt.Sprintf("warning: unknown distance to %s: %v", sourceUrl, err))
+		return -1
+	}
+
+	return distanceKm
+}
@@ -0,0 +1,162 @@
+package download
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const defaultHealthCheckTimeout = 5 * time.Second
+
+// sourceHealthRegistry keeps track of which sources are currently considered unhealthy.
+type sourceHealthRegistry struct {
+	mu        sync.RWMutex
+	unhealthy map[string]bool
+}
+
+func newSourceHealthRegistry() *sourceHealthRegistry {
+	return &sourceHealthRegistry{
+		unhealthy: make(map[string]bool),
+	}
+}
+
+// isHealthy returns true unless the source has been marked as unhealthy.
+func (shr *sourceHealthRegistry) isHealthy(url string) bool {
+	shr.mu.RLock()
+	defer shr.mu.RUnlock()
+
+	return !shr.unhealthy[url]
+}
+
+// setHealthy marks the source as healthy or unhealthy and returns true if this changed its status.
+func (shr *sourceHealthRegistry) setHealthy(url string, healthy bool) bool {
+	shr.mu.Lock()
+	defer shr.mu.Unlock()
+
+	changed := shr.unhealthy[url] == healthy
+	shr.unhealthy[url] = !healthy
+
+	return changed
+}
+
+// pickSource returns the source at the preferred index if it is healthy. Otherwise, the next
+// healthy source (wrapping around) is returned. If no source is healthy, the preferred index is
+// returned as there is nothing better to choose from.
+func (shr *sourceHealthRegistry) pickSource(sourceUrls []string, preferredIdx int) int {
+	for k := 0; k < len(sourceUrls); k++ {
+		idx := (preferredIdx + k) % len(sourceUrls)
+		if shr.isHealthy(sourceUrls[idx]) {
+			return idx
+		}
+	}
+
+	return preferredIdx
+}
+
+// healthySources returns the healthy sources (in the same order) or all of them if none is healthy.
+func (shr *sourceHealthRegistry) healthySources(sourceUrls []string) []string {
+	var healthy []string
+	for _, url := range sourceUrls {
+		if shr.isHealthy(url) {
+			healthy = append(healthy, url)
+		}
+	}
+
+	if len(healthy) == 0 {
+		return sourceUrls
+	}
+	return healthy
+}
+
+// monitorSourceHealth periodically probes the sources (using HEAD requests bypassing the Cache) at the
+// configured interval and updates the registry accordingly until the context is done.
+func (s *Service) monitorSourceHealth(ctx context.Context, sourceUrls []string, registry *sourceHealthRegistry) {
+	ticker := time.NewTicker(s.opts.SourceRecheckInterval)
+	defer ticker.Stop()
+
+	ctx = withBypassCache(ctx) // a cached response tells nothing about the current health
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		var wg sync.WaitGroup
+		for _, url := range sourceUrls {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+
+				_, err := s.fetchFileMetadata(ctx, url)
+				if ctx.Err() != nil {
+					return // errors due to the download being done are not the source's fault
+				}
+
+				if registry.setHealthy(url, err == nil) {
+					if err != nil {
+						printErr(fmt.Errorf("source %s became unhealthy: %w", url, err))
+					} else {
+						s.logln(fmt.Sprintf("source %s recovered", url))
+					}
+				}
+			}()
+		}
+		wg.Wait()
+	}
+}
+
+// HealthCheck probes the given sources concurrently (like ValidateSources but bypassing the Cache)
+// within HealthCheckTimeout (defaults to 5 seconds) regardless of the download timeout. This returns an
+// error wrapping both ErrSourcesUnhealthy and a MultiSourceError if more than half of the sources are
+// unhealthy.
+func (s *Service) HealthCheck(ctx context.Context, sourceUrls []string) error {
+	if len(sourceUrls) == 0 {
+		return ErrNoSourceUrls
+	}
+
+	ctx, cancel := context.WithTimeout(withBypassCache(ctx), s.healthCheckTimeout())
+	defer cancel()
+
+	var multiErr MultiSourceError
+	for _, info := range s.ValidateSources(ctx, sourceUrls) {
+		if !info.Healthy() {
+			multiErr.Errors = append(multiErr.Errors, SourceError{URL: info.URL, Err: info.Err})
+		}
+	}
+
+	if 2*len(multiErr.Errors) > len(sourceUrls) {
+		return fmt.Errorf("%w: %w", ErrSourcesUnhealthy, multiErr)
+	}
+
+	return nil
+}
+
+// healthCheckTimeout returns the configured HealthCheckTimeout or its default.
+func (s *Service) healthCheckTimeout() time.Duration {
+	if s.opts.HealthCheckTimeout > 0 {
+		return s.opts.HealthCheckTimeout
+	}
+
+	return defaultHealthCheckTimeout
+}
+
+// HealthHandler returns an HTTP handler (e.g., for Kubernetes liveness or readiness probes) which
+// responds with 200 OK if the HealthCheck of the given sources passes or 503 Service Unavailable
+// (along with the error) otherwise.
+func HealthHandler(svc *Service, sourceUrls []string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+
+		if err := svc.HealthCheck(r.Context(), sourceUrls); err != nil {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			fmt.Fprintln(w, err)
+			return
+		}
+
+		fmt.Fprintln(w, "ok")
+	})
+}
@@ -0,0 +1,179 @@
+package download
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/gkatanacio/multisource-downloader/cache"
+)
+
+func Test_Service_monitorSourceHealth(t *testing.T) {
+	content := []byte("0123456789")
+
+	var srv2Down atomic.Bool
+	newSrv := func(down *atomic.Bool) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if down != nil && down.Load() {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			http.ServeContent(w, r, "digits.txt", time.Time{}, bytes.NewReader(content))
+		}))
+	}
+	srv1 := newSrv(nil)
+	defer srv1.Close()
+	srv2 := newSrv(&srv2Down)
+	defer srv2.Close()
+
+	sourceUrls := []string{srv1.URL, srv2.URL}
+	registry := newSourceHealthRegistry()
+	s := NewService(Options{Timeout: 3, Quiet: true, SourceRecheckInterval: 10 * time.Millisecond}, nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go s.monitorSourceHealth(ctx, sourceUrls, registry)
+
+	assert.Equal(t, 1, registry.pickSource(sourceUrls, 1))
+
+	srv2Down.Store(true)
+	assert.Eventually(t, func() bool { return !registry.isHealthy(srv2.URL) }, time.Second, 10*time.Millisecond)
+	assert.Equal(t, 0, registry.pickSource(sourceUrls, 1), "unhealthy source should be skipped")
+
+	srv2Down.Store(false)
+	assert.Eventually(t, func() bool { return registry.isHealthy(srv2.URL) }, time.Second, 10*time.Millisecond)
+	assert.Equal(t, 1, registry.pickSource(sourceUrls, 1), "recovered source should be re-admitted")
+}
+
+func Test_Service_HealthProbes_BypassCache(t *testing.T) {
+	content := []byte("0123456789")
+
+	var down atomic.Bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if down.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Cache-Control", "max-age=3600")
+		w.Header().Set("ETag", `"abc"`)
+		http.ServeContent(w, r, "digits.txt", time.Time{}, bytes.NewReader(content))
+	}))
+	defer srv.Close()
+
+	sourceUrls := []string{srv.URL}
+	s := NewService(Options{Timeout: 3, Quiet: true, SourceRecheckInterval: 10 * time.Millisecond, Cache: cache.NewDiskCache(t.TempDir())}, nil)
+
+	// the cached response is still fresh once the source goes down
+	_, err := s.fetchFileMetadata(context.Background(), srv.URL)
+	assert.NoError(t, err)
+	down.Store(true)
+	_, err = s.fetchFileMetadata(context.Background(), srv.URL)
+	assert.NoError(t, err)
+
+	assert.ErrorIs(t, s.HealthCheck(context.Background(), sourceUrls), ErrSourcesUnhealthy)
+
+	registry := newSourceHealthRegistry()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go s.monitorSourceHealth(ctx, sourceUrls, registry)
+
+	assert.Eventually(t, func() bool { return !registry.isHealthy(srv.URL) }, time.Second, 10*time.Millisecond)
+}
+
+func Test_sourceHealthRegistry_pickSource_NoneHealthy(t *testing.T) {
+	sourceUrls := []string{"http://source1.com", "http://source2.com"}
+	registry := newSourceHealthRegistry()
+	registry.setHealthy(sourceUrls[0], false)
+	registry.setHealthy(sourceUrls[1], false)
+
+	assert.Equal(t, 1, registry.pickSource(sourceUrls, 1))
+}
+
+func Test_HealthHandler(t *testing.T) {
+	content := []byte("0123456789")
+
+	newSrv := func(status int, delay time.Duration) string {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			select {
+			case <-r.Context().Done():
+				return
+			case <-time.After(delay):
+			}
+			if status != http.StatusOK {
+				w.WriteHeader(status)
+				return
+			}
+			http.ServeContent(w, r, "digits.txt", time.Time{}, bytes.NewReader(content))
+		}))
+		t.Cleanup(srv.Close)
+		return srv.URL + "/digits.txt"
+	}
+
+	healthy := newSrv(http.StatusOK, 0)
+	failing := newSrv(http.StatusInternalServerError, 0)
+	slow := newSrv(http.StatusOK, 5*time.Second)
+
+	testCases := map[string]struct {
+		sourceUrls     []string
+		expectedStatus int
+	}{
+		"all healthy": {
+			sourceUrls:     []string{healthy, healthy, healthy},
+			expectedStatus: http.StatusOK,
+		},
+		"minority unhealthy": {
+			sourceUrls:     []string{healthy, failing, healthy},
+			expectedStatus: http.StatusOK,
+		},
+		"half unhealthy": {
+			sourceUrls:     []string{healthy, failing},
+			expectedStatus: http.StatusOK,
+		},
+		"majority unhealthy": {
+			sourceUrls:     []string{failing, healthy, failing},
+			expectedStatus: http.StatusServiceUnavailable,
+		},
+		"majority slower than health check timeout": {
+			sourceUrls:     []string{slow, slow, healthy},
+			expectedStatus: http.StatusServiceUnavailable,
+		},
+		"no sources": {
+			expectedStatus: http.StatusServiceUnavailable,
+		},
+	}
+
+	downloadService := NewService(Options{
+		Timeout:            10,
+		Quiet:              true,
+		HealthCheckTimeout: 200 * time.Millisecond,
+	}, nil)
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			start := time.Now()
+			HealthHandler(downloadService, tc.sourceUrls).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+
+			assert.Equal(t, tc.expectedStatus, rec.Code, rec.Body.String())
+			assert.Less(t, time.Since(start), 2*time.Second, "should be bounded by the health check timeout")
+		})
+	}
+}
+
+func Test_Service_HealthCheck_Error(t *testing.T) {
+	downloadService := NewService(Options{Timeout: 3, Quiet: true}, nil)
+
+	err := downloadService.HealthCheck(context.Background(), []string{"http://127.0.0.1:1/a.txt", "not a url"})
+
+	assert.ErrorIs(t, err, ErrSourcesUnhealthy)
+	var multiErr MultiSourceError
+	if assert.ErrorAs(t, err, &multiErr) {
+		assert.Len(t, multiErr.Errors, 2)
+	}
+}
@@ -1,21 +1,40 @@
 package download
 
 import (
+	"bufio"
 	"crypto/md5"
+	"crypto/sha256"
+	"crypto/sha512"
+	"errors"
 	"fmt"
+	"hash"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"net/url"
 	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"slices"
 	"sort"
+	"strconv"
+	"strings"
+	"time"
 )
 
 // allSourcesMatchFileMetadata returns false if there is a mismatch in the file metadata
 // across the sources. The checkETag parameter can be used to optionally consider the ETag
-// consistency across the sources as well.
-func allSourcesMatchFileMetadata(srcFileMetas []sourceFileMetadata, checkETag bool) bool {
+// consistency across the sources as well. The content types are compared as is if
+// strictContentType is true and only by their media types otherwise.
+func allSourcesMatchFileMetadata(srcFileMetas []sourceFileMetadata, checkETag, strictContentType bool) bool {
 	for i := 1; i < len(srcFileMetas); i++ {
 		sfmA := srcFileMetas[i-1]
 		sfmB := srcFileMetas[i]
 
-		if sfmA.size != sfmB.size || sfmA.contentType != sfmB.contentType {
+		if sfmA.size != sfmB.size || !contentTypesMatch(sfmA.contentType, sfmB.contentType, strictContentType) {
 			return false
 		}
 
@@ -27,15 +46,65 @@ func allSourcesMatchFileMetadata(srcFileMetas []sourceFileMetadata, checkETag bo
 	return true
 }
 
+// contentTypesMatch returns true if the content types are equal or, unless strict, if their media
+// types are equal regardless of parameters such as charset (e.g., `text/plain; charset=utf-8`
+// matches `text/plain`). Unparsable content types are compared as is.
+func contentTypesMatch(contentTypeA, contentTypeB string, strict bool) bool {
+	if strict || contentTypeA == contentTypeB {
+		return contentTypeA == contentTypeB
+	}
+
+	mediaTypeA, _, errA := mime.ParseMediaType(contentTypeA)
+	mediaTypeB, _, errB := mime.ParseMediaType(contentTypeB)
+	if errA != nil || errB != nil {
+		return false
+	}
+
+	return mediaTypeA == mediaTypeB
+}
+
 // sourceUrlsSortedByEstLatency returns the source URLs sorted by the estimated latency
-// of the sources in ascending order.
+// of the sources in ascending order. If the distance to every source is known, the latency
+// and the distance (each relative to the maximum among the sources) are blended 50/50 instead.
+// If the throughput of any source was measured (see BenchmarkBeforeDownload), the sources are
+// sorted by their throughput in descending order instead.
 func sourceUrlsSortedByEstLatency(srcFileMetas []sourceFileMetadata) []string {
 	// just to avoid parameter mutation
 	srcFileMetasCopy := make([]sourceFileMetadata, len(srcFileMetas))
 	copy(srcFileMetasCopy, srcFileMetas)
 
-	sort.Slice(srcFileMetasCopy, func(i, j int) bool {
-		return srcFileMetasCopy[i].estLatency < srcFileMetasCopy[j].estLatency
+	score := func(sfm sourceFileMetadata) float64 {
+		return float64(sfm.estLatency)
+	}
+
+	var maxLatency time.Duration
+	var maxDistanceKm float64
+	allDistancesKnown := true
+	for _, sfm := range srcFileMetasCopy {
+		maxLatency = max(maxLatency, sfm.estLatency)
+		maxDistanceKm = max(maxDistanceKm, sfm.distanceKm)
+		allDistancesKnown = allDistancesKnown && sfm.distanceKm >= 0
+	}
+
+	if allDistancesKnown && maxDistanceKm > 0 {
+		score = func(sfm sourceFileMetadata) float64 {
+			relLatency := 0.0
+			if maxLatency > 0 {
+				relLatency = float64(sfm.estLatency) / float64(maxLatency)
+			}
+
+			return 0.5*relLatency + 0.5*sfm.distanceKm/maxDistanceKm
+		}
+	}
+
+	if slices.ContainsFunc(srcFileMetasCopy, func(sfm sourceFileMetadata) bool { return sfm.bytesPerSec > 0 }) {
+		score = func(sfm sourceFileMetadata) float64 {
+			return -sfm.bytesPerSec
+		}
+	}
+
+	sort.SliceStable(srcFileMetasCopy, func(i, j int) bool {
+		return score(srcFileMetasCopy[i]) < score(srcFileMetasCopy[j])
 	})
 
 	var sourceUrls []string
@@ -46,6 +115,217 @@ func sourceUrlsSortedByEstLatency(srcFileMetas []sourceFileMetadata) []string {
 	return sourceUrls
 }
 
+// FormatRangeHeader returns the Range header value (i.e., `bytes=start-end`) requesting the bytes from
+// the given start offset up to and including the given end offset (e.g., `bytes=0-99` for the first
+// 100 bytes or `bytes=900-999` for the last 100 bytes of a 1000-byte file).
+func FormatRangeHeader(start, end int64) string {
+	return fmt.Sprintf("bytes=%d-%d", start, end)
+}
+
+// ParseRangeHeader extracts the start and (inclusive) end offsets from a Range header value of the form
+// `bytes=start-end` like the ones sent by the download service (see FormatRangeHeader), e.g., for test
+// servers. Open-ended, suffix and multiple ranges are not supported and result in ErrInvalidRangeHeader,
+// as do negative offsets and an end before the start.
+func ParseRangeHeader(header string) (start, end int64, err error) {
+	spec, ok := strings.CutPrefix(header, "bytes=")
+	if !ok {
+		return 0, 0, fmt.Errorf("%w: %q has no bytes unit", ErrInvalidRangeHeader, header)
+	}
+
+	startStr, endStr, ok := strings.Cut(spec, "-")
+	if !ok {
+		return 0, 0, fmt.Errorf("%w: %q has no range", ErrInvalidRangeHeader, header)
+	}
+
+	// ParseUint rather than ParseInt since signs (e.g., of suffix ranges) are not allowed
+	startU, err := strconv.ParseUint(startStr, 10, 63)
+	if err != nil {
+		return 0, 0, fmt.Errorf("%w: %q has an invalid start", ErrInvalidRangeHeader, header)
+	}
+	endU, err := strconv.ParseUint(endStr, 10, 63)
+	if err != nil {
+		return 0, 0, fmt.Errorf("%w: %q has an invalid end", ErrInvalidRangeHeader, header)
+	}
+
+	if endU < startU {
+		return 0, 0, fmt.Errorf("%w: %q ends before its start", ErrInvalidRangeHeader, header)
+	}
+
+	return int64(startU), int64(endU), nil
+}
+
+// byteSizeUnits are the (case-insensitive) units of ParseByteSize.
+var byteSizeUnits = map[string]int64{
+	"":    1,
+	"b":   1,
+	"kb":  1e3,
+	"mb":  1e6,
+	"gb":  1e9,
+	"tb":  1e12,
+	"kib": 1 << 10,
+	"mib": 1 << 20,
+	"gib": 1 << 30,
+	"tib": 1 << 40,
+}
+
+// ParseByteSize parses a human-friendly size such as `500MB`, `2GB` or `1.5 GiB` into bytes, where KB, MB,
+// GB and TB are powers of 1000 whereas KiB, MiB, GiB and TiB are powers of 1024 (a plain number is in bytes).
+// ErrInvalidByteSize is returned for negative sizes and unknown units.
+func ParseByteSize(size string) (int64, error) {
+	trimmed := strings.TrimSpace(size)
+	numEnd := strings.IndexFunc(trimmed, func(r rune) bool {
+		return (r < '0' || r > '9') && r != '.'
+	})
+	if numEnd == -1 {
+		numEnd = len(trimmed)
+	}
+
+	num, err := strconv.ParseFloat(trimmed[:numEnd], 64)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidByteSize, size)
+	}
+
+	unit, ok := byteSizeUnits[strings.ToLower(strings.TrimSpace(trimmed[numEnd:]))]
+	if !ok {
+		return 0, fmt.Errorf("%w: %q has an unknown unit", ErrInvalidByteSize, size)
+	}
+
+	return int64(num * float64(unit)), nil
+}
+
+// parseContentRange extracts the inclusive start and end offsets from a Content-Range
+// header value of the form `bytes start-end/total`.
+func parseContentRange(contentRange string) (int64, int64, error) {
+	var start, end int64
+	var total string
+
+	if _, err := fmt.Sscanf(contentRange, "bytes %d-%d/%s", &start, &end, &total); err != nil {
+		return 0, 0, fmt.Errorf("invalid Content-Range %q: %w", contentRange, err)
+	}
+
+	return start, end, nil
+}
+
+// parseRetryAfter returns the delay indicated by a Retry-After header value, which can either be
+// in delta-seconds or HTTP-date format (RFC 7231). The second return value is false if the header
+// value is invalid.
+func parseRetryAfter(retryAfter string, now time.Time) (time.Duration, bool) {
+	if seconds, err := strconv.Atoi(retryAfter); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	date, err := http.ParseTime(retryAfter)
+	if err != nil {
+		return 0, false
+	}
+
+	if date.Before(now) {
+		return 0, true
+	}
+
+	return date.Sub(now), true
+}
+
+// writeHashFile writes the hash of the given file to a sidecar file (named after the algorithm)
+// in the `<hash>  <file name>` format used by tools like sha256sum. The sidecar file gets the
+// same permissions as the given file.
+func writeHashFile(filePath, algorithm string, calculateHash ETagCalculator) error {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	fileInfo, err := file.Stat()
+	if err != nil {
+		return err
+	}
+
+	hash, err := calculateHash(file)
+	if err != nil {
+		return err
+	}
+
+	hashFilePath := filePath + "." + algorithm
+	contents := fmt.Sprintf("%s  %s\n", hash, filepath.Base(filePath))
+
+	if err := os.WriteFile(hashFilePath, []byte(contents), fileInfo.Mode().Perm()); err != nil {
+		return err
+	}
+
+	// explicitly set since the permissions used on creation are subject to umask
+	return os.Chmod(hashFilePath, fileInfo.Mode().Perm())
+}
+
+// ReadHashFile returns the hash stored in the sidecar hash file written for the given file path
+// with the given algorithm (i.e., by Download with WriteHashFile enabled).
+func ReadHashFile(filePath, algorithm string) (string, error) {
+	b, err := os.ReadFile(filePath + "." + algorithm)
+	if err != nil {
+		return "", err
+	}
+
+	fields := strings.Fields(string(b))
+	if len(fields) == 0 {
+		return "", fmt.Errorf("empty hash file for %s", filePath)
+	}
+
+	return fields[0], nil
+}
+
+// renameNoClobber moves the source file to the destination path unless a file already exists there,
+// in which case ErrFileAlreadyExists is returned. A hard link is used so that the existence check
+// and the move are done atomically.
+func renameNoClobber(src, dest string) error {
+	if err := os.Link(src, dest); errors.Is(err, fs.ErrExist) {
+		return fmt.Errorf("%w: %s", ErrFileAlreadyExists, dest)
+	} else if err != nil {
+		return err
+	}
+
+	return os.Remove(src)
+}
+
+// appendFile appends the contents of the source file to the destination file and removes the
+// source file afterwards. The concatenation is done in a temporary file which then replaces the
+// destination file so that the destination file is never left partially appended.
+func appendFile(destFilePath, srcFilePath string) error {
+	tmpFile, err := os.OpenFile(destFilePath+suffixOngoingAppend, os.O_APPEND|os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer tmpFile.Close()
+
+	for _, filePath := range []string{destFilePath, srcFilePath} {
+		file, err := os.Open(filePath)
+		if errors.Is(err, fs.ErrNotExist) && filePath == destFilePath {
+			continue // nothing to append to yet
+		}
+		if err != nil {
+			return err
+		}
+
+		_, err = io.Copy(tmpFile, file)
+		file.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := tmpFile.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmpFile.Name(), destFilePath); err != nil {
+		return err
+	}
+
+	return os.Remove(srcFilePath)
+}
+
 // min returns the minimum of two numbers.
 func min(a, b int64) int64 {
 	if a < b {
@@ -61,19 +341,170 @@ func printErr(err error) {
 	fmt.Fprintln(os.Stderr, err)
 }
 
-// GetMD5Hash calculates the MD5 hash of the file contents and returns
-// the hex encoding.
-func GetMD5Hash(file *os.File) (string, error) {
-	fileInfo, err := file.Stat()
+// DestFilePathFromURL returns the path of a file inside the given directory named after
+// the last path segment of the given source URL. ErrNoFileNameInUrl is returned if that segment
+// cannot be used as a file name inside the directory (e.g., `..` which would refer to its parent).
+func DestFilePathFromURL(dir, sourceUrl string) (string, error) {
+	u, err := url.Parse(sourceUrl)
 	if err != nil {
 		return "", err
 	}
 
-	b := make([]byte, fileInfo.Size())
-	_, err = file.Read(b)
+	fileName := filepath.Clean(path.Base(u.Path))
+	if fileName == "." || fileName == ".." || strings.ContainsAny(fileName, `/\`) {
+		return "", fmt.Errorf("%w: %s", ErrNoFileNameInUrl, sourceUrl)
+	}
+
+	return filepath.Join(dir, fileName), nil
+}
+
+// NormalizeURL returns the canonical form of the given absolute URL such that equivalent URLs can
+// be compared as strings: the scheme and host are lowercased, the default port is removed, repeated
+// slashes in the path are collapsed, and percent-encoded unreserved characters (RFC 3986) are decoded
+// while other percent-encodings are uppercased.
+func NormalizeURL(raw string) (string, error) {
+	u, err := url.Parse(raw)
 	if err != nil {
 		return "", err
 	}
 
-	return fmt.Sprintf("%x", md5.Sum(b)), nil
+	u.Scheme = strings.ToLower(u.Scheme)
+
+	// local files have no host (i.e., `file:///path/to/file`)
+	if len(u.Scheme) == 0 || (len(u.Host) == 0 && (u.Scheme != schemeFile || len(u.Path) == 0)) {
+		return "", fmt.Errorf("%w: %s", ErrInvalidSourceUrl, raw)
+	}
+
+	u.Host = strings.ToLower(u.Host)
+
+	if port := u.Port(); (u.Scheme == "http" && port == "80") || (u.Scheme == "https" && port == "443") {
+		u.Host = strings.TrimSuffix(u.Host, ":"+port)
+	}
+
+	escapedPath := normalizePercentEncoding(repeatedSlashesPattern.ReplaceAllString(u.EscapedPath(), "/"))
+	if u.Path, err = url.PathUnescape(escapedPath); err != nil {
+		return "", err
+	}
+	u.RawPath = escapedPath
+
+	return u.String(), nil
+}
+
+// repeatedSlashesPattern matches consecutive slashes in a URL path.
+var repeatedSlashesPattern = regexp.MustCompile(`/{2,}`)
+
+// percentEncodingPattern matches a percent-encoded octet.
+var percentEncodingPattern = regexp.MustCompile(`%[0-9A-Fa-f]{2}`)
+
+// normalizePercentEncoding decodes the percent-encoded unreserved characters in the given
+// escaped string and uppercases the remaining percent-encodings.
+func normalizePercentEncoding(escaped string) string {
+	return percentEncodingPattern.ReplaceAllStringFunc(escaped, func(encoded string) string {
+		b, _ := strconv.ParseUint(encoded[1:], 16, 8) // always valid based on the pattern
+		if c := byte(b); isUnreservedChar(c) {
+			return string(c)
+		}
+
+		return strings.ToUpper(encoded)
+	})
+}
+
+// isUnreservedChar returns true if the character is unreserved in URIs (RFC 3986 section 2.3).
+func isUnreservedChar(c byte) bool {
+	return ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z') || ('0' <= c && c <= '9') ||
+		c == '-' || c == '.' || c == '_' || c == '~'
+}
+
+// normalizeURLs returns the normalized form of each of the given URLs.
+func normalizeURLs(rawUrls []string) ([]string, error) {
+	urls := make([]string, 0, len(rawUrls))
+	for _, raw := range rawUrls {
+		normalized, err := NormalizeURL(raw)
+		if err != nil {
+			return nil, err
+		}
+		urls = append(urls, normalized)
+	}
+
+	return urls, nil
+}
+
+// GetMD5Hash calculates the MD5 hash of the contents (from the start) and returns
+// the hex encoding.
+func GetMD5Hash(r io.ReadSeeker) (string, error) {
+	return calculateHash(r, md5.New())
+}
+
+// GetSHA256Hash calculates the SHA-256 hash of the contents (from the start) and returns
+// the hex encoding.
+func GetSHA256Hash(r io.ReadSeeker) (string, error) {
+	return calculateHash(r, sha256.New())
+}
+
+// GetSHA512Hash calculates the SHA-512 hash of the contents (from the start) and returns
+// the hex encoding.
+func GetSHA512Hash(r io.ReadSeeker) (string, error) {
+	return calculateHash(r, sha512.New())
+}
+
+// hashBufferSize is the size of the blocks in which the contents are fed to the hasher.
+const hashBufferSize = 64 * 1024
+
+// calculateHash feeds the whole contents (from the start) to the hasher and returns the hex
+// encoding of the resulting hash. The contents are streamed in blocks so that the memory usage
+// does not depend on their size.
+func calculateHash(r io.ReadSeeker, hasher hash.Hash) (string, error) {
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+
+	if _, err := io.Copy(hasher, bufio.NewReaderSize(r, hashBufferSize)); err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%x", hasher.Sum(nil)), nil
+}
+
+// hashCalculators maps the supported hash algorithm names to their corresponding calculators.
+var hashCalculators = map[string]ETagCalculator{
+	"md5":    GetMD5Hash,
+	"sha256": GetSHA256Hash,
+	"sha512": GetSHA512Hash,
+}
+
+// hashConstructors maps the supported hash algorithm names to the hashers used by their calculators.
+var hashConstructors = map[string]func() hash.Hash{
+	"md5":    md5.New,
+	"sha256": sha256.New,
+	"sha512": sha512.New,
+}
+
+// HashCalculatorFor returns the calculator for the given hash algorithm name (md5, sha256 or sha512), e.g.,
+// for sources whose ETags are SHA-256 hashes. ErrUnsupportedHashAlgorithm is returned for other names.
+func HashCalculatorFor(algorithm string) (ETagCalculator, error) {
+	calculateHash, ok := hashCalculators[algorithm]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedHashAlgorithm, algorithm)
+	}
+
+	return calculateHash, nil
+}
+
+// streamingHasherFor returns a new hasher for calculating the ETag on the bytes as they are streamed
+// which matches the given calculator, i.e., the hasher of the built-in calculators (see HashCalculatorFor)
+// or MD5 if there is no calculator. Nil is returned for custom calculators since they need the contents.
+func streamingHasherFor(calculateETag ETagCalculator) hash.Hash {
+	if calculateETag == nil {
+		return md5.New()
+	}
+
+	// functions are not comparable so their pointers are compared instead
+	calculatorPtr := reflect.ValueOf(calculateETag).Pointer()
+	for algorithm, calculateHash := range hashCalculators {
+		if reflect.ValueOf(calculateHash).Pointer() == calculatorPtr {
+			return hashConstructors[algorithm]()
+		}
+	}
+
+	return nil
 }
@@ -0,0 +1,408 @@
+package download_test
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha256"
+	"crypto/sha512"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/gkatanacio/multisource-downloader/download"
+)
+
+func Test_DestFilePathFromURL(t *testing.T) {
+	testCases := map[string]struct {
+		sourceUrl        string
+		expectedFilePath string
+		specificErr      error
+	}{
+		"with extension": {
+			sourceUrl:        "http://source1.com/files/a.txt",
+			expectedFilePath: filepath.Join("downloads", "a.txt"),
+		},
+		"without extension": {
+			sourceUrl:        "http://source1.com/files/archive",
+			expectedFilePath: filepath.Join("downloads", "archive"),
+		},
+		"with query string": {
+			sourceUrl:        "http://source1.com/a.tar.gz?token=abc",
+			expectedFilePath: filepath.Join("downloads", "a.tar.gz"),
+		},
+		"with escaped characters": {
+			sourceUrl:        "http://source1.com/my%20file.txt",
+			expectedFilePath: filepath.Join("downloads", "my file.txt"),
+		},
+		"no path": {
+			sourceUrl:   "http://source1.com",
+			specificErr: download.ErrNoFileNameInUrl,
+		},
+		"root path": {
+			sourceUrl:   "http://source1.com/",
+			specificErr: download.ErrNoFileNameInUrl,
+		},
+		"parent directory": {
+			sourceUrl:   "http://source1.com/files/..",
+			specificErr: download.ErrNoFileNameInUrl,
+		},
+		"escaped parent directory": {
+			sourceUrl:   "http://source1.com/files/%2e%2e",
+			specificErr: download.ErrNoFileNameInUrl,
+		},
+		"current directory": {
+			sourceUrl:   "http://source1.com/files/.",
+			specificErr: download.ErrNoFileNameInUrl,
+		},
+		"backslash separator": {
+			sourceUrl:   "http://source1.com/files/..%5Csecret.txt",
+			specificErr: download.ErrNoFileNameInUrl,
+		},
+		"dots within name": {
+			sourceUrl:        "http://source1.com/files/a..b",
+			expectedFilePath: filepath.Join("downloads", "a..b"),
+		},
+	}
+
+	for scenario, tc := range testCases {
+		t.Run(scenario, func(t *testing.T) {
+			filePath, err := download.DestFilePathFromURL("downloads", tc.sourceUrl)
+
+			if tc.specificErr != nil {
+				assert.ErrorIs(t, err, tc.specificErr)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectedFilePath, filePath)
+		})
+	}
+}
+
+func Test_HashCalculators(t *testing.T) {
+	testCases := map[string]struct {
+		calculateHash download.ETagCalculator
+		expectedHash  string
+	}{
+		"md5": {
+			calculateHash: download.GetMD5Hash,
+			expectedHash:  "9e107d9d372bb6826bd81d3542a419d6",
+		},
+		"sha256": {
+			calculateHash: download.GetSHA256Hash,
+			expectedHash:  "d7a8fbb307d7809469ca9abcb0082e4f8d5651e46d3cdb762d02d0bf37c9e592",
+		},
+		"sha512": {
+			calculateHash: download.GetSHA512Hash,
+			expectedHash:  "07e547d9586f6a73f73fbac0435ed76951218fb7d0c8d788a309d785436bbb642e93a252a954f23912547d1e8a3b5ed6e1bfd7097821233fa0538f3db854fee6",
+		},
+	}
+
+	for scenario, tc := range testCases {
+		t.Run(scenario, func(t *testing.T) {
+			r := strings.NewReader("The quick brown fox jumps over the lazy dog")
+			r.Seek(10, io.SeekStart) // should be hashed from the start regardless of current position
+
+			hash, err := tc.calculateHash(r)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectedHash, hash)
+		})
+	}
+}
+
+func Test_GetMD5Hash_FileAtRandomPosition(t *testing.T) {
+	content := bytes.Repeat([]byte("The quick brown fox jumps over the lazy dog\n"), 100)
+
+	file, err := os.Create(filepath.Join(t.TempDir(), "fox.txt"))
+	assert.NoError(t, err)
+	defer file.Close()
+
+	// the position after writing chunks is wherever the last write ended
+	_, err = file.Write(content)
+	assert.NoError(t, err)
+	_, err = file.Seek(rand.Int64N(int64(len(content))+1), io.SeekStart)
+	assert.NoError(t, err)
+
+	hash, err := download.GetMD5Hash(file)
+	assert.NoError(t, err)
+	assert.Equal(t, fmt.Sprintf("%x", md5.Sum(content)), hash)
+}
+
+func Test_HashCalculators_MatchBulkHashing(t *testing.T) {
+	content := readFixture(t, "dummy.png")
+
+	testCases := map[string]struct {
+		calculateHash download.ETagCalculator
+		bulkHash      func(b []byte) []byte
+	}{
+		"md5": {
+			calculateHash: download.GetMD5Hash,
+			bulkHash:      func(b []byte) []byte { h := md5.Sum(b); return h[:] },
+		},
+		"sha256": {
+			calculateHash: download.GetSHA256Hash,
+			bulkHash:      func(b []byte) []byte { h := sha256.Sum256(b); return h[:] },
+		},
+		"sha512": {
+			calculateHash: download.GetSHA512Hash,
+			bulkHash:      func(b []byte) []byte { h := sha512.Sum512(b); return h[:] },
+		},
+	}
+
+	for scenario, tc := range testCases {
+		t.Run(scenario, func(t *testing.T) {
+			hash, err := tc.calculateHash(bytes.NewReader(content))
+			assert.NoError(t, err)
+			assert.Equal(t, fmt.Sprintf("%x", tc.bulkHash(content)), hash)
+		})
+	}
+}
+
+// writeSyntheticFile writes a file of the given size with pseudo-random contents and returns it opened.
+func writeSyntheticFile(tb testing.TB, size int) *os.File {
+	tb.Helper()
+
+	file, err := os.Create(filepath.Join(tb.TempDir(), "synthetic.bin"))
+	if err != nil {
+		tb.Fatal(err)
+	}
+	tb.Cleanup(func() { file.Close() })
+
+	if _, err := io.CopyN(file, rand.NewChaCha8([32]byte{}), int64(size)); err != nil {
+		tb.Fatal(err)
+	}
+
+	return file
+}
+
+func Test_GetMD5Hash_MemoryIndependentOfFileSize(t *testing.T) {
+	file := writeSyntheticFile(t, 32<<20)
+
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	_, err := download.GetMD5Hash(file)
+	runtime.ReadMemStats(&after)
+
+	assert.NoError(t, err)
+	assert.Less(t, after.TotalAlloc-before.TotalAlloc, uint64(1<<20), "should only allocate a fixed-size buffer")
+}
+
+func BenchmarkGetMD5Hash(b *testing.B) {
+	const size = 100 << 20
+	file := writeSyntheticFile(b, size)
+
+	b.SetBytes(size)
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for range b.N {
+		if _, err := download.GetMD5Hash(file); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func Test_ReadHashFile(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "dummy.txt")
+	err := os.WriteFile(filePath+".md5", []byte("0a1b2c3d  dummy.txt\n"), 0644)
+	assert.NoError(t, err)
+
+	hash, err := download.ReadHashFile(filePath, "md5")
+	assert.NoError(t, err)
+	assert.Equal(t, "0a1b2c3d", hash)
+
+	_, err = download.ReadHashFile(filePath, "sha256")
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func Test_NormalizeURL(t *testing.T) {
+	canonical := "https://mirror.example.com/pub/file.tar.gz"
+	equivalentUrls := []string{
+		"https://mirror.example.com/pub/file.tar.gz",
+		"HTTPS://mirror.example.com/pub/file.tar.gz",
+		"https://Mirror.Example.Com/pub/file.tar.gz",
+		"HTTPS://MIRROR.EXAMPLE.COM/pub/file.tar.gz",
+		"https://mirror.example.com:443/pub/file.tar.gz",
+		"https://mirror.example.com//pub/file.tar.gz",
+		"https://mirror.example.com/pub///file.tar.gz",
+		"https://mirror.example.com/%70ub/file%2Etar%2egz",
+		"https://mirror.example.com/%70%75%62/%66ile.tar.gz",
+		"HTTPS://Mirror.Example.Com:443//%70ub//file.tar.gz",
+	}
+
+	for _, raw := range equivalentUrls {
+		t.Run(raw, func(t *testing.T) {
+			normalized, err := download.NormalizeURL(raw)
+			assert.NoError(t, err)
+			assert.Equal(t, canonical, normalized)
+		})
+	}
+
+	testCases := map[string]struct {
+		raw                string
+		expectedNormalized string
+		specificErr        error
+	}{
+		"default http port": {
+			raw:                "http://Mirror.Example.Com:80/file.tar.gz",
+			expectedNormalized: "http://mirror.example.com/file.tar.gz",
+		},
+		"non-default port": {
+			raw:                "https://mirror.example.com:8443/file.tar.gz",
+			expectedNormalized: "https://mirror.example.com:8443/file.tar.gz",
+		},
+		"http port on https": {
+			raw:                "https://mirror.example.com:80/file.tar.gz",
+			expectedNormalized: "https://mirror.example.com:80/file.tar.gz",
+		},
+		"reserved characters stay encoded in uppercase": {
+			raw:                "https://mirror.example.com/my%20file%2fname%3f.tar.gz",
+			expectedNormalized: "https://mirror.example.com/my%20file%2Fname%3F.tar.gz",
+		},
+		"query is preserved": {
+			raw:                "https://Mirror.Example.Com//file.tar.gz?Token=AbC&x=%2F",
+			expectedNormalized: "https://mirror.example.com/file.tar.gz?Token=AbC&x=%2F",
+		},
+		"IPv6 host with default port": {
+			raw:                "http://[::1]:80/file.tar.gz",
+			expectedNormalized: "http://[::1]/file.tar.gz",
+		},
+		"relative URL": {
+			raw:         "mirror.example.com/file.tar.gz",
+			specificErr: download.ErrInvalidSourceUrl,
+		},
+		"local file": {
+			raw:                "FILE:///srv/mirror//file.tar.gz",
+			expectedNormalized: "file:///srv/mirror/file.tar.gz",
+		},
+		"local file without path": {
+			raw:         "file://",
+			specificErr: download.ErrInvalidSourceUrl,
+		},
+	}
+
+	for scenario, tc := range testCases {
+		t.Run(scenario, func(t *testing.T) {
+			normalized, err := download.NormalizeURL(tc.raw)
+
+			if tc.specificErr != nil {
+				assert.ErrorIs(t, err, tc.specificErr)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectedNormalized, normalized)
+		})
+	}
+}
+
+func Test_RangeHeader_RoundTrip(t *testing.T) {
+	const fileSize = 1000
+
+	testCases := map[string]struct {
+		start    int64
+		end      int64
+		expected string
+	}{
+		"first byte": {
+			start:    0,
+			end:      0,
+			expected: "bytes=0-0",
+		},
+		"from the start": {
+			start:    0,
+			end:      99,
+			expected: "bytes=0-99",
+		},
+		"to the end": {
+			start:    900,
+			end:      fileSize - 1,
+			expected: "bytes=900-999",
+		},
+		"whole file": {
+			start:    0,
+			end:      fileSize - 1,
+			expected: "bytes=0-999",
+		},
+		"beyond 4 GiB": {
+			start:    1 << 33,
+			end:      1<<33 + 1<<20 - 1,
+			expected: "bytes=8589934592-8590983167",
+		},
+	}
+
+	for scenario, tc := range testCases {
+		t.Run(scenario, func(t *testing.T) {
+			header := download.FormatRangeHeader(tc.start, tc.end)
+			assert.Equal(t, tc.expected, header)
+
+			start, end, err := download.ParseRangeHeader(header)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.start, start)
+			assert.Equal(t, tc.end, end)
+		})
+	}
+}
+
+func Test_ParseRangeHeader_Invalid(t *testing.T) {
+	for _, header := range []string{
+		"",
+		"0-99",
+		"items=0-99",
+		"bytes=",
+		"bytes=99",
+		"bytes=-99",
+		"bytes=900-",
+		"bytes=0-99,200-299",
+		"bytes=+1-99",
+		"bytes=a-99",
+		"bytes=99-0",
+		"bytes= 0-99",
+		"bytes=0-99999999999999999999",
+	} {
+		t.Run(header, func(t *testing.T) {
+			_, _, err := download.ParseRangeHeader(header)
+			assert.ErrorIs(t, err, download.ErrInvalidRangeHeader)
+		})
+	}
+}
+
+func Test_ParseByteSize(t *testing.T) {
+	testCases := map[string]struct {
+		size     string
+		expected int64
+	}{
+		"plain bytes":       {size: "1024", expected: 1024},
+		"bytes unit":        {size: "10B", expected: 10},
+		"decimal megabytes": {size: "500MB", expected: 500_000_000},
+		"decimal gigabytes": {size: "2GB", expected: 2_000_000_000},
+		"binary kibibytes":  {size: "4KiB", expected: 4096},
+		"fractional":        {size: "1.5 GiB", expected: 3 << 29},
+		"lowercase unit":    {size: "3mb", expected: 3_000_000},
+		"zero":              {size: "0", expected: 0},
+	}
+
+	for scenario, tc := range testCases {
+		t.Run(scenario, func(t *testing.T) {
+			size, err := download.ParseByteSize(tc.size)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, size)
+		})
+	}
+}
+
+func Test_ParseByteSize_Invalid(t *testing.T) {
+	for _, size := range []string{"", "MB", "-5MB", "5XB", "5 M B", "1.2.3GB"} {
+		t.Run(size, func(t *testing.T) {
+			_, err := download.ParseByteSize(size)
+			assert.ErrorIs(t, err, download.ErrInvalidByteSize)
+		})
+	}
+}
@@ -0,0 +1,62 @@
+package download
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// lockPollInterval is how often a held lock on the destination file is tried again.
+const lockPollInterval = 50 * time.Millisecond
+
+// lockDestFile acquires the exclusive advisory lock on `<DestFilePath>.lock`, trying again until
+// LockTimeout elapses, and returns the function for releasing it. ErrDownloadAlreadyInProgress is
+// returned if the lock is still held by then. The lock file itself is kept since removing it could
+// let another process lock a different file of the same name.
+func (s *Service) lockDestFile(ctx context.Context) (func(), error) {
+	lockFile, err := os.OpenFile(s.opts.DestFilePath+suffixLock, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	deadline := time.Now().Add(s.opts.LockTimeout)
+	for {
+		err := tryLockFile(lockFile)
+		if err == nil {
+			break
+		}
+
+		if errors.Is(err, errFileLockUnsupported) {
+			lockFile.Close()
+			s.logln("warning: skipping lock of destination file:", err)
+			return func() {}, nil
+		} else if !errors.Is(err, errFileLockHeld) {
+			lockFile.Close()
+			return nil, err
+		}
+
+		if !time.Now().Before(deadline) {
+			lockFile.Close()
+			return nil, fmt.Errorf("%w: %s", ErrDownloadAlreadyInProgress, s.opts.DestFilePath)
+		}
+
+		wait := lockPollInterval
+		if untilDeadline := time.Until(deadline); untilDeadline < wait {
+			wait = untilDeadline
+		}
+
+		select {
+		case <-ctx.Done():
+			lockFile.Close()
+			return nil, ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+
+	return func() {
+		unlockFile(lockFile)
+		lockFile.Close() // also releases the lock if unlocking failed
+	}, nil
+}
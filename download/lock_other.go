@@ -0,0 +1,15 @@
+//go:build !unix && !windows
+
+package download
+
+import "os"
+
+// tryLockFile is not supported on this platform.
+func tryLockFile(file *os.File) error {
+	return errFileLockUnsupported
+}
+
+// unlockFile is not supported on this platform.
+func unlockFile(file *os.File) error {
+	return errFileLockUnsupported
+}
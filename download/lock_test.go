@@ -0,0 +1,79 @@
+//go:build unix || windows
+
+package download_test
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/gkatanacio/multisource-downloader/download"
+)
+
+func Test_Service_Download_UseFlock(t *testing.T) {
+	content := readFixture(t, "dummy.txt")
+
+	srv := newTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			time.Sleep(200 * time.Millisecond) // keeps the lock held long enough to overlap
+		}
+		serveContent("dummy.txt", content)(w, r)
+	}))
+
+	testCases := map[string]struct {
+		lockTimeout  time.Duration
+		expectedErrs int
+	}{
+		"second download fails": {
+			expectedErrs: 1,
+		},
+		"second download waits": {
+			lockTimeout: 5 * time.Second,
+		},
+	}
+
+	for scenario, tc := range testCases {
+		t.Run(scenario, func(t *testing.T) {
+			destFilePath := filepath.Join(t.TempDir(), "dummy.txt")
+
+			var wg sync.WaitGroup
+			errs := make([]error, 2)
+			for i := range errs {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+
+					downloadService := download.NewService(download.Options{
+						Connections:  2,
+						Timeout:      3,
+						Quiet:        true,
+						DestFilePath: destFilePath,
+						UseFlock:     true,
+						LockTimeout:  tc.lockTimeout,
+					}, download.GetMD5Hash)
+					errs[i] = downloadService.Download([]string{srv.URL + "/dummy.txt"})
+				}()
+			}
+			wg.Wait()
+
+			var failed int
+			for _, err := range errs {
+				if err != nil {
+					assert.ErrorIs(t, err, download.ErrDownloadAlreadyInProgress)
+					failed++
+				}
+			}
+			assert.Equal(t, tc.expectedErrs, failed)
+
+			downloaded, err := os.ReadFile(destFilePath)
+			assert.NoError(t, err)
+			assert.Equal(t, content, downloaded)
+			assert.NoFileExists(t, destFilePath+".download")
+		})
+	}
+}
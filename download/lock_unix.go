@@ -0,0 +1,25 @@
+//go:build unix
+
+package download
+
+import (
+	"errors"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// tryLockFile acquires an exclusive flock on the file without blocking.
+func tryLockFile(file *os.File) error {
+	err := unix.Flock(int(file.Fd()), unix.LOCK_EX|unix.LOCK_NB)
+	if errors.Is(err, unix.EWOULDBLOCK) {
+		return errFileLockHeld
+	}
+
+	return err
+}
+
+// unlockFile releases the flock on the file.
+func unlockFile(file *os.File) error {
+	return unix.Flock(int(file.Fd()), unix.LOCK_UN)
+}
@@ -0,0 +1,87 @@
+package download
+
+import (
+	"context"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// pendingChunk represents a chunk which is pending to be downloaded.
+type pendingChunk struct {
+	index  int
+	offset int64
+	limit  int64 // exclusive
+}
+
+// chunkLanes is a fixed number of connections which each fetch their next chunk while the chunks
+// they fetched before are being delivered (i.e., per-connection lookahead).
+type chunkLanes struct {
+	pending chan pendingChunk
+}
+
+// startChunkLanes starts the given number of lanes in the errgroup which consume the enqueued chunks
+// until the lanes are closed. Each lane consists of a goroutine fetching the chunks and another one
+// delivering them in order, with at most the given depth of fetched chunks pending delivery per lane.
+// The lanes stop as soon as the context is done (e.g., if another goroutine in the group fails).
+func startChunkLanes(eg *errgroup.Group, ctx context.Context, lanes, depth uint, fetch func(context.Context, pendingChunk) (chunkWrite, error), deliver func(context.Context, chunkWrite) error) *chunkLanes {
+	cl := &chunkLanes{pending: make(chan pendingChunk)}
+
+	for range lanes {
+		fetched := make(chan chunkWrite, depth-1) // the one being delivered is the remaining one
+
+		eg.Go(func() error {
+			defer close(fetched)
+
+			for {
+				select {
+				case <-ctx.Done():
+					return ctx.Err()
+				case pc, ok := <-cl.pending:
+					if !ok {
+						return nil
+					}
+
+					cw, err := fetch(ctx, pc)
+					if err != nil {
+						return err
+					}
+
+					select {
+					case <-ctx.Done():
+						return ctx.Err()
+					case fetched <- cw:
+					}
+				}
+			}
+		})
+
+		eg.Go(func() error {
+			for cw := range fetched {
+				if ctx.Err() != nil {
+					return ctx.Err()
+				}
+				if err := deliver(ctx, cw); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	}
+
+	return cl
+}
+
+// enqueue hands the chunk to the next available lane and blocks until there is one or the context is done.
+func (cl *chunkLanes) enqueue(ctx context.Context, pc pendingChunk) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case cl.pending <- pc:
+		return nil
+	}
+}
+
+// close signals the lanes that no more chunks will be enqueued.
+func (cl *chunkLanes) close() {
+	close(cl.pending)
+}
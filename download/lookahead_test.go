@@ -0,0 +1,71 @@
+package download
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/sync/errgroup"
+)
+
+func Test_chunkLanes_BoundedLookahead(t *testing.T) {
+	const depth = 3
+
+	var fetched, delivered, maxPending atomic.Int64
+	fetch := func(ctx context.Context, pc pendingChunk) (chunkWrite, error) {
+		time.Sleep(time.Millisecond)
+		n := fetched.Add(1) - delivered.Load()
+		for {
+			m := maxPending.Load()
+			if n <= m || maxPending.CompareAndSwap(m, n) {
+				break
+			}
+		}
+		return chunkWrite{index: pc.index}, nil
+	}
+
+	var order []int
+	deliver := func(ctx context.Context, cw chunkWrite) error {
+		time.Sleep(5 * time.Millisecond) // slower than fetching so that the lookahead fills up
+		order = append(order, cw.index)  // a single lane delivers sequentially
+		delivered.Add(1)
+		return nil
+	}
+
+	eg, ctx := errgroup.WithContext(context.Background())
+	lanes := startChunkLanes(eg, ctx, 1, depth, fetch, deliver)
+
+	for i := range 20 {
+		assert.NoError(t, lanes.enqueue(ctx, pendingChunk{index: i}))
+	}
+	lanes.close()
+
+	assert.NoError(t, eg.Wait())
+	assert.Len(t, order, 20)
+	for i, index := range order {
+		assert.Equal(t, i, index)
+	}
+	// the chunks pending delivery plus the one just fetched
+	assert.LessOrEqual(t, maxPending.Load(), int64(depth+1))
+	assert.Greater(t, maxPending.Load(), int64(1))
+}
+
+func Test_chunkLanes_DeliverErrorCancels(t *testing.T) {
+	deliverErr := errors.New("disk full")
+
+	eg, ctx := errgroup.WithContext(context.Background())
+	lanes := startChunkLanes(eg, ctx, 2, 2, func(ctx context.Context, pc pendingChunk) (chunkWrite, error) {
+		return chunkWrite{index: pc.index}, nil
+	}, func(ctx context.Context, cw chunkWrite) error {
+		return deliverErr
+	})
+
+	assert.NoError(t, lanes.enqueue(ctx, pendingChunk{index: 0}))
+
+	// the lanes stop once the delivery error cancelled the context and accept no more chunks
+	assert.ErrorIs(t, eg.Wait(), deliverErr)
+	assert.ErrorIs(t, lanes.enqueue(ctx, pendingChunk{index: 1}), context.Canceled)
+}
@@ -0,0 +1,90 @@
+package download
+
+import (
+	"encoding/json"
+	"os"
+	"time"
+)
+
+// Manifest records the provenance of a completed download.
+type Manifest struct {
+	Timestamp  time.Time        `json:"timestamp"`
+	DestFile   string           `json:"dest_file"`
+	TotalBytes int64            `json:"total_bytes"`
+	HashMD5    string           `json:"hash_md5"`
+	HashSHA256 string           `json:"hash_sha256"`
+	Sources    []ManifestSource `json:"sources"`
+	DurationMs int64            `json:"duration_ms"`
+}
+
+// ManifestSource records the contribution of a single source to a download.
+type ManifestSource struct {
+	URL              string  `json:"url"`
+	ChunksDownloaded int     `json:"chunks_downloaded"`
+	BytesDownloaded  int64   `json:"bytes_downloaded"`
+	AvgLatencyMs     float64 `json:"avg_latency_ms"`
+}
+
+// buildManifest returns the manifest of the downloaded file at the given path.
+func buildManifest(filePath string, startedAt time.Time, stats *sourceStatsCollector) (*Manifest, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	md5Hash, err := GetMD5Hash(f)
+	if err != nil {
+		return nil, err
+	}
+
+	sha256Hash, err := GetSHA256Hash(f)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Manifest{
+		Timestamp:  startedAt.UTC(),
+		DestFile:   filePath,
+		TotalBytes: info.Size(),
+		HashMD5:    md5Hash,
+		HashSHA256: sha256Hash,
+		Sources:    manifestSources(stats.downloadStats()),
+		DurationMs: time.Since(startedAt).Milliseconds(),
+	}, nil
+}
+
+// manifestSources returns the manifest entries for the sources in the given stats.
+func manifestSources(stats *DownloadStats) []ManifestSource {
+	sources := make([]ManifestSource, 0, len(stats.Sources))
+	for _, ss := range stats.Sources {
+		sources = append(sources, ManifestSource{
+			URL:              ss.URL,
+			ChunksDownloaded: ss.ChunksDelivered,
+			BytesDownloaded:  ss.BytesDelivered,
+			AvgLatencyMs:     ss.AvgChunkDurationMs,
+		})
+	}
+
+	return sources
+}
+
+// writeManifest writes the manifest as JSON to the given file. The manifest is written
+// to a temporary file first and then renamed so that the file is never partially written.
+func writeManifest(filePath string, manifest *Manifest) error {
+	b, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.WriteFile(filePath+".tmp", b, 0644); err != nil {
+		return err
+	}
+
+	return os.Rename(filePath+".tmp", filePath)
+}
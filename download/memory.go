@@ -0,0 +1,138 @@
+package download
+
+import (
+	"context"
+	"fmt"
+	"runtime/metrics"
+	"sync"
+	"time"
+)
+
+const (
+	memoryPollInterval = 50 * time.Millisecond
+	heapInuseShare     = 0.9 // of MaxMemoryUsageBytes above which no new chunks are started
+)
+
+// memoryLimitedChunkSize caps the given chunk size such that a chunk fits into MaxMemoryUsageBytes
+// (if set). Unless ChunkBytes is set, the file-size-based chunk size is capped such that all of the
+// connections fit into it instead (see memoryLimitedConnections).
+func (s *Service) memoryLimitedChunkSize(chunkSize int64) int64 {
+	if s.opts.MaxMemoryUsageBytes <= 0 {
+		return chunkSize
+	}
+
+	if s.opts.ChunkBytes <= 0 {
+		chunkSize = min(chunkSize, s.opts.MaxMemoryUsageBytes/(int64(max(s.opts.Connections, 1))*s.chunksPerConnection()))
+	}
+
+	return max(min(chunkSize, s.opts.MaxMemoryUsageBytes/s.chunksPerConnection()), 1)
+}
+
+// memoryLimitedConnections returns the number of connections such that their in-flight chunks of the
+// given size fit into MaxMemoryUsageBytes (if set), logging a warning if this is fewer than Connections.
+func (s *Service) memoryLimitedConnections(chunkSize int64) uint {
+	connections := s.opts.Connections
+	if s.opts.MaxMemoryUsageBytes <= 0 {
+		return connections
+	}
+
+	if limit := uint(max(s.opts.MaxMemoryUsageBytes/(chunkSize*s.chunksPerConnection()), 1)); limit < connections {
+		s.logln(fmt.Sprintf("warning: MaxMemoryUsageBytes (%d) only allows %d of the %d connections for chunks of %d bytes", s.opts.MaxMemoryUsageBytes, limit, connections, chunkSize))
+		connections = limit
+	}
+
+	return connections
+}
+
+// chunksPerConnection is the max number of fetched chunks held in memory per connection.
+func (s *Service) chunksPerConnection() int64 {
+	if s.opts.PipelineChunks > 1 && !s.opts.AutoScaleConnections {
+		return int64(s.opts.PipelineChunks)
+	}
+	return 1
+}
+
+// memoryGate pauses new chunk downloads while the heap in use exceeds the threshold. Since the heap may
+// be used by others as well, a chunk is only paused while other chunks are in flight (which free their
+// memory once done). A nil gate never pauses.
+type memoryGate struct {
+	threshold uint64
+	heapInuse func() uint64
+
+	// the check and the increment of inFlight are done together under mu so that concurrent chunks
+	// cannot all pass the check before any of them is counted
+	mu       sync.Mutex
+	inFlight int64
+}
+
+// newMemoryGate returns the memory gate for MaxMemoryUsageBytes or nil if it is not set.
+func newMemoryGate(maxMemoryUsageBytes int64) *memoryGate {
+	if maxMemoryUsageBytes <= 0 {
+		return nil
+	}
+
+	return &memoryGate{
+		threshold: uint64(float64(maxMemoryUsageBytes) * heapInuseShare),
+		heapInuse: readHeapInuse,
+	}
+}
+
+// readHeapInuse returns the bytes of the heap spans in use (i.e., runtime.MemStats.HeapInuse) via
+// runtime/metrics which, unlike runtime.ReadMemStats, does not stop the world on every poll.
+func readHeapInuse() uint64 {
+	samples := []metrics.Sample{
+		{Name: "/memory/classes/heap/objects:bytes"},
+		{Name: "/memory/classes/heap/unused:bytes"},
+	}
+	metrics.Read(samples)
+
+	var heapInuse uint64
+	for _, sample := range samples {
+		if sample.Value.Kind() == metrics.KindUint64 {
+			heapInuse += sample.Value.Uint64()
+		}
+	}
+	return heapInuse
+}
+
+// acquire blocks until the chunk can be started or the context is done. Each successful acquire
+// must be followed by a release once the chunk is done.
+func (mg *memoryGate) acquire(ctx context.Context) error {
+	if mg == nil {
+		return nil
+	}
+
+	for !mg.tryAcquire() {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(memoryPollInterval):
+		}
+	}
+
+	return nil
+}
+
+// tryAcquire counts the chunk as in flight and returns true if it can be started right away.
+func (mg *memoryGate) tryAcquire() bool {
+	mg.mu.Lock()
+	defer mg.mu.Unlock()
+
+	if mg.inFlight > 0 && mg.heapInuse() > mg.threshold {
+		return false
+	}
+
+	mg.inFlight++
+	return true
+}
+
+func (mg *memoryGate) release() {
+	if mg == nil {
+		return
+	}
+
+	mg.mu.Lock()
+	defer mg.mu.Unlock()
+
+	mg.inFlight--
+}
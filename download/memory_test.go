@@ -0,0 +1,174 @@
+package download
+
+import (
+	"context"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Service_memoryLimits(t *testing.T) {
+	testCases := map[string]struct {
+		opts                Options
+		fileSize            int64
+		expectedChunkSize   int64
+		expectedConnections uint
+	}{
+		"no limit": {
+			opts:                Options{Connections: 4},
+			fileSize:            4000,
+			expectedChunkSize:   1000,
+			expectedConnections: 4,
+		},
+		"within limit": {
+			opts:                Options{Connections: 4, MaxMemoryUsageBytes: 4000},
+			fileSize:            4000,
+			expectedChunkSize:   1000,
+			expectedConnections: 4,
+		},
+		"file-size-based chunks reduced": {
+			opts:                Options{Connections: 4, MaxMemoryUsageBytes: 1000},
+			fileSize:            4000,
+			expectedChunkSize:   250,
+			expectedConnections: 4,
+		},
+		"chunk bytes reduce connections": {
+			opts:                Options{Connections: 8, ChunkBytes: 1000, MaxMemoryUsageBytes: 2500},
+			fileSize:            10000,
+			expectedChunkSize:   1000,
+			expectedConnections: 2,
+		},
+		"chunk bytes beyond limit": {
+			opts:                Options{Connections: 2, ChunkBytes: 4000, MaxMemoryUsageBytes: 1000},
+			fileSize:            10000,
+			expectedChunkSize:   1000,
+			expectedConnections: 1,
+		},
+		"pipelined chunks": {
+			opts:                Options{Connections: 4, ChunkBytes: 1000, PipelineChunks: 2, MaxMemoryUsageBytes: 4000},
+			fileSize:            10000,
+			expectedChunkSize:   1000,
+			expectedConnections: 2,
+		},
+	}
+
+	for scenario, tc := range testCases {
+		t.Run(scenario, func(t *testing.T) {
+			tc.opts.Quiet = true
+			s := NewService(tc.opts, nil)
+
+			chunkSize := s.chunkSize(tc.fileSize)
+			assert.Equal(t, tc.expectedChunkSize, chunkSize)
+			assert.Equal(t, tc.expectedConnections, s.memoryLimitedConnections(chunkSize))
+
+			if tc.opts.MaxMemoryUsageBytes > 0 {
+				assert.LessOrEqual(t, int64(tc.expectedConnections)*chunkSize*s.chunksPerConnection(), tc.opts.MaxMemoryUsageBytes)
+			}
+		})
+	}
+}
+
+func Test_Service_memoryLimitedConnections_ResumedChunkSize(t *testing.T) {
+	s := NewService(Options{Connections: 4, MaxMemoryUsageBytes: 1000, Quiet: true}, nil)
+
+	// e.g., the chunk size of a download started without the limit
+	assert.Equal(t, uint(2), s.memoryLimitedConnections(500))
+}
+
+func Test_memoryGate(t *testing.T) {
+	var heapInuse atomic.Uint64
+	heapInuse.Store(2000)
+
+	mg := newMemoryGate(1000)
+	mg.heapInuse = heapInuse.Load
+
+	// the first chunk is started regardless since no other chunk could free memory
+	assert.NoError(t, mg.acquire(context.Background()))
+
+	acquired := make(chan error)
+	go func() {
+		acquired <- mg.acquire(context.Background())
+	}()
+
+	select {
+	case <-acquired:
+		t.Fatal("chunk started while the heap in use exceeds the threshold")
+	case <-time.After(3 * memoryPollInterval):
+	}
+
+	heapInuse.Store(800)
+	select {
+	case err := <-acquired:
+		assert.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("chunk not started after the heap in use dropped below the threshold")
+	}
+
+	// the threshold is 90% of the limit
+	heapInuse.Store(950)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.ErrorIs(t, mg.acquire(ctx), context.Canceled)
+
+	mg.release()
+	mg.release()
+	assert.NoError(t, mg.acquire(ctx)) // no other chunk in flight anymore
+}
+
+func Test_memoryGate_ConcurrentAcquirers(t *testing.T) {
+	mg := newMemoryGate(1000)
+	mg.heapInuse = func() uint64 {
+		return uint64(mg.inFlight) * 300 // each chunk in flight uses 300 bytes (called with mu held)
+	}
+
+	var acquired atomic.Int32
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var wg sync.WaitGroup
+	for range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if mg.acquire(ctx) == nil {
+				acquired.Add(1)
+			}
+		}()
+	}
+
+	// up to 900 bytes (90% of the limit) are in use before the last chunk is started
+	assert.Eventually(t, func() bool { return acquired.Load() == 4 }, time.Second, memoryPollInterval)
+	time.Sleep(3 * memoryPollInterval)
+	assert.Equal(t, int32(4), acquired.Load())
+
+	// each released chunk makes room for another one
+	mg.release()
+	assert.Eventually(t, func() bool { return acquired.Load() == 5 }, time.Second, memoryPollInterval)
+	time.Sleep(3 * memoryPollInterval)
+	assert.Equal(t, int32(5), acquired.Load())
+
+	cancel()
+	wg.Wait()
+}
+
+func Test_memoryGate_Nil(t *testing.T) {
+	var mg *memoryGate = newMemoryGate(0)
+	assert.Nil(t, mg)
+
+	assert.NoError(t, mg.acquire(context.Background()))
+	mg.release()
+}
+
+func Test_readHeapInuse(t *testing.T) {
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+
+	// the heap may change in between but not by orders of magnitude
+	heapInuse := readHeapInuse()
+	assert.Greater(t, heapInuse, ms.HeapInuse/2)
+	assert.Less(t, heapInuse, ms.HeapInuse*2)
+}
@@ -0,0 +1,269 @@
+package download
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const (
+	metalinkMediaType           = "application/metalink4+xml"
+	maxMetalinkBytes            = 1 << 20 // 1 MiB
+	defaultMaxDiscoveredMirrors = 10
+)
+
+// metalinkDocument represents the relevant parts of a Metalink (RFC 5854) document.
+type metalinkDocument struct {
+	Files []struct {
+		Name string `xml:"name,attr"`
+		URLs []struct {
+			Priority int    `xml:"priority,attr"`
+			Value    string `xml:",chardata"`
+		} `xml:"url"`
+	} `xml:"file"`
+}
+
+// metalinkURLFromHeader returns the absolute URL of the metalink document advertised by the given
+// Link header values (i.e., `<url.metalink>; rel=describedby; type="application/metalink4+xml"`)
+// with relative references resolved against the given request URL. An empty string is returned if
+// there is none.
+func metalinkURLFromHeader(reqURL *url.URL, links []string) string {
+	for _, link := range parseLinkHeader(links) {
+		if !strings.EqualFold(link.params["type"], metalinkMediaType) {
+			continue
+		}
+		if !containsFold(strings.Fields(link.params["rel"]), "describedby") {
+			continue
+		}
+
+		ref, err := url.Parse(link.target)
+		if err != nil {
+			continue
+		}
+		if reqURL != nil {
+			ref = reqURL.ResolveReference(ref)
+		}
+		return ref.String()
+	}
+
+	return ""
+}
+
+// linkValue represents a single link of a Link header (RFC 8288).
+type linkValue struct {
+	target string
+	params map[string]string // keyed by lowercased name with quotes removed from the values
+}
+
+// parseLinkHeader parses the links of the given Link header values. Malformed links are skipped.
+func parseLinkHeader(values []string) []linkValue {
+	var links []linkValue
+	for _, value := range values {
+		for len(value) > 0 {
+			start := strings.IndexByte(value, '<')
+			end := strings.IndexByte(value, '>')
+			if start == -1 || end < start {
+				break
+			}
+
+			link := linkValue{target: value[start+1 : end], params: make(map[string]string)}
+			value = value[end+1:]
+
+			// the parameters extend up to the next comma which is not within a quoted value
+			inQuotes := false
+			i := 0
+			for ; i < len(value); i++ {
+				if value[i] == '"' {
+					inQuotes = !inQuotes
+				} else if value[i] == ',' && !inQuotes {
+					break
+				}
+			}
+			params := value[:i]
+			value = strings.TrimPrefix(value[i:], ",")
+
+			for _, param := range strings.Split(params, ";") {
+				name, val, _ := strings.Cut(param, "=")
+				if name = strings.ToLower(strings.TrimSpace(name)); len(name) > 0 {
+					link.params[name] = strings.Trim(strings.TrimSpace(val), `"`)
+				}
+			}
+
+			links = append(links, link)
+		}
+	}
+
+	return links
+}
+
+// containsFold returns true if any of the given strings is equal to the target under case-folding.
+func containsFold(strs []string, target string) bool {
+	for _, s := range strs {
+		if strings.EqualFold(s, target) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// withDiscoveredMirrors returns the given sources along with the mirrors discovered from their
+// metalink documents if AutoDiscoverMirrors is enabled. The mirrors are added to the stats as well.
+func (s *Service) withDiscoveredMirrors(ctx context.Context, srcFileMetas []sourceFileMetadata, stats *sourceStatsCollector) []sourceFileMetadata {
+	if !s.opts.AutoDiscoverMirrors {
+		return srcFileMetas
+	}
+
+	for _, mirrorMeta := range s.discoverMirrors(ctx, srcFileMetas) {
+		srcFileMetas = append(srcFileMetas, mirrorMeta)
+		stats.addSource(mirrorMeta.url)
+	}
+
+	return srcFileMetas
+}
+
+// discoverMirrors returns the metadata of the mirrors listed in the metalink documents advertised
+// by the given sources (see AutoDiscoverMirrors) up to MaxDiscoveredMirrors. Discovery is best effort
+// so mirrors which fail or do not match the file from the given sources are dropped with a warning.
+func (s *Service) discoverMirrors(ctx context.Context, srcFileMetas []sourceFileMetadata) []sourceFileMetadata {
+	known := make(map[string]bool)
+	for _, sfm := range srcFileMetas {
+		known[sfm.url] = true
+	}
+
+	var mirrorUrls []string
+	fetched := make(map[string]bool)
+	for _, sfm := range srcFileMetas {
+		if len(sfm.metalinkURL) == 0 || fetched[sfm.metalinkURL] {
+			continue
+		}
+		fetched[sfm.metalinkURL] = true
+
+		urls, err := s.fetchMetalinkMirrors(ctx, sfm.metalinkURL, path.Base(sfm.url))
+		if err != nil {
+			s.logln(fmt.Sprintf("warning: skipping metalink %s: %v", sfm.metalinkURL, err))
+			continue
+		}
+
+		for _, u := range urls {
+			if len(mirrorUrls) >= int(s.maxDiscoveredMirrors()) {
+				break
+			}
+
+			normalized, err := NormalizeURL(u)
+			if err != nil || known[normalized] {
+				continue
+			}
+			known[normalized] = true
+			mirrorUrls = append(mirrorUrls, normalized)
+		}
+	}
+
+	mirrorMetas := make([]sourceFileMetadata, len(mirrorUrls))
+	mirrorErrs := make([]error, len(mirrorUrls))
+
+	var wg sync.WaitGroup
+	for i, url := range mirrorUrls {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			mirrorMetas[i], mirrorErrs[i] = s.fetchFileMetadata(ctx, url)
+		}()
+	}
+	wg.Wait()
+
+	var discovered []sourceFileMetadata
+	for i, mirrorMeta := range mirrorMetas {
+		if mirrorErrs[i] != nil {
+			s.logln(fmt.Sprintf("warning: dropping discovered mirror %s: %v", mirrorUrls[i], mirrorErrs[i]))
+			continue
+		}
+
+		if !allSourcesMatchFileMetadata([]sourceFileMetadata{srcFileMetas[0], mirrorMeta}, s.opts.CheckETag, !s.opts.LooseContentTypeMatch) {
+			s.logln(fmt.Sprintf("warning: dropping discovered mirror %s: %v", mirrorUrls[i], ErrSourcesFileMismatch))
+			continue
+		}
+
+		s.logln("discovered mirror:", mirrorUrls[i])
+		discovered = append(discovered, mirrorMeta)
+	}
+
+	return discovered
+}
+
+// fetchMetalinkMirrors retrieves the metalink document at the given URL and returns the http(s)
+// URLs of the file with the given name (or of the only file listed) ordered by priority.
+func (s *Service) fetchMetalinkMirrors(ctx context.Context, metalinkURL, fileName string) ([]string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, metalinkURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, statusError(resp, metalinkURL)
+	}
+
+	var doc metalinkDocument
+	if err := xml.NewDecoder(io.LimitReader(resp.Body, maxMetalinkBytes)).Decode(&doc); err != nil {
+		return nil, err
+	}
+
+	type prioritizedURL struct {
+		url      string
+		priority int
+	}
+
+	var candidates []prioritizedURL
+	for _, file := range doc.Files {
+		if len(doc.Files) > 1 && file.Name != fileName {
+			continue
+		}
+
+		for _, u := range file.URLs {
+			value := strings.TrimSpace(u.Value)
+			if parsed, err := url.Parse(value); err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") {
+				continue
+			}
+
+			priority := u.Priority
+			if priority <= 0 {
+				priority = 999999 // lowest possible priority as per RFC 5854
+			}
+			candidates = append(candidates, prioritizedURL{url: value, priority: priority})
+		}
+	}
+
+	// lower values have higher priority
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].priority < candidates[j].priority
+	})
+
+	urls := make([]string, len(candidates))
+	for i, candidate := range candidates {
+		urls[i] = candidate.url
+	}
+
+	return urls, nil
+}
+
+// maxDiscoveredMirrors returns the configured cap for mirrors discovered via metalinks or the default if not set.
+func (s *Service) maxDiscoveredMirrors() uint {
+	if s.opts.MaxDiscoveredMirrors == 0 {
+		return defaultMaxDiscoveredMirrors
+	}
+
+	return s.opts.MaxDiscoveredMirrors
+}
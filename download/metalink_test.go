@@ -0,0 +1,160 @@
+package download_test
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/gkatanacio/multisource-downloader/cache"
+	"github.com/gkatanacio/multisource-downloader/download"
+)
+
+func Test_Service_Download_AutoDiscoverMirrors(t *testing.T) {
+	content := readFixture(t, "dummy.txt")
+
+	mirror1 := newTestServer(t, serveContent("dummy.txt", content))
+	mirror2 := newTestServer(t, serveContent("dummy.txt", content))
+	mirror3 := newTestServer(t, serveContent("dummy.txt", content))
+	brokenMirror := newTestServer(t, http.NotFoundHandler())
+
+	var primaryURL string
+	primary := newTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/dummy.txt.meta4" {
+			w.Header().Set("Content-Type", "application/metalink4+xml")
+			fmt.Fprintf(w, `<?xml version="1.0" encoding="UTF-8"?>
+<metalink xmlns="urn:ietf:params:xml:ns:metalink">
+  <file name="dummy.txt">
+    <url priority="3">%s/dummy.txt</url>
+    <url priority="1">%s/dummy.txt</url>
+    <url priority="2">%s/dummy.txt</url>
+    <url>%s/dummy.txt</url>
+    <url priority="1">%s</url>
+    <url priority="1">ftp://ftp.example.com/dummy.txt</url>
+  </file>
+</metalink>`, mirror3.URL, brokenMirror.URL, mirror1.URL, mirror2.URL, primaryURL)
+			return
+		}
+
+		w.Header().Add("Link", `</dummy.txt.meta4>; rel=describedby; type="application/metalink4+xml"`)
+		serveContent("dummy.txt", content)(w, r)
+	}))
+	primaryURL = primary.URL + "/dummy.txt"
+
+	testCases := map[string]struct {
+		maxDiscoveredMirrors uint
+		expectedUrls         []string
+	}{
+		"all mirrors": {
+			expectedUrls: []string{primaryURL, mirror1.URL + "/dummy.txt", mirror3.URL + "/dummy.txt", mirror2.URL + "/dummy.txt"},
+		},
+		"bounded": {
+			maxDiscoveredMirrors: 2, // the broken mirror counts as well
+			expectedUrls:         []string{primaryURL, mirror1.URL + "/dummy.txt"},
+		},
+	}
+
+	for scenario, tc := range testCases {
+		t.Run(scenario, func(t *testing.T) {
+			destFilePath := filepath.Join(t.TempDir(), "dummy.txt")
+			downloadService := download.NewService(download.Options{
+				Connections:          4,
+				Timeout:              3,
+				Quiet:                true,
+				DestFilePath:         destFilePath,
+				AutoDiscoverMirrors:  true,
+				MaxDiscoveredMirrors: tc.maxDiscoveredMirrors,
+			}, download.GetMD5Hash)
+
+			err := downloadService.Download([]string{primaryURL})
+			assert.NoError(t, err)
+
+			var urls []string
+			for _, ss := range downloadService.LastDownloadStats().Sources {
+				urls = append(urls, ss.URL)
+			}
+			assert.Equal(t, tc.expectedUrls, urls)
+
+			downloaded, err := os.ReadFile(destFilePath)
+			assert.NoError(t, err)
+			assert.Equal(t, content, downloaded)
+		})
+	}
+}
+
+func Test_Service_Download_AutoDiscoverMirrorsDisabled(t *testing.T) {
+	content := readFixture(t, "dummy.txt")
+
+	var metalinkRequested bool
+	srv := newTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/dummy.txt.meta4" {
+			metalinkRequested = true
+			http.NotFound(w, r)
+			return
+		}
+
+		w.Header().Add("Link", `</dummy.txt.meta4>; rel=describedby; type="application/metalink4+xml"`)
+		serveContent("dummy.txt", content)(w, r)
+	}))
+
+	downloadService := download.NewService(download.Options{
+		Connections:  2,
+		Timeout:      3,
+		Quiet:        true,
+		DestFilePath: filepath.Join(t.TempDir(), "dummy.txt"),
+	}, download.GetMD5Hash)
+
+	err := downloadService.Download([]string{srv.URL + "/dummy.txt"})
+	assert.NoError(t, err)
+	assert.False(t, metalinkRequested)
+	assert.Len(t, downloadService.LastDownloadStats().Sources, 1)
+}
+
+func Test_Service_Download_AutoDiscoverMirrors_CachedHead(t *testing.T) {
+	content := readFixture(t, "dummy.txt")
+	mirror := newTestServer(t, serveContent("dummy.txt", content))
+
+	var headRequests atomic.Int32
+	primary := newTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/dummy.txt.meta4" {
+			w.Header().Set("Content-Type", "application/metalink4+xml")
+			fmt.Fprintf(w, `<?xml version="1.0" encoding="UTF-8"?>
+<metalink xmlns="urn:ietf:params:xml:ns:metalink">
+  <file name="dummy.txt">
+    <url>%s/dummy.txt</url>
+  </file>
+</metalink>`, mirror.URL)
+			return
+		}
+
+		if r.Method == http.MethodHead {
+			headRequests.Add(1)
+		}
+		w.Header().Set("Cache-Control", "max-age=3600")
+		w.Header().Add("Link", `</dummy.txt.meta4>; rel=describedby; type="application/metalink4+xml"`)
+		serveContent("dummy.txt", content)(w, r)
+	}))
+
+	diskCache := cache.NewDiskCache(t.TempDir())
+	for run := range 2 {
+		downloadService := download.NewService(download.Options{
+			Connections:         2,
+			Timeout:             3,
+			Quiet:               true,
+			DestFilePath:        filepath.Join(t.TempDir(), "dummy.txt"),
+			AutoDiscoverMirrors: true,
+			Cache:               diskCache,
+		}, download.GetMD5Hash)
+
+		err := downloadService.Download([]string{primary.URL + "/dummy.txt"})
+		assert.NoError(t, err)
+
+		// the HEAD response of the second run comes from the cache but still advertises the metalink
+		assert.Len(t, downloadService.LastDownloadStats().Sources, 2, "run %d", run)
+	}
+	assert.Equal(t, int32(1), headRequests.Load())
+}
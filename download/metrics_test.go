@@ -0,0 +1,74 @@
+package download_test
+
+import (
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/gkatanacio/multisource-downloader/download"
+)
+
+func Test_Service_Download_Metrics(t *testing.T) {
+	content := readFixture(t, "dummy.txt")
+
+	goodSrv := newTestServer(t, serveContent("dummy.txt", content))
+	badSrv := newTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		serveContent("dummy.txt", content)(w, r)
+	}))
+	goodUrl := goodSrv.URL + "/dummy.txt"
+	badUrl := badSrv.URL + "/dummy.txt"
+
+	registry := prometheus.NewRegistry()
+	downloadService := download.NewService(download.Options{
+		Connections:       2,
+		Timeout:           3,
+		Quiet:             true,
+		DestFilePath:      filepath.Join(t.TempDir(), "dummy.txt"),
+		MetricsRegisterer: registry,
+	}, download.GetMD5Hash)
+
+	err := downloadService.Download([]string{goodUrl, badUrl})
+	assert.NoError(t, err)
+
+	expected := fmt.Sprintf(`
+# HELP msdl_active_downloads Number of downloads currently in progress.
+# TYPE msdl_active_downloads gauge
+msdl_active_downloads 0
+# HELP msdl_bytes_downloaded_total Total number of bytes downloaded per source.
+# TYPE msdl_bytes_downloaded_total counter
+msdl_bytes_downloaded_total{source="%s"} %d
+# HELP msdl_chunk_failures_total Total number of failed chunk download attempts per source and error type.
+# TYPE msdl_chunk_failures_total counter
+msdl_chunk_failures_total{error_type="status_500",source="%s"} 1
+`, goodUrl, len(content), badUrl)
+
+	err = testutil.GatherAndCompare(registry, strings.NewReader(expected),
+		"msdl_active_downloads", "msdl_bytes_downloaded_total", "msdl_chunk_failures_total")
+	assert.NoError(t, err)
+
+	// each chunk attempt is observed: 2 successful from the good source and 1 failed from the bad source
+	assert.Equal(t, 2, testutil.CollectAndCount(registry, "msdl_chunk_duration_seconds"))
+	metricFamilies, err := registry.Gather()
+	assert.NoError(t, err)
+
+	sampleCounts := make(map[string]uint64)
+	for _, mf := range metricFamilies {
+		if mf.GetName() != "msdl_chunk_duration_seconds" {
+			continue
+		}
+		for _, m := range mf.GetMetric() {
+			sampleCounts[m.GetLabel()[0].GetValue()] = m.GetHistogram().GetSampleCount()
+		}
+	}
+	assert.Equal(t, map[string]uint64{goodUrl: 2, badUrl: 1}, sampleCounts)
+}
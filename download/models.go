@@ -1,21 +1,364 @@
 package download
 
 import (
-	"os"
+	"io"
+	"net/http"
 	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/gkatanacio/multisource-downloader/backoff"
+	"github.com/gkatanacio/multisource-downloader/cache"
 )
 
-// Options represents the configuration for the download service.
+// Options represents the configuration for the download service. The runtime dependencies (e.g.,
+// RoundTripper) are left out when persisting the options as YAML (see Config).
 type Options struct {
-	Connections  uint
-	Timeout      uint
-	CheckETag    bool
-	Quiet        bool
-	DestFilePath string
+	Connections  uint              `yaml:"connections,omitempty"`
+	Timeout      uint              `yaml:"timeout,omitempty"`
+	CheckETag    bool              `yaml:"check_etag,omitempty"`
+	Quiet        bool              `yaml:"quiet,omitempty"`
+	DestFilePath string            `yaml:"dest_file_path,omitempty"` // named pipes (FIFOs) are written sequentially without a `.download` file
+	RangeStyle   RangeStyle        `yaml:"range_style,omitempty"`
+	RoundTripper http.RoundTripper `yaml:"-"` // optional custom transport used in place of the one configured by the options
+
+	// WrapTransport decorates the transport configured by the options (e.g., with DialTimeout and Proxy) or
+	// RoundTripper, e.g., for request signing (see auth.NewSigV4RoundTripper) or transport.RoundTripperMiddleware.
+	WrapTransport func(http.RoundTripper) http.RoundTripper `yaml:"-"`
+
+	// Proxy is the URL of the HTTP(S) or SOCKS5 proxy which the requests to the sources go through
+	// (otherwise, the proxy is taken from the HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables).
+	// It does not apply to a custom RoundTripper.
+	Proxy string `yaml:"proxy,omitempty"`
+
+	// UserAgent overrides the User-Agent header of the requests to the sources.
+	UserAgent string `yaml:"user_agent,omitempty"`
+
+	// SourceCredentials are sent to the sources of the respective hosts (i.e., `host` or `host:port`)
+	// unless the request already has an Authorization header (e.g., from RoundTripper).
+	SourceCredentials map[string]SourceCredentials `yaml:"source_credentials,omitempty"`
+
+	// PerSourceHeaders are custom headers (keyed by source URL) sent to the respective sources only,
+	// e.g., when mirrors require different tokens. They are not sent to the URLs which sources redirect to.
+	PerSourceHeaders map[string]map[string]string `yaml:"per_source_headers,omitempty"`
+
+	// AllowPartialSources drops the unhealthy sources and proceeds with the remaining ones instead of
+	// failing the download if any of the sources is unhealthy.
+	AllowPartialSources bool `yaml:"allow_partial_sources,omitempty"`
+
+	// LooseContentTypeMatch only compares the media types of the Content-Type of the sources (e.g.,
+	// `text/plain; charset=utf-8` matches `text/plain`) instead of requiring them to be identical.
+	// This is the inverse of a StrictContentTypeMatch defaulting to true, which a bool cannot express
+	// as its zero value, so that the zero Options (and patches via WithOptions) keep matching strictly.
+	LooseContentTypeMatch bool `yaml:"loose_content_type_match,omitempty"`
+
+	// AutoDiscoverMirrors adds the mirrors listed in the metalink document (RFC 5854) which a source
+	// advertises via a `Link: <url.metalink>; rel=describedby; type="application/metalink4+xml"`
+	// header to the sources. Mirrors which fail or do not match the file are dropped.
+	AutoDiscoverMirrors  bool `yaml:"auto_discover_mirrors,omitempty"`
+	MaxDiscoveredMirrors uint `yaml:"max_discovered_mirrors,omitempty"` // cap for AutoDiscoverMirrors (defaults to 10)
+
+	// ValidateContentType is the expected content type of the file (e.g., `application/gzip`) which is
+	// checked against the one detected from its first 512 bytes (see http.DetectContentType) before
+	// moving it to DestFilePath. The Content-Type reported by the sources is only considered if the
+	// detection is inconclusive (i.e., `application/octet-stream`). Named pipes are not validated.
+	ValidateContentType string `yaml:"validate_content_type,omitempty"`
+
+	// SigstoreVerify verifies the downloaded file against the cosign bundle at SigstoreBundleURL (or a local
+	// path) before moving it to DestFilePath, i.e., its signature must match the public key at
+	// SigstorePublicKeyPath and its Rekor transparency log entry must match the file. The Rekor public keys
+	// are those of the public Sigstore instance unless SIGSTORE_REKOR_PUBLIC_KEY points to another key file.
+	// Only key-based signatures are supported and named pipes are not verified.
+	SigstoreVerify        bool   `yaml:"sigstore_verify,omitempty"`
+	SigstoreBundleURL     string `yaml:"sigstore_bundle_url,omitempty"`
+	SigstorePublicKeyPath string `yaml:"sigstore_public_key_path,omitempty"`
+
+	WriteHashFile     bool   `yaml:"write_hash_file,omitempty"`     // write a `<hash>  <file name>` sidecar file next to the downloaded file
+	HashFileAlgorithm string `yaml:"hash_file_algorithm,omitempty"` // md5, sha256 (default) or sha512
+
+	// AppendMode appends the downloaded contents to the destination file (if existing) instead of overwriting it.
+	AppendMode bool `yaml:"append_mode,omitempty"`
+
+	// Resume continues an interrupted download using the `.download` and `.download.state` files.
+	// The chunk requests are conditional on the ETag of the interrupted download (using If-Range)
+	// and the download restarts from scratch if the file changed in the meantime.
+	Resume bool `yaml:"resume,omitempty"`
+
+	// UseFlock holds an exclusive advisory lock on `<DestFilePath>.lock` (e.g., flock) while downloading so
+	// that concurrent downloads of the same file (from other processes or services) do not race. The lock is
+	// tried again until LockTimeout elapses (i.e., zero fails right away with ErrDownloadAlreadyInProgress).
+	UseFlock    bool          `yaml:"use_flock,omitempty"`
+	LockTimeout time.Duration `yaml:"lock_timeout,omitempty"`
+
+	// SourceRecheckInterval enables periodic health checks of the sources during the download so
+	// that unhealthy sources are skipped when assigning chunks (zero disables the checks).
+	SourceRecheckInterval time.Duration `yaml:"source_recheck_interval,omitempty"`
+
+	// BenchmarkBeforeDownload downloads BenchmarkSampleBytes (defaults to 1 MiB) from the start of the file
+	// of each source concurrently (discarding the data) before the actual download so that the sources
+	// are ranked by their measured throughput rather than by the latency of their HEAD requests, which
+	// is more accurate for sources with similar latency but different bandwidth. Sources whose sample
+	// fails are ranked last. It does not apply to files of unknown length.
+	BenchmarkBeforeDownload bool  `yaml:"benchmark_before_download,omitempty"`
+	BenchmarkSampleBytes    int64 `yaml:"benchmark_sample_bytes,omitempty"`
+
+	// SourceAPIURL is the URL of a JSON API listing the sources of the file (i.e., a response such as
+	// `{"urls": ["http://...", ...]}`), which are fetched with a GET request before each download and
+	// prepended to the given sources. The request goes through the same HTTP client (and thus transport
+	// and RoundTripper) as the chunk requests and carries the UserAgent and the PerSourceHeaders of the
+	// API URL (e.g., for authentication). The download fails with ErrSourceAPIFailed if it cannot be fetched.
+	SourceAPIURL string `yaml:"source_api_url,omitempty"`
+
+	// SourceStrategy determines which source each chunk is initially downloaded from (round-robin by
+	// default), the other sources being tried if it fails. It does not apply to AutoScaleConnections,
+	// which spreads the chunks over the sources in proportion to their weights instead.
+	SourceStrategy SourceStrategy `yaml:"source_strategy,omitempty"`
+
+	// HealthCheckTimeout limits the time spent by HealthCheck (defaults to 5 seconds).
+	HealthCheckTimeout time.Duration `yaml:"health_check_timeout,omitempty"`
+
+	// DialTimeout limits the time spent establishing TCP connections (zero means no separate limit),
+	// which allows detecting unreachable hosts faster than the overall request timeout.
+	DialTimeout time.Duration `yaml:"dial_timeout,omitempty"`
+
+	// ForceIPv4 and ForceIPv6 restrict the connections to the sources to the respective IP version (e.g., in
+	// environments with broken IPv6) such that only its addresses are resolved and connected to.
+	ForceIPv4 bool `yaml:"force_ipv4,omitempty"`
+	ForceIPv6 bool `yaml:"force_ipv6,omitempty"`
+
+	// SocketMark (SO_MARK, requiring CAP_NET_ADMIN) and SocketPriority (SO_PRIORITY) are set on the sockets
+	// of the connections to the sources (zero leaves them unset), e.g., for policy-based routing or traffic
+	// shaping. Connections fail if they cannot be set. Both are only supported on Linux and ignored elsewhere.
+	SocketMark     int `yaml:"socket_mark,omitempty"`
+	SocketPriority int `yaml:"socket_priority,omitempty"`
+
+	// TLSHandshakeTimeout limits the time spent on TLS handshakes and ResponseHeaderTimeout limits the time
+	// spent waiting for the response headers once a request is sent (zero means no separate limit).
+	TLSHandshakeTimeout   time.Duration `yaml:"tls_handshake_timeout,omitempty"`
+	ResponseHeaderTimeout time.Duration `yaml:"response_header_timeout,omitempty"`
+
+	// AutoConnections derives Connections from the number of sources (multiplied by
+	// ConnectionsMultiplier, which defaults to 2) via ResolveAutoConnections.
+	AutoConnections       bool `yaml:"auto_connections,omitempty"`
+	ConnectionsMultiplier uint `yaml:"connections_multiplier,omitempty"`
+
+	// AutoScaleConnections rebalances the download based on the chunk errors every AutoScaleInterval
+	// (defaults to 1 second). A source responsible for more than half of the recent errors (e.g., as it
+	// limits concurrent connections) is initially assigned fewer chunks in favour of the other sources,
+	// while Connections is increased by one (up to MaxConnections) whenever less than 5% of the recent
+	// chunk attempts failed.
+	AutoScaleConnections bool          `yaml:"auto_scale_connections,omitempty"`
+	AutoScaleInterval    time.Duration `yaml:"auto_scale_interval,omitempty"`
+	MaxConnections       uint          `yaml:"max_connections,omitempty"`
+
+	// Verbose logs the headers of each HTTP request and response to stderr (ignored in quiet mode).
+	Verbose bool `yaml:"verbose,omitempty"`
+
+	// HonourRetryAfter waits for the delay indicated by the Retry-After header of 429 and 503 responses
+	// (capped at MaxRetryAfterSleep, which defaults to 30 seconds) before retrying a chunk from the same source.
+	HonourRetryAfter   bool          `yaml:"honour_retry_after,omitempty"`
+	MaxRetryAfterSleep time.Duration `yaml:"max_retry_after_sleep,omitempty"`
+
+	// HeadRetryAttempts is the number of times a failed HEAD request to a source is retried with an
+	// exponential backoff (HeadRetryBackoff, then twice as much and so on) before the source is
+	// considered unhealthy. The Retry-After header overrides the backoff if HonourRetryAfter is set.
+	HeadRetryAttempts uint          `yaml:"head_retry_attempts,omitempty"`
+	HeadRetryBackoff  time.Duration `yaml:"head_retry_backoff,omitempty"`
+
+	// RetryBackoff determines the delay before retrying a failed chunk from the next source, starting
+	// at RetryBackoffBase (defaults to 100 milliseconds) and up to RetryBackoffCap (defaults to 10 seconds).
+	// Chunks are retried immediately if not set.
+	RetryBackoff     backoff.Backoff `yaml:"-"`
+	RetryBackoffBase time.Duration   `yaml:"retry_backoff_base,omitempty"`
+	RetryBackoffCap  time.Duration   `yaml:"retry_backoff_cap,omitempty"`
+
+	// AllowUnknownLength downloads files whose length is not reported by the sources (i.e., without
+	// Content-Length) instead of failing with ErrUnknownContentLength. Such files cannot be chunked so
+	// they are streamed with a single connection from the first source which responds. The ETag check
+	// (if enabled) uses the ETag of that response if it has one.
+	AllowUnknownLength bool `yaml:"allow_unknown_length,omitempty"`
+
+	// MaxFileSize rejects files larger than this many bytes (0 means no limit) with a FileTooLargeError,
+	// either before downloading anything if their length is reported by the sources or, for files of
+	// unknown length (see AllowUnknownLength), once more bytes than this are received.
+	MaxFileSize int64 `yaml:"max_file_size,omitempty"`
+
+	// PreallocateFile allocates the whole file size for the `.download` file before writing any chunk
+	// to reduce fragmentation (only supported on Linux and macOS).
+	PreallocateFile bool `yaml:"preallocate_file,omitempty"`
+
+	// AcceptFullResponseFallback accepts the whole file (i.e., a 200 instead of a 206 response) from the
+	// sources ignoring the Range header, from which the bytes of the chunk are then extracted (with a
+	// warning since each chunk still transfers the file up to its end), and also sources not advertising
+	// `Accept-Ranges`. ErrTruncatedResponse is returned if the file ends before the chunk does. Resuming
+	// from such sources fails with ErrResourceChangedDuringResume since they also ignore If-Range.
+	AcceptFullResponseFallback bool `yaml:"accept_full_response_fallback,omitempty"`
+
+	// ChunkStallTimeout aborts a chunk download (of the default HTTP fetcher) if no bytes of its response
+	// body are received within the duration, e.g., from a server trickling bytes too slowly to ever hit
+	// Timeout, so that the chunk is retried from another source (0 disables this).
+	ChunkStallTimeout time.Duration `yaml:"chunk_stall_timeout,omitempty"`
+
+	// ChunkBytes is the size of each chunk (except for the last one) which makes Connections only
+	// limit the concurrency instead of also determining the number of chunks (0 means the file size
+	// divided by Connections).
+	ChunkBytes int64 `yaml:"chunk_bytes,omitempty"`
+
+	// MaxMemoryUsageBytes bounds the memory used by the in-flight chunks (0 means no bound). The chunks of
+	// the file-size-based chunk size are made small enough for all Connections to fit into it, whereas fewer
+	// Connections are used (with a warning) for ChunkBytes, for PipelineChunks or when resuming a download
+	// with larger chunks. No new chunks are started while the heap in use (see runtime.MemStats) exceeds 90%
+	// of it, unless no other chunk is in flight.
+	MaxMemoryUsageBytes int64 `yaml:"max_memory_usage_bytes,omitempty"`
+
+	// MinChunkBytes is the file size below which the file is downloaded with a single request to the
+	// source with the lowest estimated latency instead of being split into chunks (0 disables this).
+	// Files with PieceHashes are always split into chunks.
+	MinChunkBytes int64 `yaml:"min_chunk_bytes,omitempty"`
+
+	// PieceHashes are the hashes (hex encoded) of the consecutive pieces of PieceSize bytes the file is
+	// divided into, using PieceHashAlgorithm (sha256 by default or sha1 as in `.torrent` files). Each piece
+	// is verified once all of its chunks are written and its chunks are downloaded again (once) from a
+	// different source on mismatch. Note that the TeeWriter (and thus StreamingVerification) receives the
+	// chunks before their pieces are verified. Downloads to named pipes fail with ErrNamedPipeUnsupported
+	// since the pieces cannot be read back.
+	PieceSize          int64    `yaml:"piece_size,omitempty"`
+	PieceHashes        []string `yaml:"piece_hashes,omitempty"`
+	PieceHashAlgorithm string   `yaml:"piece_hash_algorithm,omitempty"`
+
+	// SegmentedTempFiles writes each chunk to its own temp file (i.e., `<destFile>.download.chunk-N`) instead
+	// of writing the chunks concurrently to the `.download` file, which avoids contention on file locks on some
+	// filesystems. The segments are copied in order to the `.download` file once all chunks are downloaded
+	// (which is when the TeeWriter receives them) and are removed either way.
+	SegmentedTempFiles bool `yaml:"segmented_temp_files,omitempty"`
+
+	// WriteBufferSize is the size of the buffer used for writing each chunk to the `.download` file
+	// (0 means unbuffered).
+	WriteBufferSize int `yaml:"write_buffer_size,omitempty"`
+
+	// ManifestPath is the path of the JSON manifest recording the provenance of the download
+	// which is written once the download is successfully completed (empty means no manifest).
+	ManifestPath string `yaml:"manifest_path,omitempty"`
+
+	// AuditLogPath is the path of the append-only log to which a JSON line (see AuditRecord) is added
+	// for each completed download.
+	AuditLogPath string `yaml:"audit_log_path,omitempty"`
+
+	// IfNoneMatch is the ETag of a previous download which is sent in the `If-None-Match` header
+	// of the HEAD requests such that Download returns ErrNotModified if the file is unchanged.
+	IfNoneMatch string `yaml:"if_none_match,omitempty"`
+
+	// SkipIfUnmodified makes Download return ErrNotModified without downloading if a file already exists
+	// at DestFilePath and the first source reports no modification since (see Service.IsModified).
+	SkipIfUnmodified bool `yaml:"skip_if_unmodified,omitempty"`
+
+	// TeeWriter receives a copy of the downloaded bytes in order as the chunks are completed (or as they
+	// are written to the pipe when DestFilePath is a named pipe).
+	TeeWriter io.Writer `yaml:"-"`
+
+	// OnChunkStart is called when a chunk starts downloading, i.e., once a connection is available for it and
+	// before requesting it from the given source, so that queuing can be told apart from transfer times.
+	// OnProgress is called once the chunk is completed (i.e., written to the `.download` file) with the
+	// source which delivered it (another one if it was retried). Both are called once per chunk of Download
+	// (from concurrent goroutines) and OnChunkStart always before OnProgress for the same chunk. Downloads to
+	// named pipes fail with ErrNamedPipeUnsupported if either is set since the chunks are not tracked then.
+	OnChunkStart ChunkCallback `yaml:"-"`
+	OnProgress   ChunkCallback `yaml:"-"`
+
+	// RetryOnETagMismatch restarts the download from scratch (re-probing all sources for fresh ETags)
+	// when the downloaded file does not match the ETag, up to MaxETagRetries times (defaults to 3).
+	// This requires CheckETag to be enabled.
+	RetryOnETagMismatch bool `yaml:"retry_on_etag_mismatch,omitempty"`
+	MaxETagRetries      uint `yaml:"max_etag_retries,omitempty"`
+
+	// NoClobber makes the download fail with ErrFileAlreadyExists instead of overwriting an
+	// existing destination file (not applicable in append mode).
+	NoClobber bool `yaml:"no_clobber,omitempty"`
+
+	// StreamingVerification calculates the hash for the ETag check as the chunks are written instead of
+	// reading the whole file again after the download. This needs one of the built-in ETag calculators
+	// (see HashCalculatorFor) and the whole file is read again for custom ones.
+	StreamingVerification bool `yaml:"streaming_verification,omitempty"`
+
+	// CompressStateFile writes the state file of the ongoing download compressed with zstd
+	// (i.e., `.download.state.zst`). Resuming reads either kind of state file.
+	CompressStateFile bool `yaml:"compress_state_file,omitempty"`
+
+	// WriteWorkers is the number of goroutines writing the downloaded chunks to the `.download` file
+	// separately from the downloading goroutines, which queue up to WriteQueueDepth chunks for them
+	// (0 means each chunk is written by the goroutine which downloaded it).
+	WriteWorkers    uint `yaml:"write_workers,omitempty"`
+	WriteQueueDepth uint `yaml:"write_queue_depth,omitempty"`
+
+	// PipelineChunks is the number of fetched chunks each of the Connections can have pending to be
+	// written while it already fetches its next chunk (0 or 1 means each connection writes a chunk
+	// before fetching the next one). Unlike WriteWorkers, this is a lookahead per connection.
+	// It is not applied with AutoScaleConnections.
+	PipelineChunks uint `yaml:"pipeline_chunks,omitempty"`
+
+	// ChunkBufferPool reuses the buffers the chunks are read into once they are written instead of
+	// allocating a new buffer for every chunk (only for the fetchers supporting it, like the default).
+	ChunkBufferPool bool `yaml:"chunk_buffer_pool,omitempty"`
+
+	// Cache stores the HEAD and ranged GET responses of the default HTTP transport which are then
+	// served from the cache within their Cache-Control max-age (and revalidated once expired). The health
+	// probes (see SourceRecheckInterval and HealthCheck) always reach the sources.
+	Cache *cache.DiskCache `yaml:"-"`
+
+	// GeoDBPath is the path of a MaxMind GeoLite2 City database used for prioritizing the sources
+	// by a blend of their estimated latency and their distance from the local host (50/50).
+	// GeoLocalIP overrides the detected local IP address (e.g., with the public one if behind NAT).
+	GeoDBPath  string `yaml:"geo_db_path,omitempty"`
+	GeoLocalIP string `yaml:"geo_local_ip,omitempty"`
+
+	// DirectoryDepth is the number of levels of subdirectory index pages followed by DownloadDirectory
+	// (0 means only the files listed by the given index page).
+	DirectoryDepth uint `yaml:"directory_depth,omitempty"`
+
+	// TracerProvider is used for creating OpenTelemetry spans (defaults to the global provider).
+	TracerProvider trace.TracerProvider `yaml:"-"`
+
+	// MetricsRegisterer is used for registering Prometheus metrics (nil means no metrics).
+	MetricsRegisterer prometheus.Registerer `yaml:"-"`
+
+	// Fetcher replaces the default HTTP based transport for retrieving file metadata and contents.
+	Fetcher Fetcher `yaml:"-"`
 }
 
-// ETagCalculator represents a function that calculates the ETag of a file.
-type ETagCalculator func(file *os.File) (string, error)
+const defaultConnectionsMultiplier = 2
+
+// ResolveAutoConnections sets Connections based on the number of sources if AutoConnections is enabled.
+func (o *Options) ResolveAutoConnections(numSources int) {
+	if !o.AutoConnections {
+		return
+	}
+
+	multiplier := o.ConnectionsMultiplier
+	if multiplier == 0 {
+		multiplier = defaultConnectionsMultiplier
+	}
+
+	o.Connections = uint(numSources) * multiplier
+}
+
+// RangeStyle represents how the end offset of a Range request header is interpreted by the sources.
+type RangeStyle uint
+
+const (
+	// RangeStyleInclusive is the RFC 7233 style where the end offset refers to the last byte of the range.
+	RangeStyleInclusive RangeStyle = iota
+	// RangeStyleExclusive is for unconventional servers where the end offset refers to the byte after the range.
+	RangeStyleExclusive
+)
+
+// ETagCalculator represents a function that calculates the ETag of a file's contents.
+// Implementations are expected to seek to the start before reading.
+type ETagCalculator func(r io.ReadSeeker) (string, error)
+
+// ChunkCallback is notified about the chunk with the given index covering the given number of bytes
+// from the given offset of the file, which is downloaded from the source with the given URL.
+type ChunkCallback func(chunkIndex int, source string, offset, size int64)
 
 // fileMetadata is comprised of relevant metadata for a download file.
 type fileMetadata struct {
@@ -28,6 +371,9 @@ type fileMetadata struct {
 // some details for its corresponding download source.
 type sourceFileMetadata struct {
 	fileMetadata
-	url        string
-	estLatency time.Duration
+	url         string
+	estLatency  time.Duration
+	bytesPerSec float64 // measured by BenchmarkBeforeDownload (0 if not measured)
+	distanceKm  float64 // from the local host (negative if unknown)
+	metalinkURL string
 }
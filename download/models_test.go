@@ -0,0 +1,40 @@
+package download_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/gkatanacio/multisource-downloader/download"
+)
+
+func Test_Options_ResolveAutoConnections(t *testing.T) {
+	testCases := map[string]struct {
+		opts                download.Options
+		numSources          int
+		expectedConnections uint
+	}{
+		"auto with default multiplier": {
+			opts:                download.Options{Connections: 5, AutoConnections: true},
+			numSources:          3,
+			expectedConnections: 6,
+		},
+		"auto with custom multiplier": {
+			opts:                download.Options{Connections: 5, AutoConnections: true, ConnectionsMultiplier: 4},
+			numSources:          3,
+			expectedConnections: 12,
+		},
+		"not auto": {
+			opts:                download.Options{Connections: 5, ConnectionsMultiplier: 4},
+			numSources:          3,
+			expectedConnections: 5,
+		},
+	}
+
+	for scenario, tc := range testCases {
+		t.Run(scenario, func(t *testing.T) {
+			tc.opts.ResolveAutoConnections(tc.numSources)
+			assert.Equal(t, tc.expectedConnections, tc.opts.Connections)
+		})
+	}
+}
@@ -0,0 +1,65 @@
+package download
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+)
+
+// IsModified tells whether the file at the given source URL was modified since the local copy at the
+// given path was obtained, i.e., it sends a HEAD request with the modification time of the local file
+// in the `If-Modified-Since` header and returns false if the server responds with 304 Not Modified
+// or true if it responds with 200 OK. Any other response is an error.
+func (s *Service) IsModified(ctx context.Context, sourceURL, localFilePath string) (bool, error) {
+	fileInfo, err := os.Stat(localFilePath)
+	if err != nil {
+		return false, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, sourceURL, nil)
+	if err != nil {
+		return false, err
+	}
+	s.setSourceHeaders(req, sourceURL)
+	req.Header.Set("If-Modified-Since", fileInfo.ModTime().UTC().Format(http.TimeFormat))
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusNotModified:
+		return false, nil
+	case http.StatusOK:
+		return true, nil
+	default:
+		return false, statusError(resp, sourceURL)
+	}
+}
+
+// checkUnmodified returns ErrNotModified if SkipIfUnmodified is enabled and the first source reports
+// no modification since the file at DestFilePath was downloaded. Nothing is checked if there is no
+// such file yet since there is nothing to skip then.
+func (s *Service) checkUnmodified(ctx context.Context, sourceUrls []string) error {
+	if !s.opts.SkipIfUnmodified || len(sourceUrls) == 0 {
+		return nil
+	}
+
+	modified, err := s.IsModified(ctx, sourceUrls[0], s.opts.DestFilePath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if !modified {
+		return fmt.Errorf("%w: %s", ErrNotModified, sourceUrls[0])
+	}
+
+	return nil
+}
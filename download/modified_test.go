@@ -0,0 +1,133 @@
+package download_test
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/gkatanacio/multisource-downloader/download"
+)
+
+// serveContentModifiedAt serves the given content as last modified at the given time, responding with
+// 304 Not Modified to conditional requests whose If-Modified-Since is not before it.
+func serveContentModifiedAt(fileName string, content []byte, lastModified time.Time, receivedIfModifiedSince *atomic.Value) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead {
+			receivedIfModifiedSince.Store(r.Header.Get("If-Modified-Since"))
+		}
+		http.ServeContent(w, r, fileName, lastModified, bytes.NewReader(content))
+	}
+}
+
+func Test_Service_IsModified(t *testing.T) {
+	content := readFixture(t, "dummy.txt")
+	lastModified := time.Date(2024, time.March, 1, 12, 0, 0, 0, time.UTC)
+
+	var receivedIfModifiedSince atomic.Value
+	srv := newTestServer(t, serveContentModifiedAt("dummy.txt", content, lastModified, &receivedIfModifiedSince))
+
+	testCases := map[string]struct {
+		localModTime     time.Time
+		expectedModified bool
+	}{
+		"200 since modified after the local copy": {
+			localModTime:     lastModified.Add(-time.Hour),
+			expectedModified: true,
+		},
+		"304 since unmodified since the local copy": {
+			localModTime:     lastModified.Add(time.Hour),
+			expectedModified: false,
+		},
+		"304 since last modified with the local copy": {
+			localModTime:     lastModified,
+			expectedModified: false,
+		},
+	}
+
+	for scenario, tc := range testCases {
+		t.Run(scenario, func(t *testing.T) {
+			localFilePath := writeTempFile(t, "dummy.txt", content)
+			assert.NoError(t, os.Chtimes(localFilePath, tc.localModTime, tc.localModTime))
+
+			downloadService := download.NewService(download.Options{Timeout: 3, Quiet: true}, download.GetMD5Hash)
+
+			modified, err := downloadService.IsModified(context.Background(), srv.URL+"/dummy.txt", localFilePath)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectedModified, modified)
+			assert.Equal(t, tc.localModTime.Format(http.TimeFormat), receivedIfModifiedSince.Load())
+		})
+	}
+}
+
+func Test_Service_IsModified_Failed(t *testing.T) {
+	srv := newTestServer(t, http.NotFoundHandler())
+	downloadService := download.NewService(download.Options{Timeout: 3, Quiet: true}, download.GetMD5Hash)
+
+	_, err := downloadService.IsModified(context.Background(), srv.URL+"/dummy.txt", writeTempFile(t, "dummy.txt", nil))
+	assert.ErrorContains(t, err, "received 404 response")
+
+	_, err = downloadService.IsModified(context.Background(), srv.URL+"/dummy.txt", filepath.Join(t.TempDir(), "missing.txt"))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func Test_Service_Download_SkipIfUnmodified(t *testing.T) {
+	content := readFixture(t, "dummy.txt")
+	lastModified := time.Date(2024, time.March, 1, 12, 0, 0, 0, time.UTC)
+
+	var receivedIfModifiedSince atomic.Value
+	srv := newTestServer(t, serveContentModifiedAt("dummy.txt", content, lastModified, &receivedIfModifiedSince))
+
+	testCases := map[string]struct {
+		existingContent []byte // nil means no existing file
+		localModTime    time.Time
+		expectedErr     error
+		expectedContent []byte
+	}{
+		"unmodified since the existing file": {
+			existingContent: []byte("outdated"),
+			localModTime:    lastModified.Add(time.Hour),
+			expectedErr:     download.ErrNotModified,
+			expectedContent: []byte("outdated"),
+		},
+		"modified since the existing file": {
+			existingContent: []byte("outdated"),
+			localModTime:    lastModified.Add(-time.Hour),
+			expectedContent: content,
+		},
+		"no existing file": {
+			expectedContent: content,
+		},
+	}
+
+	for scenario, tc := range testCases {
+		t.Run(scenario, func(t *testing.T) {
+			destFilePath := filepath.Join(t.TempDir(), "dummy.txt")
+			if tc.existingContent != nil {
+				assert.NoError(t, os.WriteFile(destFilePath, tc.existingContent, 0644))
+				assert.NoError(t, os.Chtimes(destFilePath, tc.localModTime, tc.localModTime))
+			}
+
+			downloadService := download.NewService(download.Options{
+				Connections:      2,
+				Timeout:          3,
+				Quiet:            true,
+				DestFilePath:     destFilePath,
+				SkipIfUnmodified: true,
+			}, download.GetMD5Hash)
+
+			err := downloadService.Download([]string{srv.URL + "/dummy.txt"})
+			assert.ErrorIs(t, err, tc.expectedErr)
+
+			downloaded, err := os.ReadFile(destFilePath)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectedContent, downloaded)
+		})
+	}
+}
@@ -0,0 +1,75 @@
+package download
+
+import (
+	"context"
+)
+
+// Rule routes the source URLs matched by MatchFn through the given Service (i.e., its transport).
+type Rule struct {
+	MatchFn func(url string) bool
+	Service *Service
+}
+
+// MultiService downloads a file from sources which require different configurations (e.g., internal
+// mirrors behind a proxy and external sources accessed directly). Each source URL is handled by the
+// Service of the first matching rule or by the default Service if none matches.
+type MultiService struct {
+	defaultService *Service
+	rules          []Rule
+}
+
+// NewMultiService creates a MultiService with the given default Service and rules (matched in order).
+// The download itself (e.g., destination file and connections) is configured by the default Service.
+func NewMultiService(defaultService *Service, rules ...Rule) *MultiService {
+	return &MultiService{
+		defaultService: defaultService,
+		rules:          rules,
+	}
+}
+
+// Download downloads the file from all of the given sources concurrently like Service.Download
+// does, where the requests to each source go through the transport of its matching Service.
+func (ms *MultiService) Download(ctx context.Context, sourceUrls []string) error {
+	opts := ms.defaultService.opts
+	opts.Fetcher = &routingFetcher{ms: ms}
+
+	s := newService(serviceConfig{
+		opts:          opts,
+		calculateETag: ms.defaultService.calculateETag,
+		httpClient:    ms.defaultService.httpClient,
+		logger:        ms.defaultService.logger,
+	})
+
+	return s.downloadWithContext(ctx, sourceUrls)
+}
+
+// serviceFor returns the Service handling the given source URL.
+func (ms *MultiService) serviceFor(url string) *Service {
+	for _, rule := range ms.rules {
+		if rule.MatchFn(url) {
+			return rule.Service
+		}
+	}
+
+	return ms.defaultService
+}
+
+// routingFetcher delegates to the Fetcher of the Service handling each source URL.
+type routingFetcher struct {
+	ms *MultiService
+}
+
+// Head implements Fetcher.
+func (rf *routingFetcher) Head(ctx context.Context, url string) (*HeadResult, error) {
+	return rf.ms.serviceFor(url).fetcher.Head(ctx, url)
+}
+
+// GetRange implements Fetcher.
+func (rf *routingFetcher) GetRange(ctx context.Context, url string, start, end int64) ([]byte, error) {
+	return rf.ms.serviceFor(url).fetcher.GetRange(ctx, url, start, end)
+}
+
+// GetRangeInto implements bufferedFetcher.
+func (rf *routingFetcher) GetRangeInto(ctx context.Context, url string, start, end int64, buf []byte) ([]byte, error) {
+	return getRangeInto(ctx, rf.ms.serviceFor(url).fetcher, url, start, end, buf)
+}
@@ -0,0 +1,91 @@
+package download_test
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/gkatanacio/multisource-downloader/download"
+)
+
+// bearerRoundTripper sets the Authorization header with the given token on every request before passing
+// it on to the next transport (or http.DefaultTransport if nil).
+type bearerRoundTripper struct {
+	token string
+	next  http.RoundTripper
+}
+
+func (brt bearerRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.Header.Set("Authorization", "Bearer "+brt.token)
+	if brt.next == nil {
+		return http.DefaultTransport.RoundTrip(req)
+	}
+	return brt.next.RoundTrip(req)
+}
+
+func Test_MultiService_Download(t *testing.T) {
+	content := readFixture(t, "dummy.png")
+
+	// each server only accepts requests with its own token
+	newAuthServer := func(token string, served *atomic.Int64) string {
+		srv := newTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Authorization") != "Bearer "+token {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			served.Add(1)
+			serveContent("dummy.png", content)(w, r)
+		}))
+		return srv.URL
+	}
+
+	var internalServed, externalServed, publicServed atomic.Int64
+	internalUrl := newAuthServer("internal", &internalServed)
+	externalUrl := newAuthServer("external", &externalServed)
+	publicSrv := newTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		publicServed.Add(1)
+		serveContent("dummy.png", content)(w, r)
+	}))
+
+	destFilePath := filepath.Join(t.TempDir(), "dummy.png")
+	defaultService := download.NewService(download.Options{
+		Connections:  6,
+		Timeout:      3,
+		Quiet:        true,
+		DestFilePath: destFilePath,
+	}, download.GetMD5Hash)
+
+	multiService := download.NewMultiService(defaultService,
+		download.Rule{
+			MatchFn: func(url string) bool { return strings.HasPrefix(url, internalUrl) },
+			Service: download.NewService(download.Options{Timeout: 3, RoundTripper: bearerRoundTripper{token: "internal"}}, nil),
+		},
+		download.Rule{
+			MatchFn: func(url string) bool { return strings.HasPrefix(url, externalUrl) },
+			Service: download.NewService(download.Options{Timeout: 3, RoundTripper: bearerRoundTripper{token: "external"}}, nil),
+		},
+	)
+
+	err := multiService.Download(context.Background(), []string{
+		internalUrl + "/dummy.png",
+		externalUrl + "/dummy.png",
+		publicSrv.URL + "/dummy.png",
+	})
+	assert.NoError(t, err)
+
+	downloaded, err := os.ReadFile(destFilePath)
+	assert.NoError(t, err)
+	assert.Equal(t, content, downloaded)
+
+	// every source served its HEAD request and at least one chunk
+	assert.Greater(t, internalServed.Load(), int64(1))
+	assert.Greater(t, externalServed.Load(), int64(1))
+	assert.Greater(t, publicServed.Load(), int64(1))
+}
@@ -0,0 +1,186 @@
+package download
+
+import (
+	"log/slog"
+	"maps"
+	"net/http"
+	"reflect"
+	"slices"
+	"time"
+)
+
+// ServiceOption configures a Service created via NewServiceWithOptions.
+type ServiceOption func(*serviceConfig)
+
+// serviceConfig holds everything needed for constructing a Service.
+type serviceConfig struct {
+	opts          Options
+	timeout       time.Duration
+	calculateETag ETagCalculator
+	httpClient    *http.Client // overrides the client derived from the options if set
+	logger        *slog.Logger
+}
+
+// NewServiceWithOptions creates a Service from the given functional options, which are applied
+// in order (i.e., later options override earlier ones). Unless overridden, the service uses 5
+// connections, a 10-second timeout and MD5 hashes for ETag checking.
+func NewServiceWithOptions(opts ...ServiceOption) *Service {
+	cfg := serviceConfig{
+		opts: Options{
+			Connections: 5,
+		},
+		timeout:       10 * time.Second,
+		calculateETag: GetMD5Hash,
+	}
+
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	return newService(cfg)
+}
+
+// WithBaseOptions replaces all the options with the given ones (including the timeout).
+func WithBaseOptions(opts Options) ServiceOption {
+	return func(cfg *serviceConfig) {
+		cfg.opts = opts
+		cfg.timeout = time.Second * time.Duration(opts.Timeout)
+	}
+}
+
+// WithConnections sets the max number of concurrent connections.
+func WithConnections(n uint) ServiceOption {
+	return func(cfg *serviceConfig) {
+		cfg.opts.Connections = n
+	}
+}
+
+// WithTimeout sets the timeout for each connection.
+func WithTimeout(d time.Duration) ServiceOption {
+	return func(cfg *serviceConfig) {
+		cfg.timeout = d
+	}
+}
+
+// WithCheckETag enables or disables checking the ETag of the downloaded file.
+func WithCheckETag(checkETag bool) ServiceOption {
+	return func(cfg *serviceConfig) {
+		cfg.opts.CheckETag = checkETag
+	}
+}
+
+// WithQuiet enables or disables quiet mode.
+func WithQuiet(quiet bool) ServiceOption {
+	return func(cfg *serviceConfig) {
+		cfg.opts.Quiet = quiet
+	}
+}
+
+// WithDestFilePath sets the destination file path.
+func WithDestFilePath(filePath string) ServiceOption {
+	return func(cfg *serviceConfig) {
+		cfg.opts.DestFilePath = filePath
+	}
+}
+
+// WithETagCalculator sets the function used for calculating the ETag of the downloaded file.
+func WithETagCalculator(f ETagCalculator) ServiceOption {
+	return func(cfg *serviceConfig) {
+		cfg.calculateETag = f
+	}
+}
+
+// WithHTTPClient sets the HTTP client to be used as is (i.e., timeout and transport related
+// options are not applied to it).
+func WithHTTPClient(c *http.Client) ServiceOption {
+	return func(cfg *serviceConfig) {
+		cfg.httpClient = c
+	}
+}
+
+// WithFetcher sets a custom Fetcher in place of the default HTTP based one.
+func WithFetcher(f Fetcher) ServiceOption {
+	return func(cfg *serviceConfig) {
+		cfg.opts.Fetcher = f
+	}
+}
+
+// WithLogger sets the logger for progress messages (which are otherwise printed to stdout).
+func WithLogger(l *slog.Logger) ServiceOption {
+	return func(cfg *serviceConfig) {
+		cfg.logger = l
+	}
+}
+
+// Clone returns a copy of the service with the same configuration which can then be modified
+// independently, including the maps and slices of the options (the underlying HTTP transport is
+// shared since it is safe for concurrent use).
+func (s *Service) Clone() *Service {
+	return s.clone(s.opts, false)
+}
+
+// WithOptions returns a copy of the service where the non-zero fields of the given options override
+// the ones of the service (copying the maps and slices of both, see Clone). Note that boolean fields can
+// therefore only be enabled this way.
+// The HTTP client is recreated if any of the options affecting it (i.e., Timeout, RoundTripper, WrapTransport,
+// DialTimeout, TLSHandshakeTimeout, ResponseHeaderTimeout, ForceIPv4, ForceIPv6, SocketMark, SocketPriority,
+// Verbose, Proxy or SourceCredentials) is overridden.
+func (s *Service) WithOptions(patch Options) *Service {
+	opts := mergeOptions(s.opts, patch)
+
+	recreateClient := patch.Timeout > 0 || patch.RoundTripper != nil || patch.WrapTransport != nil || patch.DialTimeout > 0 ||
+		patch.TLSHandshakeTimeout > 0 || patch.ResponseHeaderTimeout > 0 || patch.ForceIPv4 || patch.ForceIPv6 ||
+		patch.SocketMark != 0 || patch.SocketPriority != 0 || patch.Verbose || len(patch.Proxy) > 0 || len(patch.SourceCredentials) > 0
+	return s.clone(opts, recreateClient)
+}
+
+// mergeOptions returns the base options where the non-zero fields of the given patch override the
+// ones of the base.
+func mergeOptions(base, patch Options) Options {
+	merged := reflect.ValueOf(&base).Elem()
+	patchValue := reflect.ValueOf(patch)
+	for i := range patchValue.NumField() {
+		if field := patchValue.Field(i); !field.IsZero() {
+			merged.Field(i).Set(field)
+		}
+	}
+
+	return base
+}
+
+// clone returns a copy of the service with the given options which either uses a copy of the
+// HTTP client of the service or a new one derived from the options.
+func (s *Service) clone(opts Options, recreateClient bool) *Service {
+	cfg := serviceConfig{
+		opts:          cloneOptions(opts), // the maps and slices may be the ones of the service or of a patch
+		timeout:       time.Second * time.Duration(opts.Timeout),
+		calculateETag: s.calculateETag,
+		logger:        s.logger,
+	}
+
+	if !recreateClient {
+		httpClient := *s.httpClient
+		cfg.httpClient = &httpClient
+	} else if opts.Timeout == 0 {
+		cfg.timeout = s.httpClient.Timeout // e.g., set via WithTimeout
+	}
+
+	return newService(cfg)
+}
+
+// cloneOptions returns a deep copy of the options, i.e., their maps and slices are copied so that
+// modifying those of the copy does not affect the original. The interfaces, functions and pointers
+// (e.g., Fetcher, OnProgress and Cache) are meant to be shared and are therefore not copied.
+func cloneOptions(opts Options) Options {
+	opts.SourceCredentials = maps.Clone(opts.SourceCredentials)
+	if opts.PerSourceHeaders != nil {
+		headers := make(map[string]map[string]string, len(opts.PerSourceHeaders))
+		for url, sourceHeaders := range opts.PerSourceHeaders {
+			headers[url] = maps.Clone(sourceHeaders)
+		}
+		opts.PerSourceHeaders = headers
+	}
+	opts.PieceHashes = slices.Clone(opts.PieceHashes)
+
+	return opts
+}
@@ -0,0 +1,239 @@
+package download
+
+import (
+	"bytes"
+	"io"
+	"log/slog"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_NewServiceWithOptions_Defaults(t *testing.T) {
+	s := NewServiceWithOptions()
+
+	assert.Equal(t, uint(5), s.opts.Connections)
+	assert.Equal(t, 10*time.Second, s.httpClient.Timeout)
+	assert.NotNil(t, s.calculateETag)
+}
+
+func Test_NewServiceWithOptions_Compose(t *testing.T) {
+	calculateETag := func(r io.ReadSeeker) (string, error) { return "custom", nil }
+
+	s := NewServiceWithOptions(
+		WithConnections(8),
+		WithTimeout(3*time.Second),
+		WithCheckETag(true),
+		WithDestFilePath("destfile.txt"),
+		WithETagCalculator(calculateETag),
+	)
+
+	assert.Equal(t, uint(8), s.opts.Connections)
+	assert.Equal(t, 3*time.Second, s.httpClient.Timeout)
+	assert.True(t, s.opts.CheckETag)
+	assert.Equal(t, "destfile.txt", s.opts.DestFilePath)
+
+	eTag, err := s.calculateETag(nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "custom", eTag)
+}
+
+func Test_NewServiceWithOptions_LaterOverridesEarlier(t *testing.T) {
+	s := NewServiceWithOptions(
+		WithConnections(8),
+		WithTimeout(3*time.Second),
+		WithBaseOptions(Options{Connections: 2, Timeout: 7, DestFilePath: "base.txt"}),
+		WithConnections(4),
+	)
+
+	assert.Equal(t, uint(4), s.opts.Connections)
+	assert.Equal(t, 7*time.Second, s.httpClient.Timeout)
+	assert.Equal(t, "base.txt", s.opts.DestFilePath)
+}
+
+func Test_NewServiceWithOptions_HTTPClient(t *testing.T) {
+	httpClient := &http.Client{Timeout: time.Minute}
+
+	s := NewServiceWithOptions(WithTimeout(time.Second), WithHTTPClient(httpClient))
+
+	assert.Same(t, httpClient, s.httpClient)
+	assert.Same(t, httpClient, s.fetcher.(*schemeFetcher).fallback.(*httpFetcher).client)
+	assert.Equal(t, time.Minute, httpClient.Timeout, "custom client should be used as is")
+}
+
+func Test_NewServiceWithOptions_Logger(t *testing.T) {
+	var logs bytes.Buffer
+	s := NewServiceWithOptions(WithLogger(slog.New(slog.NewTextHandler(&logs, nil))))
+
+	s.logln("chunk", 1, "downloaded")
+	assert.Contains(t, logs.String(), `msg="chunk 1 downloaded"`)
+
+	logs.Reset()
+	s = NewServiceWithOptions(WithLogger(slog.New(slog.NewTextHandler(&logs, nil))), WithQuiet(true))
+
+	s.logln("chunk", 1, "downloaded")
+	assert.Empty(t, logs.String())
+}
+
+func Test_Service_Clone(t *testing.T) {
+	original := NewService(Options{Connections: 4, Timeout: 3, DestFilePath: "original.txt"}, GetMD5Hash)
+
+	clone := original.Clone()
+	clone.opts.Connections = 8
+	clone.opts.DestFilePath = "clone.txt"
+	clone.httpClient.Timeout = time.Minute
+
+	assert.Equal(t, uint(4), original.opts.Connections)
+	assert.Equal(t, "original.txt", original.opts.DestFilePath)
+	assert.Equal(t, 3*time.Second, original.httpClient.Timeout)
+	assert.NotSame(t, original.httpClient, clone.httpClient)
+	assert.NotNil(t, clone.calculateETag)
+
+	// the fetcher of the clone uses the clone's own client
+	assert.Same(t, clone.httpClient, clone.fetcher.(*schemeFetcher).fallback.(*httpFetcher).client)
+}
+
+func Test_Service_Clone_DeepCopy(t *testing.T) {
+	original := NewService(Options{
+		Timeout:           3,
+		PerSourceHeaders:  map[string]map[string]string{"http://a.com/f": {"X-Token": "original"}},
+		SourceCredentials: map[string]SourceCredentials{"a.com": {BearerToken: "original"}},
+		PieceHashes:       []string{"00", "11"},
+	}, GetMD5Hash)
+
+	testCases := map[string]struct {
+		clone func() *Service
+	}{
+		"Clone": {
+			clone: original.Clone,
+		},
+		"WithOptions": {
+			clone: func() *Service { return original.WithOptions(Options{Connections: 8}) },
+		},
+	}
+
+	for scenario, tc := range testCases {
+		t.Run(scenario, func(t *testing.T) {
+			clone := tc.clone()
+			clone.opts.PerSourceHeaders["http://a.com/f"]["X-Token"] = "clone"
+			clone.opts.PerSourceHeaders["http://b.com/f"] = map[string]string{"X-Token": "clone"}
+			clone.opts.SourceCredentials["a.com"] = SourceCredentials{BearerToken: "clone"}
+			clone.opts.PieceHashes[0] = "ff"
+
+			assert.Equal(t, map[string]map[string]string{"http://a.com/f": {"X-Token": "original"}}, original.opts.PerSourceHeaders)
+			assert.Equal(t, map[string]SourceCredentials{"a.com": {BearerToken: "original"}}, original.opts.SourceCredentials)
+			assert.Equal(t, []string{"00", "11"}, original.opts.PieceHashes)
+			assert.Equal(t, "original", original.fetcher.(*schemeFetcher).fallback.(*httpFetcher).headers["http://a.com/f"]["X-Token"])
+		})
+	}
+}
+
+func Test_Service_WithOptions_PatchNotShared(t *testing.T) {
+	patch := Options{PerSourceHeaders: map[string]map[string]string{"http://a.com/f": {"X-Token": "patch"}}}
+	s := NewService(Options{Timeout: 3}, GetMD5Hash).WithOptions(patch)
+
+	patch.PerSourceHeaders["http://a.com/f"]["X-Token"] = "changed"
+	assert.Equal(t, "patch", s.opts.PerSourceHeaders["http://a.com/f"]["X-Token"])
+
+	opts := s.Options()
+	opts.PerSourceHeaders["http://a.com/f"]["X-Token"] = "changed"
+	assert.Equal(t, "patch", s.opts.PerSourceHeaders["http://a.com/f"]["X-Token"])
+}
+
+func Test_Service_WithOptions(t *testing.T) {
+	roundTripper := newLoggingRoundTripper(nil, io.Discard)
+	original := NewServiceWithOptions(
+		WithConnections(4),
+		WithTimeout(7*time.Second),
+		WithCheckETag(true),
+		WithDestFilePath("original.txt"),
+	)
+
+	testCases := map[string]struct {
+		patch           Options
+		expectedOpts    func(opts Options) Options
+		expectedTimeout time.Duration
+		expectNewClient bool
+		expectDialer    bool // the new client has a transport with the custom dialer of the options
+	}{
+		"destination only": {
+			patch: Options{DestFilePath: "variant.txt"},
+			expectedOpts: func(opts Options) Options {
+				opts.DestFilePath = "variant.txt"
+				return opts
+			},
+			expectedTimeout: 7 * time.Second,
+		},
+		"connections and quiet": {
+			patch: Options{Connections: 16, Quiet: true},
+			expectedOpts: func(opts Options) Options {
+				opts.Connections = 16
+				opts.Quiet = true
+				return opts
+			},
+			expectedTimeout: 7 * time.Second,
+		},
+		"timeout recreates client": {
+			patch: Options{Timeout: 2},
+			expectedOpts: func(opts Options) Options {
+				opts.Timeout = 2
+				return opts
+			},
+			expectedTimeout: 2 * time.Second,
+			expectNewClient: true,
+		},
+		"round tripper recreates client keeping timeout": {
+			patch: Options{RoundTripper: roundTripper},
+			expectedOpts: func(opts Options) Options {
+				opts.RoundTripper = roundTripper
+				return opts
+			},
+			expectedTimeout: 7 * time.Second,
+			expectNewClient: true,
+		},
+		"socket mark recreates client": {
+			patch: Options{SocketMark: 42},
+			expectedOpts: func(opts Options) Options {
+				opts.SocketMark = 42
+				return opts
+			},
+			expectedTimeout: 7 * time.Second,
+			expectNewClient: true,
+			expectDialer:    true,
+		},
+		"socket priority recreates client": {
+			patch: Options{SocketPriority: 5},
+			expectedOpts: func(opts Options) Options {
+				opts.SocketPriority = 5
+				return opts
+			},
+			expectedTimeout: 7 * time.Second,
+			expectNewClient: true,
+			expectDialer:    true,
+		},
+	}
+
+	for scenario, tc := range testCases {
+		t.Run(scenario, func(t *testing.T) {
+			before := original.opts
+
+			variant := original.WithOptions(tc.patch)
+
+			assert.Equal(t, tc.expectedOpts(before), variant.opts)
+			assert.Equal(t, before, original.opts) // original unaffected
+			assert.Equal(t, tc.expectedTimeout, variant.httpClient.Timeout)
+			assert.NotSame(t, original.httpClient, variant.httpClient)
+			if tc.expectDialer {
+				transport, ok := variant.httpClient.Transport.(*http.Transport)
+				assert.True(t, ok)
+				assert.NotNil(t, transport.DialContext)
+			} else if tc.expectNewClient {
+				assert.Equal(t, tc.patch.RoundTripper, variant.httpClient.Transport)
+			} else {
+				assert.Equal(t, original.httpClient.Transport, variant.httpClient.Transport)
+			}
+		})
+	}
+}
@@ -0,0 +1,101 @@
+package download
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+)
+
+// pauseController gates the chunk requests of a Service so that downloads can be paused and resumed.
+// While paused, the chunk goroutines block on the gate channel before issuing their next request.
+type pauseController struct {
+	paused atomic.Bool
+
+	mu       sync.Mutex
+	gate     chan struct{} // closed on resume
+	inFlight int
+	drained  chan struct{} // closed once there are no more requests in flight
+}
+
+// acquire blocks while paused and then registers a request as in flight. The returned function
+// must be called once the request is done. An error is returned if the context is done while paused.
+func (pc *pauseController) acquire(ctx context.Context) (func(), error) {
+	for {
+		pc.mu.Lock()
+		if !pc.paused.Load() {
+			pc.inFlight++
+			pc.mu.Unlock()
+			return pc.release, nil
+		}
+		gate := pc.gate
+		pc.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-gate:
+		}
+	}
+}
+
+// release marks a request as no longer in flight.
+func (pc *pauseController) release() {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+
+	pc.inFlight--
+	if pc.inFlight == 0 && pc.drained != nil {
+		close(pc.drained)
+		pc.drained = nil
+	}
+}
+
+// pause stops new requests from being issued and waits for the ones in flight to be done.
+func (pc *pauseController) pause() {
+	pc.mu.Lock()
+	if !pc.paused.Load() {
+		pc.gate = make(chan struct{})
+		pc.paused.Store(true)
+	}
+
+	if pc.inFlight == 0 {
+		pc.mu.Unlock()
+		return
+	}
+
+	if pc.drained == nil {
+		pc.drained = make(chan struct{})
+	}
+	drained := pc.drained
+	pc.mu.Unlock()
+
+	<-drained
+}
+
+// resume lets the blocked requests proceed.
+func (pc *pauseController) resume() {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+
+	if pc.paused.Load() {
+		pc.paused.Store(false)
+		close(pc.gate)
+	}
+}
+
+// Pause stops the ongoing downloads of the service from issuing new chunk requests and returns
+// once the requests in flight are done. The progress is kept so the downloads continue where they
+// left off on Resume. Downloads started while paused wait as well.
+func (s *Service) Pause() {
+	s.pause.pause()
+}
+
+// Resume continues the downloads of the service stopped by Pause.
+func (s *Service) Resume() {
+	s.pause.resume()
+}
+
+// Paused returns true if the service is paused.
+func (s *Service) Paused() bool {
+	return s.pause.paused.Load()
+}
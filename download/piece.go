@@ -0,0 +1,186 @@
+package download
+
+import (
+	"context"
+	"crypto/sha1"
+	"crypto/sha256"
+	"fmt"
+	"hash"
+	"io"
+	"strings"
+	"sync"
+)
+
+// pieceVerifier keeps track of the written bytes of each piece (as set by PieceSize and PieceHashes)
+// so that pieces can be verified as soon as all of their bytes are written.
+type pieceVerifier struct {
+	mu           sync.Mutex
+	hashes       []string
+	newHasher    func() hash.Hash
+	pieceSize    int64
+	chunkSize    int64
+	size         int64
+	pending      []int64     // bytes yet to be written per piece
+	chunkSources map[int]int // index of the source which delivered each chunk
+}
+
+// pieceHashers maps the supported hash algorithm names for the pieces to their constructors.
+var pieceHashers = map[string]func() hash.Hash{
+	"sha1":   sha1.New, // as in `.torrent` files
+	"sha256": sha256.New,
+}
+
+// newPieceVerifier returns a pieceVerifier for a file of the given size using the given hash algorithm
+// (sha256 if empty). The chunks completed before the download started (i.e., when resuming) are counted
+// as already written.
+func newPieceVerifier(hashes []string, pieceSize int64, algorithm string, size int64, tracker *chunkTracker) (*pieceVerifier, error) {
+	if pieceSize <= 0 {
+		return nil, fmt.Errorf("%w: piece size must be positive", ErrInvalidPieceHashes)
+	}
+
+	if len(algorithm) == 0 {
+		algorithm = "sha256"
+	}
+	newHasher, ok := pieceHashers[algorithm]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedHashAlgorithm, algorithm)
+	}
+
+	numPieces := int((size + pieceSize - 1) / pieceSize)
+	if len(hashes) != numPieces {
+		return nil, fmt.Errorf("%w: expected %d piece hashes but got %d", ErrInvalidPieceHashes, numPieces, len(hashes))
+	}
+
+	pv := &pieceVerifier{
+		hashes:       hashes,
+		newHasher:    newHasher,
+		pieceSize:    pieceSize,
+		chunkSize:    tracker.state.ChunkSize,
+		size:         size,
+		pending:      make([]int64, numPieces),
+		chunkSources: make(map[int]int),
+	}
+	for p := range pv.pending {
+		start, end := pv.pieceRange(p)
+		pv.pending[p] = end - start
+	}
+
+	for i := 0; int64(i)*pv.chunkSize < size; i++ {
+		if tracker.isCompleted(i) {
+			pv.chunkWritten(i, -1)
+		}
+	}
+
+	return pv, nil
+}
+
+// pieceRange returns the offsets of the piece with the given index (end is exclusive).
+func (pv *pieceVerifier) pieceRange(p int) (int64, int64) {
+	start := int64(p) * pv.pieceSize
+	return start, min(start+pv.pieceSize, pv.size)
+}
+
+// chunkRange returns the offsets of the chunk with the given index (end is exclusive).
+func (pv *pieceVerifier) chunkRange(i int) (int64, int64) {
+	offset := int64(i) * pv.chunkSize
+	return offset, min(offset+pv.chunkSize, pv.size)
+}
+
+// chunkWritten records the chunk with the given index as written after being delivered by the source
+// with the given index (-1 if unknown) and returns the pieces which are now completely written.
+func (pv *pieceVerifier) chunkWritten(i, source int) []int {
+	pv.mu.Lock()
+	defer pv.mu.Unlock()
+
+	pv.chunkSources[i] = source
+
+	offset, limit := pv.chunkRange(i)
+
+	var completed []int
+	for p := int(offset / pv.pieceSize); p <= int((limit-1)/pv.pieceSize); p++ {
+		start, end := pv.pieceRange(p)
+		pv.pending[p] -= min(limit, end) - max(offset, start)
+		if pv.pending[p] == 0 {
+			completed = append(completed, p)
+		}
+	}
+
+	return completed
+}
+
+// completedPieces returns the pieces which are already completely written.
+func (pv *pieceVerifier) completedPieces() []int {
+	pv.mu.Lock()
+	defer pv.mu.Unlock()
+
+	var completed []int
+	for p, pending := range pv.pending {
+		if pending == 0 {
+			completed = append(completed, p)
+		}
+	}
+
+	return completed
+}
+
+// chunksOf returns the indices of the chunks overlapping the piece with the given index along
+// with the index of the source which delivered each of them.
+func (pv *pieceVerifier) chunksOf(p int) map[int]int {
+	pv.mu.Lock()
+	defer pv.mu.Unlock()
+
+	start, end := pv.pieceRange(p)
+
+	chunks := make(map[int]int)
+	for i := int(start / pv.chunkSize); i <= int((end-1)/pv.chunkSize); i++ {
+		chunks[i] = pv.chunkSources[i]
+	}
+
+	return chunks
+}
+
+// pieceHash returns the hash of the piece with the given index as written in the file.
+func (pv *pieceVerifier) pieceHash(file io.ReaderAt, p int) (string, error) {
+	start, end := pv.pieceRange(p)
+	return calculateHash(io.NewSectionReader(file, start, end-start), pv.newHasher())
+}
+
+// verifyPiece checks the hash of the written piece with the given index. On mismatch, the chunks
+// of the piece are downloaded (and written) again from a different source than before and the hash
+// is checked once more, in which case a PieceVerificationError is returned on mismatch.
+func (s *Service) verifyPiece(ctx context.Context, stats *sourceStatsCollector, sourceUrls []string, destFile readerWriterAt, pv *pieceVerifier, p int) error {
+	got, err := pv.pieceHash(destFile, p)
+	if err != nil {
+		return err
+	}
+	if strings.EqualFold(got, pv.hashes[p]) {
+		return nil
+	}
+
+	s.logln(fmt.Sprintf("warning: piece %d does not match its hash, downloading it again", p))
+
+	for i, source := range pv.chunksOf(p) {
+		offset, limit := pv.chunkRange(i)
+		srcIdxInitAttempt := (source + 1) % len(sourceUrls) // the first source if unknown (i.e., -1)
+
+		chunk, url, err := s.fetchChunkFromSources(ctx, stats, sourceUrls, srcIdxInitAttempt, i, offset, limit, nil)
+		if err != nil {
+			return fmt.Errorf("failed to download piece %d again: %w", p, err)
+		}
+
+		if err := s.writeChunk(destFile, offset, chunk); err != nil {
+			return err
+		}
+
+		s.logln(fmt.Sprintf("chunk %d downloaded again from %s", i, url))
+	}
+
+	if got, err = pv.pieceHash(destFile, p); err != nil {
+		return err
+	}
+	if !strings.EqualFold(got, pv.hashes[p]) {
+		return PieceVerificationError{PieceIndex: p, Expected: pv.hashes[p], Got: got}
+	}
+
+	return nil
+}
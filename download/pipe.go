@@ -0,0 +1,107 @@
+package download
+
+import (
+	"context"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+)
+
+// isNamedPipe returns true if the given path refers to an existing named pipe (FIFO).
+func isNamedPipe(filePath string) bool {
+	fileInfo, err := os.Stat(filePath)
+	return err == nil && fileInfo.Mode()&os.ModeNamedPipe != 0
+}
+
+// downloadToPipe downloads the file from the given sources to the named pipe at DestFilePath.
+// Since pipes are not seekable, the chunks are reassembled in order and written sequentially
+// instead of using a `.download` file. The ETag check (if enabled) is done on the streamed bytes
+// which are also copied to the TeeWriter (if set).
+func (s *Service) downloadToPipe(ctx context.Context, sourceUrls []string, stats *sourceStatsCollector) error {
+	if err := s.checkPipeOptions(); err != nil {
+		return err
+	}
+
+	srcFileMetas, err := s.fetchFileMetadataFromSources(ctx, sourceUrls)
+	if err != nil {
+		return err
+	}
+	srcFileMetas = s.withDiscoveredMirrors(ctx, srcFileMetas, stats)
+
+	if !allSourcesMatchFileMetadata(srcFileMetas, s.opts.CheckETag, !s.opts.LooseContentTypeMatch) {
+		return ErrSourcesFileMismatch
+	}
+	if s.opts.BenchmarkBeforeDownload {
+		s.benchmarkThroughput(ctx, srcFileMetas)
+	}
+
+	fileMetadata := srcFileMetas[0].fileMetadata // any will do since they are assumed to be matching
+
+	// blocks until a reader opens the other end of the pipe
+	pipe, err := os.OpenFile(s.opts.DestFilePath, os.O_WRONLY, 0)
+	if err != nil {
+		return err
+	}
+	defer pipe.Close()
+
+	var w io.Writer = pipe
+	if s.opts.TeeWriter != nil {
+		w = io.MultiWriter(pipe, s.opts.TeeWriter)
+	}
+
+	var hasher hash.Hash
+	if s.opts.CheckETag {
+		hasher = streamingHasherFor(s.calculateETag) // not nil as per checkPipeOptions
+	}
+	checkETag := s.opts.CheckETag && len(fileMetadata.eTag) > 0
+
+	if fileMetadata.size == -1 {
+		// the hash is calculated regardless since only the response may include the ETag
+		sw := w
+		if hasher != nil {
+			sw = io.MultiWriter(w, hasher)
+		}
+		_, eTag, err := s.streamFileContents(ctx, sourceUrlsSortedByEstLatency(srcFileMetas), sw, stats)
+		if err != nil {
+			return err
+		}
+		if len(eTag) > 0 {
+			fileMetadata.eTag = eTag
+		}
+		checkETag = s.opts.CheckETag && len(fileMetadata.eTag) > 0
+	} else {
+		if checkETag {
+			w = io.MultiWriter(w, hasher)
+		}
+		if err := s.downloadRange(ctx, sourceUrlsSortedByEstLatency(srcFileMetas), 0, fileMetadata.size, w, stats); err != nil {
+			return err
+		}
+	}
+
+	if checkETag && fmt.Sprintf("%x", hasher.Sum(nil)) != fileMetadata.eTag {
+		return ErrETagMismatch
+	}
+
+	s.logln("Download complete:", s.opts.DestFilePath)
+
+	return nil
+}
+
+// checkPipeOptions returns ErrNamedPipeUnsupported for the options which cannot be honoured when
+// writing to a named pipe: the written bytes cannot be read back for verifying PieceHashes or for
+// the ETag check with a custom ETag calculator, and the chunks are only handed over in order, so
+// there is no chunk completion for OnChunkStart and OnProgress.
+func (s *Service) checkPipeOptions() error {
+	if s.opts.CheckETag && streamingHasherFor(s.calculateETag) == nil {
+		return fmt.Errorf("%w: CheckETag with a custom ETag calculator", ErrNamedPipeUnsupported)
+	}
+	if len(s.opts.PieceHashes) > 0 {
+		return fmt.Errorf("%w: PieceHashes", ErrNamedPipeUnsupported)
+	}
+	if s.opts.OnChunkStart != nil || s.opts.OnProgress != nil {
+		return fmt.Errorf("%w: OnChunkStart and OnProgress", ErrNamedPipeUnsupported)
+	}
+
+	return nil
+}
@@ -0,0 +1,27 @@
+package download
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// preallocateFile allocates the given size for the file contiguously (as much as possible).
+func preallocateFile(file *os.File, size int64) error {
+	fstore := &unix.Fstore_t{
+		Flags:   unix.F_ALLOCATECONTIG,
+		Posmode: unix.F_PEOFPOSMODE,
+		Length:  size,
+	}
+
+	if err := unix.FcntlFstore(file.Fd(), unix.F_PREALLOCATE, fstore); err != nil {
+		// fall back to a non-contiguous allocation
+		fstore.Flags = unix.F_ALLOCATEALL
+		if err := unix.FcntlFstore(file.Fd(), unix.F_PREALLOCATE, fstore); err != nil {
+			return err
+		}
+	}
+
+	// F_PREALLOCATE only reserves the space and does not change the file size
+	return file.Truncate(size)
+}
@@ -0,0 +1,17 @@
+package download
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// preallocateFile allocates the given size for the file contiguously (as much as possible).
+func preallocateFile(file *os.File, size int64) error {
+	err := syscall.Fallocate(int(file.Fd()), 0, 0, size)
+	if errors.Is(err, syscall.EOPNOTSUPP) {
+		return errPreallocationUnsupported // e.g., filesystem does not support fallocate
+	}
+
+	return err
+}
@@ -0,0 +1,10 @@
+//go:build !linux && !darwin
+
+package download
+
+import "os"
+
+// preallocateFile is not supported on this platform.
+func preallocateFile(file *os.File, size int64) error {
+	return errPreallocationUnsupported
+}
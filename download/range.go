@@ -0,0 +1,67 @@
+package download
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/sync/errgroup"
+)
+
+// DownloadRange downloads only the given byte range of the file (where rangeStart is inclusive and
+// rangeEnd is exclusive) from the given sources in a concurrent manner and writes the bytes in order
+// to the given writer. Unlike Download, the file metadata is not fetched from the sources beforehand
+// and no `.download` file is created.
+func (s *Service) DownloadRange(ctx context.Context, sourceUrls []string, rangeStart, rangeEnd int64, destFile io.Writer) error {
+	ctx, span := s.tracer.Start(ctx, "DownloadRange", trace.WithAttributes(
+		attribute.StringSlice("download.sources", sourceUrls),
+		attribute.Int64("download.range_start", rangeStart),
+		attribute.Int64("download.range_end", rangeEnd),
+	))
+	defer span.End()
+
+	sourceUrls, err := normalizeURLs(sourceUrls)
+	if err != nil {
+		return recordSpanError(span, err)
+	}
+
+	if len(sourceUrls) == 0 {
+		return recordSpanError(span, ErrNoSourceUrls)
+	}
+
+	if rangeStart < 0 || rangeEnd <= rangeStart {
+		return recordSpanError(span, fmt.Errorf("invalid range %d-%d", rangeStart, rangeEnd))
+	}
+
+	return recordSpanError(span, s.downloadRange(ctx, sourceUrls, rangeStart, rangeEnd, destFile, newSourceStatsCollector(sourceUrls)))
+}
+
+// downloadRange contains the actual logic of DownloadRange (which expects validated arguments).
+func (s *Service) downloadRange(ctx context.Context, sourceUrls []string, rangeStart, rangeEnd int64, destFile io.Writer, stats *sourceStatsCollector) error {
+	size := rangeEnd - rangeStart
+	chunkSize := s.chunkSize(size)
+
+	// chunks are handed over in order to the writer (offsets relative to the start of the range)
+	tracker := newChunkTracker(DownloadState{Size: size, ChunkSize: chunkSize}, "")
+	tee := newChunkTee(destFile, nil, tracker, size)
+
+	eg, ctx := errgroup.WithContext(ctx)
+	eg.SetLimit(int(s.memoryLimitedConnections(chunkSize)))
+
+	for offset, i := int64(0), 0; offset < size; offset, i = offset+chunkSize, i+1 {
+		limit := min(offset+chunkSize, size)
+
+		eg.Go(func() error {
+			chunk, _, err := s.fetchChunkFromSources(ctx, stats, sourceUrls, i%len(sourceUrls), i, rangeStart+offset, rangeStart+limit, nil)
+			if err != nil {
+				return fmt.Errorf("failed to download range: %w", err)
+			}
+
+			return tee.deliver(i, chunk)
+		})
+	}
+
+	return eg.Wait()
+}
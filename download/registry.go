@@ -0,0 +1,69 @@
+package download
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// FetcherFactory creates the Fetcher for the sources of a URL scheme given the options of the Service.
+type FetcherFactory func(opts Options) (Fetcher, error)
+
+// schemeRegistration is a registered FetcherFactory, where a nil factory stands for the built-in
+// HTTP fetcher of each Service (which also depends on its HTTP client).
+type schemeRegistration struct {
+	factory FetcherFactory
+}
+
+var (
+	schemeRegistryMu sync.RWMutex
+	schemeRegistry   = map[string]schemeRegistration{
+		"http":     {},
+		"https":    {},
+		schemeFile: {factory: func(Options) (Fetcher, error) { return fileFetcher{}, nil }},
+	}
+)
+
+// RegisterScheme registers the factory of the Fetcher for the sources with the given (case-insensitive)
+// URL scheme (e.g., `sftp`), replacing any previous registration including the built-in ones (i.e.,
+// `http`, `https` and `file`). The registry is consulted when creating a Service, so registering a scheme
+// does not affect existing services. A failing factory leaves its scheme to the default HTTP fetcher.
+func RegisterScheme(scheme string, factory FetcherFactory) {
+	schemeRegistryMu.Lock()
+	defer schemeRegistryMu.Unlock()
+
+	schemeRegistry[strings.ToLower(scheme)] = schemeRegistration{factory: factory}
+}
+
+// UnregisterScheme removes the registration of the given URL scheme (if any), so that the sources
+// with that scheme are left to the default HTTP fetcher (e.g., failing as unsupported).
+func UnregisterScheme(scheme string) {
+	schemeRegistryMu.Lock()
+	defer schemeRegistryMu.Unlock()
+
+	delete(schemeRegistry, strings.ToLower(scheme))
+}
+
+// registeredFetchers returns the fetchers of the registered schemes created from the given options,
+// where the built-in HTTP schemes get the given HTTP fetcher.
+func registeredFetchers(opts Options, hf *httpFetcher) map[string]Fetcher {
+	schemeRegistryMu.RLock()
+	defer schemeRegistryMu.RUnlock()
+
+	fetchers := make(map[string]Fetcher, len(schemeRegistry))
+	for scheme, reg := range schemeRegistry {
+		if reg.factory == nil {
+			fetchers[scheme] = hf
+			continue
+		}
+
+		fetcher, err := reg.factory(opts)
+		if err != nil {
+			printErr(fmt.Errorf("fetcher for scheme %s disabled: %w", scheme, err))
+			continue
+		}
+		fetchers[scheme] = fetcher
+	}
+
+	return fetchers
+}
@@ -0,0 +1,71 @@
+package download_test
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/gkatanacio/multisource-downloader/download"
+)
+
+func Test_RegisterScheme(t *testing.T) {
+	content := readFixture(t, "dummy.txt")
+	fetcher := &mockFetcher{content: content}
+
+	var factoryOpts download.Options
+	download.RegisterScheme("myproto", func(opts download.Options) (download.Fetcher, error) {
+		factoryOpts = opts
+		return fetcher, nil
+	})
+	t.Cleanup(func() { download.UnregisterScheme("myproto") })
+
+	// same Content-Type as the mock fetcher (rather than the detected `text/plain; charset=utf-8`)
+	srv := newTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		serveContent("dummy.txt", content)(w, r)
+	}))
+	destFilePath := filepath.Join(t.TempDir(), "dummy.txt")
+
+	downloadService := download.NewService(download.Options{
+		Connections:  2,
+		Quiet:        true,
+		DestFilePath: destFilePath,
+	}, download.GetMD5Hash)
+	assert.Equal(t, destFilePath, factoryOpts.DestFilePath)
+
+	// the HTTP source is still served by the built-in fetcher
+	err := downloadService.Download([]string{"myproto://mirror/dummy.txt", srv.URL + "/dummy.txt"})
+	assert.NoError(t, err)
+
+	calls := fetcher.recordedCalls()
+	assert.Contains(t, calls, "HEAD myproto://mirror/dummy.txt")
+	for _, call := range calls {
+		assert.Contains(t, call, "myproto://mirror/dummy.txt")
+	}
+
+	downloaded, err := os.ReadFile(destFilePath)
+	assert.NoError(t, err)
+	assert.Equal(t, content, downloaded)
+}
+
+func Test_UnregisterScheme(t *testing.T) {
+	fetcher := &mockFetcher{content: []byte("0123456789")}
+	download.RegisterScheme("MyProto", func(download.Options) (download.Fetcher, error) {
+		return fetcher, nil
+	})
+	download.UnregisterScheme("myproto")
+
+	downloadService := download.NewService(download.Options{
+		Connections:  2,
+		Timeout:      3,
+		Quiet:        true,
+		DestFilePath: filepath.Join(t.TempDir(), "digits.txt"),
+	}, download.GetMD5Hash)
+
+	err := downloadService.Download([]string{"myproto://mirror/digits.txt"})
+	assert.ErrorContains(t, err, "unsupported protocol scheme") // the HTTP fetcher does not support the scheme
+	assert.Empty(t, fetcher.recordedCalls())
+}
@@ -0,0 +1,110 @@
+package download
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// RepairFile downloads the given corrupt ranges of an already downloaded file again (e.g., those found
+// by Diff against a known good copy) and writes them into the file at their offsets, which avoids
+// downloading the whole file again when the corruption is localised. Each range is treated as a chunk,
+// i.e., it is retried from the other sources if it fails, with up to Connections ranges in parallel.
+// The file is truncated to the size reported by the sources if it is larger and the repaired file is
+// then verified against the ETag of the sources (if they report one) like Verify. ErrInvalidByteRange
+// is returned for ranges which are empty or beyond the end of the file.
+func (s *Service) RepairFile(ctx context.Context, filePath string, sourceUrls []string, corruptRanges []ByteRange) error {
+	sourceUrls, err := normalizeURLs(sourceUrls)
+	if err != nil {
+		return err
+	}
+
+	if len(sourceUrls) == 0 {
+		return ErrNoSourceUrls
+	}
+
+	srcFileMetas, err := s.fetchFileMetadataFromSources(ctx, sourceUrls)
+	if err != nil {
+		return err
+	}
+
+	if !allSourcesMatchFileMetadata(srcFileMetas, s.opts.CheckETag, !s.opts.LooseContentTypeMatch) {
+		return ErrSourcesFileMismatch
+	}
+
+	fileMetadata := srcFileMetas[0].fileMetadata // any will do since they are assumed to be matching
+
+	for _, r := range corruptRanges {
+		if r.Start < 0 || r.End <= r.Start || r.End > fileMetadata.size {
+			return fmt.Errorf("%w: %d-%d of %d bytes", ErrInvalidByteRange, r.Start, r.End, fileMetadata.size)
+		}
+	}
+
+	file, err := os.OpenFile(filePath, os.O_RDWR, 0)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	stats := newSourceStatsCollector(sourceUrls)
+	defer func() {
+		s.lastStats.Store(stats.downloadStats())
+	}()
+
+	sortedUrls := sourceUrlsSortedByEstLatency(srcFileMetas)
+
+	eg, egCtx := errgroup.WithContext(ctx)
+	eg.SetLimit(int(max(s.opts.Connections, 1)))
+	for i, r := range corruptRanges {
+		eg.Go(func() error {
+			chunk, url, err := s.fetchChunkFromSources(egCtx, stats, sortedUrls, i%len(sortedUrls), i, r.Start, r.End, nil)
+			if err != nil {
+				return fmt.Errorf("failed to repair file contents: %w", err)
+			}
+
+			if err := s.writeChunk(file, r.Start, chunk); err != nil {
+				return err
+			}
+
+			s.logln(fmt.Sprintf("bytes %d-%d repaired from %s", r.Start, r.End, url))
+			return nil
+		})
+	}
+	if err := eg.Wait(); err != nil {
+		return err
+	}
+
+	fileInfo, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	if fileInfo.Size() > fileMetadata.size {
+		if err := file.Truncate(fileMetadata.size); err != nil {
+			return err
+		}
+	}
+
+	if len(fileMetadata.eTag) == 0 {
+		s.logln("warning: skipping verification of the repaired file since the sources report no ETag")
+		return nil
+	}
+
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	calculatedETag, err := s.calculateETag(file)
+	if err != nil {
+		return err
+	}
+
+	if calculatedETag != fileMetadata.eTag {
+		return ErrETagMismatch
+	}
+
+	s.logln("Repair successful:", filePath)
+
+	return nil
+}
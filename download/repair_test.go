@@ -0,0 +1,111 @@
+package download_test
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/gkatanacio/multisource-downloader/download"
+)
+
+func Test_Service_RepairFile(t *testing.T) {
+	content := readFixture(t, "dummy.png")
+	original := writeTempFile(t, "original.png", content)
+
+	testCases := map[string]struct {
+		corrupt func([]byte) []byte
+	}{
+		"corrupt bytes": {
+			corrupt: func(b []byte) []byte {
+				for _, i := range []int{7, 8, 9, 1500, 4999} {
+					b[i] ^= 0xff
+				}
+				return b
+			},
+		},
+		"truncated": {
+			corrupt: func(b []byte) []byte { return b[:3000] },
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			srv1 := download.NewTestServer(t, content)
+			srv2 := download.NewTestServer(t, content)
+			sourceUrls := []string{srv1.FileURL(), srv2.FileURL()}
+
+			destFilePath := filepath.Join(t.TempDir(), "dummy.png")
+			downloadService := download.NewService(download.Options{
+				Connections:  2,
+				Timeout:      3,
+				CheckETag:    true,
+				Quiet:        true,
+				DestFilePath: destFilePath,
+			}, download.GetMD5Hash)
+			assert.NoError(t, downloadService.Download(sourceUrls))
+
+			downloaded, err := os.ReadFile(destFilePath)
+			assert.NoError(t, err)
+			assert.NoError(t, os.WriteFile(destFilePath, tc.corrupt(bytes.Clone(downloaded)), 0644))
+
+			corruptRanges, err := download.Diff(original, destFilePath, 1024)
+			assert.NoError(t, err)
+			assert.NotEmpty(t, corruptRanges)
+
+			requests := srv1.Requests() + srv2.Requests()
+			err = downloadService.RepairFile(context.Background(), destFilePath, sourceUrls, corruptRanges)
+			assert.NoError(t, err)
+
+			// a HEAD request per source and a single request per corrupt range
+			assert.Equal(t, int64(len(sourceUrls)+len(corruptRanges)), srv1.Requests()+srv2.Requests()-requests)
+
+			repaired, err := os.ReadFile(destFilePath)
+			assert.NoError(t, err)
+			assert.Equal(t, content, repaired)
+		})
+	}
+}
+
+func Test_Service_RepairFile_Failed(t *testing.T) {
+	content := readFixture(t, "dummy.txt")
+	srv := download.NewTestServer(t, content)
+
+	testCases := map[string]struct {
+		corruptRanges []download.ByteRange
+		expectedErr   error
+	}{
+		"range beyond the end": {
+			corruptRanges: []download.ByteRange{{Start: 3000, End: int64(len(content)) + 1}},
+			expectedErr:   download.ErrInvalidByteRange,
+		},
+		"empty range": {
+			corruptRanges: []download.ByteRange{{Start: 10, End: 10}},
+			expectedErr:   download.ErrInvalidByteRange,
+		},
+		"corruption outside of the ranges": {
+			corruptRanges: []download.ByteRange{{Start: 0, End: 10}},
+			expectedErr:   download.ErrETagMismatch,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			corrupted := bytes.Clone(content)
+			corrupted[100] ^= 0xff
+			filePath := writeTempFile(t, "dummy.txt", corrupted)
+
+			downloadService := download.NewService(download.Options{
+				Connections: 1,
+				Timeout:     3,
+				Quiet:       true,
+			}, download.GetMD5Hash)
+
+			err := downloadService.RepairFile(context.Background(), filePath, []string{srv.FileURL()}, tc.corruptRanges)
+			assert.ErrorIs(t, err, tc.expectedErr)
+		})
+	}
+}
@@ -0,0 +1,87 @@
+package download
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"slices"
+	"sync"
+)
+
+// segmentFiles keeps track of the chunks written to individual temp files (see SegmentedTempFiles)
+// named after the `.download` file (i.e., `<destFile>.download.chunk-N`).
+type segmentFiles struct {
+	mu       sync.Mutex
+	prefix   string
+	segments map[int]chunkWrite // without the chunk itself
+}
+
+// newSegmentFiles returns the segment files for the given `.download` file.
+func newSegmentFiles(ongoingDownloadFilePath string) *segmentFiles {
+	return &segmentFiles{
+		prefix:   ongoingDownloadFilePath + ".chunk-",
+		segments: make(map[int]chunkWrite),
+	}
+}
+
+// path returns the path of the segment file for the chunk with the given index.
+func (sf *segmentFiles) path(index int) string {
+	return fmt.Sprintf("%s%d", sf.prefix, index)
+}
+
+// write writes the chunk to its own segment file.
+func (sf *segmentFiles) write(cw chunkWrite) error {
+	sf.mu.Lock()
+	sf.segments[cw.index] = chunkWrite{index: cw.index, offset: cw.offset, source: cw.source}
+	sf.mu.Unlock()
+
+	return os.WriteFile(sf.path(cw.index), cw.chunk, 0644)
+}
+
+// assemble reads the segment files in order of their chunks and passes each chunk to the given
+// function (e.g., for writing it to the `.download` file) before removing its segment file.
+func (sf *segmentFiles) assemble(complete func(cw chunkWrite) error) error {
+	sf.mu.Lock()
+	indices := make([]int, 0, len(sf.segments))
+	for index := range sf.segments {
+		indices = append(indices, index)
+	}
+	sf.mu.Unlock()
+
+	slices.Sort(indices)
+
+	for _, index := range indices {
+		cw := sf.segments[index]
+
+		var err error
+		if cw.chunk, err = os.ReadFile(sf.path(index)); err != nil {
+			return err
+		}
+
+		if err := complete(cw); err != nil {
+			return err
+		}
+
+		if err := os.Remove(sf.path(index)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// removeAll removes the segment files which are left (e.g., after a failed download).
+func (sf *segmentFiles) removeAll() error {
+	sf.mu.Lock()
+	defer sf.mu.Unlock()
+
+	var errs []error
+	for index := range sf.segments {
+		if err := os.Remove(sf.path(index)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
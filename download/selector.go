@@ -0,0 +1,132 @@
+package download
+
+import (
+	"math/rand/v2"
+	"slices"
+	"sync"
+	"time"
+)
+
+// SourceStrategy represents how the sources are initially assigned to the chunks (see SourceSelector).
+type SourceStrategy uint
+
+const (
+	// StrategyRoundRobin assigns the chunks to the sources in turn.
+	StrategyRoundRobin SourceStrategy = iota
+	// StrategyRandom assigns each chunk to a source picked uniformly at random.
+	StrategyRandom
+	// StrategyLatencyWeighted assigns each chunk to a source picked at random with a probability
+	// proportional to the inverse of its estimated latency.
+	StrategyLatencyWeighted
+	// StrategySticky assigns the chunks to the fastest source until a chunk fails from it, after which
+	// the next fastest source is used (and so on).
+	StrategySticky
+)
+
+// SourceSelector selects the source a chunk is initially downloaded from among the available (i.e.,
+// healthy) sources, which are sorted by their estimated latency in ascending order. Implementations
+// must be safe for concurrent use.
+type SourceSelector interface {
+	Select(chunkIndex int, availableSources []string) string
+}
+
+// sourceFailureObserver can be implemented by a SourceSelector which needs to know about the sources
+// failing to deliver the chunks assigned to them.
+type sourceFailureObserver interface {
+	sourceFailed(url string)
+}
+
+// NewSourceSelector returns the SourceSelector of the given strategy, where the estimated latencies of
+// the sources are only used by StrategyLatencyWeighted. Unknown strategies fall back to StrategyRoundRobin.
+func NewSourceSelector(strategy SourceStrategy, latencies map[string]time.Duration) SourceSelector {
+	switch strategy {
+	case StrategyRandom:
+		return randomSelector{}
+	case StrategyLatencyWeighted:
+		return latencyWeightedSelector{latencies: latencies}
+	case StrategySticky:
+		return &stickySelector{failed: make(map[string]bool)}
+	default:
+		return roundRobinSelector{}
+	}
+}
+
+// newSourceSelector returns the SourceSelector of the configured SourceStrategy for the given sources.
+func (s *Service) newSourceSelector(srcFileMetas []sourceFileMetadata) SourceSelector {
+	latencies := make(map[string]time.Duration, len(srcFileMetas))
+	for _, sfm := range srcFileMetas {
+		latencies[sfm.url] = sfm.estLatency
+	}
+
+	return NewSourceSelector(s.opts.SourceStrategy, latencies)
+}
+
+type roundRobinSelector struct{}
+
+func (roundRobinSelector) Select(chunkIndex int, availableSources []string) string {
+	return availableSources[chunkIndex%len(availableSources)]
+}
+
+type randomSelector struct{}
+
+func (randomSelector) Select(_ int, availableSources []string) string {
+	return availableSources[rand.N(len(availableSources))]
+}
+
+type latencyWeightedSelector struct {
+	latencies map[string]time.Duration
+}
+
+// Select treats sources of unknown latency (e.g., discovered mirrors) like the slowest known source.
+func (lws latencyWeightedSelector) Select(_ int, availableSources []string) string {
+	var slowest time.Duration
+	for _, url := range availableSources {
+		slowest = max(slowest, lws.latencies[url])
+	}
+
+	weights := make([]float64, len(availableSources))
+	var total float64
+	for i, url := range availableSources {
+		latency, ok := lws.latencies[url]
+		if !ok {
+			latency = slowest
+		}
+		weights[i] = 1 / float64(max(latency, time.Microsecond)) // a zero latency would get all chunks
+		total += weights[i]
+	}
+
+	r := rand.Float64() * total
+	for i, weight := range weights {
+		if r < weight {
+			return availableSources[i]
+		}
+		r -= weight
+	}
+
+	return availableSources[len(availableSources)-1] // only reached due to rounding
+}
+
+type stickySelector struct {
+	mu     sync.Mutex
+	failed map[string]bool
+}
+
+// Select returns the first available source which has not failed, or the first available source
+// if all of them have failed (in which case there is nothing better to choose from).
+func (ss *stickySelector) Select(_ int, availableSources []string) string {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	if i := slices.IndexFunc(availableSources, func(url string) bool { return !ss.failed[url] }); i >= 0 {
+		return availableSources[i]
+	}
+
+	return availableSources[0]
+}
+
+func (ss *stickySelector) sourceFailed(url string) {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	ss.failed[url] = true
+}
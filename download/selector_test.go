@@ -0,0 +1,126 @@
+package download_test
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/gkatanacio/multisource-downloader/download"
+)
+
+func Test_SourceSelector_RoundRobin(t *testing.T) {
+	sources := []string{"http://a", "http://b", "http://c"}
+	selector := download.NewSourceSelector(download.StrategyRoundRobin, nil)
+
+	var picks []string
+	for i := range 6 {
+		picks = append(picks, selector.Select(i, sources))
+	}
+
+	assert.Equal(t, []string{"http://a", "http://b", "http://c", "http://a", "http://b", "http://c"}, picks)
+}
+
+func Test_SourceSelector_Random(t *testing.T) {
+	const n = 3000
+	sources := []string{"http://a", "http://b", "http://c"}
+	selector := download.NewSourceSelector(download.StrategyRandom, nil)
+
+	picks := make(map[string]int)
+	for i := range n {
+		picks[selector.Select(i, sources)]++
+	}
+
+	// each source gets about a third of the chunks but not exactly in turn
+	assert.Len(t, picks, len(sources))
+	for _, url := range sources {
+		assert.InDelta(t, n/3, picks[url], n/10, url)
+	}
+	assert.False(t, picks["http://a"] == n/3 && picks["http://b"] == n/3, "the picks should not be exactly uniform")
+}
+
+func Test_SourceSelector_LatencyWeighted(t *testing.T) {
+	const n = 3000
+	sources := []string{"http://fast", "http://slow", "http://unknown"}
+	selector := download.NewSourceSelector(download.StrategyLatencyWeighted, map[string]time.Duration{
+		"http://fast": 10 * time.Millisecond,
+		"http://slow": 40 * time.Millisecond,
+	})
+
+	picks := make(map[string]int)
+	for i := range n {
+		picks[selector.Select(i, sources)]++
+	}
+
+	// weights of 4:1:1 since the unknown latency is treated like the slowest one
+	assert.InDelta(t, n*4/6, picks["http://fast"], n/10)
+	assert.InDelta(t, n/6, picks["http://slow"], n/10)
+	assert.InDelta(t, n/6, picks["http://unknown"], n/10)
+}
+
+func Test_SourceSelector_Sticky(t *testing.T) {
+	sources := []string{"http://a", "http://b", "http://c"}
+	selector := download.NewSourceSelector(download.StrategySticky, nil)
+
+	for i := range 5 {
+		assert.Equal(t, "http://a", selector.Select(i, sources))
+	}
+
+	// the first source failed its health check
+	for i := 5; i < 10; i++ {
+		assert.Equal(t, "http://b", selector.Select(i, sources[1:]))
+	}
+}
+
+func Test_Service_Download_StrategySticky(t *testing.T) {
+	content := readFixture(t, "dummy.txt")
+
+	var fastChunks atomic.Int32
+	fast := newTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet && fastChunks.Add(1) > 2 {
+			w.WriteHeader(http.StatusInternalServerError) // fails from the third chunk on
+			return
+		}
+		serveContent("dummy.txt", content)(w, r)
+	}))
+	slow := newTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead {
+			time.Sleep(100 * time.Millisecond)
+		}
+		serveContent("dummy.txt", content)(w, r)
+	}))
+
+	destFilePath := filepath.Join(t.TempDir(), "dummy.txt")
+	downloadService := download.NewService(download.Options{
+		Connections:    1,
+		Timeout:        3,
+		Quiet:          true,
+		DestFilePath:   destFilePath,
+		ChunkBytes:     500,
+		SourceStrategy: download.StrategySticky,
+	}, download.GetMD5Hash)
+
+	err := downloadService.Download([]string{slow.URL + "/dummy.txt", fast.URL + "/dummy.txt"})
+	assert.NoError(t, err)
+
+	downloaded, err := os.ReadFile(destFilePath)
+	assert.NoError(t, err)
+	assert.Equal(t, content, downloaded)
+
+	// the fast source is used until its first failure and the slow one from then on
+	stats := downloadService.LastDownloadStats()
+	for _, ss := range stats.Sources {
+		switch ss.URL {
+		case fast.URL + "/dummy.txt":
+			assert.Equal(t, 2, ss.ChunksDelivered)
+			assert.Equal(t, 1, ss.Errors)
+		case slow.URL + "/dummy.txt":
+			assert.Equal(t, 5, ss.ChunksDelivered)
+			assert.Equal(t, 0, ss.Errors)
+		}
+	}
+}
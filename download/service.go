@@ -1,17 +1,32 @@
 package download
 
 import (
+	"bufio"
 	"bytes"
 	"context"
 	"errors"
 	"fmt"
+	"hash"
 	"io"
+	"io/fs"
+	"log/slog"
 	"net/http"
+	"net/url"
 	"os"
+	"slices"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"golang.org/x/sync/errgroup"
+
+	"github.com/gkatanacio/multisource-downloader/geo"
+	"github.com/gkatanacio/multisource-downloader/metrics"
+	"github.com/gkatanacio/multisource-downloader/sigstore"
 )
 
 var (
@@ -21,223 +36,1232 @@ var (
 	ErrUnknownContentLength          = errors.New("unknown content length")
 	ErrPartialRequestUnsupported     = errors.New("partial request not supported")
 	ErrFailedChunkDownloadAllSources = errors.New("failed to download chunk after attempting from all sources")
+	ErrContentRangeMismatch          = errors.New("Content-Range does not match requested range")
+	ErrNoFileNameInUrl               = errors.New("unable to derive file name from URL")
+	ErrETagUnavailable               = errors.New("ETag not available from sources")
+	ErrUnsupportedHashAlgorithm      = errors.New("unsupported hash algorithm")
+	ErrNoDownloadState               = errors.New("no resumable download found")
+	ErrDownloadStateMismatch         = errors.New("file from sources changed since the download was interrupted")
+	ErrUndefinedTemplateVar          = errors.New("undefined URL template variable")
+	ErrNotModified                   = errors.New("file not modified")
+	ErrFileAlreadyExists             = errors.New("destination file already exists")
+	ErrInvalidSourceUrl              = errors.New("invalid source URL")
+	ErrTruncatedResponse             = errors.New("truncated response body")
+	ErrChecksumMismatch              = errors.New("checksum mismatch")
+	ErrPieceHashMismatch             = errors.New("piece hash mismatch")
+	ErrInvalidPieceHashes            = errors.New("invalid piece hashes")
+	ErrConflictingIPVersions         = errors.New("ForceIPv4 and ForceIPv6 are mutually exclusive")
+	ErrWrongIPVersion                = errors.New("address of wrong IP version")
+	ErrSourcesUnhealthy              = errors.New("more than half of the sources are unhealthy")
+	ErrResourceChangedDuringResume   = errors.New("file from sources changed while resuming the download")
+	ErrUnknownProfile                = errors.New("unknown config profile")
+	ErrIncompleteSigstoreOptions     = errors.New("SigstoreVerify requires SigstoreBundleURL and SigstorePublicKeyPath")
+	ErrSigstoreVerificationFailed    = errors.New("sigstore verification failed")
+	ErrDownloadAlreadyInProgress     = errors.New("download of the destination file already in progress")
+	ErrInvalidSampleBytes            = errors.New("sample bytes must be positive")
+	ErrContentTypeMismatch           = errors.New("content type mismatch")
+	ErrInvalidPartSize               = errors.New("part size must be positive")
+	ErrChunkStalled                  = errors.New("chunk download stalled")
+	ErrMissingEnvVar                 = errors.New("required environment variable not set")
+	ErrInvalidEnvVar                 = errors.New("invalid environment variable")
+	ErrInvalidRangeHeader            = errors.New("invalid Range header")
+	ErrDestinationNotReadable        = errors.New("destination does not implement io.ReaderAt")
+	ErrFileTooLarge                  = errors.New("file exceeds the max file size")
+	ErrInvalidByteSize               = errors.New("invalid byte size")
+	ErrSourceAPIFailed               = errors.New("failed to fetch source URLs from API")
+	ErrInvalidBlockSize              = errors.New("block size must be positive")
+	ErrInvalidByteRange              = errors.New("invalid byte range")
+	ErrNamedPipeUnsupported          = errors.New("not supported when writing to a named pipe")
+
+	errPreallocationUnsupported = errors.New("file preallocation not supported")
+	errFileLockHeld             = errors.New("file lock held by another process")
+	errFileLockUnsupported      = errors.New("file locking not supported")
 )
 
-const suffixOngoingDownload = ".download"
+const (
+	suffixOngoingDownload     = ".download"
+	suffixOngoingAppend       = ".download.append"
+	suffixLock                = ".lock"
+	defaultHashFileAlgorithm  = "sha256"
+	defaultMaxRetryAfterSleep = 30 * time.Second
+	defaultMaxETagRetries     = 3
+	defaultRetryBackoffBase   = 100 * time.Millisecond
+	defaultRetryBackoffCap    = 10 * time.Second
+)
 
 // Service is the service layer that contains operations for downloading.
 type Service struct {
 	opts          Options
 	calculateETag ETagCalculator
 	httpClient    *http.Client
+	fetcher       Fetcher
+	tracer        trace.Tracer
+	metrics       *metrics.Collector
+	logger        *slog.Logger
+	geoScorer     *geo.Scorer
+	pause         pauseController
+	lastStats     atomic.Pointer[DownloadStats]
 }
 
 func NewService(opts Options, calculateETag ETagCalculator) *Service {
-	return &Service{
+	return newService(serviceConfig{
 		opts:          opts,
+		timeout:       time.Second * time.Duration(opts.Timeout),
 		calculateETag: calculateETag,
-		httpClient: &http.Client{
-			Timeout: time.Second * time.Duration(opts.Timeout),
-		},
+	})
+}
+
+// newService creates a Service from the given configuration.
+func newService(cfg serviceConfig) *Service {
+	httpClient := cfg.httpClient
+	if httpClient == nil {
+		httpClient = &http.Client{
+			Timeout:   cfg.timeout,
+			Transport: newTransport(cfg.opts),
+		}
+	}
+
+	hf := &httpFetcher{
+		client:             httpClient,
+		rangeStyle:         cfg.opts.RangeStyle,
+		ifNoneMatch:        cfg.opts.IfNoneMatch,
+		cache:              cfg.opts.Cache,
+		headers:            cfg.opts.PerSourceHeaders,
+		stallTimeout:       cfg.opts.ChunkStallTimeout,
+		userAgent:          cfg.opts.UserAgent,
+		acceptFullResponse: cfg.opts.AcceptFullResponseFallback,
+	}
+
+	var fetcher Fetcher = &schemeFetcher{
+		fetchers: registeredFetchers(cfg.opts, hf),
+		fallback: hf,
+	}
+	if cfg.opts.Fetcher != nil {
+		fetcher = cfg.opts.Fetcher
+	}
+
+	s := &Service{
+		opts:          cfg.opts,
+		calculateETag: cfg.calculateETag,
+		httpClient:    httpClient,
+		fetcher:       fetcher,
+		tracer:        newTracer(cfg.opts.TracerProvider),
+		metrics:       newMetricsCollector(cfg.opts.MetricsRegisterer),
+		logger:        cfg.logger,
+		geoScorer:     newGeoScorer(cfg.opts),
 	}
+
+	if cfg.httpClient == nil && cfg.opts.RoundTripper != nil && hasTransportOptions(cfg.opts) {
+		s.logln("warning: the dial, TLS handshake and response header timeouts, the proxy, the IP version and the socket options do not apply to a custom RoundTripper (use WrapTransport instead)")
+	}
+
+	hf.onFullResponse = func(url string) {
+		s.logln("warning: source", url, "ignores the Range header and sends the whole file for each chunk")
+	}
+
+	return s
+}
+
+// newMetricsCollector returns the metrics collector for the given registerer.
+// A nil collector (i.e., no metrics) is returned if there is no registerer.
+func newMetricsCollector(registerer prometheus.Registerer) *metrics.Collector {
+	if registerer == nil {
+		return nil
+	}
+
+	collector, err := metrics.NewCollector(registerer)
+	if err != nil {
+		printErr(fmt.Errorf("metrics disabled: %w", err))
+		return nil
+	}
+
+	return collector
+}
+
+// newTransport returns the HTTP transport based on the given options. A nil transport
+// means http.DefaultTransport is to be used.
+func newTransport(opts Options) http.RoundTripper {
+	if opts.Verbose && !opts.Quiet {
+		opts.Verbose = false
+		return newLoggingRoundTripper(newTransport(opts), os.Stderr)
+	}
+
+	if len(opts.SourceCredentials) > 0 {
+		credentials := opts.SourceCredentials
+		opts.SourceCredentials = nil
+		return newCredentialsRoundTripper(newTransport(opts), credentials)
+	}
+
+	if opts.WrapTransport != nil {
+		wrap := opts.WrapTransport
+		opts.WrapTransport = nil
+		base := newTransport(opts)
+		if base == nil {
+			base = http.DefaultTransport
+		}
+		return wrap(base)
+	}
+
+	if opts.RoundTripper != nil {
+		return opts.RoundTripper
+	}
+
+	if !hasTransportOptions(opts) {
+		return nil
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if hasCustomDialer(opts) {
+		transport.DialContext = newDialContext(opts)
+	}
+	if opts.TLSHandshakeTimeout > 0 {
+		transport.TLSHandshakeTimeout = opts.TLSHandshakeTimeout
+	}
+	transport.ResponseHeaderTimeout = opts.ResponseHeaderTimeout
+	if len(opts.Proxy) > 0 {
+		proxyURL, err := url.Parse(opts.Proxy) // an invalid URL fails each request
+		transport.Proxy = func(*http.Request) (*url.URL, error) {
+			return proxyURL, err
+		}
+	}
+
+	return transport
+}
+
+// hasTransportOptions returns true if any of the options configuring the transport is set, which
+// do not apply to a custom RoundTripper.
+func hasTransportOptions(opts Options) bool {
+	return hasCustomDialer(opts) || opts.TLSHandshakeTimeout > 0 || opts.ResponseHeaderTimeout > 0 || len(opts.Proxy) > 0
+}
+
+// hasCustomDialer returns true if any of the options configuring the dialer is set.
+func hasCustomDialer(opts Options) bool {
+	return opts.DialTimeout > 0 || opts.ForceIPv4 || opts.ForceIPv6 || opts.SocketMark != 0 || opts.SocketPriority != 0
 }
 
 // Download attempts to download a file from the given sources in a concurrent manner (i.e., in chunks).
 // This creates a temporary file while the download is ongoing and moves it to the actual configured
-// destination file once the download is successfully completed.
+// destination file once the download is successfully completed (or appends it to the destination
+// file when in append mode).
 func (s *Service) Download(sourceUrls []string) error {
+	return s.downloadWithContext(context.Background(), sourceUrls)
+}
+
+// downloadWithContext is the same as Download but with the given context.
+func (s *Service) downloadWithContext(ctx context.Context, sourceUrls []string) error {
+	ctx, span := s.tracer.Start(ctx, "Download", trace.WithAttributes(
+		attribute.String("download.dest_file", s.opts.DestFilePath),
+		attribute.StringSlice("download.sources", sourceUrls),
+	))
+	defer span.End()
+
+	s.metrics.DownloadStarted()
+	defer s.metrics.DownloadFinished()
+
+	sourceUrls, err := normalizeURLs(sourceUrls)
+	if err != nil {
+		return recordSpanError(span, err)
+	}
+
+	if sourceUrls, err = s.withSourceAPIUrls(ctx, sourceUrls); err != nil {
+		return recordSpanError(span, err)
+	}
+
+	if err := s.checkUnmodified(ctx, sourceUrls); err != nil {
+		return recordSpanError(span, err)
+	}
+
+	return recordSpanError(span, s.downloadWithETagRetries(ctx, sourceUrls))
+}
+
+// downloadWithETagRetries performs the download and restarts it from scratch on ETag mismatch
+// if RetryOnETagMismatch is enabled.
+func (s *Service) downloadWithETagRetries(ctx context.Context, sourceUrls []string) error {
+	err := s.download(ctx, sourceUrls, s.opts.Resume)
+	if s.opts.Resume && errors.Is(err, ErrResourceChangedDuringResume) {
+		s.logln("warning: file changed since the download was interrupted, restarting download")
+		// the outdated download was discarded so start afresh
+		if err = s.download(ctx, sourceUrls, false); err != nil {
+			err = fmt.Errorf("%w: %w", ErrResourceChangedDuringResume, err)
+		}
+	}
+	if !s.opts.RetryOnETagMismatch {
+		return err
+	}
+
+	for retry := uint(1); errors.Is(err, ErrETagMismatch) && retry <= s.maxETagRetries(); retry++ {
+		s.logln(fmt.Sprintf("warning: ETag mismatch, retrying download (%d/%d)", retry, s.maxETagRetries()))
+		err = s.download(ctx, sourceUrls, false) // the corrupted download was discarded so start afresh
+	}
+
+	return err
+}
+
+// download contains the actual logic of Download. If resume is true, the existing `.download` file is reused.
+func (s *Service) download(ctx context.Context, sourceUrls []string, resume bool) error {
 	if len(sourceUrls) == 0 {
 		return ErrNoSourceUrls
 	}
 
-	srcFileMetas, err := s.fetchFileMetadataFromSources(sourceUrls)
+	startedAt := time.Now()
+
+	stats := newSourceStatsCollector(sourceUrls)
+	defer func() {
+		s.lastStats.Store(stats.downloadStats())
+	}()
+
+	if isNamedPipe(s.opts.DestFilePath) {
+		if len(s.opts.ValidateContentType) > 0 {
+			s.logln("warning: skipping content type validation for named pipe", s.opts.DestFilePath)
+		}
+		if s.opts.SigstoreVerify {
+			s.logln("warning: skipping sigstore verification for named pipe", s.opts.DestFilePath)
+		}
+		return s.downloadToPipe(ctx, sourceUrls, stats)
+	}
+
+	if s.opts.UseFlock {
+		unlock, err := s.lockDestFile(ctx)
+		if err != nil {
+			return err
+		}
+		defer unlock()
+	}
+
+	noClobber := s.opts.NoClobber && !s.opts.AppendMode
+	if noClobber {
+		// fail early rather than after the whole file has been downloaded
+		if _, err := os.Lstat(s.opts.DestFilePath); err == nil {
+			return fmt.Errorf("%w: %s", ErrFileAlreadyExists, s.opts.DestFilePath)
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+
+	var calculateHash ETagCalculator
+	if s.opts.WriteHashFile {
+		// fail early rather than after the whole file has been downloaded
+		var err error
+		if calculateHash, err = HashCalculatorFor(s.hashFileAlgorithm()); err != nil {
+			return err
+		}
+	}
+
+	var sigstoreVerifier *sigstore.Verifier
+	if s.opts.SigstoreVerify {
+		// fail early rather than after the whole file has been downloaded
+		var err error
+		if sigstoreVerifier, err = s.newSigstoreVerifier(); err != nil {
+			return err
+		}
+	}
+
+	srcFileMetas, err := s.fetchFileMetadataFromSources(ctx, sourceUrls)
 	if err != nil {
 		return err
 	}
+	srcFileMetas = s.withDiscoveredMirrors(ctx, srcFileMetas, stats)
 
-	if !allSourcesMatchFileMetadata(srcFileMetas, s.opts.CheckETag) {
+	if !allSourcesMatchFileMetadata(srcFileMetas, s.opts.CheckETag, !s.opts.LooseContentTypeMatch) {
 		return ErrSourcesFileMismatch
 	}
+	if s.opts.BenchmarkBeforeDownload {
+		s.benchmarkThroughput(ctx, srcFileMetas)
+	}
 
 	fileMetadata := srcFileMetas[0].fileMetadata // any will do since they are assumed to be matching
 
-	ongoingDownloadFile, err := os.Create(s.opts.DestFilePath + suffixOngoingDownload)
+	ongoingDownloadFile, tracker, err := s.prepareOngoingDownload(fileMetadata, resume)
 	if err != nil {
 		return err
 	}
 	defer ongoingDownloadFile.Close()
 
-	if err := s.downloadFileContents(
-		sourceUrlsSortedByEstLatency(srcFileMetas), // sort to prioritize sources with lowest estimated latency
-		fileMetadata,
-		ongoingDownloadFile,
-	); err != nil {
-		return err
+	if s.opts.PreallocateFile && fileMetadata.size >= 0 {
+		err := preallocateFile(ongoingDownloadFile, fileMetadata.size)
+		if errors.Is(err, errPreallocationUnsupported) {
+			s.logln("warning: skipping file preallocation:", err)
+		} else if err != nil {
+			return err
+		}
+	}
+
+	checkETag := s.opts.CheckETag && len(fileMetadata.eTag) > 0
+
+	teeWriter := s.opts.TeeWriter
+	var streamingHasher hash.Hash
+	if checkETag && s.opts.StreamingVerification {
+		streamingHasher = streamingHasherFor(s.calculateETag)
+	}
+	if streamingHasher != nil { // the whole file is read again otherwise (e.g., for a custom ETag calculator)
+		if teeWriter != nil {
+			teeWriter = io.MultiWriter(teeWriter, streamingHasher)
+		} else {
+			teeWriter = streamingHasher
+		}
 	}
 
-	if s.opts.CheckETag && len(fileMetadata.eTag) > 0 {
-		calculatedETag, err := s.calculateETag(ongoingDownloadFile)
+	if fileMetadata.size == -1 {
+		if err := s.streamOngoingDownload(ctx, sourceUrlsSortedByEstLatency(srcFileMetas), &fileMetadata, ongoingDownloadFile, stats, teeWriter); err != nil {
+			return err
+		}
+		checkETag = s.opts.CheckETag && len(fileMetadata.eTag) > 0
+	} else {
+		contentsCtx := ctx
+		if resume && len(fileMetadata.eTag) > 0 {
+			// the HEAD requests may be served by an outdated cache so also check each chunk request
+			contentsCtx = withIfRange(ctx, fileMetadata.eTag)
+		}
+
+		err := s.downloadFileContents(
+			contentsCtx,
+			sourceUrlsSortedByEstLatency(srcFileMetas), // sort to prioritize sources with lowest estimated latency
+			s.newSourceSelector(srcFileMetas),
+			fileMetadata,
+			ongoingDownloadFile,
+			tracker,
+			stats,
+			teeWriter,
+		)
+		// the chunks completed since the last batch written to the state file are needed for resuming
+		err = errors.Join(err, tracker.flush())
+		if errors.Is(err, ErrResourceChangedDuringResume) {
+			if err := discardOngoingDownload(ongoingDownloadFile, tracker); err != nil {
+				return err
+			}
+		}
 		if err != nil {
 			return err
 		}
+	}
+
+	if checkETag {
+		var calculatedETag string
+		if streamingHasher != nil {
+			calculatedETag = fmt.Sprintf("%x", streamingHasher.Sum(nil))
+		} else {
+			if _, err := ongoingDownloadFile.Seek(0, io.SeekStart); err != nil {
+				return err
+			}
+
+			if calculatedETag, err = s.calculateETag(ongoingDownloadFile); err != nil {
+				return err
+			}
+		}
 
 		if calculatedETag != fileMetadata.eTag {
+			if s.opts.RetryOnETagMismatch {
+				if err := discardOngoingDownload(ongoingDownloadFile, tracker); err != nil {
+					return err
+				}
+			}
 			return ErrETagMismatch
 		}
 	}
 
-	if err := os.Rename(ongoingDownloadFile.Name(), s.opts.DestFilePath); err != nil {
+	if len(s.opts.ValidateContentType) > 0 {
+		if err := validateContentType(ongoingDownloadFile.Name(), fileMetadata.contentType, s.opts.ValidateContentType); err != nil {
+			// the file must not end up at the destination path
+			if errors.Is(err, ErrContentTypeMismatch) {
+				if err := discardOngoingDownload(ongoingDownloadFile, tracker); err != nil {
+					return err
+				}
+			}
+			return err
+		}
+	}
+
+	if sigstoreVerifier != nil {
+		if err := sigstoreVerifier.Verify(ctx, ongoingDownloadFile.Name(), s.opts.SigstoreBundleURL); err != nil {
+			// the file must not end up at the destination path
+			if err := discardOngoingDownload(ongoingDownloadFile, tracker); err != nil {
+				return err
+			}
+			return fmt.Errorf("%w: %w", ErrSigstoreVerificationFailed, err)
+		}
+	}
+
+	if err := os.Remove(tracker.filePath); err != nil {
 		return err
 	}
 
+	if s.opts.AppendMode {
+		if err := appendFile(s.opts.DestFilePath, ongoingDownloadFile.Name()); err != nil {
+			return err
+		}
+	} else if noClobber {
+		if err := renameNoClobber(ongoingDownloadFile.Name(), s.opts.DestFilePath); err != nil {
+			return err
+		}
+	} else if err := os.Rename(ongoingDownloadFile.Name(), s.opts.DestFilePath); err != nil {
+		return err
+	}
+
+	if len(s.opts.AuditLogPath) > 0 {
+		record := buildAuditRecord(s.opts.DestFilePath, fileMetadata, startedAt, stats, checkETag)
+		if err := appendAuditRecord(s.opts.AuditLogPath, record); err != nil {
+			return err
+		}
+	}
+
+	if s.opts.WriteHashFile {
+		if err := writeHashFile(s.opts.DestFilePath, s.hashFileAlgorithm(), calculateHash); err != nil {
+			return err
+		}
+	}
+
+	if len(s.opts.ManifestPath) > 0 {
+		manifest, err := buildManifest(s.opts.DestFilePath, startedAt, stats)
+		if err != nil {
+			return err
+		}
+
+		if err := writeManifest(s.opts.ManifestPath, manifest); err != nil {
+			return err
+		}
+	}
+
 	s.logln("Download complete:", s.opts.DestFilePath)
 
 	return nil
 }
 
+// Options returns a copy of the options of the service (e.g., for wrapping their callbacks with WithOptions).
+func (s *Service) Options() Options {
+	return cloneOptions(s.opts)
+}
+
+// LastDownloadStats returns the per-source stats of the most recent Download attempt
+// (nil if there was none).
+func (s *Service) LastDownloadStats() *DownloadStats {
+	return s.lastStats.Load()
+}
+
+// Verify checks the integrity of an already downloaded file by comparing its calculated ETag
+// against the ETag currently reported by the given sources.
+func (s *Service) Verify(ctx context.Context, filePath string, sourceUrls []string) error {
+	if len(sourceUrls) == 0 {
+		return ErrNoSourceUrls
+	}
+
+	srcFileMetas, err := s.fetchFileMetadataFromSources(ctx, sourceUrls)
+	if err != nil {
+		return err
+	}
+
+	if !allSourcesMatchFileMetadata(srcFileMetas, true, !s.opts.LooseContentTypeMatch) {
+		return ErrSourcesFileMismatch
+	}
+
+	eTag := srcFileMetas[0].eTag // any will do since they are assumed to be matching
+	if len(eTag) == 0 {
+		return ErrETagUnavailable
+	}
+
+	file, err := os.Open(filePath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	calculatedETag, err := s.calculateETag(file)
+	if err != nil {
+		return err
+	}
+
+	if calculatedETag != eTag {
+		return ErrETagMismatch
+	}
+
+	s.logln("Verification successful:", filePath)
+
+	return nil
+}
+
+// prepareOngoingDownload opens the temporary file for the ongoing download along with the tracker
+// for its completed chunks. When resuming, the existing temporary file and state are reused.
+func (s *Service) prepareOngoingDownload(fileMetadata fileMetadata, resume bool) (*os.File, *chunkTracker, error) {
+	ongoingDownloadFilePath := s.opts.DestFilePath + suffixOngoingDownload
+
+	if !resume {
+		stateFilePath := s.opts.DestFilePath + suffixDownloadState
+		if s.opts.CompressStateFile {
+			stateFilePath = s.opts.DestFilePath + suffixCompressedDownloadState
+		}
+
+		ongoingDownloadFile, err := os.Create(ongoingDownloadFilePath)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		tracker := newChunkTracker(DownloadState{
+			Size:      fileMetadata.size,
+			ETag:      fileMetadata.eTag,
+			ChunkSize: s.chunkSize(fileMetadata.size),
+		}, stateFilePath)
+		if err := tracker.persist(); err != nil {
+			ongoingDownloadFile.Close()
+			return nil, nil, err
+		}
+
+		return ongoingDownloadFile, tracker, nil
+	}
+
+	stateFilePath, err := findDownloadState(s.opts.DestFilePath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil, fmt.Errorf("%w for %s", ErrNoDownloadState, s.opts.DestFilePath)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	state, err := readDownloadState(stateFilePath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil, fmt.Errorf("%w for %s", ErrNoDownloadState, s.opts.DestFilePath)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if !state.isCompatible(fileMetadata) {
+		return nil, nil, ErrDownloadStateMismatch
+	}
+
+	ongoingDownloadFile, err := os.OpenFile(ongoingDownloadFilePath, os.O_RDWR, 0)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil, fmt.Errorf("%w for %s", ErrNoDownloadState, s.opts.DestFilePath)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	s.logln(fmt.Sprintf("resuming download with %d chunks already completed", len(state.CompletedChunks)))
+
+	return ongoingDownloadFile, newChunkTracker(*state, stateFilePath), nil
+}
+
 // fetchFileMetadataFromSources returns file metadata corresponding to each of the given sources.
-func (s *Service) fetchFileMetadataFromSources(sourceUrls []string) ([]sourceFileMetadata, error) {
-	srcFileMetasChan := make(chan sourceFileMetadata)
+// The metadata is fetched from all of the sources even if some fail so that a MultiSourceError
+// listing every failed source can be returned.
+func (s *Service) fetchFileMetadataFromSources(ctx context.Context, sourceUrls []string) ([]sourceFileMetadata, error) {
+	ctx, span := s.tracer.Start(ctx, "fetchFileMetadataFromSources")
+	defer span.End()
 
-	eg, ctx := errgroup.WithContext(context.Background())
+	srcFileMetas := make([]sourceFileMetadata, len(sourceUrls))
+	srcErrs := make([]error, len(sourceUrls))
 
-	for _, url := range sourceUrls {
-		eg.Go(func() error {
-			req, err := http.NewRequestWithContext(ctx, http.MethodHead, url, nil)
-			if err != nil {
-				return err
+	var wg sync.WaitGroup
+	for i, url := range sourceUrls {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			srcFileMetas[i], srcErrs[i] = s.fetchFileMetadata(ctx, url)
+		}()
+	}
+	wg.Wait()
+
+	var healthy []sourceFileMetadata
+	var multiErr MultiSourceError
+	for i, err := range srcErrs {
+		if err == nil {
+			healthy = append(healthy, srcFileMetas[i])
+			continue
+		}
+
+		// the file rather than the source is the problem so the other sources are not tried either
+		if errors.Is(err, ErrNotModified) || errors.Is(err, ErrFileTooLarge) {
+			return nil, recordSpanError(span, err)
+		}
+
+		multiErr.Errors = append(multiErr.Errors, SourceError{URL: sourceUrls[i], Err: err})
+	}
+
+	if len(multiErr.Errors) > 0 && !s.opts.AllowPartialSources {
+		return nil, recordSpanError(span, multiErr)
+	}
+
+	// proceed with the healthy subset of sources instead
+	for _, srcErr := range multiErr.Errors {
+		s.logln(fmt.Sprintf("warning: dropping source %s: %v", srcErr.URL, srcErr.Err))
+	}
+
+	if len(healthy) == 0 {
+		if len(multiErr.Errors) > 0 {
+			return nil, recordSpanError(span, fmt.Errorf("%w: %w", ErrNoSourceUrls, multiErr))
+		}
+		return nil, recordSpanError(span, ErrNoSourceUrls)
+	}
+
+	return healthy, nil
+}
+
+// fetchFileMetadata retrieves the relevant file metadata from the given source URL.
+func (s *Service) fetchFileMetadata(ctx context.Context, url string) (sourceFileMetadata, error) {
+	start := time.Now()
+
+	headResult, err := s.fetcher.Head(ctx, url)
+	for attempt := uint(0); err != nil && attempt < s.opts.HeadRetryAttempts; attempt++ {
+		if errors.Is(err, ErrNotModified) {
+			break
+		}
+
+		delay := s.opts.HeadRetryBackoff << attempt
+		var retryAfterErr RetryAfterError
+		if s.opts.HonourRetryAfter && errors.As(err, &retryAfterErr) {
+			delay = retryAfterErr.Delay
+			if maxSleep := s.maxRetryAfterSleep(); delay > maxSleep {
+				delay = maxSleep
 			}
+		}
 
-			start := time.Now()
+		s.logln(fmt.Sprintf("warning: retrying HEAD request to %s in %s: %v", url, delay, err))
 
-			resp, err := s.httpClient.Do(req)
-			if err != nil {
+		select {
+		case <-ctx.Done():
+			return sourceFileMetadata{}, ctx.Err()
+		case <-time.After(delay):
+		}
+
+		start = time.Now()
+		headResult, err = s.fetcher.Head(ctx, url)
+	}
+	if err != nil {
+		return sourceFileMetadata{}, err
+	}
+
+	estLatency := time.Since(start) // of the successful attempt only
+
+	if headResult.ContentLength == -1 && !s.opts.AllowUnknownLength {
+		return sourceFileMetadata{}, ErrUnknownContentLength
+	}
+
+	if s.opts.MaxFileSize > 0 && headResult.ContentLength > s.opts.MaxFileSize {
+		return sourceFileMetadata{}, FileTooLargeError{Size: headResult.ContentLength, Limit: s.opts.MaxFileSize}
+	}
+
+	// ranges are not needed for files of unknown length since they are not chunked
+	if !headResult.AcceptRanges && headResult.ContentLength != -1 && !s.opts.AcceptFullResponseFallback {
+		return sourceFileMetadata{}, ErrPartialRequestUnsupported
+	}
+
+	return sourceFileMetadata{
+		url:        url,
+		estLatency: estLatency,
+		distanceKm: s.sourceDistanceKm(ctx, url),
+		fileMetadata: fileMetadata{
+			size:        headResult.ContentLength,
+			contentType: headResult.ContentType,
+			eTag:        headResult.ETag,
+		},
+		metalinkURL: headResult.MetalinkURL,
+	}, nil
+}
+
+// downloadFileContents downloads the file contents from the given source URLs in chunks and
+// writes them in proper order in the provided destination file. The source URLs are prioritized
+// based on their ordering in the given slice and the given selector picks the source of each chunk.
+// Chunks already completed based on the tracker are skipped.
+// Chunk download attempts are recorded in the given source stats and, if a tee writer is given,
+// the chunks are also written to it in order.
+func (s *Service) downloadFileContents(ctx context.Context, sourceUrls []string, selector SourceSelector, fileMetadata fileMetadata, destFile io.WriterAt, tracker *chunkTracker, stats *sourceStatsCollector, teeWriter io.Writer) error {
+	ctx, span := s.tracer.Start(ctx, "downloadFileContents")
+	defer span.End()
+
+	chunkSize := tracker.state.ChunkSize
+
+	if fileMetadata.size < s.opts.MinChunkBytes && len(s.opts.PieceHashes) == 0 {
+		s.logDebug(fmt.Sprintf("file smaller than %d bytes, downloading it with a single request", s.opts.MinChunkBytes))
+		return recordSpanError(span, s.downloadWholeFile(ctx, sourceUrls, fileMetadata, destFile, stats, teeWriter))
+	}
+
+	// only the `.download` file of Download (rather than the destination of DownloadTo) is necessarily readable
+	readableDestFile, _ := destFile.(readerWriterAt)
+
+	var tee *chunkTee
+	if teeWriter != nil {
+		tee = newChunkTee(teeWriter, readableDestFile, tracker, fileMetadata.size)
+	}
+
+	var buffers *chunkBufferPool
+	if s.opts.ChunkBufferPool {
+		buffers = newChunkBufferPool(chunkSize)
+	}
+
+	var pieces *pieceVerifier
+	if len(s.opts.PieceHashes) > 0 {
+		if readableDestFile == nil {
+			return recordSpanError(span, fmt.Errorf("%w: required for PieceHashes", ErrDestinationNotReadable))
+		}
+
+		var err error
+		if pieces, err = newPieceVerifier(s.opts.PieceHashes, s.opts.PieceSize, s.opts.PieceHashAlgorithm, fileMetadata.size, tracker); err != nil {
+			return recordSpanError(span, err)
+		}
+
+		// pieces written before the download started (i.e., when resuming) are verified right away
+		for _, p := range pieces.completedPieces() {
+			if err := s.verifyPiece(ctx, stats, sourceUrls, readableDestFile, pieces, p); err != nil {
+				return recordSpanError(span, err)
+			}
+		}
+	}
+
+	downloadCtx := ctx // for assembling the segments after the pipeline is done
+
+	// a failure in either the download or the write stage cancels the other stage
+	pipeline, pipelineCtx := errgroup.WithContext(ctx)
+
+	var segments *segmentFiles
+	if f, ok := destFile.(*os.File); ok && s.opts.SegmentedTempFiles {
+		segments = newSegmentFiles(f.Name())
+		defer segments.removeAll() // in case the download fails
+	}
+
+	// the chunks are only delivered to the tee and marked as completed once they are in the `.download` file
+	writeChunk := func(ctx context.Context, cw chunkWrite) error {
+		if err := s.writeChunk(destFile, cw.offset, cw.chunk); err != nil {
+			return err
+		}
+
+		if tee != nil {
+			chunk := cw.chunk
+			if cw.buf != nil {
+				chunk = bytes.Clone(chunk) // the tee can hold on to the chunk until the preceding ones arrive
+			}
+			if err := tee.deliver(cw.index, chunk); err != nil {
 				return err
 			}
-			defer resp.Body.Close()
+		}
+
+		if err := tracker.markCompleted(cw.index); err != nil {
+			return err
+		}
+		if s.opts.OnProgress != nil {
+			s.opts.OnProgress(cw.index, sourceUrls[cw.source], cw.offset, int64(len(cw.chunk)))
+		}
+
+		if pieces != nil {
+			for _, p := range pieces.chunkWritten(cw.index, cw.source) {
+				if err := s.verifyPiece(ctx, stats, sourceUrls, readableDestFile, pieces, p); err != nil {
+					return err
+				}
+			}
+		}
+
+		return nil
+	}
+
+	completeChunk := func(cw chunkWrite) error {
+		defer buffers.put(cw.buf)
+
+		if segments != nil {
+			return segments.write(cw) // the segments are written to the `.download` file once all are downloaded
+		}
+
+		return writeChunk(pipelineCtx, cw)
+	}
+
+	var pool *writePool
+	if s.opts.WriteWorkers > 0 {
+		pool = startWritePool(pipeline, pipelineCtx, s.opts.WriteWorkers, s.opts.WriteQueueDepth, completeChunk)
+	}
+
+	eg, ctx := errgroup.WithContext(pipelineCtx)
+
+	connections := s.memoryLimitedConnections(chunkSize)
+	memory := newMemoryGate(s.opts.MaxMemoryUsageBytes)
 
-			estLatency := time.Since(start)
+	// the lanes are fixed per connection whereas the scaler varies the number of connections
+	pipelined := s.opts.PipelineChunks > 1 && !s.opts.AutoScaleConnections
 
-			if resp.StatusCode != http.StatusOK {
-				return fmt.Errorf("received %d response from %s", resp.StatusCode, url)
+	// the scaler limits the concurrency instead of the errgroup since the limit can increase
+	var scaler *connectionScaler
+	if s.opts.AutoScaleConnections {
+		scaler = newConnectionScaler(sourceUrls, int(connections), int(s.opts.MaxConnections))
+		selector = scaler // its weights take precedence over SourceStrategy
+
+		go s.monitorConnections(ctx, sourceUrls, stats, scaler) // ctx is cancelled once eg.Wait returns
+	} else if !pipelined {
+		eg.SetLimit(int(connections)) // the lanes already limit the concurrency otherwise
+	}
+
+	healthRegistry := newSourceHealthRegistry()
+	if s.opts.SourceRecheckInterval > 0 {
+		go s.monitorSourceHealth(ctx, sourceUrls, healthRegistry) // ctx is cancelled once eg.Wait returns
+	}
+
+	fetchChunk := func(ctx context.Context, pc pendingChunk) (cw chunkWrite, err error) {
+		ctx, span := s.tracer.Start(ctx, "chunk", trace.WithAttributes(chunkAttributes(pc.index, pc.offset, pc.limit-pc.offset)...))
+		defer func() {
+			recordSpanError(span, err)
+			span.End()
+		}()
+
+		if err := memory.acquire(ctx); err != nil {
+			return chunkWrite{}, err
+		}
+		defer memory.release()
+
+		buf := buffers.get(pc.limit - pc.offset)
+
+		// selected once the chunk starts so that the selector knows about the failures of the preceding chunks
+		preferredSrcIdx := slices.Index(sourceUrls, selector.Select(pc.index, healthRegistry.healthySources(sourceUrls)))
+		srcIdxInitAttempt := healthRegistry.pickSource(sourceUrls, preferredSrcIdx)
+		if s.opts.OnChunkStart != nil {
+			s.opts.OnChunkStart(pc.index, sourceUrls[srcIdxInitAttempt], pc.offset, pc.limit-pc.offset)
+		}
+		chunk, url, err := s.fetchChunkFromSources(ctx, stats, sourceUrls, srcIdxInitAttempt, pc.index, pc.offset, pc.limit, buf.bytes())
+		if fo, ok := selector.(sourceFailureObserver); ok && ctx.Err() == nil && url != sourceUrls[srcIdxInitAttempt] {
+			fo.sourceFailed(sourceUrls[srcIdxInitAttempt])
+		}
+		if err != nil {
+			return chunkWrite{}, fmt.Errorf("failed to download file contents: %w", err)
+		}
+
+		s.logln(fmt.Sprintf("chunk %d downloaded from %s", pc.index, url))
+		span.SetAttributes(attribute.String("chunk.source", url))
+
+		return chunkWrite{index: pc.index, offset: pc.offset, chunk: chunk, buf: buf, source: slices.Index(sourceUrls, url)}, nil
+	}
+
+	deliverChunk := func(ctx context.Context, cw chunkWrite) error {
+		if pool != nil {
+			return pool.submit(ctx, cw)
+		}
+		return completeChunk(cw)
+	}
+
+	var lanes *chunkLanes
+	if pipelined {
+		lanes = startChunkLanes(eg, ctx, connections, s.opts.PipelineChunks, fetchChunk, deliverChunk)
+	}
+
+	for offset, i := int64(0), 0; offset < fileMetadata.size; offset, i = offset+chunkSize, i+1 {
+		if tracker.isCompleted(i) {
+			continue
+		}
+
+		pc := pendingChunk{index: i, offset: offset, limit: min(offset+chunkSize, fileMetadata.size)}
+
+		if lanes != nil {
+			if err := lanes.enqueue(ctx, pc); err != nil {
+				break // the error which cancelled the context is returned by eg.Wait
 			}
+			continue
+		}
 
-			if resp.ContentLength == -1 {
-				return ErrUnknownContentLength
+		if scaler != nil {
+			if err := scaler.acquire(ctx); err != nil {
+				break // the error which cancelled the context is returned by eg.Wait
 			}
+		}
 
-			acceptRanges := resp.Header.Get("Accept-Ranges")
-			if len(acceptRanges) == 0 || acceptRanges == "none" {
-				return ErrPartialRequestUnsupported
+		eg.Go(func() error {
+			if scaler != nil {
+				defer scaler.release()
 			}
 
-			srcFileMetasChan <- sourceFileMetadata{
-				url:        url,
-				estLatency: estLatency,
-				fileMetadata: fileMetadata{
-					size:        resp.ContentLength,
-					contentType: resp.Header.Get("Content-Type"),
-					eTag:        strings.Trim(resp.Header.Get("ETag"), `"`),
-				},
+			cw, err := fetchChunk(ctx, pc)
+			if err != nil {
+				return err
 			}
 
-			return nil
+			return deliverChunk(ctx, cw)
 		})
 	}
+	if lanes != nil {
+		lanes.close()
+	}
 
-	go func() {
-		eg.Wait()
-		close(srcFileMetasChan)
-	}()
+	err := eg.Wait()
+	if pool != nil {
+		pool.close()
+	}
+	if pipelineErr := pipeline.Wait(); pipelineErr != nil {
+		err = pipelineErr // the first error of either stage rather than the resulting cancellation
+	}
+	if err != nil {
+		return recordSpanError(span, err)
+	}
 
-	var srcFileMetas []sourceFileMetadata
-	for sfm := range srcFileMetasChan {
-		srcFileMetas = append(srcFileMetas, sfm)
+	if segments != nil {
+		if err := segments.assemble(func(cw chunkWrite) error { return writeChunk(downloadCtx, cw) }); err != nil {
+			return recordSpanError(span, err)
+		}
 	}
 
-	return srcFileMetas, eg.Wait()
+	if tee != nil {
+		return recordSpanError(span, tee.flush())
+	}
+
+	return nil
 }
 
-// downloadFileContents downloads the file contents from the given source URLs in chunks and
-// writes them in proper order in the provided destination file. The source URLs are prioritized
-// based on their ordering in the given slice.
-func (s *Service) downloadFileContents(sourceUrls []string, fileMetadata fileMetadata, destFile *os.File) error {
-	chunkSize := fileMetadata.size / int64(s.opts.Connections)
+// downloadWholeFile downloads the whole file with a single request to the first source (i.e., the one
+// with the lowest estimated latency), falling back to the other sources like a chunk would, and writes
+// it to the destination file and to the tee writer if given.
+func (s *Service) downloadWholeFile(ctx context.Context, sourceUrls []string, fileMetadata fileMetadata, destFile io.WriterAt, stats *sourceStatsCollector, teeWriter io.Writer) error {
+	if s.opts.OnChunkStart != nil {
+		s.opts.OnChunkStart(0, sourceUrls[0], 0, fileMetadata.size)
+	}
 
-	eg, ctx := errgroup.WithContext(context.Background())
-	eg.SetLimit(int(s.opts.Connections))
+	contents, url, err := s.fetchChunkFromSources(ctx, stats, sourceUrls, 0, 0, 0, fileMetadata.size, nil)
+	if err != nil {
+		return fmt.Errorf("failed to download file contents: %w", err)
+	}
 
-	for offset, i := int64(0), 0; offset < fileMetadata.size; offset, i = offset+chunkSize, i+1 {
-		srcIdxInitAttempt := i % len(sourceUrls)
-		limit := min(offset+chunkSize, fileMetadata.size)
+	if err := s.writeChunk(destFile, 0, contents); err != nil {
+		return err
+	}
 
-		eg.Go(func() error {
-			var chunk []byte
-			url := sourceUrls[srcIdxInitAttempt]
+	if teeWriter != nil {
+		if _, err := teeWriter.Write(contents); err != nil {
+			return err
+		}
+	}
 
-			chunk, err := s.fetchChunk(ctx, url, offset, limit)
-			if err != nil {
-				printErr(fmt.Errorf("failed initial download of chunk %d from %s: %w", i, url, err))
-
-				// try to download chunk from other sources (priority based on sourceUrls ordering)
-				for j := 0; j < len(sourceUrls) && err != nil; j++ {
-					// stop retrying if context already done (e.g., error returned in another goroutine)
-					select {
-					case <-ctx.Done():
-						return ctx.Err()
-					default:
-					}
-
-					if j == srcIdxInitAttempt {
-						continue
-					}
-
-					url = sourceUrls[j]
-					chunk, err = s.fetchChunk(ctx, url, offset, limit)
-					if err != nil {
-						printErr(fmt.Errorf("failed download retry of chunk %d from %s: %w", i, url, err))
-					}
-				}
+	if s.opts.OnProgress != nil {
+		s.opts.OnProgress(0, url, 0, int64(len(contents)))
+	}
 
-				if err != nil {
-					return ErrFailedChunkDownloadAllSources
-				}
-			}
+	s.logln(fmt.Sprintf("file (%d bytes) downloaded from %s", len(contents), url))
+	return nil
+}
 
-			s.logln(fmt.Sprintf("chunk %d downloaded from %s", i, url))
+// fetchChunkFromSources attempts to retrieve the chunk with the given index from the source with the
+// given index first and then from the other sources (priority based on sourceUrls ordering) until one
+// succeeds. The URL of the source which delivered the chunk is returned. If all sources fail,
+// a ChunkError is returned unless the context is already done.
+func (s *Service) fetchChunkFromSources(ctx context.Context, stats *sourceStatsCollector, sourceUrls []string, srcIdxInitAttempt, i int, offset, limit int64, buf []byte) ([]byte, string, error) {
+	url := sourceUrls[srcIdxInitAttempt]
 
-			_, err = io.Copy(io.NewOffsetWriter(destFile, offset), bytes.NewReader(chunk))
-			return err
+	chunk, err := s.observedFetchChunk(ctx, stats, url, offset, limit, buf)
+	if err == nil {
+		return chunk, url, nil
+	}
+	if errors.Is(err, ErrResourceChangedDuringResume) {
+		return nil, "", err // the other sources cannot help since the completed chunks are outdated
+	}
+
+	printErr(fmt.Errorf("failed initial download of chunk %d from %s: %w", i, url, err))
+
+	chunkErr := ChunkError{
+		ChunkIndex:   i,
+		Offset:       offset,
+		Size:         limit - offset,
+		TriedSources: []SourceAttempt{{URL: url, Err: err, Attempt: 1}},
+	}
+
+	for j := 0; j < len(sourceUrls); j++ {
+		// stop retrying if context already done (e.g., error returned in another goroutine)
+		select {
+		case <-ctx.Done():
+			return nil, "", ctx.Err()
+		default:
+		}
+
+		if j == srcIdxInitAttempt {
+			continue
+		}
+
+		if err := s.waitBeforeChunkRetry(ctx, len(chunkErr.TriedSources)-1); err != nil {
+			return nil, "", err
+		}
+
+		url = sourceUrls[j]
+		chunk, err = s.observedFetchChunk(ctx, stats, url, offset, limit, buf)
+		if err == nil {
+			return chunk, url, nil
+		}
+		if errors.Is(err, ErrResourceChangedDuringResume) {
+			return nil, "", err
+		}
+
+		printErr(fmt.Errorf("failed download retry of chunk %d from %s: %w", i, url, err))
+		chunkErr.TriedSources = append(chunkErr.TriedSources, SourceAttempt{
+			URL:     url,
+			Err:     err,
+			Attempt: len(chunkErr.TriedSources) + 1,
 		})
 	}
 
-	return eg.Wait()
+	return nil, "", chunkErr
 }
 
-// fetchChunk attempts to GET a chunk of the file from the given URL.
-func (s *Service) fetchChunk(ctx context.Context, url string, start, end int64) ([]byte, error) {
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+// waitBeforeChunkRetry waits for the delay determined by RetryBackoff (if set) before the retry with
+// the given attempt number (starting at 0). An error is returned if the context is done in the meantime.
+func (s *Service) waitBeforeChunkRetry(ctx context.Context, attempt int) error {
+	if s.opts.RetryBackoff == nil {
+		return nil
+	}
+
+	base, maxDelay := s.opts.RetryBackoffBase, s.opts.RetryBackoffCap
+	if base == 0 {
+		base = defaultRetryBackoffBase
+	}
+	if maxDelay == 0 {
+		maxDelay = defaultRetryBackoffCap
+	}
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(s.opts.RetryBackoff.Next(attempt, base, maxDelay)):
+		return nil
+	}
+}
+
+// writeChunk writes the chunk to the file at the given offset, buffering writes if WriteBufferSize is set.
+func (s *Service) writeChunk(destFile io.WriterAt, offset int64, chunk []byte) error {
+	var w io.Writer = io.NewOffsetWriter(destFile, offset)
+	if s.opts.WriteBufferSize <= 0 {
+		_, err := io.Copy(w, bytes.NewReader(chunk))
+		return err
+	}
+
+	bw := bufio.NewWriterSize(w, s.opts.WriteBufferSize)
+	if _, err := io.Copy(bw, bytes.NewReader(chunk)); err != nil {
+		return err
+	}
+
+	return bw.Flush()
+}
+
+// observedFetchChunk is the same as fetchChunk but also records the attempt in the metrics
+// and the source stats. It waits first if the service is paused.
+func (s *Service) observedFetchChunk(ctx context.Context, stats *sourceStatsCollector, url string, start, end int64, buf []byte) (chunk []byte, err error) {
+	release, err := s.pause.acquire(ctx)
 	if err != nil {
 		return nil, err
 	}
-	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", start, end))
+	defer release()
 
-	resp, err := s.httpClient.Do(req)
+	defer func(start time.Time) {
+		if err != nil {
+			s.metrics.ChunkFailed(url, errorType(err), time.Since(start))
+			stats.chunkFailed(url)
+		} else {
+			s.metrics.ChunkDownloaded(url, len(chunk), time.Since(start))
+			stats.chunkDownloaded(url, len(chunk), time.Since(start))
+		}
+	}(time.Now())
+
+	return s.fetchChunk(ctx, url, start, end, buf)
+}
+
+// fetchChunk attempts to retrieve a chunk of the file from the given URL.
+// The start offset is inclusive while the end offset is exclusive. The chunk is read into
+// the given buffer if it is not nil and the fetcher supports it.
+func (s *Service) fetchChunk(ctx context.Context, url string, start, end int64, buf []byte) ([]byte, error) {
+	chunk, err := s.getRangeHonouringRetryAfter(ctx, url, start, end, buf)
 	if err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusPartialContent {
-		return nil, fmt.Errorf("received %d response from %s", resp.StatusCode, url)
+	// guard against proxies silently truncating the response body
+	if int64(len(chunk)) != end-start {
+		return nil, truncatedResponseError(url, len(chunk), end-start)
+	}
+
+	return chunk, nil
+}
+
+// getRangeHonouringRetryAfter retrieves the range from the fetcher and waits before retrying
+// once if the source requested a delay and HonourRetryAfter is enabled.
+func (s *Service) getRangeHonouringRetryAfter(ctx context.Context, url string, start, end int64, buf []byte) ([]byte, error) {
+	chunk, err := getRangeInto(ctx, s.fetcher, url, start, end, buf)
+
+	var retryAfterErr RetryAfterError
+	if err == nil || !s.opts.HonourRetryAfter || !errors.As(err, &retryAfterErr) {
+		return chunk, err
+	}
+
+	retryAfter := retryAfterErr.Delay
+	if maxSleep := s.maxRetryAfterSleep(); retryAfter > maxSleep {
+		retryAfter = maxSleep
+	}
+
+	// fall back to the next source immediately if waiting would not fit before the deadline
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < retryAfter {
+		return nil, err
+	}
+
+	s.logln(fmt.Sprintf("waiting %s before retrying %s as requested by server", retryAfter, url))
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-time.After(retryAfter):
+	}
+
+	return getRangeInto(ctx, s.fetcher, url, start, end, buf)
+}
+
+// chunkSize returns the size of the chunks for a file of the given size which is either ChunkBytes
+// or the file size divided evenly among the connections (capped by MaxMemoryUsageBytes).
+func (s *Service) chunkSize(size int64) int64 {
+	if s.opts.ChunkBytes > 0 {
+		return s.memoryLimitedChunkSize(s.opts.ChunkBytes)
+	}
+
+	return s.memoryLimitedChunkSize(max(size/int64(s.opts.Connections), 1)) // avoid empty chunks if fewer bytes than connections
+}
+
+// hashFileAlgorithm returns the configured algorithm for the hash file or the default if not set.
+func (s *Service) hashFileAlgorithm() string {
+	if len(s.opts.HashFileAlgorithm) == 0 {
+		return defaultHashFileAlgorithm
 	}
 
-	return io.ReadAll(resp.Body)
+	return s.opts.HashFileAlgorithm
+}
+
+// maxRetryAfterSleep returns the configured cap for Retry-After delays or the default if not set.
+func (s *Service) maxRetryAfterSleep() time.Duration {
+	if s.opts.MaxRetryAfterSleep == 0 {
+		return defaultMaxRetryAfterSleep
+	}
+
+	return s.opts.MaxRetryAfterSleep
+}
+
+// maxETagRetries returns the configured cap for retries on ETag mismatch or the default if not set.
+func (s *Service) maxETagRetries() uint {
+	if s.opts.MaxETagRetries == 0 {
+		return defaultMaxETagRetries
+	}
+
+	return s.opts.MaxETagRetries
+}
+
+// logDebug prints the message in verbose mode (unless quiet). If the service has a logger, the message
+// is logged at debug level instead.
+func (s *Service) logDebug(msg string) {
+	if s.opts.Quiet {
+		return
+	}
+
+	if s.logger != nil {
+		s.logger.Debug(msg)
+		return
+	}
+
+	if s.opts.Verbose {
+		fmt.Println(msg)
+	}
 }
 
 // logln prints the arguments (separated by space) and a newline if the service is not in quiet mode.
+// If the service has a logger, the message is logged at info level instead.
 func (s *Service) logln(args ...any) {
-	if !s.opts.Quiet {
-		fmt.Println(args...)
+	if s.opts.Quiet {
+		return
 	}
+
+	if s.logger != nil {
+		s.logger.Info(strings.TrimSuffix(fmt.Sprintln(args...), "\n"))
+		return
+	}
+
+	fmt.Println(args...)
 }
@@ -0,0 +1,316 @@
+package download
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/gkatanacio/multisource-downloader/geo"
+)
+
+func Test_preallocateFile(t *testing.T) {
+	file, err := os.Create(filepath.Join(t.TempDir(), "preallocated.download"))
+	assert.NoError(t, err)
+	defer file.Close()
+
+	err = preallocateFile(file, 4096)
+	if errors.Is(err, errPreallocationUnsupported) {
+		t.Skip(err)
+	}
+	assert.NoError(t, err)
+
+	fileInfo, err := file.Stat()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(4096), fileInfo.Size())
+}
+
+func Test_Service_fetchChunk_ContentRangeMismatch(t *testing.T) {
+	content := []byte("0123456789")
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes 0-4/%d", len(content)))
+		w.WriteHeader(http.StatusPartialContent)
+		w.Write(content[:5])
+	}))
+	defer srv.Close()
+
+	s := NewService(Options{Timeout: 3}, nil)
+
+	_, err := s.fetchChunk(context.Background(), srv.URL, 2, 7, nil)
+	assert.ErrorIs(t, err, ErrContentRangeMismatch)
+}
+
+func Test_Service_fetchChunk_ContentRangeMatch(t *testing.T) {
+	content := []byte("0123456789")
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "digits.txt", time.Time{}, bytes.NewReader(content))
+	}))
+	defer srv.Close()
+
+	s := NewService(Options{Timeout: 3}, nil)
+
+	chunk, err := s.fetchChunk(context.Background(), srv.URL, 2, 7, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, content[2:7], chunk)
+}
+
+func Test_Service_fetchChunk_TruncatedResponse(t *testing.T) {
+	content := []byte("0123456789")
+
+	testCases := map[string]struct {
+		contentLengthHeader string
+	}{
+		"connection closed before declared content length": {
+			contentLengthHeader: "Content-Length: 5\r\n",
+		},
+		"connection closed without declared content length": {
+			contentLengthHeader: "",
+		},
+	}
+
+	for scenario, tc := range testCases {
+		t.Run(scenario, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				conn, buf, err := w.(http.Hijacker).Hijack()
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				defer conn.Close()
+
+				// the headers are for the requested range but the body ends prematurely
+				fmt.Fprintf(buf, "HTTP/1.1 206 Partial Content\r\nContent-Range: bytes 2-6/%d\r\n%sConnection: close\r\n\r\n", len(content), tc.contentLengthHeader)
+				buf.Write(content[2:4])
+				buf.Flush()
+			}))
+			defer srv.Close()
+
+			s := NewService(Options{Timeout: 3}, nil)
+
+			_, err := s.fetchChunk(context.Background(), srv.URL, 2, 7, nil)
+			assert.ErrorIs(t, err, ErrTruncatedResponse)
+		})
+	}
+}
+
+func Test_parseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 4, 12, 10, 0, 0, 0, time.UTC)
+
+	testCases := map[string]struct {
+		retryAfter    string
+		expectedDelay time.Duration
+		expectedOk    bool
+	}{
+		"delta-seconds": {
+			retryAfter:    "120",
+			expectedDelay: 2 * time.Minute,
+			expectedOk:    true,
+		},
+		"HTTP-date": {
+			retryAfter:    "Fri, 12 Apr 2024 10:00:05 GMT",
+			expectedDelay: 5 * time.Second,
+			expectedOk:    true,
+		},
+		"HTTP-date in the past": {
+			retryAfter:    "Fri, 12 Apr 2024 09:00:00 GMT",
+			expectedDelay: 0,
+			expectedOk:    true,
+		},
+		"negative delta-seconds": {
+			retryAfter: "-1",
+		},
+		"empty": {
+			retryAfter: "",
+		},
+		"invalid": {
+			retryAfter: "soon",
+		},
+	}
+
+	for scenario, tc := range testCases {
+		t.Run(scenario, func(t *testing.T) {
+			delay, ok := parseRetryAfter(tc.retryAfter, now)
+			assert.Equal(t, tc.expectedOk, ok)
+			assert.Equal(t, tc.expectedDelay, delay)
+		})
+	}
+}
+
+func Test_chunkTee_OutOfOrderHash(t *testing.T) {
+	content := []byte("the quick brown fox jumps over the lazy dog")
+	chunkSize := int64(5)
+
+	// the first chunk was already completed before the download started (i.e., resumed)
+	destFilePath := filepath.Join(t.TempDir(), "dummy.txt.download")
+	assert.NoError(t, os.WriteFile(destFilePath, content, 0644))
+	destFile, err := os.Open(destFilePath)
+	assert.NoError(t, err)
+	defer destFile.Close()
+
+	tracker := newChunkTracker(DownloadState{
+		Size:            int64(len(content)),
+		ChunkSize:       chunkSize,
+		CompletedChunks: []int{0},
+	}, destFilePath+".state")
+
+	hasher := md5.New()
+	tee := newChunkTee(hasher, destFile, tracker, int64(len(content)))
+
+	numChunks := (len(content) + int(chunkSize) - 1) / int(chunkSize)
+	for i := numChunks - 1; i > 0; i-- {
+		start := int64(i) * chunkSize
+		end := min(start+chunkSize, int64(len(content)))
+		assert.NoError(t, tee.deliver(i, content[start:end]))
+	}
+	assert.NoError(t, tee.flush())
+
+	expected, err := GetMD5Hash(bytes.NewReader(content))
+	assert.NoError(t, err)
+	assert.Equal(t, expected, fmt.Sprintf("%x", hasher.Sum(nil)))
+}
+
+// inMemoryRangeRoundTripper serves the requested byte ranges of the content without any network
+// so that allocations are mostly due to the download itself.
+type inMemoryRangeRoundTripper struct {
+	content []byte
+}
+
+func (rt inMemoryRangeRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	var start, end int
+	if _, err := fmt.Sscanf(req.Header.Get("Range"), "bytes=%d-%d", &start, &end); err != nil {
+		return nil, err
+	}
+
+	return &http.Response{
+		StatusCode: http.StatusPartialContent,
+		Header:     http.Header{"Content-Range": {fmt.Sprintf("bytes %d-%d/%d", start, end, len(rt.content))}},
+		Body:       io.NopCloser(bytes.NewReader(rt.content[start : end+1])),
+		Request:    req,
+	}, nil
+}
+
+func Test_Service_downloadFileContents_ChunkBufferPool(t *testing.T) {
+	content := bytes.Repeat([]byte("0123456789abcdef"), 1<<18) // 4 MiB
+	urls := []string{"http://source.example.com/file.bin"}
+
+	measure := func(chunkBufferPool bool) (allocs float64, allocBytes uint64) {
+		s := NewServiceWithOptions(
+			WithHTTPClient(&http.Client{Transport: inMemoryRangeRoundTripper{content: content}}),
+			WithConnections(2),
+			WithQuiet(true),
+		)
+		s.opts.ChunkBufferPool = chunkBufferPool
+
+		destFile, err := os.Create(filepath.Join(t.TempDir(), "file.bin.download"))
+		assert.NoError(t, err)
+		defer destFile.Close()
+
+		runDownload := func() {
+			tracker := newChunkTracker(DownloadState{
+				Size:      int64(len(content)),
+				ChunkSize: 256 << 10, // more chunks than connections so that buffers get reused
+			}, destFile.Name()+".state")
+
+			err := s.downloadFileContents(context.Background(), urls, roundRobinSelector{}, fileMetadata{size: int64(len(content))}, destFile, tracker, newSourceStatsCollector(urls), nil)
+			assert.NoError(t, err)
+		}
+
+		var before, after runtime.MemStats
+		runtime.ReadMemStats(&before)
+		allocs = testing.AllocsPerRun(5, runDownload)
+		runtime.ReadMemStats(&after)
+
+		downloaded, err := os.ReadFile(destFile.Name())
+		assert.NoError(t, err)
+		assert.Equal(t, content, downloaded)
+
+		return allocs, after.TotalAlloc - before.TotalAlloc
+	}
+
+	unpooledAllocs, unpooledBytes := measure(false)
+	pooledAllocs, pooledBytes := measure(true)
+
+	assert.Less(t, pooledAllocs, unpooledAllocs*0.9)
+	assert.Less(t, pooledBytes, unpooledBytes/4)
+}
+
+// mockGeoLocator locates the IP addresses based on a fixed mapping instead of a GeoIP database.
+type mockGeoLocator map[string]geo.Location
+
+func (ml mockGeoLocator) Locate(ip net.IP) (geo.Location, error) {
+	location, ok := ml[ip.String()]
+	if !ok {
+		return geo.Location{}, geo.ErrLocationUnknown
+	}
+
+	return location, nil
+}
+
+func Test_sourceUrlsSortedByEstLatency_GeoScore(t *testing.T) {
+	locator := mockGeoLocator{
+		"192.0.2.1":    {Latitude: 51.5074, Longitude: -0.1278},  // local host in London
+		"198.51.100.1": {Latitude: 48.8566, Longitude: 2.3522},   // Paris
+		"203.0.113.1":  {Latitude: 35.6762, Longitude: 139.6503}, // Tokyo
+	}
+	scorer, err := geo.NewScorer(locator, net.ParseIP("192.0.2.1"))
+	assert.NoError(t, err)
+
+	s := NewService(Options{Quiet: true}, nil)
+	s.geoScorer = scorer
+
+	nearUrl := "http://198.51.100.1/file.bin"
+	farUrl := "http://203.0.113.1/file.bin"
+	unknownUrl := "http://192.0.2.99/file.bin"
+
+	testCases := map[string]struct {
+		srcFileMetas []sourceFileMetadata
+		expectedUrls []string
+	}{
+		"closer source ranked higher despite slightly worse latency": {
+			srcFileMetas: []sourceFileMetadata{
+				{url: farUrl, estLatency: 100 * time.Millisecond},
+				{url: nearUrl, estLatency: 120 * time.Millisecond},
+			},
+			expectedUrls: []string{nearUrl, farUrl},
+		},
+		"much lower latency outweighs distance": {
+			srcFileMetas: []sourceFileMetadata{
+				{url: nearUrl, estLatency: 900 * time.Millisecond},
+				{url: farUrl, estLatency: 10 * time.Millisecond},
+			},
+			expectedUrls: []string{farUrl, nearUrl},
+		},
+		"latency only if any distance is unknown": {
+			srcFileMetas: []sourceFileMetadata{
+				{url: nearUrl, estLatency: 120 * time.Millisecond},
+				{url: unknownUrl, estLatency: 110 * time.Millisecond},
+				{url: farUrl, estLatency: 100 * time.Millisecond},
+			},
+			expectedUrls: []string{farUrl, unknownUrl, nearUrl},
+		},
+	}
+
+	for scenario, tc := range testCases {
+		t.Run(scenario, func(t *testing.T) {
+			for i := range tc.srcFileMetas {
+				tc.srcFileMetas[i].distanceKm = s.sourceDistanceKm(context.Background(), tc.srcFileMetas[i].url)
+			}
+
+			assert.Equal(t, tc.expectedUrls, sourceUrlsSortedByEstLatency(tc.srcFileMetas))
+		})
+	}
+}
@@ -0,0 +1,234 @@
+package download_test
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/gkatanacio/multisource-downloader/download"
+)
+
+// newUnresponsiveAddr returns the address of a listener with a zero backlog which is never
+// accepted from. Once its only pending connection slot is occupied, further dials hang until timeout.
+func newUnresponsiveAddr(t *testing.T) string {
+	t.Helper()
+
+	fd, err := syscall.Socket(syscall.AF_INET, syscall.SOCK_STREAM, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { syscall.Close(fd) })
+
+	if err := syscall.Bind(fd, &syscall.SockaddrInet4{Addr: [4]byte{127, 0, 0, 1}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := syscall.Listen(fd, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	sa, err := syscall.Getsockname(fd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := fmt.Sprintf("127.0.0.1:%d", sa.(*syscall.SockaddrInet4).Port)
+
+	conn, err := net.DialTimeout("tcp", addr, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	return addr
+}
+
+func Test_Service_Download_DialTimeout(t *testing.T) {
+	addr := newUnresponsiveAddr(t)
+
+	downloadService := download.NewService(download.Options{
+		Timeout:     10,
+		DialTimeout: 200 * time.Millisecond,
+		Quiet:       true,
+	}, nil)
+
+	start := time.Now()
+	err := downloadService.Download([]string{fmt.Sprintf("http://%s/dummy.txt", addr)})
+	elapsed := time.Since(start)
+
+	assert.Error(t, err)
+	assert.Less(t, elapsed, 2*time.Second, "should be bounded by the dial timeout rather than the request timeout")
+}
+
+func Test_Service_Download_NamedPipe(t *testing.T) {
+	content := readFixture(t, "dummy.png")
+	srv := newTestServer(t, serveContentWithETag("dummy.png", content))
+
+	tempDir := t.TempDir()
+	fifoPath := filepath.Join(tempDir, "dummy.png")
+	if err := syscall.Mkfifo(fifoPath, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	received := make(chan []byte)
+	go func() {
+		b, err := os.ReadFile(fifoPath) // blocks until the download opens the pipe for writing
+		assert.NoError(t, err)
+		received <- b
+	}()
+
+	downloadService := download.NewService(download.Options{
+		Connections:  8,
+		Timeout:      3,
+		CheckETag:    true,
+		Quiet:        true,
+		DestFilePath: fifoPath,
+	}, download.GetMD5Hash)
+
+	err := downloadService.Download([]string{srv.URL + "/dummy.png"})
+	assert.NoError(t, err)
+	assert.Equal(t, content, <-received)
+
+	entries, err := os.ReadDir(tempDir)
+	assert.NoError(t, err)
+	assert.Len(t, entries, 1) // only the pipe itself
+}
+
+func Test_Service_Download_NamedPipe_TeeWriter(t *testing.T) {
+	content := readFixture(t, "dummy.png")
+	srv := newTestServer(t, serveContentWithETag("dummy.png", content))
+
+	fifoPath := filepath.Join(t.TempDir(), "dummy.png")
+	if err := syscall.Mkfifo(fifoPath, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	received := make(chan []byte)
+	go func() {
+		b, err := os.ReadFile(fifoPath)
+		assert.NoError(t, err)
+		received <- b
+	}()
+
+	var tee bytes.Buffer
+	downloadService := download.NewService(download.Options{
+		Connections:  8,
+		Timeout:      3,
+		CheckETag:    true,
+		Quiet:        true,
+		DestFilePath: fifoPath,
+		TeeWriter:    &tee,
+	}, download.GetMD5Hash)
+
+	err := downloadService.Download([]string{srv.URL + "/dummy.png"})
+	assert.NoError(t, err)
+	assert.Equal(t, content, <-received)
+	assert.Equal(t, content, tee.Bytes())
+}
+
+func Test_Service_Download_NamedPipe_ETagAlgorithm(t *testing.T) {
+	content := readFixture(t, "dummy.png")
+	srv := newTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", fmt.Sprintf(`"%x"`, sha256.Sum256(content)))
+		serveContent("dummy.png", content)(w, r)
+	}))
+
+	fifoPath := filepath.Join(t.TempDir(), "dummy.png")
+	if err := syscall.Mkfifo(fifoPath, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	received := make(chan []byte)
+	go func() {
+		b, err := os.ReadFile(fifoPath)
+		assert.NoError(t, err)
+		received <- b
+	}()
+
+	downloadService := download.NewService(download.Options{
+		Connections:  8,
+		Timeout:      3,
+		CheckETag:    true,
+		Quiet:        true,
+		DestFilePath: fifoPath,
+	}, download.GetSHA256Hash)
+
+	err := downloadService.Download([]string{srv.URL + "/dummy.png"})
+	assert.NoError(t, err)
+	assert.Equal(t, content, <-received)
+}
+
+func Test_Service_Download_NamedPipe_UnsupportedOptions(t *testing.T) {
+	srv := download.NewTestServer(t, readFixture(t, "dummy.txt"))
+
+	testCases := map[string]struct {
+		opts          download.Options
+		calculateETag download.ETagCalculator
+	}{
+		"custom ETag calculator": {
+			opts: download.Options{CheckETag: true},
+			calculateETag: func(r io.ReadSeeker) (string, error) {
+				return download.GetSHA256Hash(r)
+			},
+		},
+		"piece hashes": {
+			opts: download.Options{PieceSize: 4, PieceHashes: []string{"abc"}},
+		},
+		"progress callback": {
+			opts: download.Options{OnProgress: func(int, string, int64, int64) {}},
+		},
+		"chunk start callback": {
+			opts: download.Options{OnChunkStart: func(int, string, int64, int64) {}},
+		},
+	}
+
+	for scenario, tc := range testCases {
+		t.Run(scenario, func(t *testing.T) {
+			fifoPath := filepath.Join(t.TempDir(), "dummy.txt")
+			if err := syscall.Mkfifo(fifoPath, 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			tc.opts.Timeout = 3
+			tc.opts.Quiet = true
+			tc.opts.DestFilePath = fifoPath
+			calculateETag := tc.calculateETag
+			if calculateETag == nil {
+				calculateETag = download.GetMD5Hash
+			}
+			downloadService := download.NewService(tc.opts, calculateETag)
+
+			// fails before opening the pipe, which would block without a reader
+			err := downloadService.Download([]string{srv.URL + "/dummy.txt"})
+			assert.ErrorIs(t, err, download.ErrNamedPipeUnsupported)
+		})
+	}
+}
+
+func Test_Service_Download_SocketPriority(t *testing.T) {
+	content := readFixture(t, "dummy.txt")
+	srv := download.NewTestServer(t, content)
+
+	destFilePath := filepath.Join(t.TempDir(), "dummy.txt")
+	downloadService := download.NewService(download.Options{
+		Connections:    2,
+		Timeout:        3,
+		Quiet:          true,
+		DestFilePath:   destFilePath,
+		SocketPriority: 4, // unlike SO_MARK, no capability is needed for priorities up to 6
+	}, download.GetMD5Hash)
+
+	assert.NoError(t, downloadService.Download([]string{srv.FileURL()}))
+
+	downloaded, err := os.ReadFile(destFilePath)
+	assert.NoError(t, err)
+	assert.Equal(t, content, downloaded)
+}
@@ -1,24 +1,94 @@
 package download_test
 
 import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 
+	"github.com/gkatanacio/multisource-downloader/cache"
 	"github.com/gkatanacio/multisource-downloader/download"
+	"github.com/gkatanacio/multisource-downloader/torrent"
 )
 
-// Test servers are expected to be running and serving the files
-// from the test fixtures directory (i.e, `testdata/`).
-const (
-	testServer1 = "http://test-server-1:8080"
-	testServer2 = "http://test-server-2:8080"
-)
+// newTestServer starts an httptest server with the given handler which is
+// automatically closed when the test finishes.
+func newTestServer(t *testing.T, handler http.Handler) *httptest.Server {
+	t.Helper()
+
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+
+	return srv
+}
+
+// serveContent returns a handler serving the content (with range request support)
+// as if it were the given file.
+func serveContent(fileName string, content []byte) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, fileName, time.Time{}, bytes.NewReader(content))
+	}
+}
+
+// serveContentWithETag is the same as serveContent but also sets the ETag header
+// to the MD5 hash of the content.
+func serveContentWithETag(fileName string, content []byte) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", fmt.Sprintf(`"%x"`, md5.Sum(content)))
+		serveContent(fileName, content)(w, r)
+	}
+}
+
+// writeTempFile writes the content to a new file inside a temporary directory and returns its path.
+func writeTempFile(t *testing.T, fileName string, content []byte) string {
+	t.Helper()
+
+	filePath := filepath.Join(t.TempDir(), fileName)
+	if err := os.WriteFile(filePath, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	return filePath
+}
+
+// readFixture returns the contents of the given file from the test fixtures directory.
+func readFixture(t *testing.T, fileName string) []byte {
+	t.Helper()
+
+	content, err := os.ReadFile(filepath.Join("..", "testdata", fileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return content
+}
 
 func Test_Service_Download_Success(t *testing.T) {
+	content := readFixture(t, "dummy.txt")
+	testServer1 := download.NewTestServer(t, content)
+	testServer2 := download.NewTestServer(t, content)
+
 	testCases := map[string]struct {
 		opts       download.Options
 		sourceUrls []string
@@ -30,7 +100,7 @@ func Test_Service_Download_Success(t *testing.T) {
 				DestFilePath: "single_src_single_conn.txt",
 			},
 			sourceUrls: []string{
-				fmt.Sprintf("%s/dummy.txt", testServer1),
+				testServer1.FileURL(),
 			},
 		},
 		"single source, multiple connections": {
@@ -40,7 +110,7 @@ func Test_Service_Download_Success(t *testing.T) {
 				DestFilePath: "single_src_multi_conn.txt",
 			},
 			sourceUrls: []string{
-				fmt.Sprintf("%s/dummy.txt", testServer1),
+				testServer1.FileURL(),
 			},
 		},
 		"multiple sources": {
@@ -50,8 +120,8 @@ func Test_Service_Download_Success(t *testing.T) {
 				DestFilePath: "multi_src.txt",
 			},
 			sourceUrls: []string{
-				fmt.Sprintf("%s/dummy.txt", testServer1),
-				fmt.Sprintf("%s/dummy.txt", testServer2),
+				testServer1.FileURL(),
+				testServer2.FileURL(),
 			},
 		},
 		"connections < sources": {
@@ -61,8 +131,8 @@ func Test_Service_Download_Success(t *testing.T) {
 				DestFilePath: "conn_less_src.txt",
 			},
 			sourceUrls: []string{
-				fmt.Sprintf("%s/dummy.txt", testServer1),
-				fmt.Sprintf("%s/dummy.txt", testServer2),
+				testServer1.FileURL(),
+				testServer2.FileURL(),
 			},
 		},
 	}
@@ -86,6 +156,9 @@ func Test_Service_Download_Success(t *testing.T) {
 }
 
 func Test_Service_Download_Failed(t *testing.T) {
+	txtServer := download.NewTestServer(t, readFixture(t, "dummy.txt"))
+	pngServer := download.NewTestServer(t, readFixture(t, "dummy.png"))
+
 	testCases := map[string]struct {
 		opts        download.Options
 		sourceUrls  []string
@@ -97,8 +170,8 @@ func Test_Service_Download_Failed(t *testing.T) {
 		},
 		"mismatched file from sources": {
 			sourceUrls: []string{
-				fmt.Sprintf("%s/dummy.txt", testServer1),
-				fmt.Sprintf("%s/dummy.png", testServer2),
+				txtServer.FileURL(),
+				pngServer.FileURL(),
 			},
 			specificErr: download.ErrSourcesFileMismatch,
 		},
@@ -120,3 +193,2889 @@ func Test_Service_Download_Failed(t *testing.T) {
 		})
 	}
 }
+
+func Test_Service_Download_LooseContentTypeMatch(t *testing.T) {
+	content := readFixture(t, "dummy.txt")
+
+	serveContentType := func(contentType string) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", contentType)
+			serveContent("dummy.txt", content)(w, r)
+		})
+	}
+
+	testCases := map[string]struct {
+		loose       bool
+		specificErr error
+	}{
+		"strict by default": {
+			specificErr: download.ErrSourcesFileMismatch,
+		},
+		"media type only": {
+			loose: true,
+		},
+	}
+
+	for scenario, tc := range testCases {
+		t.Run(scenario, func(t *testing.T) {
+			srv1 := newTestServer(t, serveContentType("text/plain; charset=utf-8"))
+			srv2 := newTestServer(t, serveContentType("text/plain"))
+
+			downloadService := download.NewService(download.Options{
+				Connections:           2,
+				Timeout:               3,
+				Quiet:                 true,
+				DestFilePath:          filepath.Join(t.TempDir(), "dummy.txt"),
+				LooseContentTypeMatch: tc.loose,
+			}, download.GetMD5Hash)
+
+			err := downloadService.Download([]string{srv1.URL + "/dummy.txt", srv2.URL + "/dummy.txt"})
+			if tc.specificErr != nil {
+				assert.ErrorIs(t, err, tc.specificErr)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func Test_Service_Download_ContentRangeMismatchRetried(t *testing.T) {
+	content := readFixture(t, "dummy.txt")
+
+	var badRangeRequests atomic.Int32
+	badSrv := newTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			badRangeRequests.Add(1)
+			w.Header().Set("Content-Range", fmt.Sprintf("bytes 1-2/%d", len(content)))
+			w.WriteHeader(http.StatusPartialContent)
+			w.Write(content[1:3])
+			return
+		}
+		serveContent("dummy.txt", content)(w, r)
+	}))
+	goodSrv := newTestServer(t, serveContent("dummy.txt", content))
+
+	destFilePath := filepath.Join(t.TempDir(), "content_range_mismatch.txt")
+	downloadService := download.NewService(download.Options{
+		Connections:  4,
+		Timeout:      3,
+		Quiet:        true,
+		DestFilePath: destFilePath,
+	}, download.GetMD5Hash)
+
+	err := downloadService.Download([]string{badSrv.URL + "/dummy.txt", goodSrv.URL + "/dummy.txt"})
+	assert.NoError(t, err)
+	assert.NotZero(t, badRangeRequests.Load())
+
+	downloaded, err := os.ReadFile(destFilePath)
+	assert.NoError(t, err)
+	assert.Equal(t, content, downloaded)
+}
+
+func Test_Service_Verify(t *testing.T) {
+	content := readFixture(t, "dummy.txt")
+	corrupted := bytes.Clone(content)
+	corrupted[0]++
+
+	testCases := map[string]struct {
+		localContent []byte
+		handlers     []http.Handler
+		specificErr  error
+	}{
+		"matching": {
+			localContent: content,
+			handlers: []http.Handler{
+				serveContentWithETag("dummy.txt", content),
+				serveContentWithETag("dummy.txt", content),
+			},
+		},
+		"local file corrupted": {
+			localContent: corrupted,
+			handlers: []http.Handler{
+				serveContentWithETag("dummy.txt", content),
+				serveContentWithETag("dummy.txt", content),
+			},
+			specificErr: download.ErrETagMismatch,
+		},
+		"sources disagree": {
+			localContent: content,
+			handlers: []http.Handler{
+				serveContentWithETag("dummy.txt", content),
+				serveContentWithETag("dummy.txt", corrupted),
+			},
+			specificErr: download.ErrSourcesFileMismatch,
+		},
+		"no ETag from sources": {
+			localContent: content,
+			handlers: []http.Handler{
+				serveContent("dummy.txt", content),
+			},
+			specificErr: download.ErrETagUnavailable,
+		},
+	}
+
+	for scenario, tc := range testCases {
+		t.Run(scenario, func(t *testing.T) {
+			var sourceUrls []string
+			for _, handler := range tc.handlers {
+				sourceUrls = append(sourceUrls, newTestServer(t, handler).URL+"/dummy.txt")
+			}
+
+			filePath := writeTempFile(t, "dummy.txt", tc.localContent)
+			downloadService := download.NewService(download.Options{Timeout: 3, Quiet: true}, download.GetMD5Hash)
+
+			err := downloadService.Verify(context.Background(), filePath, sourceUrls)
+
+			if tc.specificErr != nil {
+				assert.ErrorIs(t, err, tc.specificErr)
+				return
+			}
+
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func Test_Service_Download_RangeHeader(t *testing.T) {
+	content := []byte("0123456789")
+
+	testCases := map[string]struct {
+		rangeStyle     download.RangeStyle
+		expectedRanges []string
+	}{
+		"inclusive": {
+			rangeStyle:     download.RangeStyleInclusive,
+			expectedRanges: []string{"bytes=0-4", "bytes=5-9"},
+		},
+		"exclusive": {
+			rangeStyle:     download.RangeStyleExclusive,
+			expectedRanges: []string{"bytes=0-5", "bytes=5-10"},
+		},
+	}
+
+	for scenario, tc := range testCases {
+		t.Run(scenario, func(t *testing.T) {
+			var mu sync.Mutex
+			var receivedRanges []string
+
+			srv := newTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method != http.MethodGet {
+					serveContent("digits.txt", content)(w, r)
+					return
+				}
+
+				rangeHeader := r.Header.Get("Range")
+				mu.Lock()
+				receivedRanges = append(receivedRanges, rangeHeader)
+				mu.Unlock()
+
+				start, end, err := download.ParseRangeHeader(rangeHeader)
+				if err != nil {
+					w.WriteHeader(http.StatusBadRequest)
+					return
+				}
+
+				bodyEnd := end + 1
+				if tc.rangeStyle == download.RangeStyleExclusive {
+					bodyEnd = end
+				}
+
+				w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end, len(content)))
+				w.WriteHeader(http.StatusPartialContent)
+				w.Write(content[start:bodyEnd])
+			}))
+
+			destFilePath := filepath.Join(t.TempDir(), "digits.txt")
+			downloadService := download.NewService(download.Options{
+				Connections:  2,
+				Timeout:      3,
+				Quiet:        true,
+				DestFilePath: destFilePath,
+				RangeStyle:   tc.rangeStyle,
+			}, download.GetMD5Hash)
+
+			err := downloadService.Download([]string{srv.URL + "/digits.txt"})
+			assert.NoError(t, err)
+
+			sort.Strings(receivedRanges)
+			assert.Equal(t, tc.expectedRanges, receivedRanges)
+
+			downloaded, err := os.ReadFile(destFilePath)
+			assert.NoError(t, err)
+			assert.Equal(t, content, downloaded)
+		})
+	}
+}
+
+func Test_Service_Download_AllowPartialSources(t *testing.T) {
+	content := readFixture(t, "dummy.txt")
+
+	testCases := map[string]struct {
+		allowPartialSources bool
+		expectSuccess       bool
+	}{
+		"fail fast by default": {
+			allowPartialSources: false,
+			expectSuccess:       false,
+		},
+		"partial sources allowed": {
+			allowPartialSources: true,
+			expectSuccess:       true,
+		},
+	}
+
+	for scenario, tc := range testCases {
+		t.Run(scenario, func(t *testing.T) {
+			liveSrv := newTestServer(t, serveContent("dummy.txt", content))
+			deadSrv := httptest.NewServer(serveContent("dummy.txt", content))
+			deadSrv.Close()
+
+			destFilePath := filepath.Join(t.TempDir(), "dummy.txt")
+			downloadService := download.NewService(download.Options{
+				Connections:         2,
+				Timeout:             3,
+				Quiet:               true,
+				DestFilePath:        destFilePath,
+				AllowPartialSources: tc.allowPartialSources,
+			}, download.GetMD5Hash)
+
+			err := downloadService.Download([]string{deadSrv.URL + "/dummy.txt", liveSrv.URL + "/dummy.txt"})
+
+			if !tc.expectSuccess {
+				assert.Error(t, err)
+				assert.NoFileExists(t, destFilePath)
+				return
+			}
+
+			assert.NoError(t, err)
+
+			downloaded, err := os.ReadFile(destFilePath)
+			assert.NoError(t, err)
+			assert.Equal(t, content, downloaded)
+		})
+	}
+}
+
+func Test_Service_Download_NoHealthySources(t *testing.T) {
+	deadSrv := httptest.NewServer(http.NotFoundHandler())
+	deadSrv.Close()
+
+	downloadService := download.NewService(download.Options{Timeout: 3, Quiet: true, AllowPartialSources: true}, nil)
+
+	err := downloadService.Download([]string{deadSrv.URL + "/dummy.txt"})
+	assert.ErrorIs(t, err, download.ErrNoSourceUrls)
+}
+
+func Test_Service_Download_WriteHashFile(t *testing.T) {
+	content := readFixture(t, "dummy.txt")
+
+	testCases := map[string]struct {
+		algorithm        string
+		expectedFileExt  string
+		expectedChecksum string
+	}{
+		"default algorithm": {
+			expectedFileExt:  ".sha256",
+			expectedChecksum: fmt.Sprintf("%x", sha256.Sum256(content)),
+		},
+		"md5": {
+			algorithm:        "md5",
+			expectedFileExt:  ".md5",
+			expectedChecksum: fmt.Sprintf("%x", md5.Sum(content)),
+		},
+	}
+
+	for scenario, tc := range testCases {
+		t.Run(scenario, func(t *testing.T) {
+			srv := newTestServer(t, serveContent("dummy.txt", content))
+
+			destFilePath := filepath.Join(t.TempDir(), "dummy.txt")
+			downloadService := download.NewService(download.Options{
+				Connections:       2,
+				Timeout:           3,
+				Quiet:             true,
+				DestFilePath:      destFilePath,
+				WriteHashFile:     true,
+				HashFileAlgorithm: tc.algorithm,
+			}, download.GetMD5Hash)
+
+			err := downloadService.Download([]string{srv.URL + "/dummy.txt"})
+			assert.NoError(t, err)
+
+			hashFileContents, err := os.ReadFile(destFilePath + tc.expectedFileExt)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectedChecksum+"  dummy.txt\n", string(hashFileContents))
+
+			destFileInfo, err := os.Stat(destFilePath)
+			assert.NoError(t, err)
+			hashFileInfo, err := os.Stat(destFilePath + tc.expectedFileExt)
+			assert.NoError(t, err)
+			assert.Equal(t, destFileInfo.Mode().Perm(), hashFileInfo.Mode().Perm())
+		})
+	}
+}
+
+func Test_Service_Download_WriteHashFileUnsupportedAlgorithm(t *testing.T) {
+	downloadService := download.NewService(download.Options{
+		Timeout:           3,
+		Quiet:             true,
+		DestFilePath:      filepath.Join(t.TempDir(), "dummy.txt"),
+		WriteHashFile:     true,
+		HashFileAlgorithm: "crc32",
+	}, download.GetMD5Hash)
+
+	err := downloadService.Download([]string{"http://source1.com/dummy.txt"})
+	assert.ErrorIs(t, err, download.ErrUnsupportedHashAlgorithm)
+}
+
+func Test_Service_Download_AppendMode(t *testing.T) {
+	content := readFixture(t, "dummy.txt")
+
+	testCases := map[string]struct {
+		existingContent []byte
+	}{
+		"existing destination file": {
+			existingContent: []byte("existing content\n"),
+		},
+		"no destination file": {},
+	}
+
+	for scenario, tc := range testCases {
+		t.Run(scenario, func(t *testing.T) {
+			srv := newTestServer(t, serveContentWithETag("dummy.txt", content))
+
+			destFilePath := filepath.Join(t.TempDir(), "appended.txt")
+			if tc.existingContent != nil {
+				if err := os.WriteFile(destFilePath, tc.existingContent, 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			downloadService := download.NewService(download.Options{
+				Connections:  3,
+				Timeout:      3,
+				CheckETag:    true,
+				Quiet:        true,
+				DestFilePath: destFilePath,
+				AppendMode:   true,
+			}, download.GetMD5Hash)
+
+			err := downloadService.Download([]string{srv.URL + "/dummy.txt"})
+			assert.NoError(t, err)
+
+			downloaded, err := os.ReadFile(destFilePath)
+			assert.NoError(t, err)
+			assert.Equal(t, append(bytes.Clone(tc.existingContent), content...), downloaded)
+
+			leftovers, err := filepath.Glob(destFilePath + ".download*")
+			assert.NoError(t, err)
+			assert.Empty(t, leftovers)
+		})
+	}
+}
+
+func Test_Service_Download_Resume(t *testing.T) {
+	content := readFixture(t, "dummy.txt")
+	chunkSize := int64(len(content) / 4)
+
+	var mu sync.Mutex
+	var receivedRanges []string
+	srv := newTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			mu.Lock()
+			receivedRanges = append(receivedRanges, r.Header.Get("Range"))
+			mu.Unlock()
+		}
+		serveContentWithETag("dummy.txt", content)(w, r)
+	}))
+
+	// simulate an interrupted download where only the first 2 chunks were completed
+	destFilePath := filepath.Join(t.TempDir(), "dummy.txt")
+	partial := make([]byte, len(content))
+	copy(partial, content[:2*chunkSize])
+	if err := os.WriteFile(destFilePath+".download", partial, 0644); err != nil {
+		t.Fatal(err)
+	}
+	state := fmt.Sprintf(`{"size":%d,"etag":"%x","chunk_size":%d,"completed_chunks":[1,0]}`, len(content), md5.Sum(content), chunkSize)
+	if err := os.WriteFile(destFilePath+".download.state", []byte(state), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	downloadService := download.NewService(download.Options{
+		Connections:  8, // different from the interrupted download to ensure chunk size is taken from state
+		Timeout:      3,
+		CheckETag:    true,
+		Quiet:        true,
+		DestFilePath: destFilePath,
+		Resume:       true,
+	}, download.GetMD5Hash)
+
+	err := downloadService.Download([]string{srv.URL + "/dummy.txt"})
+	assert.NoError(t, err)
+
+	sort.Strings(receivedRanges)
+	assert.Equal(t, []string{
+		fmt.Sprintf("bytes=%d-%d", 2*chunkSize, 3*chunkSize-1),
+		fmt.Sprintf("bytes=%d-%d", 3*chunkSize, 4*chunkSize-1),
+		fmt.Sprintf("bytes=%d-%d", 4*chunkSize, len(content)-1),
+	}, receivedRanges)
+
+	downloaded, err := os.ReadFile(destFilePath)
+	assert.NoError(t, err)
+	assert.Equal(t, content, downloaded)
+	assert.NoFileExists(t, destFilePath+".download.state")
+}
+
+func Test_Service_Download_ResumeResourceChanged(t *testing.T) {
+	oldContent := readFixture(t, "dummy.txt")
+	newContent := bytes.ToUpper(oldContent) // same size so that only the ETag differs
+	chunkSize := int64(len(oldContent) / 4)
+
+	testCases := map[string]struct {
+		failRestart bool
+	}{
+		"restarted": {},
+		"restart failed": {
+			failRestart: true,
+		},
+	}
+
+	for scenario, tc := range testCases {
+		t.Run(scenario, func(t *testing.T) {
+			var mu sync.Mutex
+			var ifRanges []string
+			var changeDetected atomic.Bool
+			srv := newTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method == http.MethodHead {
+					if tc.failRestart && changeDetected.Load() {
+						w.WriteHeader(http.StatusServiceUnavailable)
+						return
+					}
+					// simulate an outdated cache in front of the source only for the metadata
+					serveContentWithETag("dummy.txt", oldContent)(w, r)
+					return
+				}
+
+				mu.Lock()
+				ifRanges = append(ifRanges, r.Header.Get("If-Range"))
+				mu.Unlock()
+				if len(r.Header.Get("If-Range")) > 0 {
+					changeDetected.Store(true)
+				}
+				serveContentWithETag("dummy.txt", newContent)(w, r)
+			}))
+
+			// simulate an interrupted download of the old file where only the first 2 chunks were completed
+			destFilePath := filepath.Join(t.TempDir(), "dummy.txt")
+			partial := make([]byte, len(oldContent))
+			copy(partial, oldContent[:2*chunkSize])
+			if err := os.WriteFile(destFilePath+".download", partial, 0644); err != nil {
+				t.Fatal(err)
+			}
+			state := fmt.Sprintf(`{"size":%d,"etag":"%x","chunk_size":%d,"completed_chunks":[1,0]}`, len(oldContent), md5.Sum(oldContent), chunkSize)
+			if err := os.WriteFile(destFilePath+".download.state", []byte(state), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			downloadService := download.NewService(download.Options{
+				Connections:  4,
+				Timeout:      3,
+				Quiet:        true,
+				DestFilePath: destFilePath,
+				Resume:       true,
+			}, download.GetMD5Hash)
+
+			err := downloadService.Download([]string{srv.URL + "/dummy.txt"})
+
+			assert.Contains(t, ifRanges, fmt.Sprintf(`"%x"`, md5.Sum(oldContent)))
+			assert.NoFileExists(t, destFilePath+".download.state")
+
+			if tc.failRestart {
+				assert.ErrorIs(t, err, download.ErrResourceChangedDuringResume)
+				assert.NoFileExists(t, destFilePath)
+				assert.NoFileExists(t, destFilePath+".download")
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Contains(t, ifRanges, "", "the restarted download is not conditional")
+
+			downloaded, err := os.ReadFile(destFilePath)
+			assert.NoError(t, err)
+			assert.Equal(t, newContent, downloaded)
+		})
+	}
+}
+
+func Test_Service_Download_ResumeFailed(t *testing.T) {
+	content := readFixture(t, "dummy.txt")
+
+	testCases := map[string]struct {
+		state       string
+		specificErr error
+	}{
+		"no state file": {
+			specificErr: download.ErrNoDownloadState,
+		},
+		"file changed": {
+			state:       fmt.Sprintf(`{"size":%d,"etag":"outdated","chunk_size":100,"completed_chunks":[0]}`, len(content)),
+			specificErr: download.ErrDownloadStateMismatch,
+		},
+	}
+
+	for scenario, tc := range testCases {
+		t.Run(scenario, func(t *testing.T) {
+			srv := newTestServer(t, serveContentWithETag("dummy.txt", content))
+
+			destFilePath := filepath.Join(t.TempDir(), "dummy.txt")
+			if len(tc.state) > 0 {
+				if err := os.WriteFile(destFilePath+".download.state", []byte(tc.state), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			downloadService := download.NewService(download.Options{
+				Connections:  2,
+				Timeout:      3,
+				Quiet:        true,
+				DestFilePath: destFilePath,
+				Resume:       true,
+			}, download.GetMD5Hash)
+
+			err := downloadService.Download([]string{srv.URL + "/dummy.txt"})
+			assert.ErrorIs(t, err, tc.specificErr)
+			assert.NoFileExists(t, destFilePath)
+		})
+	}
+}
+
+func Test_Service_Download_ChunkError(t *testing.T) {
+	content := readFixture(t, "dummy.txt")
+
+	failingHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		serveContent("dummy.txt", content)(w, r)
+	})
+	srv1 := newTestServer(t, failingHandler)
+	srv2 := newTestServer(t, failingHandler)
+
+	downloadService := download.NewService(download.Options{
+		Connections:  1,
+		Timeout:      3,
+		Quiet:        true,
+		DestFilePath: filepath.Join(t.TempDir(), "dummy.txt"),
+	}, download.GetMD5Hash)
+
+	err := downloadService.Download([]string{srv1.URL + "/dummy.txt", srv2.URL + "/dummy.txt"})
+	assert.ErrorIs(t, err, download.ErrFailedChunkDownloadAllSources)
+
+	var chunkErr download.ChunkError
+	if assert.True(t, errors.As(err, &chunkErr)) {
+		assert.Equal(t, 0, chunkErr.ChunkIndex)
+		assert.Equal(t, int64(0), chunkErr.Offset)
+		assert.Equal(t, int64(len(content)), chunkErr.Size)
+
+		var triedUrls []string
+		for i, sa := range chunkErr.TriedSources {
+			assert.Equal(t, i+1, sa.Attempt)
+			assert.ErrorContains(t, sa.Err, "received 500 response")
+			triedUrls = append(triedUrls, sa.URL)
+		}
+		assert.ElementsMatch(t, []string{srv1.URL + "/dummy.txt", srv2.URL + "/dummy.txt"}, triedUrls)
+	}
+}
+
+func Test_Service_Download_HonourRetryAfter(t *testing.T) {
+	content := readFixture(t, "dummy.txt")
+
+	var getRequests atomic.Int32
+	srv := newTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet && getRequests.Add(1) == 1 {
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		serveContent("dummy.txt", content)(w, r)
+	}))
+
+	destFilePath := filepath.Join(t.TempDir(), "dummy.txt")
+	downloadService := download.NewService(download.Options{
+		Connections:      1,
+		Timeout:          3,
+		Quiet:            true,
+		DestFilePath:     destFilePath,
+		HonourRetryAfter: true,
+	}, download.GetMD5Hash)
+
+	start := time.Now()
+	err := downloadService.Download([]string{srv.URL + "/dummy.txt"})
+	elapsed := time.Since(start)
+
+	assert.NoError(t, err)
+	assert.Equal(t, int32(2), getRequests.Load(), "should be retried from the same source")
+	assert.GreaterOrEqual(t, elapsed, time.Second)
+	assert.Less(t, elapsed, 2*time.Second)
+
+	downloaded, err := os.ReadFile(destFilePath)
+	assert.NoError(t, err)
+	assert.Equal(t, content, downloaded)
+}
+
+func Test_Service_Download_PreallocateFile(t *testing.T) {
+	content := readFixture(t, "dummy.png")
+	srv := newTestServer(t, serveContent("dummy.png", content))
+
+	destFilePath := filepath.Join(t.TempDir(), "dummy.png")
+	downloadService := download.NewService(download.Options{
+		Connections:     4,
+		Timeout:         3,
+		Quiet:           true,
+		DestFilePath:    destFilePath,
+		PreallocateFile: true,
+	}, download.GetMD5Hash)
+
+	err := downloadService.Download([]string{srv.URL + "/dummy.png"})
+	assert.NoError(t, err)
+
+	downloaded, err := os.ReadFile(destFilePath)
+	assert.NoError(t, err)
+	assert.Equal(t, content, downloaded)
+}
+
+func Test_Service_Download_WriteBufferSize(t *testing.T) {
+	content := readFixture(t, "dummy.txt")
+	srv := newTestServer(t, serveContent("dummy.txt", content))
+
+	testCases := map[string]struct {
+		writeBufferSize int
+	}{
+		"unbuffered": {
+			writeBufferSize: 0,
+		},
+		"buffer smaller than chunks": {
+			writeBufferSize: 7,
+		},
+		"buffer larger than chunks": {
+			writeBufferSize: 4096,
+		},
+	}
+
+	for scenario, tc := range testCases {
+		t.Run(scenario, func(t *testing.T) {
+			destFilePath := filepath.Join(t.TempDir(), "dummy.txt")
+			downloadService := download.NewService(download.Options{
+				Connections:     100,
+				Timeout:         3,
+				Quiet:           true,
+				DestFilePath:    destFilePath,
+				WriteBufferSize: tc.writeBufferSize,
+			}, download.GetMD5Hash)
+
+			err := downloadService.Download([]string{srv.URL + "/dummy.txt"})
+			assert.NoError(t, err)
+
+			downloaded, err := os.ReadFile(destFilePath)
+			assert.NoError(t, err)
+			assert.Equal(t, content, downloaded)
+		})
+	}
+}
+
+func Test_Service_Download_Manifest(t *testing.T) {
+	content := readFixture(t, "dummy.png")
+	srv1 := newTestServer(t, serveContent("dummy.png", content))
+	srv2 := newTestServer(t, serveContent("dummy.png", content))
+	sourceUrls := []string{srv1.URL + "/dummy.png", srv2.URL + "/dummy.png"}
+
+	tempDir := t.TempDir()
+	destFilePath := filepath.Join(tempDir, "dummy.png")
+	manifestPath := filepath.Join(tempDir, "manifest.json")
+	downloadService := download.NewService(download.Options{
+		Connections:  4,
+		Timeout:      3,
+		Quiet:        true,
+		DestFilePath: destFilePath,
+		ManifestPath: manifestPath,
+	}, download.GetMD5Hash)
+
+	startedAt := time.Now()
+	err := downloadService.Download(sourceUrls)
+	assert.NoError(t, err)
+
+	b, err := os.ReadFile(manifestPath)
+	assert.NoError(t, err)
+
+	var manifest download.Manifest
+	assert.NoError(t, json.Unmarshal(b, &manifest))
+
+	info, err := os.Stat(destFilePath)
+	assert.NoError(t, err)
+
+	expectedMD5, _ := download.GetMD5Hash(bytes.NewReader(content))
+	expectedSHA256, _ := download.GetSHA256Hash(bytes.NewReader(content))
+
+	assert.WithinDuration(t, startedAt, manifest.Timestamp, time.Second)
+	assert.Equal(t, destFilePath, manifest.DestFile)
+	assert.Equal(t, info.Size(), manifest.TotalBytes)
+	assert.Equal(t, expectedMD5, manifest.HashMD5)
+	assert.Equal(t, expectedSHA256, manifest.HashSHA256)
+	assert.GreaterOrEqual(t, manifest.DurationMs, int64(0))
+
+	assert.Len(t, manifest.Sources, len(sourceUrls))
+	var totalBytes int64
+	for i, source := range manifest.Sources {
+		assert.Equal(t, sourceUrls[i], source.URL)
+		assert.Greater(t, source.ChunksDownloaded, 0)
+		assert.Greater(t, source.BytesDownloaded, int64(0))
+		assert.Greater(t, source.AvgLatencyMs, float64(0))
+		totalBytes += source.BytesDownloaded
+	}
+	assert.Equal(t, manifest.TotalBytes, totalBytes)
+
+	_, err = os.Stat(manifestPath + ".tmp")
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func Test_Service_Download_IfNoneMatch(t *testing.T) {
+	content := readFixture(t, "dummy.txt")
+	eTag := fmt.Sprintf("%x", md5.Sum(content))
+
+	var receivedIfNoneMatch atomic.Value
+	srv := newTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead {
+			receivedIfNoneMatch.Store(r.Header.Get("If-None-Match"))
+		}
+		serveContentWithETag("dummy.txt", content)(w, r)
+	}))
+
+	testCases := map[string]struct {
+		ifNoneMatch   string
+		expectedErr   error
+		expectCreated bool
+	}{
+		"unchanged file": {
+			ifNoneMatch: eTag,
+			expectedErr: download.ErrNotModified,
+		},
+		"changed file": {
+			ifNoneMatch:   "outdated-etag",
+			expectCreated: true,
+		},
+	}
+
+	for scenario, tc := range testCases {
+		t.Run(scenario, func(t *testing.T) {
+			tempDir := t.TempDir()
+			destFilePath := filepath.Join(tempDir, "dummy.txt")
+			downloadService := download.NewService(download.Options{
+				Connections:  2,
+				Timeout:      3,
+				Quiet:        true,
+				DestFilePath: destFilePath,
+				IfNoneMatch:  tc.ifNoneMatch,
+			}, download.GetMD5Hash)
+
+			err := downloadService.Download([]string{srv.URL + "/dummy.txt"})
+			assert.ErrorIs(t, err, tc.expectedErr)
+			assert.Equal(t, `"`+tc.ifNoneMatch+`"`, receivedIfNoneMatch.Load())
+
+			entries, err := os.ReadDir(tempDir)
+			assert.NoError(t, err)
+			if tc.expectCreated {
+				assert.Len(t, entries, 1)
+			} else {
+				assert.Empty(t, entries)
+			}
+		})
+	}
+}
+
+// orderCheckingWriter records the written bytes and fails if written while another write is ongoing.
+type orderCheckingWriter struct {
+	buf     bytes.Buffer
+	writing atomic.Bool
+}
+
+func (ocw *orderCheckingWriter) Write(p []byte) (int, error) {
+	if !ocw.writing.CompareAndSwap(false, true) {
+		return 0, errors.New("concurrent write")
+	}
+	defer ocw.writing.Store(false)
+
+	return ocw.buf.Write(p)
+}
+
+func Test_Service_Download_TeeWriter(t *testing.T) {
+	content := readFixture(t, "dummy.png")
+
+	// delay the earlier chunks so that chunks complete out of order
+	srv := newTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if start, _, err := download.ParseRangeHeader(r.Header.Get("Range")); err == nil {
+			time.Sleep(time.Duration(int64(len(content))-start) * 50 * time.Millisecond / time.Duration(len(content)))
+		}
+		serveContent("dummy.png", content)(w, r)
+	}))
+
+	destFilePath := filepath.Join(t.TempDir(), "dummy.png")
+	tee := &orderCheckingWriter{}
+	downloadService := download.NewService(download.Options{
+		Connections:  8,
+		Timeout:      3,
+		Quiet:        true,
+		DestFilePath: destFilePath,
+		TeeWriter:    tee,
+	}, download.GetMD5Hash)
+
+	err := downloadService.Download([]string{srv.URL + "/dummy.png"})
+	assert.NoError(t, err)
+
+	downloaded, err := os.ReadFile(destFilePath)
+	assert.NoError(t, err)
+	assert.Equal(t, downloaded, tee.buf.Bytes())
+	assert.Equal(t, content, tee.buf.Bytes())
+}
+
+func Test_Service_Download_RetryOnETagMismatch(t *testing.T) {
+	content := readFixture(t, "dummy.txt")
+	corrupted := bytes.Repeat([]byte("x"), len(content))
+
+	testCases := map[string]struct {
+		corruptedHeads int64
+		maxETagRetries uint
+		expectedErr    error
+		expectedHeads  int64
+	}{
+		"source refreshed on retry": {
+			corruptedHeads: 1,
+			maxETagRetries: 2,
+			expectedHeads:  2,
+		},
+		"retries exhausted": {
+			corruptedHeads: 10,
+			maxETagRetries: 2,
+			expectedErr:    download.ErrETagMismatch,
+			expectedHeads:  3,
+		},
+	}
+
+	for scenario, tc := range testCases {
+		t.Run(scenario, func(t *testing.T) {
+			// the ETag always matches the expected content but the served bytes are
+			// corrupted until the source has been probed enough times
+			var heads atomic.Int64
+			srv := newTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method == http.MethodHead {
+					heads.Add(1)
+				}
+
+				w.Header().Set("ETag", fmt.Sprintf(`"%x"`, md5.Sum(content)))
+				if heads.Load() <= tc.corruptedHeads {
+					serveContent("dummy.txt", corrupted)(w, r)
+				} else {
+					serveContent("dummy.txt", content)(w, r)
+				}
+			}))
+
+			tempDir := t.TempDir()
+			destFilePath := filepath.Join(tempDir, "dummy.txt")
+			downloadService := download.NewService(download.Options{
+				Connections:         2,
+				Timeout:             3,
+				Quiet:               true,
+				CheckETag:           true,
+				DestFilePath:        destFilePath,
+				RetryOnETagMismatch: true,
+				MaxETagRetries:      tc.maxETagRetries,
+			}, download.GetMD5Hash)
+
+			err := downloadService.Download([]string{srv.URL + "/dummy.txt"})
+			assert.ErrorIs(t, err, tc.expectedErr)
+			assert.Equal(t, tc.expectedHeads, heads.Load())
+
+			if tc.expectedErr != nil {
+				entries, err := os.ReadDir(tempDir)
+				assert.NoError(t, err)
+				assert.Empty(t, entries) // corrupted download is discarded
+				return
+			}
+
+			downloaded, err := os.ReadFile(destFilePath)
+			assert.NoError(t, err)
+			assert.Equal(t, content, downloaded)
+		})
+	}
+}
+
+func Test_Service_Download_NoClobber(t *testing.T) {
+	content := readFixture(t, "dummy.txt")
+
+	var requests atomic.Int64
+	srv := newTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		serveContent("dummy.txt", content)(w, r)
+	}))
+
+	existing := []byte("existing contents")
+	destFilePath := writeTempFile(t, "dummy.txt", existing)
+
+	downloadService := download.NewService(download.Options{
+		Connections:  2,
+		Timeout:      3,
+		Quiet:        true,
+		DestFilePath: destFilePath,
+		NoClobber:    true,
+	}, download.GetMD5Hash)
+
+	err := downloadService.Download([]string{srv.URL + "/dummy.txt"})
+	assert.ErrorIs(t, err, download.ErrFileAlreadyExists)
+	assert.Zero(t, requests.Load())
+
+	unchanged, err := os.ReadFile(destFilePath)
+	assert.NoError(t, err)
+	assert.Equal(t, existing, unchanged)
+
+	// proceeds normally if there is no existing file
+	assert.NoError(t, os.Remove(destFilePath))
+	err = downloadService.Download([]string{srv.URL + "/dummy.txt"})
+	assert.NoError(t, err)
+
+	downloaded, err := os.ReadFile(destFilePath)
+	assert.NoError(t, err)
+	assert.Equal(t, content, downloaded)
+	_, err = os.Stat(destFilePath + ".download")
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func Test_Service_Download_LastDownloadStats(t *testing.T) {
+	content := readFixture(t, "dummy.png")
+
+	// counts the ranged requests per server and fails the first one of the second server
+	var gets [2]atomic.Int64
+	newCountingServer := func(i int) *httptest.Server {
+		return newTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == http.MethodGet && gets[i].Add(1) == 1 && i == 1 {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			serveContent("dummy.png", content)(w, r)
+		}))
+	}
+	sourceUrls := []string{newCountingServer(0).URL + "/dummy.png", newCountingServer(1).URL + "/dummy.png"}
+
+	downloadService := download.NewService(download.Options{
+		Connections:  4,
+		Timeout:      3,
+		Quiet:        true,
+		DestFilePath: filepath.Join(t.TempDir(), "dummy.png"),
+	}, download.GetMD5Hash)
+	assert.Nil(t, downloadService.LastDownloadStats())
+
+	err := downloadService.Download(sourceUrls)
+	assert.NoError(t, err)
+
+	stats := downloadService.LastDownloadStats()
+	assert.Len(t, stats.Sources, 2)
+
+	var totalBytes int64
+	for i, ss := range stats.Sources {
+		assert.Equal(t, sourceUrls[i], ss.URL)
+		assert.Greater(t, ss.ChunksDelivered, 0)
+		assert.Greater(t, ss.AvgChunkDurationMs, float64(0))
+		assert.Equal(t, int(gets[i].Load()), ss.ChunksDelivered+ss.Errors)
+		totalBytes += ss.BytesDelivered
+	}
+	assert.Equal(t, 0, stats.Sources[0].Errors)
+	assert.Equal(t, 1, stats.Sources[1].Errors)
+	assert.Equal(t, int64(len(content)), totalBytes)
+}
+
+func Test_Service_Download_StreamingVerification(t *testing.T) {
+	content := readFixture(t, "dummy.png")
+	srv := newTestServer(t, serveContentWithETag("dummy.png", content))
+
+	corrupted := bytes.Clone(content)
+	corrupted[len(corrupted)/2] ^= 0xff
+	corruptedSrv := newTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", fmt.Sprintf(`"%x"`, md5.Sum(content)))
+		serveContent("dummy.png", corrupted)(w, r)
+	}))
+
+	sha256Srv := newTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", fmt.Sprintf(`"%x"`, sha256.Sum256(content)))
+		serveContent("dummy.png", content)(w, r)
+	}))
+
+	testCases := map[string]struct {
+		sourceUrl     string
+		calculateETag download.ETagCalculator
+		expectedErr   error
+	}{
+		"matching ETag": {
+			sourceUrl:     srv.URL + "/dummy.png",
+			calculateETag: download.GetMD5Hash,
+		},
+		"mismatching ETag": {
+			sourceUrl:     corruptedSrv.URL + "/dummy.png",
+			calculateETag: download.GetMD5Hash,
+			expectedErr:   download.ErrETagMismatch,
+		},
+		"matching SHA-256 ETag": {
+			sourceUrl:     sha256Srv.URL + "/dummy.png",
+			calculateETag: download.GetSHA256Hash,
+		},
+		"custom ETag calculator": {
+			sourceUrl: sha256Srv.URL + "/dummy.png",
+			calculateETag: func(r io.ReadSeeker) (string, error) {
+				return download.GetSHA256Hash(r)
+			},
+		},
+	}
+
+	for scenario, tc := range testCases {
+		t.Run(scenario, func(t *testing.T) {
+			// both the streaming and the two-pass verification should reach the same result
+			for _, streaming := range []bool{false, true} {
+				downloadService := download.NewService(download.Options{
+					Connections:           8,
+					Timeout:               3,
+					Quiet:                 true,
+					CheckETag:             true,
+					DestFilePath:          filepath.Join(t.TempDir(), "dummy.png"),
+					StreamingVerification: streaming,
+				}, tc.calculateETag)
+
+				err := downloadService.Download([]string{tc.sourceUrl})
+				assert.ErrorIs(t, err, tc.expectedErr, "streaming: %v", streaming)
+			}
+		})
+	}
+}
+
+func Test_Service_Download_WriteWorkers(t *testing.T) {
+	content := readFixture(t, "dummy.png")
+	srv := newTestServer(t, serveContent("dummy.png", content))
+
+	destFilePath := filepath.Join(t.TempDir(), "dummy.png")
+	downloadService := download.NewService(download.Options{
+		Connections:     16,
+		Timeout:         3,
+		Quiet:           true,
+		DestFilePath:    destFilePath,
+		WriteWorkers:    2,
+		WriteQueueDepth: 4,
+	}, download.GetMD5Hash)
+
+	err := downloadService.Download([]string{srv.URL + "/dummy.png"})
+	assert.NoError(t, err)
+
+	downloaded, err := os.ReadFile(destFilePath)
+	assert.NoError(t, err)
+	assert.Equal(t, content, downloaded)
+}
+
+// slowWriter simulates a slow write phase (e.g., a tee to a slow network sink).
+type slowWriter struct {
+	delay time.Duration
+	buf   bytes.Buffer
+}
+
+func (sw *slowWriter) Write(p []byte) (int, error) {
+	time.Sleep(sw.delay)
+	return sw.buf.Write(p)
+}
+
+func Test_Service_Download_PipelineChunks(t *testing.T) {
+	content := readFixture(t, "dummy.txt")
+	const latency = 20 * time.Millisecond
+
+	srv := newTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			time.Sleep(latency)
+		}
+		serveContent("dummy.txt", content)(w, r)
+	}))
+
+	runDownload := func(pipelineChunks uint) time.Duration {
+		t.Helper()
+
+		tee := &slowWriter{delay: latency}
+		destFilePath := filepath.Join(t.TempDir(), "dummy.txt")
+		downloadService := download.NewService(download.Options{
+			Connections:    1,
+			Timeout:        3,
+			Quiet:          true,
+			DestFilePath:   destFilePath,
+			ChunkBytes:     400, // 8 chunks
+			TeeWriter:      tee,
+			PipelineChunks: pipelineChunks,
+		}, download.GetMD5Hash)
+
+		startedAt := time.Now()
+		err := downloadService.Download([]string{srv.URL + "/dummy.txt"})
+		elapsed := time.Since(startedAt)
+		assert.NoError(t, err)
+
+		downloaded, err := os.ReadFile(destFilePath)
+		assert.NoError(t, err)
+		assert.Equal(t, content, downloaded)
+		assert.Equal(t, content, tee.buf.Bytes())
+
+		return elapsed
+	}
+
+	sequential := runDownload(1)
+	pipelined := runDownload(2)
+
+	// fetching overlaps with writing instead of alternating (i.e., about half the time)
+	assert.Less(t, pipelined, sequential*4/5, "pipelined %v vs sequential %v", pipelined, sequential)
+}
+
+func Test_Service_Download_MinChunkBytes(t *testing.T) {
+	content := readFixture(t, "dummy.txt")[:100]
+
+	testCases := map[string]struct {
+		minChunkBytes   int64
+		expectedGetReqs int32
+	}{
+		"single request": {
+			minChunkBytes:   1024,
+			expectedGetReqs: 1,
+		},
+		"chunked": {
+			minChunkBytes:   100,
+			expectedGetReqs: 9, // chunks of 100/8 bytes
+		},
+	}
+
+	for scenario, tc := range testCases {
+		t.Run(scenario, func(t *testing.T) {
+			var getRequests atomic.Int32
+			srv := newTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method == http.MethodGet {
+					getRequests.Add(1)
+				}
+				serveContent("dummy.txt", content)(w, r)
+			}))
+
+			destFilePath := filepath.Join(t.TempDir(), "dummy.txt")
+			downloadService := download.NewService(download.Options{
+				Connections:   8,
+				Timeout:       3,
+				Quiet:         true,
+				DestFilePath:  destFilePath,
+				MinChunkBytes: tc.minChunkBytes,
+			}, download.GetMD5Hash)
+
+			err := downloadService.Download([]string{srv.URL + "/dummy.txt"})
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectedGetReqs, getRequests.Load())
+
+			downloaded, err := os.ReadFile(destFilePath)
+			assert.NoError(t, err)
+			assert.Equal(t, content, downloaded)
+		})
+	}
+}
+
+func Test_Service_Download_MaxMemoryUsageBytes(t *testing.T) {
+	content := readFixture(t, "dummy.txt")
+
+	testCases := map[string]struct {
+		opts         download.Options
+		maxChunkSize int64
+	}{
+		"file-size-based chunks": {
+			opts:         download.Options{Connections: 4, MaxMemoryUsageBytes: 1000},
+			maxChunkSize: 250,
+		},
+		"chunk bytes": {
+			opts:         download.Options{Connections: 8, ChunkBytes: 1000, MaxMemoryUsageBytes: 2500},
+			maxChunkSize: 1000,
+		},
+		"chunk bytes beyond limit": {
+			opts:         download.Options{Connections: 2, ChunkBytes: 4000, MaxMemoryUsageBytes: 1000},
+			maxChunkSize: 1000,
+		},
+	}
+
+	for scenario, tc := range testCases {
+		t.Run(scenario, func(t *testing.T) {
+			var mu sync.Mutex
+			var chunkSizes []int64
+			srv := newTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if start, end, err := download.ParseRangeHeader(r.Header.Get("Range")); err == nil {
+					mu.Lock()
+					chunkSizes = append(chunkSizes, end-start+1)
+					mu.Unlock()
+				}
+				serveContent("dummy.txt", content)(w, r)
+			}))
+
+			opts := tc.opts
+			opts.Timeout = 3
+			opts.Quiet = true
+			opts.DestFilePath = filepath.Join(t.TempDir(), "dummy.txt")
+
+			err := download.NewService(opts, download.GetMD5Hash).Download([]string{srv.URL + "/dummy.txt"})
+			assert.NoError(t, err)
+
+			downloaded, err := os.ReadFile(opts.DestFilePath)
+			assert.NoError(t, err)
+			assert.Equal(t, content, downloaded)
+
+			assert.NotEmpty(t, chunkSizes)
+			for _, size := range chunkSizes {
+				assert.LessOrEqual(t, size, tc.maxChunkSize)
+			}
+		})
+	}
+}
+
+func Test_Service_Download_ValidateContentType(t *testing.T) {
+	var gzipped bytes.Buffer
+	gw := gzip.NewWriter(&gzipped)
+	gw.Write(readFixture(t, "dummy.txt"))
+	gw.Close()
+
+	testCases := map[string]struct {
+		content             []byte
+		sourceContentType   string
+		validateContentType string
+		expectedErr         error
+	}{
+		"html error page": {
+			content:             []byte("<!DOCTYPE html><html><body><h1>502 Bad Gateway</h1></body></html>"),
+			validateContentType: "text/plain",
+			expectedErr:         download.ErrContentTypeMismatch,
+		},
+		"text": {
+			content:             readFixture(t, "dummy.txt"),
+			validateContentType: "text/plain",
+		},
+		"gzip": {
+			content:             gzipped.Bytes(),
+			validateContentType: "application/gzip",
+		},
+		"inconclusive detection matching source": {
+			content:             []byte{0x00, 0x01, 0x02, 0x03},
+			sourceContentType:   "application/vnd.custom",
+			validateContentType: "application/vnd.custom",
+		},
+		"inconclusive detection not matching source": {
+			content:             []byte{0x00, 0x01, 0x02, 0x03},
+			sourceContentType:   "application/octet-stream",
+			validateContentType: "application/vnd.custom",
+			expectedErr:         download.ErrContentTypeMismatch,
+		},
+	}
+
+	for scenario, tc := range testCases {
+		t.Run(scenario, func(t *testing.T) {
+			srv := newTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if len(tc.sourceContentType) > 0 {
+					w.Header().Set("Content-Type", tc.sourceContentType)
+				}
+				serveContent("file", tc.content)(w, r)
+			}))
+
+			destFilePath := filepath.Join(t.TempDir(), "file")
+			downloadService := download.NewService(download.Options{
+				Connections:         2,
+				Timeout:             3,
+				Quiet:               true,
+				DestFilePath:        destFilePath,
+				ValidateContentType: tc.validateContentType,
+			}, download.GetMD5Hash)
+
+			err := downloadService.Download([]string{srv.URL + "/file"})
+			if tc.expectedErr != nil {
+				assert.ErrorIs(t, err, tc.expectedErr)
+				assert.NoFileExists(t, destFilePath)
+				assert.NoFileExists(t, destFilePath+".download")
+				return
+			}
+
+			assert.NoError(t, err)
+
+			downloaded, err := os.ReadFile(destFilePath)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.content, downloaded)
+		})
+	}
+}
+
+func Test_Service_Download_ChunkStallTimeout(t *testing.T) {
+	content := readFixture(t, "dummy.txt")
+
+	// sends the first bytes of each range and then stalls until the client gives up
+	var stalledRequests atomic.Int32
+	stallingSrv := newTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead {
+			serveContent("dummy.txt", content)(w, r)
+			return
+		}
+		stalledRequests.Add(1)
+
+		var start, end int
+		fmt.Sscanf(r.Header.Get("Range"), "bytes=%d-%d", &start, &end)
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end, len(content)))
+		w.Header().Set("Content-Length", strconv.Itoa(end-start+1))
+		w.WriteHeader(http.StatusPartialContent)
+		w.Write(content[start : start+5])
+		w.(http.Flusher).Flush()
+
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+	}))
+	healthySrv := newTestServer(t, serveContent("dummy.txt", content))
+
+	destFilePath := filepath.Join(t.TempDir(), "dummy.txt")
+	downloadService := download.NewService(download.Options{
+		Connections:       2,
+		Timeout:           10,
+		Quiet:             true,
+		DestFilePath:      destFilePath,
+		ChunkStallTimeout: 100 * time.Millisecond,
+	}, download.GetMD5Hash)
+
+	startedAt := time.Now()
+	err := downloadService.Download([]string{stallingSrv.URL + "/dummy.txt", healthySrv.URL + "/dummy.txt"})
+	assert.NoError(t, err)
+	assert.Less(t, time.Since(startedAt), 2*time.Second)
+
+	downloaded, err := os.ReadFile(destFilePath)
+	assert.NoError(t, err)
+	assert.Equal(t, content, downloaded)
+
+	// the stalled chunk was retried from the healthy source
+	assert.Positive(t, stalledRequests.Load())
+	for _, ss := range downloadService.LastDownloadStats().Sources {
+		if ss.URL == stallingSrv.URL+"/dummy.txt" {
+			assert.Positive(t, ss.Errors)
+			assert.Zero(t, ss.ChunksDelivered)
+		}
+	}
+}
+
+func Test_Service_Download_PerSourceHeaders(t *testing.T) {
+	content := readFixture(t, "dummy.txt")
+
+	// each source only serves the requests with its own token
+	newAuthServer := func(token string, requests *atomic.Int32) *httptest.Server {
+		return newTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("X-Auth") != token {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			requests.Add(1)
+			serveContent("dummy.txt", content)(w, r)
+		}))
+	}
+
+	var requests1, requests2 atomic.Int32
+	srv1 := newAuthServer("token1", &requests1)
+	srv2 := newAuthServer("token2", &requests2)
+	sourceUrls := []string{srv1.URL + "/dummy.txt", srv2.URL + "/dummy.txt"}
+
+	destFilePath := filepath.Join(t.TempDir(), "dummy.txt")
+	downloadService := download.NewService(download.Options{
+		Connections:  4,
+		Timeout:      3,
+		Quiet:        true,
+		DestFilePath: destFilePath,
+		PerSourceHeaders: map[string]map[string]string{
+			sourceUrls[0]: {"X-Auth": "token1"},
+			sourceUrls[1]: {"X-Auth": "token2"},
+		},
+	}, download.GetMD5Hash)
+
+	err := downloadService.Download(sourceUrls)
+	assert.NoError(t, err)
+
+	downloaded, err := os.ReadFile(destFilePath)
+	assert.NoError(t, err)
+	assert.Equal(t, content, downloaded)
+
+	// HEAD and at least one chunk each
+	assert.GreaterOrEqual(t, requests1.Load(), int32(2))
+	assert.GreaterOrEqual(t, requests2.Load(), int32(2))
+}
+
+func Test_Service_Download_ChunkCallbacks(t *testing.T) {
+	content := readFixture(t, "dummy.txt")
+
+	testCases := map[string]struct {
+		opts           download.Options
+		expectedChunks int
+	}{
+		"chunks": {
+			opts:           download.Options{ChunkBytes: 500},
+			expectedChunks: 7,
+		},
+		"pipelined chunks": {
+			opts:           download.Options{ChunkBytes: 500, PipelineChunks: 2},
+			expectedChunks: 7,
+		},
+		"whole file": {
+			opts:           download.Options{MinChunkBytes: 4096},
+			expectedChunks: 1,
+		},
+	}
+
+	type chunkEvent struct {
+		index     int
+		source    string
+		offset    int64
+		size      int64
+		completed bool
+	}
+
+	for scenario, tc := range testCases {
+		t.Run(scenario, func(t *testing.T) {
+			srv1 := newTestServer(t, serveContent("dummy.txt", content))
+			srv2 := newTestServer(t, serveContent("dummy.txt", content))
+			sourceUrls := []string{srv1.URL + "/dummy.txt", srv2.URL + "/dummy.txt"}
+
+			var mu sync.Mutex
+			var events []chunkEvent
+			record := func(completed bool) download.ChunkCallback {
+				return func(chunkIndex int, source string, offset, size int64) {
+					mu.Lock()
+					defer mu.Unlock()
+					events = append(events, chunkEvent{index: chunkIndex, source: source, offset: offset, size: size, completed: completed})
+				}
+			}
+
+			opts := tc.opts
+			opts.Connections = 4
+			opts.Timeout = 3
+			opts.Quiet = true
+			opts.DestFilePath = filepath.Join(t.TempDir(), "dummy.txt")
+			opts.OnChunkStart = record(false)
+			opts.OnProgress = record(true)
+
+			err := download.NewService(opts, download.GetMD5Hash).Download(sourceUrls)
+			assert.NoError(t, err)
+
+			assert.Len(t, events, 2*tc.expectedChunks)
+
+			started := make(map[int]chunkEvent)
+			completed := make(map[int]chunkEvent)
+			var completedBytes int64
+			for _, e := range events {
+				assert.Contains(t, sourceUrls, e.source)
+				if !e.completed {
+					assert.NotContains(t, started, e.index, "chunk %d started more than once", e.index)
+					started[e.index] = e
+					continue
+				}
+
+				assert.NotContains(t, completed, e.index, "chunk %d completed more than once", e.index)
+				if assert.Contains(t, started, e.index, "chunk %d completed before it started", e.index) {
+					assert.Equal(t, started[e.index].offset, e.offset)
+					assert.Equal(t, started[e.index].size, e.size)
+				}
+				completed[e.index] = e
+				completedBytes += e.size
+			}
+			assert.Len(t, started, tc.expectedChunks)
+			assert.Equal(t, int64(len(content)), completedBytes)
+		})
+	}
+}
+
+func Test_Service_Download_ProxyUserAgent(t *testing.T) {
+	content := readFixture(t, "dummy.txt")
+
+	// the source host does not exist so the requests can only succeed through the proxy
+	var requests atomic.Int32
+	proxy := newTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Host != "source.invalid" || r.UserAgent() != "msdl-test/1.0" {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		requests.Add(1)
+		serveContent("dummy.txt", content)(w, r)
+	}))
+
+	destFilePath := filepath.Join(t.TempDir(), "dummy.txt")
+	downloadService := download.NewService(download.Options{
+		Connections:  4,
+		Timeout:      3,
+		Quiet:        true,
+		DestFilePath: destFilePath,
+		Proxy:        proxy.URL,
+		UserAgent:    "msdl-test/1.0",
+	}, download.GetMD5Hash)
+
+	err := downloadService.Download([]string{"http://source.invalid/dummy.txt"})
+	assert.NoError(t, err)
+
+	downloaded, err := os.ReadFile(destFilePath)
+	assert.NoError(t, err)
+	assert.Equal(t, content, downloaded)
+	assert.GreaterOrEqual(t, requests.Load(), int32(2)) // HEAD and at least one chunk
+}
+
+func Test_Service_Download_WrapTransport(t *testing.T) {
+	content := readFixture(t, "dummy.txt")
+
+	// the requests only succeed if they are both signed by the wrapper and sent through the proxy
+	var requests atomic.Int32
+	proxy := newTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Host != "source.invalid" || r.Header.Get("Authorization") != "Bearer signed" {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		requests.Add(1)
+		serveContent("dummy.txt", content)(w, r)
+	}))
+
+	destFilePath := filepath.Join(t.TempDir(), "dummy.txt")
+	downloadService := download.NewService(download.Options{
+		Connections:  4,
+		Timeout:      3,
+		Quiet:        true,
+		DestFilePath: destFilePath,
+		Proxy:        proxy.URL,
+		WrapTransport: func(base http.RoundTripper) http.RoundTripper {
+			return bearerRoundTripper{token: "signed", next: base}
+		},
+	}, download.GetMD5Hash)
+
+	err := downloadService.Download([]string{"http://source.invalid/dummy.txt"})
+	assert.NoError(t, err)
+
+	downloaded, err := os.ReadFile(destFilePath)
+	assert.NoError(t, err)
+	assert.Equal(t, content, downloaded)
+	assert.GreaterOrEqual(t, requests.Load(), int32(2)) // HEAD and at least one chunk
+}
+
+func Test_Service_Download_Cache(t *testing.T) {
+	content := readFixture(t, "dummy.txt")
+
+	var mu sync.Mutex
+	var requests, conditionalRequests int
+	srv := newTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests++
+		if len(r.Header.Get("If-None-Match")) > 0 {
+			conditionalRequests++
+		}
+		mu.Unlock()
+
+		w.Header().Set("Cache-Control", "max-age=1")
+		serveContentWithETag("dummy.txt", content)(w, r)
+	}))
+	countRequests := func() (int, int) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		total, conditional := requests, conditionalRequests
+		requests, conditionalRequests = 0, 0
+		return total, conditional
+	}
+
+	diskCache := cache.NewDiskCache(t.TempDir())
+	runDownload := func() {
+		t.Helper()
+
+		destFilePath := filepath.Join(t.TempDir(), "dummy.txt")
+		downloadService := download.NewService(download.Options{
+			Connections:  4,
+			Timeout:      3,
+			Quiet:        true,
+			DestFilePath: destFilePath,
+			Cache:        diskCache,
+		}, download.GetMD5Hash)
+
+		err := downloadService.Download([]string{srv.URL + "/dummy.txt"})
+		assert.NoError(t, err)
+
+		downloaded, err := os.ReadFile(destFilePath)
+		assert.NoError(t, err)
+		assert.Equal(t, content, downloaded)
+	}
+
+	// miss: HEAD plus the 5 ranged GETs (4 chunks and the remainder)
+	runDownload()
+	total, conditional := countRequests()
+	assert.Equal(t, 6, total)
+	assert.Zero(t, conditional)
+
+	// hit: served entirely from the cache
+	runDownload()
+	total, _ = countRequests()
+	assert.Zero(t, total)
+
+	// expired: entries are revalidated with If-None-Match
+	time.Sleep(1100 * time.Millisecond)
+	runDownload()
+	total, conditional = countRequests()
+	assert.Equal(t, 6, total)
+	assert.Equal(t, 6, conditional)
+}
+
+func Test_Service_DownloadRange(t *testing.T) {
+	content := readFixture(t, "dummy.png")
+
+	var mu sync.Mutex
+	var methods []string
+	newRecordingServer := func() *httptest.Server {
+		return newTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			methods = append(methods, r.Method)
+			mu.Unlock()
+			serveContent("dummy.png", content)(w, r)
+		}))
+	}
+	sourceUrls := []string{newRecordingServer().URL + "/dummy.png", newRecordingServer().URL + "/dummy.png"}
+
+	testCases := map[string]struct {
+		rangeStart int64
+		rangeEnd   int64
+	}{
+		"header": {
+			rangeStart: 0,
+			rangeEnd:   512,
+		},
+		"middle": {
+			rangeStart: 100,
+			rangeEnd:   333,
+		},
+		"fewer bytes than connections": {
+			rangeStart: 10,
+			rangeEnd:   13,
+		},
+	}
+
+	for scenario, tc := range testCases {
+		t.Run(scenario, func(t *testing.T) {
+			tempDir := t.TempDir()
+			downloadService := download.NewService(download.Options{
+				Connections:  4,
+				Timeout:      3,
+				Quiet:        true,
+				DestFilePath: filepath.Join(tempDir, "dummy.png"),
+			}, download.GetMD5Hash)
+
+			var buf bytes.Buffer
+			err := downloadService.DownloadRange(context.Background(), sourceUrls, tc.rangeStart, tc.rangeEnd, &buf)
+			assert.NoError(t, err)
+			assert.Equal(t, content[tc.rangeStart:tc.rangeEnd], buf.Bytes())
+
+			entries, err := os.ReadDir(tempDir)
+			assert.NoError(t, err)
+			assert.Empty(t, entries) // no `.download` file
+		})
+	}
+
+	assert.NotContains(t, methods, http.MethodHead)
+}
+
+func Test_Service_Download_AcceptFullResponseFallback(t *testing.T) {
+	content := readFixture(t, "dummy.txt")
+
+	testCases := map[string]struct {
+		fallback    bool
+		specificErr error
+	}{
+		"fallback": {
+			fallback: true,
+		},
+		"no fallback": {
+			fallback:    false,
+			specificErr: download.ErrPartialRequestUnsupported,
+		},
+	}
+
+	for scenario, tc := range testCases {
+		t.Run(scenario, func(t *testing.T) {
+			// ignores the Range header (without advertising Accept-Ranges either)
+			var requestedRanges atomic.Int32
+			srv := newTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if len(r.Header.Get("Range")) > 0 {
+					requestedRanges.Add(1)
+				}
+				w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+				w.WriteHeader(http.StatusOK)
+				if r.Method == http.MethodGet {
+					w.Write(content)
+				}
+			}))
+
+			destFilePath := filepath.Join(t.TempDir(), "dummy.txt")
+			downloadService := download.NewService(download.Options{
+				Connections:                4,
+				ChunkBytes:                 500,
+				Timeout:                    3,
+				Quiet:                      true,
+				DestFilePath:               destFilePath,
+				AcceptFullResponseFallback: tc.fallback,
+			}, download.GetMD5Hash)
+
+			err := downloadService.Download([]string{srv.URL + "/dummy.txt"})
+			if tc.specificErr != nil {
+				assert.ErrorIs(t, err, tc.specificErr)
+				return
+			}
+			assert.NoError(t, err)
+
+			downloaded, err := os.ReadFile(destFilePath)
+			assert.NoError(t, err)
+			assert.Equal(t, content, downloaded)
+			assert.Equal(t, int32(7), requestedRanges.Load()) // still chunked
+		})
+	}
+}
+
+func Test_Service_Download_AcceptFullResponseFallback_Truncated(t *testing.T) {
+	content := readFixture(t, "dummy.txt")
+
+	// reports the whole file on HEAD but only sends its first half
+	srv := newTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead {
+			w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+			return
+		}
+		w.Header().Set("Content-Length", strconv.Itoa(len(content)/2))
+		w.Write(content[:len(content)/2])
+	}))
+
+	downloadService := download.NewService(download.Options{
+		Connections:                4,
+		ChunkBytes:                 500,
+		Timeout:                    3,
+		Quiet:                      true,
+		DestFilePath:               filepath.Join(t.TempDir(), "dummy.txt"),
+		AcceptFullResponseFallback: true,
+	}, download.GetMD5Hash)
+
+	err := downloadService.Download([]string{srv.URL + "/dummy.txt"})
+	assert.ErrorIs(t, err, download.ErrFailedChunkDownloadAllSources)
+
+	var chunkErr download.ChunkError
+	if assert.True(t, errors.As(err, &chunkErr)) {
+		assert.GreaterOrEqual(t, chunkErr.Offset, int64(len(content)/2-500)) // the chunks within the half are fine
+		for _, sa := range chunkErr.TriedSources {
+			assert.ErrorIs(t, sa.Err, download.ErrTruncatedResponse)
+		}
+	}
+}
+
+func Test_Service_Download_TruncatedResponseRetried(t *testing.T) {
+	content := readFixture(t, "dummy.txt")
+	healthySrv := newTestServer(t, serveContent("dummy.txt", content))
+
+	// declares the full range but only sends half of it before closing the connection
+	truncatingSrv := newTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead {
+			serveContent("dummy.txt", content)(w, r)
+			return
+		}
+
+		var start, end int
+		fmt.Sscanf(r.Header.Get("Range"), "bytes=%d-%d", &start, &end)
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end, len(content)))
+		w.WriteHeader(http.StatusPartialContent)
+		w.Write(content[start : start+(end-start+1)/2])
+		w.(http.Flusher).Flush()
+		panic(http.ErrAbortHandler)
+	}))
+	sourceUrls := []string{truncatingSrv.URL + "/dummy.txt", healthySrv.URL + "/dummy.txt"}
+
+	destFilePath := filepath.Join(t.TempDir(), "dummy.txt")
+	downloadService := download.NewService(download.Options{
+		Connections:  4,
+		Timeout:      3,
+		Quiet:        true,
+		DestFilePath: destFilePath,
+	}, download.GetMD5Hash)
+
+	err := downloadService.Download(sourceUrls)
+	assert.NoError(t, err)
+
+	downloaded, err := os.ReadFile(destFilePath)
+	assert.NoError(t, err)
+	assert.Equal(t, content, downloaded)
+
+	stats := downloadService.LastDownloadStats()
+	assert.Zero(t, stats.Sources[0].ChunksDelivered)
+	assert.Greater(t, stats.Sources[0].Errors, 0)
+}
+
+func Test_Service_Download_HeadRetry(t *testing.T) {
+	content := readFixture(t, "dummy.txt")
+
+	testCases := map[string]struct {
+		failedHeads       int64
+		headRetryAttempts uint
+		honourRetryAfter  bool
+		retryAfter        string
+		expectErr         bool
+		minElapsed        time.Duration
+	}{
+		"succeeds on the third try": {
+			failedHeads:       2,
+			headRetryAttempts: 3,
+			minElapsed:        30 * time.Millisecond, // 10ms + 20ms
+		},
+		"attempts exhausted": {
+			failedHeads:       5,
+			headRetryAttempts: 3,
+			expectErr:         true,
+		},
+		"no retries by default": {
+			failedHeads: 1,
+			expectErr:   true,
+		},
+		"Retry-After overrides backoff": {
+			failedHeads:       1,
+			headRetryAttempts: 1,
+			honourRetryAfter:  true,
+			retryAfter:        "1",
+			minElapsed:        time.Second,
+		},
+	}
+
+	for scenario, tc := range testCases {
+		t.Run(scenario, func(t *testing.T) {
+			var heads atomic.Int64
+			srv := newTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method == http.MethodHead && heads.Add(1) <= tc.failedHeads {
+					if len(tc.retryAfter) > 0 {
+						w.Header().Set("Retry-After", tc.retryAfter)
+					}
+					w.WriteHeader(http.StatusServiceUnavailable)
+					return
+				}
+				serveContent("dummy.txt", content)(w, r)
+			}))
+
+			downloadService := download.NewService(download.Options{
+				Connections:       2,
+				Timeout:           3,
+				Quiet:             true,
+				DestFilePath:      filepath.Join(t.TempDir(), "dummy.txt"),
+				HeadRetryAttempts: tc.headRetryAttempts,
+				HeadRetryBackoff:  10 * time.Millisecond,
+				HonourRetryAfter:  tc.honourRetryAfter,
+			}, download.GetMD5Hash)
+
+			start := time.Now()
+			err := downloadService.Download([]string{srv.URL + "/dummy.txt"})
+
+			if tc.expectErr {
+				assert.Error(t, err)
+				assert.Equal(t, int64(tc.headRetryAttempts)+1, heads.Load())
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tc.failedHeads+1, heads.Load())
+			assert.GreaterOrEqual(t, time.Since(start), tc.minElapsed)
+		})
+	}
+}
+
+func Test_Service_Download_ChunkRangesNotOverlapping(t *testing.T) {
+	testCases := map[string]struct {
+		size        int
+		connections uint
+	}{
+		"evenly divisible": {
+			size:        1000,
+			connections: 4,
+		},
+		"not evenly divisible": {
+			size:        1003,
+			connections: 7,
+		},
+		"remainder of one byte": {
+			size:        10,
+			connections: 3,
+		},
+		"fewer bytes than connections": {
+			size:        3,
+			connections: 8,
+		},
+		"single byte": {
+			size:        1,
+			connections: 4,
+		},
+	}
+
+	for scenario, tc := range testCases {
+		t.Run(scenario, func(t *testing.T) {
+			content := make([]byte, tc.size)
+			for i := range content {
+				content[i] = byte(i % 251) // no repeated sections within chunks
+			}
+
+			var mu sync.Mutex
+			var ranges [][2]int
+			srv := newTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				var start, end int
+				if _, err := fmt.Sscanf(r.Header.Get("Range"), "bytes=%d-%d", &start, &end); err == nil {
+					mu.Lock()
+					ranges = append(ranges, [2]int{start, end})
+					mu.Unlock()
+				}
+				serveContent("bytes.bin", content)(w, r)
+			}))
+
+			destFilePath := filepath.Join(t.TempDir(), "bytes.bin")
+			downloadService := download.NewService(download.Options{
+				Connections:  tc.connections,
+				Timeout:      3,
+				Quiet:        true,
+				DestFilePath: destFilePath,
+			}, download.GetMD5Hash)
+
+			err := downloadService.Download([]string{srv.URL + "/bytes.bin"})
+			assert.NoError(t, err)
+
+			downloaded, err := os.ReadFile(destFilePath)
+			assert.NoError(t, err)
+			assert.Equal(t, content, downloaded)
+
+			// the (inclusive) ranges must cover every byte exactly once
+			sort.Slice(ranges, func(i, j int) bool { return ranges[i][0] < ranges[j][0] })
+			next := 0
+			for _, r := range ranges {
+				assert.Equal(t, next, r[0], "range %v overlaps or leaves a gap", r)
+				assert.GreaterOrEqual(t, r[1], r[0])
+				next = r[1] + 1
+			}
+			assert.Equal(t, tc.size, next)
+		})
+	}
+}
+
+func Test_Service_Download_LocalFileSources(t *testing.T) {
+	content := readFixture(t, "dummy.txt")
+	localFilePath := writeTempFile(t, "dummy.txt", content)
+	localFileUrl := (&url.URL{Scheme: "file", Path: filepath.ToSlash(localFilePath)}).String()
+
+	testCases := map[string]struct {
+		withHTTPSource bool
+	}{
+		"local file only": {
+			withHTTPSource: false,
+		},
+		"local file and HTTP source": {
+			withHTTPSource: true,
+		},
+	}
+
+	for scenario, tc := range testCases {
+		t.Run(scenario, func(t *testing.T) {
+			var httpRequests atomic.Int32
+			srv := newTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				httpRequests.Add(1)
+				serveContentWithETag("dummy.txt", content)(w, r)
+			}))
+
+			urls := []string{localFileUrl}
+			if tc.withHTTPSource {
+				urls = append(urls, srv.URL+"/dummy.txt")
+			}
+
+			destFilePath := filepath.Join(t.TempDir(), "dummy.txt")
+			downloadService := download.NewService(download.Options{
+				Connections:  4,
+				Timeout:      3,
+				Quiet:        true,
+				CheckETag:    true,
+				DestFilePath: destFilePath,
+			}, download.GetMD5Hash)
+
+			err := downloadService.Download(urls)
+			assert.NoError(t, err)
+
+			downloaded, err := os.ReadFile(destFilePath)
+			assert.NoError(t, err)
+			assert.Equal(t, content, downloaded)
+			assert.Equal(t, tc.withHTTPSource, httpRequests.Load() > 0)
+		})
+	}
+}
+
+func Test_Service_Download_ChunkBytes(t *testing.T) {
+	testCases := map[string]struct {
+		size               int
+		chunkBytes         int64
+		connections        uint
+		expectedChunkSizes []int
+	}{
+		"evenly divisible": {
+			size:               1000,
+			chunkBytes:         250,
+			connections:        2,
+			expectedChunkSizes: []int{250, 250, 250, 250},
+		},
+		"not evenly divisible": {
+			size:               1000,
+			chunkBytes:         300,
+			connections:        2,
+			expectedChunkSizes: []int{300, 300, 300, 100},
+		},
+		"fewer chunks than connections": {
+			size:               1000,
+			chunkBytes:         600,
+			connections:        8,
+			expectedChunkSizes: []int{600, 400},
+		},
+		"chunk larger than file": {
+			size:               10,
+			chunkBytes:         4096,
+			connections:        4,
+			expectedChunkSizes: []int{10},
+		},
+	}
+
+	for scenario, tc := range testCases {
+		t.Run(scenario, func(t *testing.T) {
+			content := make([]byte, tc.size)
+			for i := range content {
+				content[i] = byte(i % 251)
+			}
+
+			var mu sync.Mutex
+			var ranges [][2]int
+			var inFlight, maxInFlight int
+			srv := newTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				var start, end int
+				if _, err := fmt.Sscanf(r.Header.Get("Range"), "bytes=%d-%d", &start, &end); err == nil {
+					mu.Lock()
+					ranges = append(ranges, [2]int{start, end})
+					inFlight++
+					maxInFlight = max(maxInFlight, inFlight)
+					mu.Unlock()
+
+					time.Sleep(10 * time.Millisecond) // let the other chunks start in the meantime
+
+					defer func() {
+						mu.Lock()
+						inFlight--
+						mu.Unlock()
+					}()
+				}
+				serveContent("bytes.bin", content)(w, r)
+			}))
+
+			destFilePath := filepath.Join(t.TempDir(), "bytes.bin")
+			downloadService := download.NewService(download.Options{
+				Connections:  tc.connections,
+				ChunkBytes:   tc.chunkBytes,
+				Timeout:      3,
+				Quiet:        true,
+				DestFilePath: destFilePath,
+			}, download.GetMD5Hash)
+
+			err := downloadService.Download([]string{srv.URL + "/bytes.bin"})
+			assert.NoError(t, err)
+
+			downloaded, err := os.ReadFile(destFilePath)
+			assert.NoError(t, err)
+			assert.Equal(t, content, downloaded)
+
+			sort.Slice(ranges, func(i, j int) bool { return ranges[i][0] < ranges[j][0] })
+			next := 0
+			var chunkSizes []int
+			for _, r := range ranges {
+				assert.Equal(t, next, r[0], "range %v overlaps or leaves a gap", r)
+				chunkSizes = append(chunkSizes, r[1]-r[0]+1)
+				next = r[1] + 1
+			}
+			assert.Equal(t, tc.expectedChunkSizes, chunkSizes)
+			assert.LessOrEqual(t, maxInFlight, int(tc.connections))
+		})
+	}
+}
+
+// recordingBackoff returns a fixed delay and records the arguments it is called with.
+type recordingBackoff struct {
+	delay time.Duration
+
+	mu    sync.Mutex
+	calls [][3]time.Duration // attempt, base, cap
+}
+
+func (rb *recordingBackoff) Next(attempt int, base, cap time.Duration) time.Duration {
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+
+	rb.calls = append(rb.calls, [3]time.Duration{time.Duration(attempt), base, cap})
+
+	return rb.delay
+}
+
+func Test_Service_Download_RetryBackoff(t *testing.T) {
+	content := readFixture(t, "dummy.txt")
+	healthySrv := newTestServer(t, serveContent("dummy.txt", content))
+	failingSrv := newTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead {
+			serveContent("dummy.txt", content)(w, r)
+			return
+		}
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+
+	testCases := map[string]struct {
+		base, cap                 time.Duration
+		expectedBase, expectedCap time.Duration
+	}{
+		"defaults": {
+			expectedBase: 100 * time.Millisecond,
+			expectedCap:  10 * time.Second,
+		},
+		"configured": {
+			base:         time.Millisecond,
+			cap:          time.Second,
+			expectedBase: time.Millisecond,
+			expectedCap:  time.Second,
+		},
+	}
+
+	for scenario, tc := range testCases {
+		t.Run(scenario, func(t *testing.T) {
+			rb := &recordingBackoff{delay: 20 * time.Millisecond}
+
+			destFilePath := filepath.Join(t.TempDir(), "dummy.txt")
+			downloadService := download.NewService(download.Options{
+				Connections:      4,
+				Timeout:          3,
+				Quiet:            true,
+				DestFilePath:     destFilePath,
+				RetryBackoff:     rb,
+				RetryBackoffBase: tc.base,
+				RetryBackoffCap:  tc.cap,
+			}, download.GetMD5Hash)
+
+			start := time.Now()
+			err := downloadService.Download([]string{failingSrv.URL + "/dummy.txt", healthySrv.URL + "/dummy.txt"})
+			assert.NoError(t, err)
+			assert.GreaterOrEqual(t, time.Since(start), rb.delay)
+
+			downloaded, err := os.ReadFile(destFilePath)
+			assert.NoError(t, err)
+			assert.Equal(t, content, downloaded)
+
+			// every chunk which started at the failing source is retried once from the healthy source
+			assert.NotEmpty(t, rb.calls)
+			for _, call := range rb.calls {
+				assert.Equal(t, [3]time.Duration{0, tc.expectedBase, tc.expectedCap}, call)
+			}
+		})
+	}
+}
+
+func Test_Service_PauseResume(t *testing.T) {
+	content := readFixture(t, "dummy.txt")
+
+	var chunkRequests atomic.Int32
+	firstChunkRequested := make(chan struct{})
+	var once sync.Once
+	srv := newTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			chunkRequests.Add(1)
+			once.Do(func() { close(firstChunkRequested) })
+			time.Sleep(10 * time.Millisecond)
+		}
+		serveContent("dummy.txt", content)(w, r)
+	}))
+
+	destFilePath := filepath.Join(t.TempDir(), "dummy.txt")
+	downloadService := download.NewService(download.Options{
+		Connections:  2,
+		ChunkBytes:   100, // many more chunks than connections
+		Timeout:      3,
+		Quiet:        true,
+		DestFilePath: destFilePath,
+	}, download.GetMD5Hash)
+
+	done := make(chan error, 1)
+	go func() {
+		done <- downloadService.Download([]string{srv.URL + "/dummy.txt"})
+	}()
+
+	<-firstChunkRequested
+	downloadService.Pause()
+	assert.True(t, downloadService.Paused())
+
+	requestsWhenPaused := chunkRequests.Load()
+	select {
+	case err := <-done:
+		t.Fatalf("download finished while paused: %v", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+	assert.Equal(t, requestsWhenPaused, chunkRequests.Load(), "no new requests while paused")
+	assert.Less(t, int(requestsWhenPaused), (len(content)+99)/100)
+
+	downloadService.Resume()
+	assert.False(t, downloadService.Paused())
+
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("download did not complete after resuming")
+	}
+
+	downloaded, err := os.ReadFile(destFilePath)
+	assert.NoError(t, err)
+	assert.Equal(t, content, downloaded)
+}
+
+func Test_Service_Download_PieceHashes(t *testing.T) {
+	content := readFixture(t, "dummy.txt")
+	pieceSize := int64(512)
+
+	var pieceHashes []string
+	for start := int64(0); start < int64(len(content)); start += pieceSize {
+		end := min(start+pieceSize, int64(len(content)))
+		pieceHashes = append(pieceHashes, fmt.Sprintf("%x", sha256.Sum256(content[start:end])))
+	}
+
+	corruptedOffset := 100 // within piece 0 (and chunk 0)
+
+	// corrupts a byte of every ranged response covering the corrupted offset
+	newCorruptingHandler := func(headDelay time.Duration) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == http.MethodHead {
+				time.Sleep(headDelay)
+				serveContent("dummy.txt", content)(w, r)
+				return
+			}
+
+			var start, end int
+			fmt.Sscanf(r.Header.Get("Range"), "bytes=%d-%d", &start, &end)
+			chunk := bytes.Clone(content[start : end+1])
+			if start <= corruptedOffset && corruptedOffset <= end {
+				chunk[corruptedOffset-start] ^= 0xff
+			}
+			w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end, len(content)))
+			w.WriteHeader(http.StatusPartialContent)
+			w.Write(chunk)
+		}
+	}
+
+	testCases := map[string]struct {
+		healthySource bool
+		pieceHashes   []string
+		expectedErr   error
+	}{
+		"corrupted piece downloaded again from a different source": {
+			healthySource: true,
+			pieceHashes:   pieceHashes,
+		},
+		"corrupted piece on all sources": {
+			healthySource: false,
+			pieceHashes:   pieceHashes,
+			expectedErr:   download.ErrPieceHashMismatch,
+		},
+		"wrong number of piece hashes": {
+			healthySource: true,
+			pieceHashes:   pieceHashes[1:],
+			expectedErr:   download.ErrInvalidPieceHashes,
+		},
+	}
+
+	for scenario, tc := range testCases {
+		t.Run(scenario, func(t *testing.T) {
+			// the corrupting source responds faster to be prioritized
+			sourceUrls := []string{newTestServer(t, newCorruptingHandler(0)).URL + "/dummy.txt"}
+
+			var healthyRequests atomic.Int32
+			healthySrv := newTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method == http.MethodHead {
+					time.Sleep(50 * time.Millisecond)
+				} else {
+					healthyRequests.Add(1)
+				}
+				serveContent("dummy.txt", content)(w, r)
+			}))
+			if tc.healthySource {
+				sourceUrls = append(sourceUrls, healthySrv.URL+"/dummy.txt")
+			} else {
+				sourceUrls = append(sourceUrls, newTestServer(t, newCorruptingHandler(50*time.Millisecond)).URL+"/dummy.txt")
+			}
+
+			destFilePath := filepath.Join(t.TempDir(), "dummy.txt")
+			downloadService := download.NewService(download.Options{
+				Connections:  4,
+				Timeout:      3,
+				Quiet:        true,
+				DestFilePath: destFilePath,
+				PieceSize:    pieceSize,
+				PieceHashes:  tc.pieceHashes,
+			}, download.GetMD5Hash)
+
+			err := downloadService.Download(sourceUrls)
+
+			if tc.expectedErr != nil {
+				assert.ErrorIs(t, err, tc.expectedErr)
+
+				var pieceErr download.PieceVerificationError
+				if errors.As(err, &pieceErr) {
+					assert.Equal(t, 0, pieceErr.PieceIndex)
+					assert.Equal(t, pieceHashes[0], pieceErr.Expected)
+					assert.NotEqual(t, pieceHashes[0], pieceErr.Got)
+				}
+				return
+			}
+
+			assert.NoError(t, err)
+
+			downloaded, err := os.ReadFile(destFilePath)
+			assert.NoError(t, err)
+			assert.Equal(t, content, downloaded)
+
+			// chunks 1 and 3 initially and then chunk 0 again
+			assert.Equal(t, int32(3), healthyRequests.Load())
+		})
+	}
+}
+
+func Test_Service_Download_TorrentPieceHashes(t *testing.T) {
+	content := readFixture(t, "dummy.txt")
+	srv := newTestServer(t, serveContent("dummy.txt", content))
+
+	pieceLength, hashes, err := torrent.ParseTorrentHashes(filepath.Join("..", "torrent", "testdata", "dummy.txt.torrent"))
+	assert.NoError(t, err)
+
+	var pieceHashes []string
+	for _, h := range hashes {
+		pieceHashes = append(pieceHashes, fmt.Sprintf("%x", h))
+	}
+
+	testCases := map[string]struct {
+		algorithm   string
+		expectedErr error
+	}{
+		"sha1": {
+			algorithm: "sha1",
+		},
+		"default algorithm": {
+			expectedErr: download.ErrPieceHashMismatch,
+		},
+		"unsupported algorithm": {
+			algorithm:   "crc32",
+			expectedErr: download.ErrUnsupportedHashAlgorithm,
+		},
+	}
+
+	for scenario, tc := range testCases {
+		t.Run(scenario, func(t *testing.T) {
+			destFilePath := filepath.Join(t.TempDir(), "dummy.txt")
+			downloadService := download.NewService(download.Options{
+				Connections:        3,
+				Timeout:            3,
+				Quiet:              true,
+				DestFilePath:       destFilePath,
+				PieceSize:          pieceLength,
+				PieceHashes:        pieceHashes,
+				PieceHashAlgorithm: tc.algorithm,
+			}, download.GetMD5Hash)
+
+			err := downloadService.Download([]string{srv.URL + "/dummy.txt"})
+			if tc.expectedErr != nil {
+				assert.ErrorIs(t, err, tc.expectedErr)
+				return
+			}
+
+			assert.NoError(t, err)
+
+			downloaded, err := os.ReadFile(destFilePath)
+			assert.NoError(t, err)
+			assert.Equal(t, content, downloaded)
+		})
+	}
+}
+
+func Test_Service_Download_MultiSourceError(t *testing.T) {
+	content := readFixture(t, "dummy.txt")
+	liveSrv := newTestServer(t, serveContent("dummy.txt", content))
+
+	var deadUrls []string
+	for range 2 {
+		deadSrv := httptest.NewServer(http.NotFoundHandler())
+		deadSrv.Close()
+		deadUrls = append(deadUrls, deadSrv.URL+"/dummy.txt")
+	}
+
+	testCases := map[string]struct {
+		allowPartialSources bool
+		sourceUrls          []string
+		specificErr         error
+	}{
+		"some sources failed with all required": {
+			allowPartialSources: false,
+			sourceUrls:          []string{deadUrls[0], liveSrv.URL + "/dummy.txt", deadUrls[1]},
+		},
+		"all sources failed": {
+			allowPartialSources: true,
+			sourceUrls:          deadUrls,
+			specificErr:         download.ErrNoSourceUrls,
+		},
+	}
+
+	for scenario, tc := range testCases {
+		t.Run(scenario, func(t *testing.T) {
+			downloadService := download.NewService(download.Options{
+				Connections:         2,
+				Timeout:             3,
+				Quiet:               true,
+				DestFilePath:        filepath.Join(t.TempDir(), "dummy.txt"),
+				AllowPartialSources: tc.allowPartialSources,
+			}, download.GetMD5Hash)
+
+			err := downloadService.Download(tc.sourceUrls)
+
+			var multiErr download.MultiSourceError
+			assert.ErrorAs(t, err, &multiErr)
+			assert.Len(t, multiErr.Errors, 2)
+			for i, srcErr := range multiErr.Errors {
+				assert.Equal(t, deadUrls[i], srcErr.URL)
+				assert.Error(t, srcErr.Err)
+			}
+
+			if tc.specificErr != nil {
+				assert.ErrorIs(t, err, tc.specificErr)
+			}
+		})
+	}
+}
+
+func Test_Service_Download_AuditLog(t *testing.T) {
+	content := readFixture(t, "dummy.txt")
+	srv := newTestServer(t, serveContentWithETag("dummy.txt", content))
+	otherSrv := newTestServer(t, serveContentWithETag("dummy.txt", content))
+
+	auditLogPath := filepath.Join(t.TempDir(), "audit.log")
+	destDir := t.TempDir()
+
+	for i, checkETag := range []bool{false, true} {
+		downloadService := download.NewService(download.Options{
+			Connections:  2,
+			Timeout:      3,
+			Quiet:        true,
+			CheckETag:    checkETag,
+			DestFilePath: filepath.Join(destDir, fmt.Sprintf("dummy-%d.txt", i)),
+			AuditLogPath: auditLogPath,
+		}, download.GetMD5Hash)
+
+		sourceUrls := []string{srv.URL + "/dummy.txt"}
+		if checkETag {
+			sourceUrls = append(sourceUrls, otherSrv.URL+"/dummy.txt")
+		}
+
+		err := downloadService.Download(sourceUrls)
+		assert.NoError(t, err)
+	}
+
+	b, err := os.ReadFile(auditLogPath)
+	assert.NoError(t, err)
+
+	lines := strings.Split(strings.TrimSuffix(string(b), "\n"), "\n")
+	assert.Len(t, lines, 2)
+
+	for i, line := range lines {
+		var record download.AuditRecord
+		assert.NoError(t, json.Unmarshal([]byte(line), &record))
+
+		assert.Equal(t, filepath.Join(destDir, fmt.Sprintf("dummy-%d.txt", i)), record.DestFile)
+		assert.Equal(t, int64(len(content)), record.Bytes)
+		assert.Equal(t, fmt.Sprintf("%x", md5.Sum(content)), record.ETag)
+		assert.Equal(t, i == 1, record.Verified)
+		assert.Contains(t, record.SourcesUsed, srv.URL+"/dummy.txt")
+		assert.NotEmpty(t, record.ProcessUser)
+		assert.WithinDuration(t, time.Now(), record.Timestamp, time.Minute)
+		assert.GreaterOrEqual(t, record.DurationMs, int64(0))
+	}
+}
+
+func Test_Service_Download_TLSHandshakeTimeout(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept() // never responds to the client hello
+			if err != nil {
+				return
+			}
+			t.Cleanup(func() { conn.Close() })
+		}
+	}()
+
+	downloadService := download.NewService(download.Options{
+		Timeout:             10,
+		TLSHandshakeTimeout: 200 * time.Millisecond,
+		Quiet:               true,
+	}, nil)
+
+	start := time.Now()
+	err = downloadService.Download([]string{fmt.Sprintf("https://%s/dummy.txt", listener.Addr())})
+	elapsed := time.Since(start)
+
+	assert.ErrorContains(t, err, "TLS handshake timeout")
+	assert.Less(t, elapsed, 2*time.Second, "should be bounded by the TLS handshake timeout rather than the request timeout")
+}
+
+func Test_Service_Download_ResponseHeaderTimeout(t *testing.T) {
+	srv := newTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done() // never sends the response headers
+	}))
+
+	downloadService := download.NewService(download.Options{
+		Timeout:               10,
+		ResponseHeaderTimeout: 200 * time.Millisecond,
+		Quiet:                 true,
+	}, nil)
+
+	start := time.Now()
+	err := downloadService.Download([]string{srv.URL + "/dummy.txt"})
+	elapsed := time.Since(start)
+
+	assert.ErrorContains(t, err, "timeout awaiting response headers")
+	assert.Less(t, elapsed, 2*time.Second, "should be bounded by the response header timeout rather than the request timeout")
+}
+
+// serveStreamedContent returns a handler serving the whole content without Content-Length (i.e., chunked
+// transfer encoding) and without range support, along with the given ETag if not empty.
+func serveStreamedContent(content []byte, eTag string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if len(eTag) > 0 {
+			w.Header().Set("ETag", fmt.Sprintf(`"%s"`, eTag))
+		}
+		w.Header().Set("Content-Type", "text/plain")
+		w.(http.Flusher).Flush() // sends the headers before the length of the body is known
+		if r.Method == http.MethodGet {
+			w.Write(content)
+		}
+	}
+}
+
+func Test_Service_Download_AllowUnknownLength(t *testing.T) {
+	content := readFixture(t, "dummy.txt")
+	md5Hash := fmt.Sprintf("%x", md5.Sum(content))
+
+	testCases := map[string]struct {
+		allowUnknownLength bool
+		eTag               string
+		expectedErr        error
+	}{
+		"streamed with matching ETag": {
+			allowUnknownLength: true,
+			eTag:               md5Hash,
+		},
+		"streamed without ETag": {
+			allowUnknownLength: true,
+		},
+		"streamed with mismatching ETag": {
+			allowUnknownLength: true,
+			eTag:               "d41d8cd98f00b204e9800998ecf8427e",
+			expectedErr:        download.ErrETagMismatch,
+		},
+		"unknown length not allowed": {
+			allowUnknownLength: false,
+			eTag:               md5Hash,
+			expectedErr:        download.ErrUnknownContentLength,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			srv := newTestServer(t, serveStreamedContent(content, tc.eTag))
+			destFilePath := filepath.Join(t.TempDir(), "dummy.txt")
+
+			downloadService := download.NewService(download.Options{
+				Connections:        4,
+				Timeout:            3,
+				CheckETag:          true,
+				Quiet:              true,
+				DestFilePath:       destFilePath,
+				AllowUnknownLength: tc.allowUnknownLength,
+			}, download.GetMD5Hash)
+
+			err := downloadService.Download([]string{srv.URL + "/dummy.txt"})
+			if tc.expectedErr != nil {
+				assert.ErrorIs(t, err, tc.expectedErr)
+				return
+			}
+			assert.NoError(t, err)
+
+			downloaded, err := os.ReadFile(destFilePath)
+			assert.NoError(t, err)
+			assert.Equal(t, content, downloaded)
+		})
+	}
+}
+
+func Test_Service_Download_AllowUnknownLength_SourceHeaders(t *testing.T) {
+	content := readFixture(t, "dummy.txt")
+
+	// the mirror only streams the file to requests with its token and the configured User-Agent
+	srv := newTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer mirror-token" || r.UserAgent() != "msdl-test/1.0" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		serveStreamedContent(content, "")(w, r)
+	}))
+	sourceURL := srv.URL + "/dummy.txt"
+
+	destFilePath := filepath.Join(t.TempDir(), "dummy.txt")
+	downloadService := download.NewService(download.Options{
+		Connections:        2,
+		Timeout:            3,
+		Quiet:              true,
+		DestFilePath:       destFilePath,
+		AllowUnknownLength: true,
+		UserAgent:          "msdl-test/1.0",
+		PerSourceHeaders:   map[string]map[string]string{sourceURL: {"Authorization": "Bearer mirror-token"}},
+	}, download.GetMD5Hash)
+
+	assert.NoError(t, downloadService.Download([]string{sourceURL}))
+
+	downloaded, err := os.ReadFile(destFilePath)
+	assert.NoError(t, err)
+	assert.Equal(t, content, downloaded)
+}
+
+func Test_Service_Download_MaxFileSize(t *testing.T) {
+	content := readFixture(t, "dummy.txt")
+
+	testCases := map[string]struct {
+		handler     http.HandlerFunc
+		maxFileSize int64
+		expectedErr error
+	}{
+		"known size within limit": {
+			handler:     serveContent("dummy.txt", content),
+			maxFileSize: int64(len(content)),
+		},
+		"known size exceeding limit": {
+			handler:     serveContent("dummy.txt", content),
+			maxFileSize: int64(len(content)) - 1,
+			expectedErr: download.FileTooLargeError{Size: int64(len(content)), Limit: int64(len(content)) - 1},
+		},
+		"streamed within limit": {
+			handler:     serveStreamedContent(content, ""),
+			maxFileSize: int64(len(content)),
+		},
+		"streamed exceeding limit": {
+			handler:     serveStreamedContent(content, ""),
+			maxFileSize: 1000,
+			expectedErr: download.FileTooLargeError{Size: -1, Limit: 1000},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			srv := newTestServer(t, tc.handler)
+			destFilePath := filepath.Join(t.TempDir(), "dummy.txt")
+
+			downloadService := download.NewService(download.Options{
+				Connections:        4,
+				Timeout:            3,
+				Quiet:              true,
+				DestFilePath:       destFilePath,
+				AllowUnknownLength: true,
+				MaxFileSize:        tc.maxFileSize,
+			}, download.GetMD5Hash)
+
+			err := downloadService.Download([]string{srv.URL + "/dummy.txt"})
+			if tc.expectedErr != nil {
+				assert.ErrorIs(t, err, download.ErrFileTooLarge)
+
+				var tooLargeErr download.FileTooLargeError
+				if assert.True(t, errors.As(err, &tooLargeErr)) {
+					assert.Equal(t, tc.expectedErr, tooLargeErr)
+				}
+				assert.NoFileExists(t, destFilePath)
+				return
+			}
+			assert.NoError(t, err)
+
+			downloaded, err := os.ReadFile(destFilePath)
+			assert.NoError(t, err)
+			assert.Equal(t, content, downloaded)
+		})
+	}
+}
+
+func Test_Service_Download_SegmentedTempFiles(t *testing.T) {
+	content := readFixture(t, "dummy.png")
+
+	testCases := map[string]struct {
+		failingRange string
+		expectedErr  error
+	}{
+		"assembled in order": {},
+		"failed chunk": {
+			failingRange: "bytes=0-",
+			expectedErr:  download.ErrFailedChunkDownloadAllSources,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			srv := newTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if len(tc.failingRange) > 0 && strings.HasPrefix(r.Header.Get("Range"), tc.failingRange) {
+					w.WriteHeader(http.StatusInternalServerError)
+					return
+				}
+				serveContentWithETag("dummy.png", content)(w, r)
+			}))
+
+			runDownload := func(segmented bool) (string, error) {
+				destFilePath := filepath.Join(t.TempDir(), "dummy.png")
+				var tee bytes.Buffer
+
+				downloadService := download.NewService(download.Options{
+					Connections:        8,
+					Timeout:            3,
+					CheckETag:          true,
+					Quiet:              true,
+					DestFilePath:       destFilePath,
+					SegmentedTempFiles: segmented,
+					WriteWorkers:       2,
+					TeeWriter:          &tee,
+				}, download.GetMD5Hash)
+
+				err := downloadService.Download([]string{srv.URL + "/dummy.png"})
+
+				leftovers, _ := filepath.Glob(destFilePath + ".download.chunk-*")
+				assert.Empty(t, leftovers, "segment files should be removed")
+
+				if err == nil {
+					assert.Equal(t, content, tee.Bytes())
+				}
+				return destFilePath, err
+			}
+
+			segmentedPath, err := runDownload(true)
+			if tc.expectedErr != nil {
+				assert.ErrorIs(t, err, tc.expectedErr)
+				return
+			}
+			assert.NoError(t, err)
+
+			unsegmentedPath, err := runDownload(false)
+			assert.NoError(t, err)
+
+			segmented, err := os.ReadFile(segmentedPath)
+			assert.NoError(t, err)
+			unsegmented, err := os.ReadFile(unsegmentedPath)
+			assert.NoError(t, err)
+			assert.Equal(t, unsegmented, segmented)
+			assert.Equal(t, content, segmented)
+		})
+	}
+}
+
+func Test_Service_Download_ForceIPVersion(t *testing.T) {
+	listener, err := net.Listen("tcp", ":0") // dual-stack if IPv6 is available
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ipv6Listener, err := net.Listen("tcp6", "[::1]:0"); err != nil {
+		listener.Close()
+		t.Skip("IPv6 loopback not available:", err)
+	} else {
+		ipv6Listener.Close()
+	}
+
+	content := readFixture(t, "dummy.txt")
+
+	var mu sync.Mutex
+	var remoteAddrs map[string]bool
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host, _, _ := net.SplitHostPort(r.RemoteAddr)
+		mu.Lock()
+		remoteAddrs[host] = true
+		mu.Unlock()
+		serveContent("dummy.txt", content)(w, r)
+	}))
+	srv.Listener.Close()
+	srv.Listener = listener
+	srv.Start()
+	t.Cleanup(srv.Close)
+
+	port := listener.Addr().(*net.TCPAddr).Port
+
+	testCases := map[string]struct {
+		forceIPv4, forceIPv6 bool
+		host                 string
+		expectedRemoteAddr   string
+		expectedErr          error
+	}{
+		"IPv4 forced for host name": {
+			forceIPv4:          true,
+			host:               "localhost",
+			expectedRemoteAddr: "127.0.0.1",
+		},
+		"IPv4 forced for IPv6 address": {
+			forceIPv4: true,
+			host:      "[::1]",
+		},
+		"IPv6 forced for IPv6 address": {
+			forceIPv6:          true,
+			host:               "[::1]",
+			expectedRemoteAddr: "::1",
+		},
+		"IPv6 forced for IPv4 address": {
+			forceIPv6: true,
+			host:      "127.0.0.1",
+		},
+		"both forced": {
+			forceIPv4:   true,
+			forceIPv6:   true,
+			host:        "127.0.0.1",
+			expectedErr: download.ErrConflictingIPVersions,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			mu.Lock()
+			remoteAddrs = make(map[string]bool)
+			mu.Unlock()
+
+			downloadService := download.NewService(download.Options{
+				Connections:  2,
+				Timeout:      3,
+				Quiet:        true,
+				DestFilePath: filepath.Join(t.TempDir(), "dummy.txt"),
+				ForceIPv4:    tc.forceIPv4,
+				ForceIPv6:    tc.forceIPv6,
+			}, nil)
+
+			err := downloadService.Download([]string{fmt.Sprintf("http://%s:%d/dummy.txt", tc.host, port)})
+			if len(tc.expectedRemoteAddr) == 0 {
+				assert.Error(t, err)
+				if tc.expectedErr != nil {
+					assert.ErrorIs(t, err, tc.expectedErr)
+				}
+				return
+			}
+			assert.NoError(t, err)
+
+			mu.Lock()
+			defer mu.Unlock()
+			assert.Equal(t, map[string]bool{tc.expectedRemoteAddr: true}, remoteAddrs)
+		})
+	}
+}
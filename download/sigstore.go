@@ -0,0 +1,28 @@
+package download
+
+import (
+	"os"
+
+	"github.com/gkatanacio/multisource-downloader/sigstore"
+)
+
+// newSigstoreVerifier returns the verifier for SigstoreVerify using the public key at the path in
+// the options and the HTTP client of the service (for fetching the bundle).
+func (s *Service) newSigstoreVerifier() (*sigstore.Verifier, error) {
+	if len(s.opts.SigstoreBundleURL) == 0 || len(s.opts.SigstorePublicKeyPath) == 0 {
+		return nil, ErrIncompleteSigstoreOptions
+	}
+
+	publicKeyPEM, err := os.ReadFile(s.opts.SigstorePublicKeyPath)
+	if err != nil {
+		return nil, err
+	}
+
+	verifier, err := sigstore.NewVerifier(publicKeyPEM)
+	if err != nil {
+		return nil, err
+	}
+	verifier.HTTPClient = s.httpClient
+
+	return verifier, nil
+}
@@ -0,0 +1,76 @@
+package download_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/gkatanacio/multisource-downloader/download"
+	"github.com/gkatanacio/multisource-downloader/sigstore"
+)
+
+func Test_Service_Download_SigstoreVerify(t *testing.T) {
+	fixturesDir := filepath.Join("..", "sigstore", "testdata")
+	t.Setenv("SIGSTORE_REKOR_PUBLIC_KEY", filepath.Join(fixturesDir, "rekor.pub"))
+
+	artifact, err := os.ReadFile(filepath.Join(fixturesDir, "artifact.txt"))
+	assert.NoError(t, err)
+	bundle, err := os.ReadFile(filepath.Join(fixturesDir, "artifact.txt.bundle"))
+	assert.NoError(t, err)
+
+	bundleSrv := newTestServer(t, serveContent("artifact.txt.bundle", bundle))
+
+	testCases := map[string]struct {
+		content       []byte
+		publicKeyPath string
+		specificErr   error
+	}{
+		"verified": {
+			content:       artifact,
+			publicKeyPath: filepath.Join(fixturesDir, "cosign.pub"),
+		},
+		"tampered file": {
+			content:       bytes.ToUpper(artifact),
+			publicKeyPath: filepath.Join(fixturesDir, "cosign.pub"),
+			specificErr:   sigstore.ErrSignatureMismatch,
+		},
+		"no public key": {
+			content:     artifact,
+			specificErr: download.ErrIncompleteSigstoreOptions,
+		},
+	}
+
+	for scenario, tc := range testCases {
+		t.Run(scenario, func(t *testing.T) {
+			srv := newTestServer(t, serveContent("artifact.txt", tc.content))
+
+			destFilePath := filepath.Join(t.TempDir(), "artifact.txt")
+			downloadService := download.NewService(download.Options{
+				Connections:           2,
+				Timeout:               3,
+				Quiet:                 true,
+				DestFilePath:          destFilePath,
+				SigstoreVerify:        true,
+				SigstoreBundleURL:     bundleSrv.URL + "/artifact.txt.bundle",
+				SigstorePublicKeyPath: tc.publicKeyPath,
+			}, download.GetMD5Hash)
+
+			err := downloadService.Download([]string{srv.URL + "/artifact.txt"})
+			if tc.specificErr != nil {
+				assert.ErrorIs(t, err, tc.specificErr)
+				assert.NoFileExists(t, destFilePath)
+				assert.NoFileExists(t, destFilePath+".download")
+				return
+			}
+
+			assert.NoError(t, err)
+
+			downloaded, err := os.ReadFile(destFilePath)
+			assert.NoError(t, err)
+			assert.Equal(t, artifact, downloaded)
+		})
+	}
+}
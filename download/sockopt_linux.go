@@ -0,0 +1,36 @@
+package download
+
+import (
+	"fmt"
+	"syscall"
+)
+
+// newSocketControl returns the control function of the dialer setting SocketMark and SocketPriority
+// on the sockets (nil if neither is set).
+func newSocketControl(opts Options) func(network, address string, c syscall.RawConn) error {
+	if opts.SocketMark == 0 && opts.SocketPriority == 0 {
+		return nil
+	}
+
+	return func(_, _ string, c syscall.RawConn) error {
+		var sockErr error
+		err := c.Control(func(fd uintptr) {
+			if opts.SocketMark != 0 {
+				if err := syscall.SetsockoptInt(int(fd), syscall.SOL_SOCKET, syscall.SO_MARK, opts.SocketMark); err != nil {
+					sockErr = fmt.Errorf("failed to set SO_MARK to %d: %w", opts.SocketMark, err)
+					return
+				}
+			}
+			if opts.SocketPriority != 0 {
+				if err := syscall.SetsockoptInt(int(fd), syscall.SOL_SOCKET, syscall.SO_PRIORITY, opts.SocketPriority); err != nil {
+					sockErr = fmt.Errorf("failed to set SO_PRIORITY to %d: %w", opts.SocketPriority, err)
+				}
+			}
+		})
+		if err != nil {
+			return err
+		}
+
+		return sockErr
+	}
+}
@@ -0,0 +1,63 @@
+package download
+
+import (
+	"context"
+	"errors"
+	"net"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// listenWithSocketControl listens on a local port with the socket control of the given options
+// and returns the values of SO_MARK and SO_PRIORITY of the listener's socket.
+func listenWithSocketControl(t *testing.T, opts Options) (mark, priority int, err error) {
+	t.Helper()
+
+	lc := net.ListenConfig{Control: newSocketControl(opts)}
+	listener, err := lc.Listen(context.Background(), "tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, 0, err
+	}
+	defer listener.Close()
+
+	rawConn, err := listener.(*net.TCPListener).SyscallConn()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var markErr, priorityErr error
+	if err := rawConn.Control(func(fd uintptr) {
+		mark, markErr = syscall.GetsockoptInt(int(fd), syscall.SOL_SOCKET, syscall.SO_MARK)
+		priority, priorityErr = syscall.GetsockoptInt(int(fd), syscall.SOL_SOCKET, syscall.SO_PRIORITY)
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if markErr != nil || priorityErr != nil {
+		t.Fatal(markErr, priorityErr)
+	}
+
+	return mark, priority, nil
+}
+
+func Test_newSocketControl(t *testing.T) {
+	mark, priority, err := listenWithSocketControl(t, Options{SocketMark: 42, SocketPriority: 5})
+	if errors.Is(err, syscall.EPERM) {
+		t.Skip("setting SO_MARK requires CAP_NET_ADMIN")
+	}
+	assert.NoError(t, err)
+	assert.Equal(t, 42, mark)
+	assert.Equal(t, 5, priority)
+}
+
+func Test_newSocketControl_PriorityOnly(t *testing.T) {
+	mark, priority, err := listenWithSocketControl(t, Options{SocketPriority: 3})
+	assert.NoError(t, err)
+	assert.Equal(t, 0, mark)
+	assert.Equal(t, 3, priority)
+}
+
+func Test_newSocketControl_Unset(t *testing.T) {
+	assert.Nil(t, newSocketControl(Options{}))
+}
@@ -0,0 +1,10 @@
+//go:build !linux
+
+package download
+
+import "syscall"
+
+// newSocketControl ignores SocketMark and SocketPriority since they are not supported on this platform.
+func newSocketControl(opts Options) func(network, address string, c syscall.RawConn) error {
+	return nil
+}
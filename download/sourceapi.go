@@ -0,0 +1,71 @@
+package download
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// maxSourceAPIBytes limits the size of the response of SourceAPIURL.
+const maxSourceAPIBytes = 1 << 20 // 1 MiB
+
+// sourceAPIResponse represents the relevant part of the response of SourceAPIURL.
+type sourceAPIResponse struct {
+	URLs []string `json:"urls"`
+}
+
+// withSourceAPIUrls returns the source URLs fetched from SourceAPIURL (if set) followed by the given
+// ones, leaving out the given URLs which were fetched as well. Unlike discovered mirrors, the fetched
+// URLs are required so a failure of the API fails the download with ErrSourceAPIFailed.
+func (s *Service) withSourceAPIUrls(ctx context.Context, sourceUrls []string) ([]string, error) {
+	if len(s.opts.SourceAPIURL) == 0 {
+		return sourceUrls, nil
+	}
+
+	fetched, err := s.fetchSourceAPIUrls(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrSourceAPIFailed, err)
+	}
+	s.logDebug(fmt.Sprintf("fetched %d source URL(s) from %s", len(fetched), s.opts.SourceAPIURL))
+
+	known := make(map[string]bool, len(fetched)+len(sourceUrls))
+	urls := make([]string, 0, len(fetched)+len(sourceUrls))
+	for _, url := range append(fetched, sourceUrls...) {
+		if !known[url] {
+			known[url] = true
+			urls = append(urls, url)
+		}
+	}
+
+	return urls, nil
+}
+
+// fetchSourceAPIUrls retrieves the `urls` of the JSON response of SourceAPIURL (normalized) with the
+// HTTP client of the service and the UserAgent and PerSourceHeaders (e.g., Authorization) of the API URL.
+func (s *Service) fetchSourceAPIUrls(ctx context.Context) ([]string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.opts.SourceAPIURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	s.setSourceHeaders(req, s.opts.SourceAPIURL)
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, statusError(resp, s.opts.SourceAPIURL)
+	}
+
+	var body sourceAPIResponse
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxSourceAPIBytes)).Decode(&body); err != nil {
+		return nil, err
+	}
+
+	return normalizeURLs(body.URLs)
+}
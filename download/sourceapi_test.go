@@ -0,0 +1,95 @@
+package download_test
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/gkatanacio/multisource-downloader/download"
+)
+
+func Test_Service_Download_SourceAPIURL(t *testing.T) {
+	content := readFixture(t, "dummy.txt")
+	mirror1 := download.NewTestServer(t, content)
+	mirror2 := download.NewTestServer(t, content)
+	explicit := download.NewTestServer(t, content)
+
+	var authorization, userAgent atomic.Value
+	api := newTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization.Store(r.Header.Get("Authorization"))
+		userAgent.Store(r.Header.Get("User-Agent"))
+		assert.Equal(t, "kernel.tar.gz", r.URL.Query().Get("file"))
+
+		w.Header().Set("Content-Type", "application/json")
+		// the explicit source is listed as well, which must not make it a duplicate
+		fmt.Fprintf(w, `{"urls": [%q, %q, %q]}`, mirror1.FileURL(), mirror2.FileURL(), explicit.FileURL())
+	}))
+	apiURL := api.URL + "/api/mirrors?file=kernel.tar.gz"
+
+	destFilePath := filepath.Join(t.TempDir(), "dummy.txt")
+	downloadService := download.NewService(download.Options{
+		Connections:      3,
+		Timeout:          3,
+		Quiet:            true,
+		DestFilePath:     destFilePath,
+		SourceAPIURL:     apiURL,
+		UserAgent:        "msdl-test",
+		PerSourceHeaders: map[string]map[string]string{apiURL: {"Authorization": "Bearer secret"}},
+	}, download.GetMD5Hash)
+
+	err := downloadService.Download([]string{explicit.FileURL()})
+	assert.NoError(t, err)
+
+	assert.Equal(t, "Bearer secret", authorization.Load())
+	assert.Equal(t, "msdl-test", userAgent.Load())
+
+	var sources []string
+	for _, ss := range downloadService.LastDownloadStats().Sources {
+		sources = append(sources, ss.URL)
+	}
+	assert.Equal(t, []string{mirror1.FileURL(), mirror2.FileURL(), explicit.FileURL()}, sources)
+
+	downloaded, err := os.ReadFile(destFilePath)
+	assert.NoError(t, err)
+	assert.Equal(t, content, downloaded)
+}
+
+func Test_Service_Download_SourceAPIURL_Failed(t *testing.T) {
+	content := readFixture(t, "dummy.txt")
+	explicit := download.NewTestServer(t, content)
+
+	testCases := map[string]http.HandlerFunc{
+		"error status": func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusUnauthorized)
+		},
+		"invalid JSON": func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, `{"urls": [`)
+		},
+		"invalid URL": func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, `{"urls": ["not a url"]}`)
+		},
+	}
+
+	for name, handler := range testCases {
+		t.Run(name, func(t *testing.T) {
+			api := newTestServer(t, handler)
+
+			downloadService := download.NewService(download.Options{
+				Connections:  1,
+				Timeout:      3,
+				Quiet:        true,
+				DestFilePath: filepath.Join(t.TempDir(), "dummy.txt"),
+				SourceAPIURL: api.URL,
+			}, download.GetMD5Hash)
+
+			err := downloadService.Download([]string{explicit.FileURL()})
+			assert.ErrorIs(t, err, download.ErrSourceAPIFailed)
+			assert.Zero(t, explicit.Requests())
+		})
+	}
+}
@@ -0,0 +1,60 @@
+package download
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// SourceInfo represents the metadata reported by a source (or the error if it is unhealthy).
+type SourceInfo struct {
+	URL         string        `json:"url"`
+	Size        int64         `json:"size"`
+	ContentType string        `json:"content_type"`
+	ETag        string        `json:"etag"`
+	Latency     time.Duration `json:"latency"`
+	Err         error         `json:"-"`
+}
+
+// Healthy returns true if the source can be used for downloading.
+func (si SourceInfo) Healthy() bool {
+	return si.Err == nil
+}
+
+// ValidateSources fetches the file metadata from each of the given sources concurrently. Unlike
+// Download, unhealthy sources do not cause a failure but are reported with their error instead.
+// The results are in the same order as the given sources (with normalized URLs).
+func (s *Service) ValidateSources(ctx context.Context, sourceUrls []string) []SourceInfo {
+	infos := make([]SourceInfo, len(sourceUrls))
+
+	var wg sync.WaitGroup
+	for i, url := range sourceUrls {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			infos[i] = SourceInfo{URL: url}
+
+			url, err := NormalizeURL(url)
+			if err != nil {
+				infos[i].Err = err
+				return
+			}
+			infos[i].URL = url
+
+			sfm, err := s.fetchFileMetadata(ctx, url)
+			if err != nil {
+				infos[i].Err = err
+				return
+			}
+
+			infos[i].Size = sfm.size
+			infos[i].ContentType = sfm.contentType
+			infos[i].ETag = sfm.eTag
+			infos[i].Latency = sfm.estLatency
+		}()
+	}
+	wg.Wait()
+
+	return infos
+}
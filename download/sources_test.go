@@ -0,0 +1,41 @@
+package download_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/gkatanacio/multisource-downloader/download"
+)
+
+func Test_Service_ValidateSources(t *testing.T) {
+	content := readFixture(t, "dummy.txt")
+	healthySrv := newTestServer(t, serveContentWithETag("dummy.txt", content))
+	unhealthySrv := newTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+
+	sourceUrls := []string{healthySrv.URL + "/dummy.txt", unhealthySrv.URL + "/dummy.txt", healthySrv.URL + "/dummy.txt"}
+
+	downloadService := download.NewService(download.Options{Connections: 1, Timeout: 3, Quiet: true}, download.GetMD5Hash)
+	infos := downloadService.ValidateSources(context.Background(), sourceUrls)
+
+	assert.Len(t, infos, len(sourceUrls))
+	for i, info := range infos {
+		assert.Equal(t, sourceUrls[i], info.URL)
+	}
+
+	for _, info := range []download.SourceInfo{infos[0], infos[2]} {
+		assert.True(t, info.Healthy())
+		assert.Equal(t, int64(len(content)), info.Size)
+		assert.Equal(t, "text/plain; charset=utf-8", info.ContentType)
+		assert.Len(t, info.ETag, 32)
+		assert.Greater(t, info.Latency, time.Duration(0))
+	}
+
+	assert.False(t, infos[1].Healthy())
+	assert.ErrorContains(t, infos[1].Err, "503")
+}
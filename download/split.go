@@ -0,0 +1,100 @@
+package download
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// SplitFile writes the contents of the source file as consecutive parts of the given size (except for
+// the last one) to the destination directory, named `<basename>.part000`, `<basename>.part001` and so
+// on, and returns their paths in order. An empty file results in a single empty part. Like the hash
+// calculations, the contents are streamed in blocks so that the memory usage does not depend on the
+// part size.
+func SplitFile(src string, chunkSize int64, destDir string) ([]string, error) {
+	if chunkSize <= 0 {
+		return nil, ErrInvalidPartSize
+	}
+
+	srcFile, err := os.Open(src)
+	if err != nil {
+		return nil, err
+	}
+	defer srcFile.Close()
+
+	fileInfo, err := srcFile.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(destDir, 0755); err != nil {
+		return nil, err
+	}
+
+	r := bufio.NewReaderSize(srcFile, hashBufferSize)
+	numParts := max((fileInfo.Size()+chunkSize-1)/chunkSize, 1)
+
+	parts := make([]string, 0, numParts)
+	for i := range numParts {
+		part := filepath.Join(destDir, fmt.Sprintf("%s.part%03d", filepath.Base(src), i))
+		if err := writePart(part, r, chunkSize); err != nil {
+			return nil, err
+		}
+		parts = append(parts, part)
+	}
+
+	return parts, nil
+}
+
+// writePart writes up to the given number of bytes from the reader to the file at the given path.
+func writePart(path string, r io.Reader, size int64) error {
+	partFile, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer partFile.Close()
+
+	if _, err := io.CopyN(partFile, r, size); err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+
+	return partFile.Close()
+}
+
+// MergeFiles concatenates the given files in order into the destination file (which is overwritten if
+// it exists), e.g., the parts written by SplitFile.
+func MergeFiles(parts []string, dest string) error {
+	destFile, err := os.Create(dest)
+	if err != nil {
+		return err
+	}
+	defer destFile.Close()
+
+	w := bufio.NewWriterSize(destFile, hashBufferSize)
+	for _, part := range parts {
+		if err := appendPart(w, part); err != nil {
+			return err
+		}
+	}
+
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	return destFile.Close()
+}
+
+// appendPart copies the contents of the file at the given path to the writer.
+func appendPart(w io.Writer, path string) error {
+	partFile, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer partFile.Close()
+
+	_, err = io.Copy(w, bufio.NewReaderSize(partFile, hashBufferSize))
+	return err
+}
@@ -0,0 +1,67 @@
+package download_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/gkatanacio/multisource-downloader/download"
+)
+
+func Test_SplitFile_MergeFiles(t *testing.T) {
+	content := readFixture(t, "dummy.png") // 5000 bytes
+	src := writeTempFile(t, "dummy.png", content)
+
+	testCases := map[string]struct {
+		chunkSize     int64
+		expectedParts int
+		lastPartSize  int64
+	}{
+		"exact parts": {
+			chunkSize:     1000,
+			expectedParts: 5,
+			lastPartSize:  1000,
+		},
+		"shorter last part": {
+			chunkSize:     1024,
+			expectedParts: 5,
+			lastPartSize:  904,
+		},
+		"single part": {
+			chunkSize:     10000,
+			expectedParts: 1,
+			lastPartSize:  5000,
+		},
+	}
+
+	for scenario, tc := range testCases {
+		t.Run(scenario, func(t *testing.T) {
+			destDir := filepath.Join(t.TempDir(), "parts")
+
+			parts, err := download.SplitFile(src, tc.chunkSize, destDir)
+			assert.NoError(t, err)
+			assert.Len(t, parts, tc.expectedParts)
+			assert.Equal(t, filepath.Join(destDir, "dummy.png.part000"), parts[0])
+
+			lastPart, err := os.Stat(parts[len(parts)-1])
+			assert.NoError(t, err)
+			assert.Equal(t, tc.lastPartSize, lastPart.Size())
+
+			merged := filepath.Join(t.TempDir(), "merged.png")
+			assert.NoError(t, download.MergeFiles(parts, merged))
+
+			mergedContent, err := os.ReadFile(merged)
+			assert.NoError(t, err)
+			assert.Equal(t, content, mergedContent)
+		})
+	}
+}
+
+func Test_SplitFile_InvalidPartSize(t *testing.T) {
+	src := writeTempFile(t, "dummy.txt", readFixture(t, "dummy.txt"))
+
+	_, err := download.SplitFile(src, 0, t.TempDir())
+	assert.ErrorIs(t, err, download.ErrInvalidPartSize)
+}
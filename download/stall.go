@@ -0,0 +1,38 @@
+package download
+
+import (
+	"context"
+	"io"
+	"time"
+)
+
+// stallDetector wraps a response body and cancels the context of the request with ErrChunkStalled
+// if no bytes are read within the timeout since the body was opened or since the last read.
+type stallDetector struct {
+	r       io.Reader
+	timeout time.Duration
+	timer   *time.Timer
+}
+
+// newStallDetector returns a stallDetector for the given reader, whose timer starts right away.
+func newStallDetector(r io.Reader, timeout time.Duration, cancel context.CancelCauseFunc) *stallDetector {
+	return &stallDetector{
+		r:       r,
+		timeout: timeout,
+		timer:   time.AfterFunc(timeout, func() { cancel(ErrChunkStalled) }),
+	}
+}
+
+// Read implements io.Reader.
+func (sd *stallDetector) Read(p []byte) (int, error) {
+	n, err := sd.r.Read(p)
+	if n > 0 {
+		sd.timer.Reset(sd.timeout)
+	}
+	return n, err
+}
+
+// stop releases the timer once the body has been read.
+func (sd *stallDetector) stop() {
+	sd.timer.Stop()
+}
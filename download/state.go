@@ -0,0 +1,226 @@
+package download
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+const (
+	suffixDownloadState           = ".download.state"
+	suffixCompressedDownloadState = ".download.state.zst"
+
+	// the state file is rewritten once this many chunks were completed since the last write or once
+	// stateFlushInterval elapsed since then, whichever comes first
+	stateFlushChunks   = 16
+	stateFlushInterval = time.Second
+)
+
+var (
+	zstdEncoder = sync.OnceValues(func() (*zstd.Encoder, error) {
+		return zstd.NewWriter(nil, zstd.WithEncoderConcurrency(1))
+	})
+	zstdDecoder = sync.OnceValues(func() (*zstd.Decoder, error) {
+		return zstd.NewReader(nil, zstd.WithDecoderConcurrency(1))
+	})
+)
+
+// DownloadState represents the progress of an ongoing download which is persisted
+// alongside the `.download` file so that an interrupted download can be resumed.
+type DownloadState struct {
+	Size            int64  `json:"size"`
+	ETag            string `json:"etag"`
+	ChunkSize       int64  `json:"chunk_size"`
+	CompletedChunks []int  `json:"completed_chunks"`
+}
+
+// isCompatible returns true if the state was recorded for a file with the given metadata.
+func (ds *DownloadState) isCompatible(fileMetadata fileMetadata) bool {
+	return ds.Size == fileMetadata.size && ds.ETag == fileMetadata.eTag && ds.ChunkSize > 0
+}
+
+// findDownloadState returns the path of the existing state file for the given destination file,
+// preferring the compressed state file over the uncompressed one (written by prior versions).
+func findDownloadState(destFilePath string) (string, error) {
+	for _, suffix := range []string{suffixCompressedDownloadState, suffixDownloadState} {
+		if _, err := os.Stat(destFilePath + suffix); err == nil {
+			return destFilePath + suffix, nil
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			return "", err
+		}
+	}
+
+	return "", fs.ErrNotExist
+}
+
+// readDownloadState loads the download state from the given file which is decompressed
+// if it has the `.zst` extension.
+func readDownloadState(filePath string) (*DownloadState, error) {
+	b, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	if isCompressedStateFile(filePath) {
+		decoder, err := zstdDecoder()
+		if err != nil {
+			return nil, err
+		}
+
+		if b, err = decoder.DecodeAll(b, nil); err != nil {
+			return nil, err
+		}
+	}
+
+	var state DownloadState
+	if err := json.Unmarshal(b, &state); err != nil {
+		return nil, err
+	}
+
+	return &state, nil
+}
+
+// writeDownloadState persists the download state to the given file. The state is written to
+// a temporary file first so that an interruption never leaves a partially written state file.
+func writeDownloadState(filePath string, state *DownloadState) error {
+	b, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+
+	if isCompressedStateFile(filePath) {
+		encoder, err := zstdEncoder()
+		if err != nil {
+			return err
+		}
+
+		b = encoder.EncodeAll(b, nil)
+	}
+
+	if err := os.WriteFile(filePath+".tmp", b, 0644); err != nil {
+		return err
+	}
+
+	return os.Rename(filePath+".tmp", filePath)
+}
+
+// isCompressedStateFile returns true if the state file is meant to be compressed based on its name.
+func isCompressedStateFile(filePath string) bool {
+	return strings.HasSuffix(filePath, suffixCompressedDownloadState)
+}
+
+// chunkTracker keeps track of the completed chunks of a download and persists the progress to the
+// state file in batches as chunks get completed (see stateFlushChunks), so that the whole state file is
+// not rewritten for every chunk. Resuming redownloads at most the chunks completed after the last write.
+type chunkTracker struct {
+	mu        sync.Mutex
+	state     DownloadState
+	completed map[int]bool
+	filePath  string
+	unflushed int // number of completed chunks not persisted yet
+	flushedAt time.Time
+
+	writeMu sync.Mutex // serializes the writes of the state file which happen outside of mu
+}
+
+// newChunkTracker returns a tracker for the given state which is persisted to the given file
+// (if not empty, e.g., not for DownloadRange and DownloadTo).
+func newChunkTracker(state DownloadState, filePath string) *chunkTracker {
+	completed := make(map[int]bool)
+	for _, i := range state.CompletedChunks {
+		completed[i] = true
+	}
+
+	return &chunkTracker{
+		state:     state,
+		completed: completed,
+		filePath:  filePath,
+	}
+}
+
+// isCompleted returns true if the chunk with the given index is already completed.
+func (ct *chunkTracker) isCompleted(chunkIdx int) bool {
+	ct.mu.Lock()
+	defer ct.mu.Unlock()
+
+	return ct.completed[chunkIdx]
+}
+
+// markCompleted records the chunk with the given index as completed and persists the state if a
+// batch is due. The chunks completed since the last write are persisted by flush.
+func (ct *chunkTracker) markCompleted(chunkIdx int) error {
+	ct.mu.Lock()
+	ct.completed[chunkIdx] = true
+	ct.state.CompletedChunks = append(ct.state.CompletedChunks, chunkIdx)
+	ct.unflushed++
+	due := ct.unflushed >= stateFlushChunks || time.Since(ct.flushedAt) >= stateFlushInterval
+	ct.mu.Unlock()
+
+	if !due {
+		return nil
+	}
+	return ct.flush()
+}
+
+// flush writes the current state to the state file if any completed chunks were not persisted yet.
+func (ct *chunkTracker) flush() error {
+	if len(ct.filePath) == 0 {
+		return nil
+	}
+
+	ct.writeMu.Lock()
+	defer ct.writeMu.Unlock()
+
+	ct.mu.Lock()
+	if ct.unflushed == 0 {
+		ct.mu.Unlock()
+		return nil
+	}
+	state := ct.snapshot()
+	ct.mu.Unlock()
+
+	return writeDownloadState(ct.filePath, &state)
+}
+
+// persist writes the current state to the state file.
+func (ct *chunkTracker) persist() error {
+	ct.writeMu.Lock()
+	defer ct.writeMu.Unlock()
+
+	ct.mu.Lock()
+	state := ct.snapshot()
+	ct.mu.Unlock()
+
+	return writeDownloadState(ct.filePath, &state)
+}
+
+// snapshot returns a copy of the state to be written to the state file and resets the batch.
+// It must be called with mu held.
+func (ct *chunkTracker) snapshot() DownloadState {
+	ct.unflushed = 0
+	ct.flushedAt = time.Now()
+
+	state := ct.state
+	state.CompletedChunks = slices.Clone(ct.state.CompletedChunks)
+	return state
+}
+
+// discardOngoingDownload closes and removes the `.download` file along with its state file.
+func discardOngoingDownload(file *os.File, tracker *chunkTracker) error {
+	if err := file.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Remove(file.Name()); err != nil {
+		return err
+	}
+
+	return os.Remove(tracker.filePath)
+}
@@ -0,0 +1,84 @@
+package download
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_DownloadState_RoundTrip(t *testing.T) {
+	state := &DownloadState{
+		Size:            1 << 30,
+		ETag:            "0a1b2c3d",
+		ChunkSize:       1 << 10,
+		CompletedChunks: []int{0, 3, 1, 2, 1000, 42},
+	}
+
+	testCases := map[string]struct {
+		suffix string
+	}{
+		"uncompressed": {
+			suffix: suffixDownloadState,
+		},
+		"compressed": {
+			suffix: suffixCompressedDownloadState,
+		},
+	}
+
+	for scenario, tc := range testCases {
+		t.Run(scenario, func(t *testing.T) {
+			destFilePath := filepath.Join(t.TempDir(), "dummy.txt")
+			stateFilePath := destFilePath + tc.suffix
+
+			err := writeDownloadState(stateFilePath, state)
+			assert.NoError(t, err)
+
+			foundFilePath, err := findDownloadState(destFilePath)
+			assert.NoError(t, err)
+			assert.Equal(t, stateFilePath, foundFilePath)
+
+			readState, err := readDownloadState(foundFilePath)
+			assert.NoError(t, err)
+			assert.Equal(t, state, readState)
+
+			b, err := os.ReadFile(stateFilePath)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.suffix == suffixDownloadState, bytes.HasPrefix(b, []byte("{")))
+		})
+	}
+}
+
+func Test_findDownloadState_NotFound(t *testing.T) {
+	_, err := findDownloadState(filepath.Join(t.TempDir(), "dummy.txt"))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func Test_chunkTracker_BatchedWrites(t *testing.T) {
+	stateFilePath := filepath.Join(t.TempDir(), "dummy.txt"+suffixDownloadState)
+	tracker := newChunkTracker(DownloadState{Size: 1 << 20, ChunkSize: 1 << 10}, stateFilePath)
+	assert.NoError(t, tracker.persist())
+
+	persistedChunks := func() int {
+		state, err := readDownloadState(stateFilePath)
+		assert.NoError(t, err)
+		return len(state.CompletedChunks)
+	}
+
+	for i := 0; i < stateFlushChunks-1; i++ {
+		assert.NoError(t, tracker.markCompleted(i))
+	}
+	assert.Equal(t, 0, persistedChunks())
+
+	// the batch is complete
+	assert.NoError(t, tracker.markCompleted(stateFlushChunks-1))
+	assert.Equal(t, stateFlushChunks, persistedChunks())
+
+	assert.NoError(t, tracker.markCompleted(stateFlushChunks))
+	assert.Equal(t, stateFlushChunks, persistedChunks())
+
+	assert.NoError(t, tracker.flush())
+	assert.Equal(t, stateFlushChunks+1, persistedChunks())
+}
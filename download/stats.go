@@ -0,0 +1,94 @@
+package download
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DownloadStats represents the contribution of each source to a download.
+type DownloadStats struct {
+	Sources []SourceStats
+}
+
+// SourceStats represents the contribution of a single source to a download. The average chunk
+// duration only considers the successfully delivered chunks.
+type SourceStats struct {
+	URL                string
+	ChunksDelivered    int
+	BytesDelivered     int64
+	Errors             int
+	AvgChunkDurationMs float64
+}
+
+// sourceCounters holds the counters of a single source which are updated concurrently by the chunk goroutines.
+type sourceCounters struct {
+	chunks   atomic.Int64
+	bytes    atomic.Int64
+	errors   atomic.Int64
+	duration atomic.Int64 // total duration of the delivered chunks in nanoseconds
+}
+
+// sourceStatsCollector accumulates the chunk download attempts per source during a download.
+type sourceStatsCollector struct {
+	urls     []string
+	counters sync.Map // source URL -> *sourceCounters
+}
+
+// newSourceStatsCollector returns a collector with empty stats for the given sources.
+func newSourceStatsCollector(urls []string) *sourceStatsCollector {
+	ssc := &sourceStatsCollector{urls: urls}
+	for _, url := range urls {
+		ssc.counters.Store(url, &sourceCounters{})
+	}
+
+	return ssc
+}
+
+// addSource adds a source discovered after the collector was created (e.g., from a metalink).
+// It must not be called concurrently with downloadStats.
+func (ssc *sourceStatsCollector) addSource(url string) {
+	ssc.urls = append(ssc.urls, url)
+	ssc.counters.Store(url, &sourceCounters{})
+}
+
+// countersFor returns the counters of the given source.
+func (ssc *sourceStatsCollector) countersFor(url string) *sourceCounters {
+	counters, _ := ssc.counters.LoadOrStore(url, &sourceCounters{})
+	return counters.(*sourceCounters)
+}
+
+// chunkDownloaded records a chunk of the given size successfully downloaded from the source.
+func (ssc *sourceStatsCollector) chunkDownloaded(url string, size int, duration time.Duration) {
+	counters := ssc.countersFor(url)
+	counters.chunks.Add(1)
+	counters.bytes.Add(int64(size))
+	counters.duration.Add(int64(duration))
+}
+
+// chunkFailed records a failed chunk download attempt from the source.
+func (ssc *sourceStatsCollector) chunkFailed(url string) {
+	ssc.countersFor(url).errors.Add(1)
+}
+
+// downloadStats returns a snapshot of the stats of each source in the original order of sources.
+func (ssc *sourceStatsCollector) downloadStats() *DownloadStats {
+	stats := &DownloadStats{Sources: make([]SourceStats, 0, len(ssc.urls))}
+	for _, url := range ssc.urls {
+		counters := ssc.countersFor(url)
+
+		ss := SourceStats{
+			URL:             url,
+			ChunksDelivered: int(counters.chunks.Load()),
+			BytesDelivered:  counters.bytes.Load(),
+			Errors:          int(counters.errors.Load()),
+		}
+		if ss.ChunksDelivered > 0 {
+			avg := time.Duration(counters.duration.Load()) / time.Duration(ss.ChunksDelivered)
+			ss.AvgChunkDurationMs = float64(avg) / float64(time.Millisecond)
+		}
+		stats.Sources = append(stats.Sources, ss)
+	}
+
+	return stats
+}
@@ -0,0 +1,140 @@
+package download
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+var errStreamingUnsupported = errors.New("fetcher does not support files of unknown length")
+
+// streamingFetcher can be implemented by a Fetcher which is able to retrieve a whole file whose
+// length is unknown (see AllowUnknownLength).
+type streamingFetcher interface {
+	// GetStream returns the body of the whole file from the given source URL along with the ETag
+	// of the response (empty if there is none).
+	GetStream(ctx context.Context, url string) (io.ReadCloser, string, error)
+}
+
+// getStream retrieves the whole file from the fetcher if it supports it.
+func getStream(ctx context.Context, fetcher Fetcher, url string) (io.ReadCloser, string, error) {
+	sf, ok := fetcher.(streamingFetcher)
+	if !ok {
+		return nil, "", errStreamingUnsupported
+	}
+
+	return sf.GetStream(ctx, url)
+}
+
+// GetStream implements streamingFetcher.
+func (hf *httpFetcher) GetStream(ctx context.Context, url string) (io.ReadCloser, string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	hf.setSourceHeaders(req, url)
+
+	resp, err := hf.client.Do(req)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		return nil, "", statusError(resp, url)
+	}
+
+	return resp.Body, strings.Trim(resp.Header.Get("ETag"), `"`), nil
+}
+
+// GetStream implements streamingFetcher.
+func (sf *schemeFetcher) GetStream(ctx context.Context, sourceUrl string) (io.ReadCloser, string, error) {
+	return getStream(ctx, sf.fetcherFor(sourceUrl), sourceUrl)
+}
+
+// GetStream implements streamingFetcher.
+func (rf *routingFetcher) GetStream(ctx context.Context, url string) (io.ReadCloser, string, error) {
+	return getStream(ctx, rf.ms.serviceFor(url).fetcher, url)
+}
+
+// streamFileContents downloads the whole file with a single connection and writes it sequentially
+// to the given writer, which is used when the length of the file is unknown so that it cannot be
+// split into chunks. The source URLs are attempted in order until one of them responds, but the
+// download fails if the response breaks off since the bytes already written cannot be taken back.
+// This returns the number of bytes written along with the ETag of the response (empty if there is none).
+func (s *Service) streamFileContents(ctx context.Context, sourceUrls []string, w io.Writer, stats *sourceStatsCollector) (int64, string, error) {
+	var body io.ReadCloser
+	var eTag, url string
+	var err error
+	for _, url = range sourceUrls {
+		if body, eTag, err = getStream(ctx, s.fetcher, url); err == nil {
+			break
+		}
+
+		stats.chunkFailed(url)
+		s.logln(fmt.Sprintf("warning: failed to download file of unknown length from %s: %v", url, err))
+	}
+	if err != nil {
+		return 0, "", fmt.Errorf("%w: %w", ErrFailedChunkDownloadAllSources, err)
+	}
+	defer body.Close()
+
+	var r io.Reader = body
+	if s.opts.MaxFileSize > 0 {
+		r = io.LimitReader(body, s.opts.MaxFileSize)
+	}
+
+	start := time.Now()
+	n, err := io.Copy(w, r)
+	if err != nil {
+		stats.chunkFailed(url)
+		return 0, "", fmt.Errorf("failed to download file of unknown length from %s: %w", url, err)
+	}
+
+	// a single byte beyond the limit tells whether the file is larger without writing it
+	if s.opts.MaxFileSize > 0 && n == s.opts.MaxFileSize {
+		if extra, _ := io.CopyN(io.Discard, body, 1); extra > 0 {
+			return 0, "", FileTooLargeError{Size: -1, Limit: s.opts.MaxFileSize}
+		}
+	}
+
+	stats.chunkDownloaded(url, int(n), time.Since(start))
+	s.logln(fmt.Sprintf("file of unknown length (%d bytes) downloaded from %s", n, url))
+
+	return n, eTag, nil
+}
+
+// streamOngoingDownload streams the file of unknown length into the `.download` file (from scratch even
+// when resuming since nothing can be reused without chunks) and to the tee writer if given. The size of
+// the file metadata is updated along with its ETag if the response includes one.
+func (s *Service) streamOngoingDownload(ctx context.Context, sourceUrls []string, fileMetadata *fileMetadata, destFile *os.File, stats *sourceStatsCollector, teeWriter io.Writer) error {
+	if err := destFile.Truncate(0); err != nil {
+		return err
+	}
+	if _, err := destFile.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	var w io.Writer = destFile
+	if teeWriter != nil {
+		w = io.MultiWriter(destFile, teeWriter)
+	}
+
+	size, eTag, err := s.streamFileContents(ctx, sourceUrls, w, stats)
+	if err != nil {
+		return err
+	}
+
+	fileMetadata.size = size
+	if len(eTag) > 0 {
+		fileMetadata.eTag = eTag
+	}
+
+	return nil
+}
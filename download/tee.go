@@ -0,0 +1,76 @@
+package download
+
+import (
+	"io"
+	"sync"
+)
+
+// chunkTee writes the downloaded chunks to a secondary writer in order. Since chunks are
+// downloaded concurrently, chunks completed out of order are held until all preceding
+// chunks have been written.
+type chunkTee struct {
+	mu        sync.Mutex
+	w         io.Writer
+	next      int
+	pending   map[int][]byte
+	destFile  io.ReaderAt
+	tracker   *chunkTracker
+	chunkSize int64
+	size      int64
+}
+
+// newChunkTee returns a chunkTee writing to the given writer. Chunks which were already completed
+// before the download started (i.e., when resuming) are read back from the destination file.
+func newChunkTee(w io.Writer, destFile io.ReaderAt, tracker *chunkTracker, size int64) *chunkTee {
+	return &chunkTee{
+		w:         w,
+		pending:   make(map[int][]byte),
+		destFile:  destFile,
+		tracker:   tracker,
+		chunkSize: tracker.state.ChunkSize,
+		size:      size,
+	}
+}
+
+// deliver hands over the chunk with the given index and writes all chunks which are now in order.
+// This must be called before the chunk is marked as completed in the tracker.
+func (ct *chunkTee) deliver(i int, chunk []byte) error {
+	ct.mu.Lock()
+	defer ct.mu.Unlock()
+
+	ct.pending[i] = chunk
+
+	return ct.writeInOrder()
+}
+
+// flush writes any remaining chunks which are in order (e.g., when all chunks were already
+// completed before the download started) and is meant to be called once all chunks are done.
+func (ct *chunkTee) flush() error {
+	ct.mu.Lock()
+	defer ct.mu.Unlock()
+
+	return ct.writeInOrder()
+}
+
+// writeInOrder writes the chunks following the last written one for as long as they are available.
+func (ct *chunkTee) writeInOrder() error {
+	for offset := int64(ct.next) * ct.chunkSize; offset < ct.size; offset += ct.chunkSize {
+		if chunk, ok := ct.pending[ct.next]; ok {
+			if _, err := ct.w.Write(chunk); err != nil {
+				return err
+			}
+			delete(ct.pending, ct.next)
+		} else if ct.tracker.isCompleted(ct.next) {
+			limit := min(offset+ct.chunkSize, ct.size)
+			if _, err := io.Copy(ct.w, io.NewSectionReader(ct.destFile, offset, limit-offset)); err != nil {
+				return err
+			}
+		} else {
+			break
+		}
+
+		ct.next++
+	}
+
+	return nil
+}
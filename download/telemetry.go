@@ -0,0 +1,38 @@
+package download
+
+import (
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "github.com/gkatanacio/multisource-downloader/download"
+
+// newTracer returns the tracer from the given provider or from the global provider if nil.
+func newTracer(tp trace.TracerProvider) trace.Tracer {
+	if tp == nil {
+		tp = otel.GetTracerProvider()
+	}
+
+	return tp.Tracer(tracerName)
+}
+
+// recordSpanError marks the span as failed if there is an error and returns the error as is.
+func recordSpanError(span trace.Span, err error) error {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+
+	return err
+}
+
+// chunkAttributes returns the span attributes describing a chunk.
+func chunkAttributes(chunkIdx int, offset, size int64) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		attribute.Int("chunk.index", chunkIdx),
+		attribute.Int64("chunk.offset", offset),
+		attribute.Int64("chunk.size", size),
+	}
+}
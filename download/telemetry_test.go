@@ -0,0 +1,71 @@
+package download_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"github.com/gkatanacio/multisource-downloader/download"
+)
+
+func Test_Service_Download_Tracing(t *testing.T) {
+	content := readFixture(t, "dummy.txt")
+	srv := newTestServer(t, serveContent("dummy.txt", content))
+
+	spanRecorder := tracetest.NewSpanRecorder()
+	tracerProvider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spanRecorder))
+
+	downloadService := download.NewService(download.Options{
+		Connections:    2,
+		Timeout:        3,
+		Quiet:          true,
+		DestFilePath:   filepath.Join(t.TempDir(), "dummy.txt"),
+		TracerProvider: tracerProvider,
+	}, download.GetMD5Hash)
+
+	err := downloadService.Download([]string{srv.URL + "/dummy.txt"})
+	assert.NoError(t, err)
+
+	spansByName := make(map[string][]sdktrace.ReadOnlySpan)
+	for _, span := range spanRecorder.Ended() {
+		spansByName[span.Name()] = append(spansByName[span.Name()], span)
+	}
+
+	assert.Len(t, spansByName["Download"], 1)
+	assert.Len(t, spansByName["fetchFileMetadataFromSources"], 1)
+	assert.Len(t, spansByName["downloadFileContents"], 1)
+	assert.Len(t, spansByName["chunk"], 2)
+
+	rootSpan := spansByName["Download"][0]
+	assert.False(t, rootSpan.Parent().IsValid())
+	assert.Equal(t, rootSpan.SpanContext().SpanID(), spansByName["fetchFileMetadataFromSources"][0].Parent().SpanID())
+
+	contentsSpan := spansByName["downloadFileContents"][0]
+	assert.Equal(t, rootSpan.SpanContext().SpanID(), contentsSpan.Parent().SpanID())
+
+	var chunkOffsets []int64
+	for _, chunkSpan := range spansByName["chunk"] {
+		assert.Equal(t, contentsSpan.SpanContext().SpanID(), chunkSpan.Parent().SpanID())
+
+		attrs := attribute.NewSet(chunkSpan.Attributes()...)
+		source, ok := attrs.Value("chunk.source")
+		assert.True(t, ok)
+		assert.Equal(t, srv.URL+"/dummy.txt", source.AsString())
+
+		size, ok := attrs.Value("chunk.size")
+		assert.True(t, ok)
+		assert.Equal(t, int64(len(content)/2), size.AsInt64())
+
+		_, ok = attrs.Value("chunk.index")
+		assert.True(t, ok)
+
+		offset, ok := attrs.Value("chunk.offset")
+		assert.True(t, ok)
+		chunkOffsets = append(chunkOffsets, offset.AsInt64())
+	}
+	assert.ElementsMatch(t, []int64{0, int64(len(content) / 2)}, chunkOffsets)
+}
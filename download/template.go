@@ -0,0 +1,36 @@
+package download
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+)
+
+// templateVarPattern matches the `{KEY}` placeholders in a URL template.
+var templateVarPattern = regexp.MustCompile(`\{([A-Za-z0-9_]+)\}`)
+
+// TemplateExpand replaces the `{KEY}` placeholders in the template with the corresponding
+// (URL path escaped) values from vars. An error is returned for any undefined variable.
+func TemplateExpand(template string, vars map[string]string) (string, error) {
+	var undefinedVar string
+
+	expanded := templateVarPattern.ReplaceAllStringFunc(template, func(placeholder string) string {
+		key := templateVarPattern.FindStringSubmatch(placeholder)[1]
+
+		value, ok := vars[key]
+		if !ok {
+			if len(undefinedVar) == 0 {
+				undefinedVar = key
+			}
+			return placeholder
+		}
+
+		return url.PathEscape(value)
+	})
+
+	if len(undefinedVar) > 0 {
+		return "", fmt.Errorf("%w: %s", ErrUndefinedTemplateVar, undefinedVar)
+	}
+
+	return expanded, nil
+}
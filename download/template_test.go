@@ -0,0 +1,67 @@
+package download_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/gkatanacio/multisource-downloader/download"
+)
+
+func Test_TemplateExpand(t *testing.T) {
+	testCases := map[string]struct {
+		template    string
+		vars        map[string]string
+		expectedUrl string
+		specificErr error
+	}{
+		"multiple variables": {
+			template:    "https://mirror.example.com/v{VERSION}/linux_{ARCH}/file.tar.gz",
+			vars:        map[string]string{"VERSION": "1.2.3", "ARCH": "amd64"},
+			expectedUrl: "https://mirror.example.com/v1.2.3/linux_amd64/file.tar.gz",
+		},
+		"repeated variable": {
+			template:    "https://mirror.example.com/{ARCH}/file_{ARCH}.tar.gz",
+			vars:        map[string]string{"ARCH": "arm64"},
+			expectedUrl: "https://mirror.example.com/arm64/file_arm64.tar.gz",
+		},
+		"no variables": {
+			template:    "https://mirror.example.com/file.tar.gz",
+			expectedUrl: "https://mirror.example.com/file.tar.gz",
+		},
+		"unused variables": {
+			template:    "https://mirror.example.com/{ARCH}/file.tar.gz",
+			vars:        map[string]string{"ARCH": "arm64", "VERSION": "1.2.3"},
+			expectedUrl: "https://mirror.example.com/arm64/file.tar.gz",
+		},
+		"special characters are escaped": {
+			template:    "https://mirror.example.com/{NAME}/file.tar.gz",
+			vars:        map[string]string{"NAME": "my file/with?special#chars"},
+			expectedUrl: "https://mirror.example.com/my%20file%2Fwith%3Fspecial%23chars/file.tar.gz",
+		},
+		"undefined variable": {
+			template:    "https://mirror.example.com/v{VERSION}/linux_{ARCH}/file.tar.gz",
+			vars:        map[string]string{"VERSION": "1.2.3"},
+			specificErr: download.ErrUndefinedTemplateVar,
+		},
+	}
+
+	for scenario, tc := range testCases {
+		t.Run(scenario, func(t *testing.T) {
+			expanded, err := download.TemplateExpand(tc.template, tc.vars)
+
+			if tc.specificErr != nil {
+				assert.ErrorIs(t, err, tc.specificErr)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectedUrl, expanded)
+		})
+	}
+}
+
+func Test_TemplateExpand_UndefinedVariableName(t *testing.T) {
+	_, err := download.TemplateExpand("https://mirror.example.com/{ARCH}/file.tar.gz", nil)
+	assert.ErrorContains(t, err, "ARCH")
+}
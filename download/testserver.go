@@ -0,0 +1,137 @@
+package download
+
+import (
+	"bytes"
+	"crypto/md5"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// testServerFilePath is the path of the file served by a TestServer.
+const testServerFilePath = "/file"
+
+// TestServer is an HTTP server for integration tests (e.g., of the download service) serving a single
+// file at `/file` with its Content-Length, Content-Type and ETag (i.e., the MD5 hex digest of its content)
+// and support for Range requests. Latency, errors and flakiness can be simulated for chaos testing.
+type TestServer struct {
+	*httptest.Server
+
+	content []byte
+	eTag    string
+
+	mu             sync.RWMutex
+	latency        time.Duration
+	errStatusCode  int
+	errAfterBytes  int64
+	flakyFailRate  float64
+	servedBytes    atomic.Int64
+	servedRequests atomic.Int64
+}
+
+// NewTestServer starts a TestServer serving the given content which is closed when the test finishes.
+func NewTestServer(t *testing.T, fileContent []byte) *TestServer {
+	t.Helper()
+
+	ts := &TestServer{
+		content: fileContent,
+		eTag:    fmt.Sprintf("%x", md5.Sum(fileContent)),
+	}
+	ts.Server = httptest.NewServer(http.HandlerFunc(ts.serveHTTP))
+	t.Cleanup(ts.Close)
+
+	return ts
+}
+
+// FileURL returns the URL of the served file.
+func (ts *TestServer) FileURL() string {
+	return ts.URL + testServerFilePath
+}
+
+// ETag returns the ETag of the served file (without quotes).
+func (ts *TestServer) ETag() string {
+	return ts.eTag
+}
+
+// Requests returns the number of requests for the file received so far (including the failed ones).
+func (ts *TestServer) Requests() int64 {
+	return ts.servedRequests.Load()
+}
+
+// SimulateLatency delays every response by the given duration (zero disables this).
+func (ts *TestServer) SimulateLatency(d time.Duration) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	ts.latency = d
+}
+
+// SimulateError makes every request received once the given number of bytes of the file have been
+// served (zero meaning right away) fail with the given status code (zero disables this).
+func (ts *TestServer) SimulateError(statusCode int, afterBytes int64) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	ts.errStatusCode = statusCode
+	ts.errAfterBytes = afterBytes
+}
+
+// SimulateFlakiness makes each GET request fail with 503 Service Unavailable at the given rate (between 0
+// and 1) so that the chunks need to be retried, whereas HEAD requests keep succeeding (zero disables this).
+func (ts *TestServer) SimulateFlakiness(failRate float64) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	ts.flakyFailRate = failRate
+}
+
+func (ts *TestServer) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != testServerFilePath {
+		http.NotFound(w, r)
+		return
+	}
+	ts.servedRequests.Add(1)
+
+	ts.mu.RLock()
+	latency, errStatusCode, errAfterBytes, flakyFailRate := ts.latency, ts.errStatusCode, ts.errAfterBytes, ts.flakyFailRate
+	ts.mu.RUnlock()
+
+	if latency > 0 {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-time.After(latency):
+		}
+	}
+
+	if errStatusCode > 0 && ts.servedBytes.Load() >= errAfterBytes {
+		w.WriteHeader(errStatusCode)
+		return
+	}
+
+	if r.Method == http.MethodGet && flakyFailRate > 0 && rand.Float64() < flakyFailRate {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+
+	w.Header().Set("ETag", fmt.Sprintf(`"%s"`, ts.eTag))
+	w.Header().Set("Content-Type", http.DetectContentType(ts.content))
+	http.ServeContent(&countingResponseWriter{ResponseWriter: w, n: &ts.servedBytes}, r, "", time.Time{}, bytes.NewReader(ts.content))
+}
+
+// countingResponseWriter adds the number of bytes of the body written to it to the given counter.
+type countingResponseWriter struct {
+	http.ResponseWriter
+	n *atomic.Int64
+}
+
+func (crw *countingResponseWriter) Write(p []byte) (int, error) {
+	n, err := crw.ResponseWriter.Write(p)
+	crw.n.Add(int64(n))
+	return n, err
+}
@@ -0,0 +1,118 @@
+package download_test
+
+import (
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/gkatanacio/multisource-downloader/download"
+)
+
+func Test_TestServer_Headers(t *testing.T) {
+	content := readFixture(t, "dummy.png")
+	srv := download.NewTestServer(t, content)
+
+	resp, err := http.Head(srv.FileURL())
+	assert.NoError(t, err)
+	resp.Body.Close()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "bytes", resp.Header.Get("Accept-Ranges"))
+	assert.Equal(t, strconv.Itoa(len(content)), resp.Header.Get("Content-Length"))
+	assert.Equal(t, "image/png", resp.Header.Get("Content-Type"))
+	assert.Equal(t, `"`+srv.ETag()+`"`, resp.Header.Get("ETag"))
+
+	req, err := http.NewRequest(http.MethodGet, srv.FileURL(), nil)
+	assert.NoError(t, err)
+	req.Header.Set("Range", download.FormatRangeHeader(100, 199))
+
+	resp, err = http.DefaultClient.Do(req)
+	assert.NoError(t, err)
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusPartialContent, resp.StatusCode)
+	assert.Equal(t, content[100:200], body)
+}
+
+func Test_TestServer_SimulateLatency(t *testing.T) {
+	srv := download.NewTestServer(t, []byte("0123456789"))
+	srv.SimulateLatency(100 * time.Millisecond)
+
+	start := time.Now()
+	resp, err := http.Get(srv.FileURL())
+	assert.NoError(t, err)
+	resp.Body.Close()
+
+	assert.GreaterOrEqual(t, time.Since(start), 100*time.Millisecond)
+}
+
+func Test_TestServer_SimulateError(t *testing.T) {
+	content := readFixture(t, "dummy.txt")
+	srv := download.NewTestServer(t, content)
+	srv.SimulateError(http.StatusBadGateway, 1000)
+
+	destFilePath := filepath.Join(t.TempDir(), "dummy.txt")
+	downloadService := download.NewService(download.Options{
+		Connections:  1,
+		Timeout:      3,
+		Quiet:        true,
+		DestFilePath: destFilePath,
+		ChunkBytes:   500,
+	}, download.GetMD5Hash)
+
+	// the third chunk is requested once 1000 bytes have been served
+	err := downloadService.Download([]string{srv.FileURL()})
+	assert.ErrorIs(t, err, download.ErrFailedChunkDownloadAllSources)
+
+	// the download is resumed once the server recovers, i.e., with a HEAD request and the 5 remaining chunks
+	srv.SimulateError(0, 0)
+	requests := srv.Requests()
+	downloadService = downloadService.WithOptions(download.Options{Resume: true})
+	assert.NoError(t, downloadService.Download([]string{srv.FileURL()}))
+	assert.Equal(t, int64(6), srv.Requests()-requests)
+
+	downloaded, err := os.ReadFile(destFilePath)
+	assert.NoError(t, err)
+	assert.Equal(t, content, downloaded)
+}
+
+func Test_TestServer_SimulateFlakiness(t *testing.T) {
+	content := readFixture(t, "dummy.png")
+	flaky := download.NewTestServer(t, content)
+	flaky.SimulateFlakiness(0.5)
+	stable := download.NewTestServer(t, content)
+
+	destFilePath := filepath.Join(t.TempDir(), "dummy.png")
+	downloadService := download.NewService(download.Options{
+		Connections:  4,
+		Timeout:      3,
+		Quiet:        true,
+		DestFilePath: destFilePath,
+		CheckETag:    true,
+		ChunkBytes:   100,
+	}, download.GetMD5Hash)
+
+	// the chunks failing from the flaky source are retried from the stable one
+	err := downloadService.Download([]string{flaky.FileURL(), stable.FileURL()})
+	assert.NoError(t, err)
+
+	downloaded, err := os.ReadFile(destFilePath)
+	assert.NoError(t, err)
+	assert.Equal(t, content, downloaded)
+
+	var flakyErrors int
+	for _, ss := range downloadService.LastDownloadStats().Sources {
+		if ss.URL == flaky.FileURL() {
+			flakyErrors = ss.Errors
+		}
+	}
+	assert.Positive(t, flakyErrors)
+}
@@ -0,0 +1,76 @@
+package download
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// redactedHeaders are the headers whose values are not logged in verbose mode.
+var redactedHeaders = []string{"Authorization", "Proxy-Authorization"}
+
+// loggingRoundTripper logs the headers of each request and response passing through it.
+type loggingRoundTripper struct {
+	next http.RoundTripper
+	mu   sync.Mutex
+	out  io.Writer
+}
+
+// newLoggingRoundTripper returns a round-tripper logging to the given writer which passes
+// the requests on to the given transport (or http.DefaultTransport if nil).
+func newLoggingRoundTripper(next http.RoundTripper, out io.Writer) *loggingRoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+
+	return &loggingRoundTripper{
+		next: next,
+		out:  out,
+	}
+}
+
+// RoundTrip implements http.RoundTripper.
+func (rt *loggingRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	rt.log(fmt.Sprintf("> %s %s", req.Method, req.URL), req.Header, ">")
+
+	resp, err := rt.next.RoundTrip(req)
+	if err != nil {
+		rt.log(fmt.Sprintf("< %s %s: %v", req.Method, req.URL, err), nil, "<")
+		return nil, err
+	}
+
+	rt.log(fmt.Sprintf("< %s %s %s", resp.Status, req.Method, req.URL), resp.Header, "<")
+
+	return resp, nil
+}
+
+// log writes the summary line followed by the headers (in sorted order) with the given prefix.
+// Each entry is written at once so that entries from concurrent requests do not interleave.
+func (rt *loggingRoundTripper) log(summary string, header http.Header, prefix string) {
+	var sb strings.Builder
+	sb.WriteString(summary + "\n")
+
+	keys := make([]string, 0, len(header))
+	for k := range header {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		value := strings.Join(header.Values(k), ", ")
+		for _, redacted := range redactedHeaders {
+			if http.CanonicalHeaderKey(k) == redacted {
+				value = "[REDACTED]"
+			}
+		}
+		sb.WriteString(fmt.Sprintf("%s %s: %s\n", prefix, k, value))
+	}
+
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+
+	io.WriteString(rt.out, sb.String())
+}
@@ -0,0 +1,63 @@
+package download
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_loggingRoundTripper(t *testing.T) {
+	content := []byte("0123456789")
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Served-By", "test-server")
+		http.ServeContent(w, r, "digits.txt", time.Time{}, bytes.NewReader(content))
+	}))
+	defer srv.Close()
+
+	s := NewService(Options{
+		Connections:  1,
+		Timeout:      3,
+		Quiet:        true,
+		DestFilePath: filepath.Join(t.TempDir(), "digits.txt"),
+	}, nil)
+
+	var logs bytes.Buffer
+	s.httpClient.Transport = newLoggingRoundTripper(s.httpClient.Transport, &logs)
+
+	err := s.Download([]string{srv.URL + "/digits.txt"})
+	assert.NoError(t, err)
+
+	output := logs.String()
+	assert.Contains(t, output, "> HEAD "+srv.URL+"/digits.txt")
+	assert.Contains(t, output, "> GET "+srv.URL+"/digits.txt")
+	assert.Contains(t, output, "> Range: bytes=0-9")
+	assert.Contains(t, output, "< 200 OK HEAD "+srv.URL+"/digits.txt")
+	assert.Contains(t, output, "< 206 Partial Content GET "+srv.URL+"/digits.txt")
+	assert.Contains(t, output, "< Content-Range: bytes 0-9/10")
+	assert.Contains(t, output, "< X-Served-By: test-server")
+}
+
+func Test_loggingRoundTripper_RedactsAuthorization(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	var logs bytes.Buffer
+	client := &http.Client{Transport: newLoggingRoundTripper(nil, &logs)}
+
+	req, err := http.NewRequest(http.MethodGet, srv.URL, nil)
+	assert.NoError(t, err)
+	req.Header.Set("Authorization", "Bearer secret-token")
+
+	resp, err := client.Do(req)
+	assert.NoError(t, err)
+	resp.Body.Close()
+
+	assert.Contains(t, logs.String(), "> Authorization: [REDACTED]")
+	assert.NotContains(t, logs.String(), "secret-token")
+}
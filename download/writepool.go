@@ -0,0 +1,64 @@
+package download
+
+import (
+	"context"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// chunkWrite represents a downloaded chunk which is pending to be written.
+type chunkWrite struct {
+	index  int
+	offset int64
+	chunk  []byte
+	buf    *chunkBuffer // the pooled buffer backing the chunk (if any)
+	source int          // index of the source which delivered the chunk
+}
+
+// writePool is a pool of workers which write the downloaded chunks separately from the
+// goroutines downloading them.
+type writePool struct {
+	queue chan chunkWrite
+}
+
+// startWritePool starts the given number of workers in the errgroup which consume the queued
+// chunks (up to the given queue depth) with the given write function until the pool is closed.
+// The workers stop as soon as the context is done (e.g., if another goroutine in the group fails).
+func startWritePool(eg *errgroup.Group, ctx context.Context, workers, queueDepth uint, write func(chunkWrite) error) *writePool {
+	wp := &writePool{queue: make(chan chunkWrite, queueDepth)}
+
+	for range workers {
+		eg.Go(func() error {
+			for {
+				select {
+				case <-ctx.Done():
+					return ctx.Err()
+				case cw, ok := <-wp.queue:
+					if !ok {
+						return nil
+					}
+					if err := write(cw); err != nil {
+						return err
+					}
+				}
+			}
+		})
+	}
+
+	return wp
+}
+
+// submit queues the chunk for writing and blocks if the queue is full until the context is done.
+func (wp *writePool) submit(ctx context.Context, cw chunkWrite) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case wp.queue <- cw:
+		return nil
+	}
+}
+
+// close signals the workers that no more chunks will be submitted.
+func (wp *writePool) close() {
+	close(wp.queue)
+}
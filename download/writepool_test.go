@@ -0,0 +1,61 @@
+package download
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/sync/errgroup"
+)
+
+func Test_writePool_BoundedWorkers(t *testing.T) {
+	const workers = 3
+
+	var active, maxActive, written atomic.Int64
+	write := func(cw chunkWrite) error {
+		n := active.Add(1)
+		defer active.Add(-1)
+
+		for {
+			m := maxActive.Load()
+			if n <= m || maxActive.CompareAndSwap(m, n) {
+				break
+			}
+		}
+
+		time.Sleep(5 * time.Millisecond)
+		written.Add(1)
+		return nil
+	}
+
+	eg, ctx := errgroup.WithContext(context.Background())
+	pool := startWritePool(eg, ctx, workers, 2, write)
+
+	for i := range 30 {
+		assert.NoError(t, pool.submit(ctx, chunkWrite{index: i}))
+	}
+	pool.close()
+
+	assert.NoError(t, eg.Wait())
+	assert.Equal(t, int64(30), written.Load())
+	assert.Equal(t, int64(workers), maxActive.Load())
+}
+
+func Test_writePool_WriteErrorCancels(t *testing.T) {
+	writeErr := errors.New("disk full")
+
+	eg, ctx := errgroup.WithContext(context.Background())
+	pool := startWritePool(eg, ctx, 1, 0, func(cw chunkWrite) error {
+		return writeErr
+	})
+
+	assert.NoError(t, pool.submit(ctx, chunkWrite{index: 0}))
+
+	// further submissions fail once the write error cancelled the context
+	<-ctx.Done()
+	assert.ErrorIs(t, pool.submit(ctx, chunkWrite{index: 1}), context.Canceled)
+	assert.ErrorIs(t, eg.Wait(), writeErr)
+}
@@ -0,0 +1,142 @@
+package download
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// WriterAtCloser is a destination which the chunks can be written to at their offsets in any order
+// (e.g., a multipart upload to cloud storage) and which is closed once all of them are written.
+type WriterAtCloser interface {
+	io.WriterAt
+	io.Closer
+}
+
+// readerWriterAt is a destination which the written chunks can be read back from (e.g., for verifying pieces).
+type readerWriterAt interface {
+	io.ReaderAt
+	io.WriterAt
+}
+
+// DownloadTo downloads the file from the given sources in a concurrent manner (i.e., in chunks) like
+// Download but writes the chunks to the given destination instead of DestFilePath and closes it once
+// the download is successfully completed (i.e., it is left open on failure). The download cannot be
+// resumed and the ETag (if CheckETag is enabled) is calculated from the downloaded bytes in order like
+// with StreamingVerification. PieceHashes require a destination implementing io.ReaderAt (otherwise
+// ErrDestinationNotReadable is returned) whereas SegmentedTempFiles and the options acting on the
+// downloaded file (e.g., WriteHashFile) do not apply.
+func (s *Service) DownloadTo(ctx context.Context, sourceUrls []string, dst WriterAtCloser) error {
+	ctx, span := s.tracer.Start(ctx, "DownloadTo", trace.WithAttributes(
+		attribute.StringSlice("download.sources", sourceUrls),
+	))
+	defer span.End()
+
+	sourceUrls, err := normalizeURLs(sourceUrls)
+	if err != nil {
+		return recordSpanError(span, err)
+	}
+
+	if sourceUrls, err = s.withSourceAPIUrls(ctx, sourceUrls); err != nil {
+		return recordSpanError(span, err)
+	}
+
+	if len(sourceUrls) == 0 {
+		return recordSpanError(span, ErrNoSourceUrls)
+	}
+
+	stats := newSourceStatsCollector(sourceUrls)
+	defer func() {
+		s.lastStats.Store(stats.downloadStats())
+	}()
+
+	if err := s.downloadTo(ctx, sourceUrls, dst, stats); err != nil {
+		return recordSpanError(span, err)
+	}
+
+	return recordSpanError(span, dst.Close())
+}
+
+// downloadTo contains the actual logic of DownloadTo (which expects validated arguments).
+func (s *Service) downloadTo(ctx context.Context, sourceUrls []string, dst io.WriterAt, stats *sourceStatsCollector) error {
+	srcFileMetas, err := s.fetchFileMetadataFromSources(ctx, sourceUrls)
+	if err != nil {
+		return err
+	}
+	srcFileMetas = s.withDiscoveredMirrors(ctx, srcFileMetas, stats)
+
+	if !allSourcesMatchFileMetadata(srcFileMetas, s.opts.CheckETag, !s.opts.LooseContentTypeMatch) {
+		return ErrSourcesFileMismatch
+	}
+	if s.opts.BenchmarkBeforeDownload {
+		s.benchmarkThroughput(ctx, srcFileMetas)
+	}
+
+	fileMetadata := srcFileMetas[0].fileMetadata // any will do since they are assumed to be matching
+
+	// the whole destination is read again for a custom ETag calculator
+	hasher := streamingHasherFor(s.calculateETag)
+	readableDst, _ := dst.(readerWriterAt)
+	if hasher == nil && s.opts.CheckETag && readableDst == nil {
+		return fmt.Errorf("%w: required for CheckETag with a custom ETag calculator", ErrDestinationNotReadable)
+	}
+	checkETag := s.opts.CheckETag && len(fileMetadata.eTag) > 0
+
+	if fileMetadata.size == -1 {
+		// the hash is calculated regardless since only the response may include the ETag
+		var w io.Writer = io.NewOffsetWriter(dst, 0)
+		if hasher != nil {
+			w = io.MultiWriter(w, hasher)
+		}
+		if s.opts.TeeWriter != nil {
+			w = io.MultiWriter(w, s.opts.TeeWriter)
+		}
+
+		n, eTag, err := s.streamFileContents(ctx, sourceUrlsSortedByEstLatency(srcFileMetas), w, stats)
+		if err != nil {
+			return err
+		}
+		fileMetadata.size = n
+		if len(eTag) > 0 {
+			fileMetadata.eTag = eTag
+		}
+		checkETag = s.opts.CheckETag && len(fileMetadata.eTag) > 0
+	} else {
+		var teeWriter io.Writer
+		switch {
+		case hasher == nil:
+			teeWriter = s.opts.TeeWriter
+		case checkETag && s.opts.TeeWriter != nil:
+			teeWriter = io.MultiWriter(s.opts.TeeWriter, hasher)
+		case checkETag:
+			teeWriter = hasher
+		default:
+			teeWriter = s.opts.TeeWriter
+		}
+
+		tracker := newChunkTracker(DownloadState{Size: fileMetadata.size, ChunkSize: s.chunkSize(fileMetadata.size)}, "")
+		if err := s.downloadFileContents(ctx, sourceUrlsSortedByEstLatency(srcFileMetas), s.newSourceSelector(srcFileMetas), fileMetadata, dst, tracker, stats, teeWriter); err != nil {
+			return err
+		}
+	}
+
+	if checkETag {
+		var calculatedETag string
+		if hasher != nil {
+			calculatedETag = fmt.Sprintf("%x", hasher.Sum(nil))
+		} else if calculatedETag, err = s.calculateETag(io.NewSectionReader(readableDst, 0, fileMetadata.size)); err != nil {
+			return err
+		}
+
+		if calculatedETag != fileMetadata.eTag {
+			return ErrETagMismatch
+		}
+	}
+
+	s.logln("Download complete")
+
+	return nil
+}
@@ -0,0 +1,185 @@
+package download_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/gkatanacio/multisource-downloader/download"
+)
+
+// mapWriterAt keeps the written chunks keyed by their offsets.
+type mapWriterAt struct {
+	chunks sync.Map // int64 -> []byte
+	closed atomic.Bool
+}
+
+func (w *mapWriterAt) WriteAt(p []byte, off int64) (int, error) {
+	w.chunks.Store(off, bytes.Clone(p)) // the chunk buffers may be reused
+	return len(p), nil
+}
+
+func (w *mapWriterAt) Close() error {
+	w.closed.Store(true)
+	return nil
+}
+
+// bytes places the written chunks at their offsets, failing if any of them overlap or leave gaps.
+func (w *mapWriterAt) bytes(t *testing.T, size int) []byte {
+	t.Helper()
+
+	b := make([]byte, size)
+	written := make([]bool, size)
+	w.chunks.Range(func(key, value any) bool {
+		off, chunk := key.(int64), value.([]byte)
+		for i := range chunk {
+			if !assert.False(t, written[int(off)+i], "byte %d written more than once", int(off)+i) {
+				return false
+			}
+			written[int(off)+i] = true
+		}
+		copy(b[off:], chunk)
+		return true
+	})
+	assert.NotContains(t, written, false, "not all bytes written")
+
+	return b
+}
+
+func Test_Service_DownloadTo(t *testing.T) {
+	content := readFixture(t, "dummy.png")
+
+	testCases := map[string]struct {
+		opts           download.Options
+		eTag           bool
+		expectedChunks int
+	}{
+		"chunks": {
+			opts:           download.Options{Connections: 4},
+			expectedChunks: 4,
+		},
+		"chunk bytes": {
+			opts:           download.Options{Connections: 2, ChunkBytes: 1024, ChunkBufferPool: true},
+			expectedChunks: 5,
+		},
+		"whole file": {
+			opts:           download.Options{Connections: 4, MinChunkBytes: 8192},
+			expectedChunks: 1,
+		},
+		"ETag": {
+			opts:           download.Options{Connections: 4, CheckETag: true},
+			eTag:           true,
+			expectedChunks: 4,
+		},
+	}
+
+	for scenario, tc := range testCases {
+		t.Run(scenario, func(t *testing.T) {
+			handler := serveContent("dummy.png", content)
+			if tc.eTag {
+				handler = serveContentWithETag("dummy.png", content)
+			}
+			srv1 := newTestServer(t, handler)
+			srv2 := newTestServer(t, handler)
+
+			var tee bytes.Buffer
+			opts := tc.opts
+			opts.Timeout = 3
+			opts.Quiet = true
+			opts.TeeWriter = &tee
+
+			dst := &mapWriterAt{}
+			err := download.NewService(opts, download.GetMD5Hash).DownloadTo(context.Background(), []string{srv1.URL + "/dummy.png", srv2.URL + "/dummy.png"}, dst)
+			assert.NoError(t, err)
+
+			assert.True(t, dst.closed.Load())
+			assert.Equal(t, content, dst.bytes(t, len(content)))
+			assert.Equal(t, content, tee.Bytes())
+
+			var chunks int
+			dst.chunks.Range(func(any, any) bool {
+				chunks++
+				return true
+			})
+			assert.Equal(t, tc.expectedChunks, chunks)
+		})
+	}
+}
+
+func Test_Service_DownloadTo_ETagMismatch(t *testing.T) {
+	content := readFixture(t, "dummy.txt")
+
+	srv := newTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", fmt.Sprintf(`"%x"`, md5.Sum([]byte("something else"))))
+		serveContent("dummy.txt", content)(w, r)
+	}))
+
+	dst := &mapWriterAt{}
+	err := download.NewService(download.Options{
+		Connections: 4,
+		Timeout:     3,
+		Quiet:       true,
+		CheckETag:   true,
+	}, download.GetMD5Hash).DownloadTo(context.Background(), []string{srv.URL + "/dummy.txt"}, dst)
+	assert.ErrorIs(t, err, download.ErrETagMismatch)
+	assert.False(t, dst.closed.Load(), "destination closed despite the failure")
+}
+
+func Test_Service_DownloadTo_PieceHashesRequireReaderAt(t *testing.T) {
+	content := readFixture(t, "dummy.txt")
+	srv := newTestServer(t, serveContent("dummy.txt", content))
+
+	dst := &mapWriterAt{}
+	err := download.NewService(download.Options{
+		Connections: 4,
+		Timeout:     3,
+		Quiet:       true,
+		PieceSize:   1024,
+		PieceHashes: []string{"00", "00", "00", "00"},
+	}, download.GetMD5Hash).DownloadTo(context.Background(), []string{srv.URL + "/dummy.txt"}, dst)
+	assert.ErrorIs(t, err, download.ErrDestinationNotReadable)
+}
+
+func Test_Service_DownloadTo_CustomETagCalculator(t *testing.T) {
+	content := readFixture(t, "dummy.txt")
+	srv := newTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", fmt.Sprintf(`"%x"`, sha256.Sum256(content)))
+		serveContent("dummy.txt", content)(w, r)
+	}))
+
+	// custom calculators need to read the destination again
+	calculateETag := func(r io.ReadSeeker) (string, error) {
+		return download.GetSHA256Hash(r)
+	}
+	newService := func() *download.Service {
+		return download.NewService(download.Options{
+			Connections: 4,
+			Timeout:     3,
+			Quiet:       true,
+			CheckETag:   true,
+		}, calculateETag)
+	}
+
+	err := newService().DownloadTo(context.Background(), []string{srv.URL + "/dummy.txt"}, &mapWriterAt{})
+	assert.ErrorIs(t, err, download.ErrDestinationNotReadable)
+
+	dst, err := os.Create(filepath.Join(t.TempDir(), "dummy.txt"))
+	assert.NoError(t, err)
+	err = newService().DownloadTo(context.Background(), []string{srv.URL + "/dummy.txt"}, dst)
+	assert.NoError(t, err)
+
+	downloaded, err := os.ReadFile(dst.Name())
+	assert.NoError(t, err)
+	assert.Equal(t, content, downloaded)
+}
@@ -0,0 +1,136 @@
+// Package geo estimates the geographic distance to source servers using a MaxMind GeoLite2
+// (or GeoIP2) City database.
+package geo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"os"
+
+	"github.com/oschwald/geoip2-golang"
+)
+
+// earthRadiusKm is the mean radius of the Earth.
+const earthRadiusKm = 6371.0
+
+var ErrLocationUnknown = errors.New("location of IP address is unknown")
+
+// Location represents geographic coordinates in degrees.
+type Location struct {
+	Latitude  float64
+	Longitude float64
+}
+
+// Locator returns the geographic location of IP addresses.
+type Locator interface {
+	Locate(ip net.IP) (Location, error)
+}
+
+// Database is a Locator backed by a MaxMind City database.
+type Database struct {
+	reader *geoip2.Reader
+}
+
+// OpenDatabase loads the MaxMind City database (e.g., GeoLite2-City.mmdb) at the given path.
+func OpenDatabase(path string) (*Database, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	reader, err := geoip2.FromBytes(b)
+	if err != nil {
+		return nil, fmt.Errorf("invalid GeoIP database %s: %w", path, err)
+	}
+
+	return &Database{reader: reader}, nil
+}
+
+// Locate implements Locator.
+func (db *Database) Locate(ip net.IP) (Location, error) {
+	city, err := db.reader.City(ip)
+	if err != nil {
+		return Location{}, err
+	}
+
+	// private and unlisted addresses have no coordinates at all
+	if city.Location.Latitude == 0 && city.Location.Longitude == 0 {
+		return Location{}, fmt.Errorf("%w: %s", ErrLocationUnknown, ip)
+	}
+
+	return Location{Latitude: city.Location.Latitude, Longitude: city.Location.Longitude}, nil
+}
+
+// Distance returns the great-circle distance in km between the two locations.
+func Distance(a, b Location) float64 {
+	lat1, lat2 := radians(a.Latitude), radians(b.Latitude)
+	dLat := lat2 - lat1
+	dLon := radians(b.Longitude - a.Longitude)
+
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLon/2)*math.Sin(dLon/2)
+
+	return 2 * earthRadiusKm * math.Asin(math.Sqrt(h))
+}
+
+// radians converts the angle from degrees to radians.
+func radians(degrees float64) float64 {
+	return degrees * math.Pi / 180
+}
+
+// Scorer scores hosts by their distance from the location of the local host.
+type Scorer struct {
+	locator  Locator
+	resolver *net.Resolver
+	origin   Location
+}
+
+// NewScorer returns a Scorer measuring the distance from the location of the given local IP address.
+func NewScorer(locator Locator, localIP net.IP) (*Scorer, error) {
+	origin, err := locator.Locate(localIP)
+	if err != nil {
+		return nil, fmt.Errorf("failed to locate local IP address: %w", err)
+	}
+
+	return &Scorer{locator: locator, resolver: net.DefaultResolver, origin: origin}, nil
+}
+
+// DistanceKm returns the distance in km from the local host to the given host (either a name
+// or an IP address). The first IP address of the host which can be located is used.
+func (s *Scorer) DistanceKm(ctx context.Context, host string) (float64, error) {
+	ips := []net.IP{net.ParseIP(host)}
+	if ips[0] == nil {
+		addrs, err := s.resolver.LookupIPAddr(ctx, host)
+		if err != nil {
+			return 0, err
+		}
+
+		ips = ips[:0]
+		for _, addr := range addrs {
+			ips = append(ips, addr.IP)
+		}
+	}
+
+	for _, ip := range ips {
+		if location, err := s.locator.Locate(ip); err == nil {
+			return Distance(s.origin, location), nil
+		}
+	}
+
+	return 0, fmt.Errorf("%w: %s", ErrLocationUnknown, host)
+}
+
+// LocalIP returns the IP address of the local interface used for outbound traffic. No packets
+// are sent since only a UDP socket is set up. Note that this is usually a private address if the
+// host is behind NAT, in which case it cannot be located.
+func LocalIP() (net.IP, error) {
+	conn, err := net.Dial("udp", "8.8.8.8:53")
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	return conn.LocalAddr().(*net.UDPAddr).IP, nil
+}
@@ -0,0 +1,91 @@
+package geo_test
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/gkatanacio/multisource-downloader/geo"
+)
+
+// mockLocator locates the IP addresses based on a fixed mapping.
+type mockLocator map[string]geo.Location
+
+func (ml mockLocator) Locate(ip net.IP) (geo.Location, error) {
+	location, ok := ml[ip.String()]
+	if !ok {
+		return geo.Location{}, geo.ErrLocationUnknown
+	}
+
+	return location, nil
+}
+
+var (
+	london = geo.Location{Latitude: 51.5074, Longitude: -0.1278}
+	paris  = geo.Location{Latitude: 48.8566, Longitude: 2.3522}
+	tokyo  = geo.Location{Latitude: 35.6762, Longitude: 139.6503}
+)
+
+func Test_Distance(t *testing.T) {
+	testCases := map[string]struct {
+		a, b               geo.Location
+		expectedDistanceKm float64
+	}{
+		"same location": {
+			a:                  london,
+			b:                  london,
+			expectedDistanceKm: 0,
+		},
+		"London to Paris": {
+			a:                  london,
+			b:                  paris,
+			expectedDistanceKm: 344,
+		},
+		"Paris to Tokyo": {
+			a:                  paris,
+			b:                  tokyo,
+			expectedDistanceKm: 9712,
+		},
+	}
+
+	for scenario, tc := range testCases {
+		t.Run(scenario, func(t *testing.T) {
+			assert.InDelta(t, tc.expectedDistanceKm, geo.Distance(tc.a, tc.b), 5)
+			assert.InDelta(t, geo.Distance(tc.a, tc.b), geo.Distance(tc.b, tc.a), 0.001)
+		})
+	}
+}
+
+func Test_Scorer_DistanceKm(t *testing.T) {
+	locator := mockLocator{
+		"192.0.2.1":    london,
+		"198.51.100.1": paris,
+		"203.0.113.1":  tokyo,
+	}
+
+	scorer, err := geo.NewScorer(locator, net.ParseIP("192.0.2.1"))
+	assert.NoError(t, err)
+
+	distanceKm, err := scorer.DistanceKm(context.Background(), "198.51.100.1")
+	assert.NoError(t, err)
+	assert.InDelta(t, 344, distanceKm, 5)
+
+	distanceKm, err = scorer.DistanceKm(context.Background(), "203.0.113.1")
+	assert.NoError(t, err)
+	assert.InDelta(t, 9559, distanceKm, 5)
+
+	_, err = scorer.DistanceKm(context.Background(), "192.0.2.99")
+	assert.ErrorIs(t, err, geo.ErrLocationUnknown)
+}
+
+func Test_NewScorer_UnknownLocalIP(t *testing.T) {
+	_, err := geo.NewScorer(mockLocator{}, net.ParseIP("10.0.0.1"))
+	assert.ErrorIs(t, err, geo.ErrLocationUnknown)
+}
+
+func Test_OpenDatabase_Invalid(t *testing.T) {
+	_, err := geo.OpenDatabase("does-not-exist.mmdb")
+	assert.Error(t, err)
+}
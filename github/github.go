@@ -0,0 +1,86 @@
+// Package github resolves the download URLs of GitHub Release assets via the GitHub REST API (v3).
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+)
+
+// defaultAPIBaseURL is the base URL of the GitHub REST API.
+const defaultAPIBaseURL = "https://api.github.com"
+
+// maxReleaseBytes limits the size of the release returned by the API.
+const maxReleaseBytes = 10 << 20 // 10 MiB
+
+var (
+	ErrReleaseNotFound = errors.New("release not found")
+	ErrAssetNotFound   = errors.New("release asset not found")
+)
+
+// release represents the relevant part of a release returned by the API.
+type release struct {
+	Assets []asset `json:"assets"`
+}
+
+// asset represents the relevant part of a release asset returned by the API.
+type asset struct {
+	Name               string `json:"name"`
+	BrowserDownloadURL string `json:"browser_download_url"`
+}
+
+// ResolveAssetURL queries the GitHub API for the release of the given repository with the given tag
+// (or the latest release if the tag is `latest`) and returns the `browser_download_url` of its asset
+// with the given name. The token (if any) is sent as a bearer token, e.g., to raise the rate limit
+// or to access private repositories.
+func ResolveAssetURL(ctx context.Context, owner, repo, tag, assetName, token string) (string, error) {
+	return resolveAssetURL(ctx, http.DefaultClient, defaultAPIBaseURL, owner, repo, tag, assetName, token)
+}
+
+// resolveAssetURL is the same as ResolveAssetURL but uses the given client and API base URL.
+func resolveAssetURL(ctx context.Context, client *http.Client, apiBaseURL, owner, repo, tag, assetName, token string) (string, error) {
+	releaseURL := fmt.Sprintf("%s/repos/%s/%s/releases/tags/%s", apiBaseURL, url.PathEscape(owner), url.PathEscape(repo), url.PathEscape(tag))
+	if tag == "latest" {
+		releaseURL = fmt.Sprintf("%s/repos/%s/%s/releases/latest", apiBaseURL, url.PathEscape(owner), url.PathEscape(repo))
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, releaseURL, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
+	if len(token) > 0 {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return "", fmt.Errorf("%w: %s/%s@%s", ErrReleaseNotFound, owner, repo, tag)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("received %d response from %s", resp.StatusCode, releaseURL)
+	}
+
+	var rel release
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxReleaseBytes)).Decode(&rel); err != nil {
+		return "", fmt.Errorf("invalid release from %s: %w", releaseURL, err)
+	}
+
+	for _, a := range rel.Assets {
+		if a.Name == assetName {
+			return a.BrowserDownloadURL, nil
+		}
+	}
+
+	return "", fmt.Errorf("%w: %s in %s/%s@%s", ErrAssetNotFound, assetName, owner, repo, tag)
+}
@@ -0,0 +1,108 @@
+package github
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testRelease = `{
+	"tag_name": "v1.2.0",
+	"assets": [
+		{"name": "msdl_linux_amd64.tar.gz", "browser_download_url": "https://github.com/octo/msdl/releases/download/v1.2.0/msdl_linux_amd64.tar.gz"},
+		{"name": "msdl_darwin_arm64.tar.gz", "browser_download_url": "https://github.com/octo/msdl/releases/download/v1.2.0/msdl_darwin_arm64.tar.gz"}
+	]
+}`
+
+// newTestAPI returns a fake GitHub API serving testRelease as both the release tagged v1.2.0 and
+// the latest release of octo/msdl, recording the Authorization header of the last request.
+func newTestAPI(t *testing.T, receivedAuthorization *string) *httptest.Server {
+	t.Helper()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*receivedAuthorization = r.Header.Get("Authorization")
+
+		switch r.URL.Path {
+		case "/repos/octo/msdl/releases/tags/v1.2.0", "/repos/octo/msdl/releases/latest":
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(testRelease))
+		case "/repos/octo/private/releases/tags/v1.2.0":
+			w.WriteHeader(http.StatusForbidden)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	return srv
+}
+
+func Test_resolveAssetURL(t *testing.T) {
+	var receivedAuthorization string
+	srv := newTestAPI(t, &receivedAuthorization)
+
+	testCases := map[string]struct {
+		repo                  string
+		tag                   string
+		assetName             string
+		token                 string
+		expectedURL           string
+		expectedErr           error
+		expectedErrContains   string
+		expectedAuthorization string
+	}{
+		"tagged release": {
+			repo:        "msdl",
+			tag:         "v1.2.0",
+			assetName:   "msdl_darwin_arm64.tar.gz",
+			expectedURL: "https://github.com/octo/msdl/releases/download/v1.2.0/msdl_darwin_arm64.tar.gz",
+		},
+		"latest release with token": {
+			repo:                  "msdl",
+			tag:                   "latest",
+			assetName:             "msdl_linux_amd64.tar.gz",
+			token:                 "ghp_secret",
+			expectedURL:           "https://github.com/octo/msdl/releases/download/v1.2.0/msdl_linux_amd64.tar.gz",
+			expectedAuthorization: "Bearer ghp_secret",
+		},
+		"unknown asset": {
+			repo:        "msdl",
+			tag:         "v1.2.0",
+			assetName:   "msdl_windows_amd64.zip",
+			expectedErr: ErrAssetNotFound,
+		},
+		"unknown release": {
+			repo:        "msdl",
+			tag:         "v0.0.1",
+			assetName:   "msdl_linux_amd64.tar.gz",
+			expectedErr: ErrReleaseNotFound,
+		},
+		"unexpected status": {
+			repo:                "private",
+			tag:                 "v1.2.0",
+			assetName:           "msdl_linux_amd64.tar.gz",
+			expectedErrContains: "received 403 response",
+		},
+	}
+
+	for scenario, tc := range testCases {
+		t.Run(scenario, func(t *testing.T) {
+			url, err := resolveAssetURL(context.Background(), srv.Client(), srv.URL, "octo", tc.repo, tc.tag, tc.assetName, tc.token)
+			if tc.expectedErr != nil {
+				assert.ErrorIs(t, err, tc.expectedErr)
+				return
+			}
+			if len(tc.expectedErrContains) > 0 {
+				assert.ErrorContains(t, err, tc.expectedErrContains)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectedURL, url)
+			assert.Equal(t, tc.expectedAuthorization, receivedAuthorization)
+		})
+	}
+}
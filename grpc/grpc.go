@@ -0,0 +1,116 @@
+// Package grpc provides a download.Fetcher for object stores exposing a gRPC download API
+// (see proto/download.proto) instead of HTTP.
+package grpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"google.golang.org/grpc"
+
+	"github.com/gkatanacio/multisource-downloader/download"
+	"github.com/gkatanacio/multisource-downloader/proto/downloadpb"
+)
+
+var (
+	ErrNoResponse        = errors.New("no response from RPC")
+	ErrRangeSizeMismatch = errors.New("received bytes do not match requested range")
+)
+
+// streamDesc describes the download RPC. Since unary and server-streaming RPCs are the same on
+// the wire, this supports methods of either kind.
+var streamDesc = &grpc.StreamDesc{ServerStreams: true}
+
+// Fetcher is a download.Fetcher which calls a download RPC with DownloadRequest messages for each
+// source URL and reads the bytes from the DownloadResponse messages it returns.
+type Fetcher struct {
+	conn   *grpc.ClientConn
+	method string
+}
+
+// NewGRPCFetcher returns a Fetcher calling the given method of the given (fully qualified) service
+// on the connection, e.g., NewGRPCFetcher(conn, "msdl.download.v1.ObjectStore", "Download").
+// The source URLs are passed to the RPC as is.
+func NewGRPCFetcher(conn *grpc.ClientConn, service, method string) download.Fetcher {
+	return &Fetcher{
+		conn:   conn,
+		method: fmt.Sprintf("/%s/%s", service, method),
+	}
+}
+
+// Head implements download.Fetcher by requesting an empty range, for which only the size and
+// ETag of the object are returned.
+func (f *Fetcher) Head(ctx context.Context, url string) (*download.HeadResult, error) {
+	ctx, cancel := context.WithCancel(ctx) // the stream is not necessarily read until the end
+	defer cancel()
+
+	stream, err := f.call(ctx, &downloadpb.DownloadRequest{Url: url})
+	if err != nil {
+		return nil, err
+	}
+
+	resp := new(downloadpb.DownloadResponse)
+	if err := stream.RecvMsg(resp); errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("%w: %s", ErrNoResponse, url)
+	} else if err != nil {
+		return nil, err
+	}
+
+	return &download.HeadResult{
+		ContentLength: resp.GetSize(),
+		ETag:          resp.GetEtag(),
+		AcceptRanges:  true,
+	}, nil
+}
+
+// GetRange implements download.Fetcher by concatenating the bytes of all the responses.
+func (f *Fetcher) GetRange(ctx context.Context, url string, start, end int64) ([]byte, error) {
+	ctx, cancel := context.WithCancel(ctx) // the stream is not necessarily read until the end
+	defer cancel()
+
+	stream, err := f.call(ctx, &downloadpb.DownloadRequest{Url: url, Start: start, End: end})
+	if err != nil {
+		return nil, err
+	}
+
+	chunk := make([]byte, 0, end-start)
+	for {
+		resp := new(downloadpb.DownloadResponse)
+		if err := stream.RecvMsg(resp); errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+
+		if int64(len(chunk)+len(resp.GetData())) > end-start {
+			return nil, fmt.Errorf("%w: more than %d bytes from %s", ErrRangeSizeMismatch, end-start, url)
+		}
+		chunk = append(chunk, resp.GetData()...)
+	}
+
+	if int64(len(chunk)) != end-start {
+		return nil, fmt.Errorf("%w: %d of %d bytes from %s", ErrRangeSizeMismatch, len(chunk), end-start, url)
+	}
+
+	return chunk, nil
+}
+
+// call starts the RPC with the given request, which is the only message sent.
+func (f *Fetcher) call(ctx context.Context, req *downloadpb.DownloadRequest) (grpc.ClientStream, error) {
+	stream, err := f.conn.NewStream(ctx, streamDesc, f.method)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := stream.SendMsg(req); err != nil {
+		return nil, err
+	}
+
+	if err := stream.CloseSend(); err != nil {
+		return nil, err
+	}
+
+	return stream, nil
+}
@@ -0,0 +1,206 @@
+package grpc
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/gkatanacio/multisource-downloader/download"
+	"github.com/gkatanacio/multisource-downloader/proto/downloadpb"
+)
+
+const testService = "msdl.download.v1.ObjectStore"
+
+// objectStore serves the objects over a server-streaming method (in responses of at most
+// maxResponseBytes) and a unary method.
+type objectStore struct {
+	objects          map[string][]byte
+	maxResponseBytes int
+	rangeRequests    atomic.Int32
+}
+
+func (s *objectStore) lookup(req *downloadpb.DownloadRequest) ([]byte, *downloadpb.DownloadResponse, error) {
+	object, ok := s.objects[req.GetUrl()]
+	if !ok {
+		return nil, nil, status.Errorf(codes.NotFound, "no object at %s", req.GetUrl())
+	}
+	if req.GetStart() < 0 || req.GetEnd() > int64(len(object)) || req.GetStart() > req.GetEnd() {
+		return nil, nil, status.Error(codes.OutOfRange, "invalid range")
+	}
+	if req.GetEnd() > req.GetStart() {
+		s.rangeRequests.Add(1)
+	}
+
+	return object[req.GetStart():req.GetEnd()], &downloadpb.DownloadResponse{
+		Size: int64(len(object)),
+		Etag: fmt.Sprintf("%x", md5.Sum(object)),
+	}, nil
+}
+
+func (s *objectStore) download(_ any, stream grpc.ServerStream) error {
+	req := new(downloadpb.DownloadRequest)
+	if err := stream.RecvMsg(req); err != nil {
+		return err
+	}
+
+	data, first, err := s.lookup(req)
+	if err != nil {
+		return err
+	}
+
+	resp := first
+	for {
+		n := min(len(data), s.maxResponseBytes)
+		resp.Data = data[:n]
+		if err := stream.SendMsg(resp); err != nil {
+			return err
+		}
+
+		if data = data[n:]; len(data) == 0 {
+			return nil
+		}
+		resp = new(downloadpb.DownloadResponse)
+	}
+}
+
+func (s *objectStore) downloadUnary(_ any, _ context.Context, dec func(any) error, _ grpc.UnaryServerInterceptor) (any, error) {
+	req := new(downloadpb.DownloadRequest)
+	if err := dec(req); err != nil {
+		return nil, err
+	}
+
+	data, resp, err := s.lookup(req)
+	if err != nil {
+		return nil, err
+	}
+	resp.Data = data
+
+	return resp, nil
+}
+
+// newTestConn serves the object store over an in-memory connection and returns a client connection to it.
+func newTestConn(t *testing.T, store *objectStore) *grpc.ClientConn {
+	t.Helper()
+
+	listener := bufconn.Listen(1 << 20)
+
+	srv := grpc.NewServer()
+	srv.RegisterService(&grpc.ServiceDesc{
+		ServiceName: testService,
+		HandlerType: (*any)(nil),
+		Methods:     []grpc.MethodDesc{{MethodName: "DownloadUnary", Handler: store.downloadUnary}},
+		Streams:     []grpc.StreamDesc{{StreamName: "Download", Handler: store.download, ServerStreams: true}},
+	}, store)
+	go srv.Serve(listener)
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.NewClient(
+		"passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	return conn
+}
+
+func Test_Fetcher_Download(t *testing.T) {
+	content := bytes.Repeat([]byte("0123456789"), 1000)
+
+	testCases := map[string]struct {
+		method string
+	}{
+		"server-streaming RPC": {
+			method: "Download",
+		},
+		"unary RPC": {
+			method: "DownloadUnary",
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			store := &objectStore{
+				objects:          map[string][]byte{"grpc://store/objects/digits.txt": content},
+				maxResponseBytes: 300,
+			}
+			destFilePath := filepath.Join(t.TempDir(), "digits.txt")
+
+			downloadService := download.NewService(download.Options{
+				Connections:  4,
+				Timeout:      3,
+				CheckETag:    true,
+				Quiet:        true,
+				DestFilePath: destFilePath,
+				Fetcher:      NewGRPCFetcher(newTestConn(t, store), testService, tc.method),
+			}, download.GetMD5Hash)
+
+			err := downloadService.Download([]string{"grpc://store/objects/digits.txt"})
+			assert.NoError(t, err)
+
+			downloaded, err := os.ReadFile(destFilePath)
+			assert.NoError(t, err)
+			assert.Equal(t, content, downloaded)
+			assert.Equal(t, int32(4), store.rangeRequests.Load())
+		})
+	}
+}
+
+func Test_Fetcher_GetRange(t *testing.T) {
+	content := []byte("0123456789")
+	fetcher := NewGRPCFetcher(newTestConn(t, &objectStore{
+		objects:          map[string][]byte{"grpc://store/digits.txt": content},
+		maxResponseBytes: 3,
+	}), testService, "Download")
+
+	testCases := map[string]struct {
+		url         string
+		start, end  int64
+		expected    []byte
+		expectedErr codes.Code
+	}{
+		"within object": {
+			url:      "grpc://store/digits.txt",
+			start:    2,
+			end:      9,
+			expected: content[2:9],
+		},
+		"unknown object": {
+			url:         "grpc://store/missing.txt",
+			end:         1,
+			expectedErr: codes.NotFound,
+		},
+		"beyond object": {
+			url:         "grpc://store/digits.txt",
+			start:       5,
+			end:         11,
+			expectedErr: codes.OutOfRange,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			chunk, err := fetcher.GetRange(context.Background(), tc.url, tc.start, tc.end)
+			assert.Equal(t, tc.expectedErr, status.Code(err))
+			assert.Equal(t, tc.expected, chunk)
+		})
+	}
+}
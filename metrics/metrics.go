@@ -0,0 +1,109 @@
+package metrics
+
+import (
+	"errors"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Collector holds the Prometheus metrics for download operations. A nil *Collector is
+// valid and simply does not record anything.
+type Collector struct {
+	bytesDownloaded *prometheus.CounterVec
+	chunkDuration   *prometheus.HistogramVec
+	chunkFailures   *prometheus.CounterVec
+	activeDownloads prometheus.Gauge
+}
+
+// NewCollector creates the download metrics and registers them with the given registerer.
+// Metrics already registered (e.g., by another collector using the same registerer) are reused.
+func NewCollector(registerer prometheus.Registerer) (*Collector, error) {
+	c := &Collector{
+		bytesDownloaded: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "msdl_bytes_downloaded_total",
+			Help: "Total number of bytes downloaded per source.",
+		}, []string{"source"}),
+		chunkDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "msdl_chunk_duration_seconds",
+			Help:    "Duration of chunk download attempts per source.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"source"}),
+		chunkFailures: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "msdl_chunk_failures_total",
+			Help: "Total number of failed chunk download attempts per source and error type.",
+		}, []string{"source", "error_type"}),
+		activeDownloads: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "msdl_active_downloads",
+			Help: "Number of downloads currently in progress.",
+		}),
+	}
+
+	var err error
+	if c.bytesDownloaded, err = register(registerer, c.bytesDownloaded); err != nil {
+		return nil, err
+	}
+	if c.chunkDuration, err = register(registerer, c.chunkDuration); err != nil {
+		return nil, err
+	}
+	if c.chunkFailures, err = register(registerer, c.chunkFailures); err != nil {
+		return nil, err
+	}
+	if c.activeDownloads, err = register(registerer, c.activeDownloads); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// register registers the collector and returns the already registered one if existing.
+func register[T prometheus.Collector](registerer prometheus.Registerer, collector T) (T, error) {
+	err := registerer.Register(collector)
+
+	var alreadyRegisteredErr prometheus.AlreadyRegisteredError
+	if errors.As(err, &alreadyRegisteredErr) {
+		if existing, ok := alreadyRegisteredErr.ExistingCollector.(T); ok {
+			return existing, nil
+		}
+	}
+
+	return collector, err
+}
+
+// ChunkDownloaded records a successful chunk download attempt from the given source.
+func (c *Collector) ChunkDownloaded(source string, bytes int, duration time.Duration) {
+	if c == nil {
+		return
+	}
+
+	c.bytesDownloaded.WithLabelValues(source).Add(float64(bytes))
+	c.chunkDuration.WithLabelValues(source).Observe(duration.Seconds())
+}
+
+// ChunkFailed records a failed chunk download attempt from the given source.
+func (c *Collector) ChunkFailed(source, errorType string, duration time.Duration) {
+	if c == nil {
+		return
+	}
+
+	c.chunkFailures.WithLabelValues(source, errorType).Inc()
+	c.chunkDuration.WithLabelValues(source).Observe(duration.Seconds())
+}
+
+// DownloadStarted increments the number of active downloads.
+func (c *Collector) DownloadStarted() {
+	if c == nil {
+		return
+	}
+
+	c.activeDownloads.Inc()
+}
+
+// DownloadFinished decrements the number of active downloads.
+func (c *Collector) DownloadFinished() {
+	if c == nil {
+		return
+	}
+
+	c.activeDownloads.Dec()
+}
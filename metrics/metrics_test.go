@@ -0,0 +1,45 @@
+package metrics_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/gkatanacio/multisource-downloader/metrics"
+)
+
+func Test_NewCollector_SharedRegisterer(t *testing.T) {
+	registry := prometheus.NewRegistry()
+
+	collector1, err := metrics.NewCollector(registry)
+	assert.NoError(t, err)
+	collector2, err := metrics.NewCollector(registry)
+	assert.NoError(t, err)
+
+	collector1.ChunkDownloaded("http://source1.com/a.txt", 10, time.Millisecond)
+	collector2.ChunkDownloaded("http://source1.com/a.txt", 5, time.Millisecond)
+
+	assert.Equal(t, 1, testutil.CollectAndCount(registry, "msdl_bytes_downloaded_total"))
+
+	counter, err := registry.Gather()
+	assert.NoError(t, err)
+	for _, mf := range counter {
+		if mf.GetName() == "msdl_bytes_downloaded_total" {
+			assert.Equal(t, float64(15), mf.GetMetric()[0].GetCounter().GetValue())
+		}
+	}
+}
+
+func Test_Collector_Nil(t *testing.T) {
+	var collector *metrics.Collector
+
+	assert.NotPanics(t, func() {
+		collector.DownloadStarted()
+		collector.ChunkDownloaded("http://source1.com/a.txt", 10, time.Millisecond)
+		collector.ChunkFailed("http://source1.com/a.txt", "timeout", time.Millisecond)
+		collector.DownloadFinished()
+	})
+}
@@ -0,0 +1,45 @@
+// Package mirrordisc discovers mirror URLs published in DNS TXT records.
+package mirrordisc
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+)
+
+// LookupMirrors queries the TXT records of the given domain (e.g., `_mirrors.example.com`) and
+// returns the space-separated http(s) URLs found in them. Any other tokens are ignored.
+func LookupMirrors(ctx context.Context, domain string) ([]string, error) {
+	return lookupMirrors(ctx, net.DefaultResolver, domain)
+}
+
+// lookupMirrors is the same as LookupMirrors but uses the given resolver.
+func lookupMirrors(ctx context.Context, resolver *net.Resolver, domain string) ([]string, error) {
+	records, err := resolver.LookupTXT(ctx, domain)
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up mirrors for %s: %w", domain, err)
+	}
+
+	var mirrors []string
+	for _, record := range records {
+		for _, token := range strings.Fields(record) {
+			if isMirrorURL(token) {
+				mirrors = append(mirrors, token)
+			}
+		}
+	}
+
+	return mirrors, nil
+}
+
+// isMirrorURL returns true if the given string is an absolute http(s) URL.
+func isMirrorURL(s string) bool {
+	u, err := url.Parse(s)
+	if err != nil {
+		return false
+	}
+
+	return (u.Scheme == "http" || u.Scheme == "https") && len(u.Host) > 0
+}
@@ -0,0 +1,111 @@
+package mirrordisc
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// newTestResolver returns a resolver which answers TXT queries with the given records
+// (keyed by fully qualified domain name) from a fake DNS server.
+func newTestResolver(t *testing.T, records map[string][]string) *net.Resolver {
+	t.Helper()
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+
+			var query dnsmessage.Message
+			if err := query.Unpack(buf[:n]); err != nil || len(query.Questions) == 0 {
+				continue
+			}
+			question := query.Questions[0]
+
+			resp := dnsmessage.Message{
+				Header:    dnsmessage.Header{ID: query.ID, Response: true, Authoritative: true},
+				Questions: query.Questions,
+			}
+
+			txts, ok := records[question.Name.String()]
+			if !ok {
+				resp.RCode = dnsmessage.RCodeNameError
+			} else if question.Type == dnsmessage.TypeTXT {
+				for _, txt := range txts {
+					resp.Answers = append(resp.Answers, dnsmessage.Resource{
+						Header: dnsmessage.ResourceHeader{Name: question.Name, Type: dnsmessage.TypeTXT, Class: dnsmessage.ClassINET},
+						Body:   &dnsmessage.TXTResource{TXT: []string{txt}},
+					})
+				}
+			}
+
+			b, err := resp.Pack()
+			if err != nil {
+				continue
+			}
+			conn.WriteTo(b, addr)
+		}
+	}()
+
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "udp", conn.LocalAddr().String())
+		},
+	}
+}
+
+func Test_lookupMirrors(t *testing.T) {
+	resolver := newTestResolver(t, map[string][]string{
+		"_mirrors.example.com.": {
+			"http://m1.example.com/file https://m2.example.com/file",
+			"v=mirrors1 http://m3.example.com/file",
+		},
+		"_empty.example.com.": {"v=spf1 -all"},
+	})
+
+	testCases := map[string]struct {
+		domain          string
+		expectedMirrors []string
+		expectErr       bool
+	}{
+		"multiple records": {
+			domain:          "_mirrors.example.com",
+			expectedMirrors: []string{"http://m1.example.com/file", "https://m2.example.com/file", "http://m3.example.com/file"},
+		},
+		"no URLs in records": {
+			domain: "_empty.example.com",
+		},
+		"unknown domain": {
+			domain:    "_unknown.example.com",
+			expectErr: true,
+		},
+	}
+
+	for scenario, tc := range testCases {
+		t.Run(scenario, func(t *testing.T) {
+			mirrors, err := lookupMirrors(context.Background(), resolver, tc.domain)
+
+			if tc.expectErr {
+				assert.Error(t, err)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.ElementsMatch(t, tc.expectedMirrors, mirrors)
+		})
+	}
+}
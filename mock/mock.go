@@ -0,0 +1,140 @@
+// Package mock records HTTP exchanges to fixture files and replays them, which allows downloads
+// to be tested offline (e.g., golden-file tests recorded once against live sources).
+package mock
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+)
+
+var ErrUnexpectedRequest = errors.New("no recorded response for request")
+
+// Fixture represents a recorded HTTP exchange.
+type Fixture struct {
+	Method        string      `json:"method"`
+	URL           string      `json:"url"`
+	Range         string      `json:"range,omitempty"`
+	StatusCode    int         `json:"status_code"`
+	Header        http.Header `json:"header"`
+	ContentLength int64       `json:"content_length"`
+	Body          []byte      `json:"body,omitempty"`
+}
+
+// Recorder is a round-tripper which saves every exchange with the underlying transport to a JSON
+// fixture file in its directory. Requests are identified by their method, URL and Range header so
+// a repeated request overwrites the earlier fixture.
+type Recorder struct {
+	next http.RoundTripper
+	dir  string
+}
+
+// NewRecorder returns a Recorder saving the fixtures to the given directory. The default transport
+// is used if the given one is nil.
+func NewRecorder(dir string, next http.RoundTripper) *Recorder {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+
+	return &Recorder{next: next, dir: dir}
+}
+
+// RoundTrip implements http.RoundTripper.
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := r.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	fixture := Fixture{
+		Method:        req.Method,
+		URL:           req.URL.String(),
+		Range:         req.Header.Get("Range"),
+		StatusCode:    resp.StatusCode,
+		Header:        resp.Header,
+		ContentLength: resp.ContentLength,
+		Body:          body,
+	}
+
+	if err := r.save(&fixture); err != nil {
+		return nil, fmt.Errorf("failed to record %s %s: %w", req.Method, req.URL, err)
+	}
+
+	return resp, nil
+}
+
+// save writes the fixture to its file in the directory of the recorder.
+func (r *Recorder) save(fixture *Fixture) error {
+	if err := os.MkdirAll(r.dir, 0755); err != nil {
+		return err
+	}
+
+	b, err := json.MarshalIndent(fixture, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(fixturePath(r.dir, fixture.Method, fixture.URL, fixture.Range), b, 0644)
+}
+
+// Replayer is a round-tripper which responds with the fixtures saved by a Recorder instead of making
+// any network requests. An ErrUnexpectedRequest error is returned for requests which were not recorded.
+type Replayer struct {
+	dir string
+}
+
+// NewReplayer returns a Replayer serving the fixtures in the given directory.
+func NewReplayer(dir string) *Replayer {
+	return &Replayer{dir: dir}
+}
+
+// RoundTrip implements http.RoundTripper.
+func (r *Replayer) RoundTrip(req *http.Request) (*http.Response, error) {
+	rangeHeader := req.Header.Get("Range")
+
+	b, err := os.ReadFile(fixturePath(r.dir, req.Method, req.URL.String(), rangeHeader))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %s %s (Range %q)", ErrUnexpectedRequest, req.Method, req.URL, rangeHeader)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var fixture Fixture
+	if err := json.Unmarshal(b, &fixture); err != nil {
+		return nil, fmt.Errorf("invalid fixture for %s %s: %w", req.Method, req.URL, err)
+	}
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", fixture.StatusCode, http.StatusText(fixture.StatusCode)),
+		StatusCode:    fixture.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        fixture.Header,
+		ContentLength: fixture.ContentLength,
+		Body:          io.NopCloser(bytes.NewReader(fixture.Body)),
+		Request:       req,
+	}, nil
+}
+
+// fixturePath returns the path of the fixture file for the request with the given method, URL and
+// Range header inside the given directory.
+func fixturePath(dir, method, url, rangeHeader string) string {
+	key := sha256.Sum256([]byte(method + " " + url + " " + rangeHeader))
+	return filepath.Join(dir, fmt.Sprintf("%x.json", key[:8]))
+}
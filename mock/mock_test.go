@@ -0,0 +1,75 @@
+package mock_test
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/gkatanacio/multisource-downloader/download"
+	"github.com/gkatanacio/multisource-downloader/mock"
+)
+
+func Test_Recorder_Replayer(t *testing.T) {
+	content := bytes.Repeat([]byte("0123456789"), 100)
+
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		http.ServeContent(w, r, "digits.txt", time.Time{}, bytes.NewReader(content))
+	}))
+	sourceUrls := []string{srv.URL + "/digits.txt"}
+
+	fixturesDir := filepath.Join(t.TempDir(), "fixtures")
+	recordedFilePath := filepath.Join(t.TempDir(), "recorded.txt")
+
+	// two chunks of 500 bytes
+	err := download.NewService(download.Options{
+		Connections:  2,
+		Timeout:      3,
+		Quiet:        true,
+		DestFilePath: recordedFilePath,
+		RoundTripper: mock.NewRecorder(fixturesDir, nil),
+	}, download.GetMD5Hash).Download(sourceUrls)
+	assert.NoError(t, err)
+	assert.Equal(t, int32(3), requests.Load()) // HEAD and 2 chunks
+
+	fixtures, err := os.ReadDir(fixturesDir)
+	assert.NoError(t, err)
+	assert.Len(t, fixtures, 3)
+
+	// the source is no longer reachable
+	srv.Close()
+
+	replayedFilePath := filepath.Join(t.TempDir(), "replayed.txt")
+	err = download.NewService(download.Options{
+		Connections:  2,
+		Timeout:      3,
+		Quiet:        true,
+		DestFilePath: replayedFilePath,
+		RoundTripper: mock.NewReplayer(fixturesDir),
+	}, download.GetMD5Hash).Download(sourceUrls)
+	assert.NoError(t, err)
+
+	recorded, err := os.ReadFile(recordedFilePath)
+	assert.NoError(t, err)
+	replayed, err := os.ReadFile(replayedFilePath)
+	assert.NoError(t, err)
+	assert.Equal(t, content, recorded)
+	assert.Equal(t, recorded, replayed)
+}
+
+func Test_Replayer_UnexpectedRequest(t *testing.T) {
+	req, err := http.NewRequest(http.MethodGet, "http://source.example.com/a.txt", nil)
+	assert.NoError(t, err)
+	req.Header.Set("Range", "bytes=0-9")
+
+	_, err = mock.NewReplayer(t.TempDir()).RoundTrip(req)
+	assert.ErrorIs(t, err, mock.ErrUnexpectedRequest)
+}
@@ -0,0 +1,176 @@
+// Package otel wraps download.Service with OpenTelemetry tracing and metrics so that the service
+// itself does not depend on a specific meter.
+package otel
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	otelglobal "go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/noop"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/gkatanacio/multisource-downloader/download"
+)
+
+// ObservableService records a span with the events of the chunks for each download of the inner
+// service along with the following metrics:
+//
+//	msdl.downloads             number of finished downloads per outcome (success or failure)
+//	msdl.downloads.active      number of downloads in progress
+//	msdl.download.duration     duration of the downloads in seconds per outcome
+//	msdl.chunks.started        number of started chunks per source
+//	msdl.chunks.completed      number of completed chunks per source
+//	msdl.bytes.downloaded      number of bytes of the completed chunks per source
+type ObservableService struct {
+	inner  *download.Service
+	tracer trace.Tracer
+
+	downloads        metric.Int64Counter
+	activeDownloads  metric.Int64UpDownCounter
+	downloadDuration metric.Float64Histogram
+	chunksStarted    metric.Int64Counter
+	chunksCompleted  metric.Int64Counter
+	bytesDownloaded  metric.Int64Counter
+}
+
+// NewObservableService returns an ObservableService wrapping the given service which records its spans
+// with the given tracer and its metrics with the given meter. The OnChunkStart and OnProgress callbacks
+// of the inner service are still called. Instruments which cannot be created are reported to the
+// global OpenTelemetry error handler and replaced by no-op ones.
+func NewObservableService(inner *download.Service, tracer trace.Tracer, meter metric.Meter) *ObservableService {
+	var errs []error
+	check := func(err error) {
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	o := &ObservableService{inner: inner, tracer: tracer}
+
+	var err error
+	o.downloads, err = meter.Int64Counter("msdl.downloads", metric.WithDescription("Number of finished downloads per outcome."))
+	check(err)
+	o.activeDownloads, err = meter.Int64UpDownCounter("msdl.downloads.active", metric.WithDescription("Number of downloads in progress."))
+	check(err)
+	o.downloadDuration, err = meter.Float64Histogram("msdl.download.duration", metric.WithDescription("Duration of the downloads per outcome."), metric.WithUnit("s"))
+	check(err)
+	o.chunksStarted, err = meter.Int64Counter("msdl.chunks.started", metric.WithDescription("Number of started chunks per source."))
+	check(err)
+	o.chunksCompleted, err = meter.Int64Counter("msdl.chunks.completed", metric.WithDescription("Number of completed chunks per source."))
+	check(err)
+	o.bytesDownloaded, err = meter.Int64Counter("msdl.bytes.downloaded", metric.WithDescription("Number of bytes of the completed chunks per source."), metric.WithUnit("By"))
+	check(err)
+
+	if len(errs) > 0 {
+		otelglobal.Handle(errors.Join(errs...))
+		o.replaceNilInstruments()
+	}
+
+	return o
+}
+
+// replaceNilInstruments replaces the instruments which could not be created with no-op ones.
+func (o *ObservableService) replaceNilInstruments() {
+	meter := noop.NewMeterProvider().Meter("")
+
+	if o.downloads == nil {
+		o.downloads, _ = meter.Int64Counter("")
+	}
+	if o.activeDownloads == nil {
+		o.activeDownloads, _ = meter.Int64UpDownCounter("")
+	}
+	if o.downloadDuration == nil {
+		o.downloadDuration, _ = meter.Float64Histogram("")
+	}
+	if o.chunksStarted == nil {
+		o.chunksStarted, _ = meter.Int64Counter("")
+	}
+	if o.chunksCompleted == nil {
+		o.chunksCompleted, _ = meter.Int64Counter("")
+	}
+	if o.bytesDownloaded == nil {
+		o.bytesDownloaded, _ = meter.Int64Counter("")
+	}
+}
+
+// Download is the same as download.Service.Download but observed.
+func (o *ObservableService) Download(sourceUrls []string) error {
+	_, err := o.DownloadWithStats(sourceUrls)
+	return err
+}
+
+// DownloadWithStats is the same as Download but also returns the per-source stats of the download
+// (see download.Service.LastDownloadStats), which are also returned if the download failed.
+func (o *ObservableService) DownloadWithStats(sourceUrls []string) (*download.DownloadStats, error) {
+	opts := o.inner.Options()
+
+	ctx, span := o.tracer.Start(context.Background(), "ObservableService.Download", trace.WithAttributes(
+		attribute.String("download.dest_file", opts.DestFilePath),
+		attribute.StringSlice("download.sources", sourceUrls),
+	))
+	defer span.End()
+
+	o.activeDownloads.Add(ctx, 1)
+	defer o.activeDownloads.Add(ctx, -1)
+
+	// a copy of the inner service per download so that the chunk events end up in its span
+	svc := o.inner.WithOptions(download.Options{
+		OnChunkStart: o.onChunkStart(ctx, span, opts.OnChunkStart),
+		OnProgress:   o.onProgress(ctx, span, opts.OnProgress),
+	})
+
+	start := time.Now()
+	err := svc.Download(sourceUrls)
+
+	outcome := attribute.String("outcome", "success")
+	if err != nil {
+		outcome = attribute.String("outcome", "failure")
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	o.downloads.Add(ctx, 1, metric.WithAttributes(outcome))
+	o.downloadDuration.Record(ctx, time.Since(start).Seconds(), metric.WithAttributes(outcome))
+
+	return svc.LastDownloadStats(), err
+}
+
+// onChunkStart returns the OnChunkStart callback recording the started chunks before calling the given one (if any).
+func (o *ObservableService) onChunkStart(ctx context.Context, span trace.Span, next download.ChunkCallback) download.ChunkCallback {
+	return func(chunkIndex int, source string, offset, size int64) {
+		o.chunksStarted.Add(ctx, 1, metric.WithAttributes(attribute.String("source", source)))
+		span.AddEvent("chunk.started", trace.WithAttributes(chunkAttributes(chunkIndex, source, offset, size)...))
+
+		if next != nil {
+			next(chunkIndex, source, offset, size)
+		}
+	}
+}
+
+// onProgress returns the OnProgress callback recording the completed chunks before calling the given one (if any).
+func (o *ObservableService) onProgress(ctx context.Context, span trace.Span, next download.ChunkCallback) download.ChunkCallback {
+	return func(chunkIndex int, source string, offset, size int64) {
+		sourceAttr := metric.WithAttributes(attribute.String("source", source))
+		o.chunksCompleted.Add(ctx, 1, sourceAttr)
+		o.bytesDownloaded.Add(ctx, size, sourceAttr)
+		span.AddEvent("chunk.completed", trace.WithAttributes(chunkAttributes(chunkIndex, source, offset, size)...))
+
+		if next != nil {
+			next(chunkIndex, source, offset, size)
+		}
+	}
+}
+
+// chunkAttributes returns the span event attributes describing a chunk.
+func chunkAttributes(chunkIndex int, source string, offset, size int64) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		attribute.Int("chunk.index", chunkIndex),
+		attribute.String("chunk.source", source),
+		attribute.Int64("chunk.offset", offset),
+		attribute.Int64("chunk.size", size),
+	}
+}
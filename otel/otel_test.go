@@ -0,0 +1,149 @@
+package otel_test
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/codes"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"github.com/gkatanacio/multisource-downloader/download"
+	"github.com/gkatanacio/multisource-downloader/otel"
+)
+
+// newObservableService returns an ObservableService wrapping a service with the given options along
+// with the recorder of its spans and the reader of its metrics.
+func newObservableService(opts download.Options) (*otel.ObservableService, *tracetest.SpanRecorder, *sdkmetric.ManualReader) {
+	recorder := tracetest.NewSpanRecorder()
+	tracerProvider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	reader := sdkmetric.NewManualReader()
+	meterProvider := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+
+	opts.Timeout = 3
+	opts.Quiet = true
+	inner := download.NewService(opts, download.GetMD5Hash)
+
+	return otel.NewObservableService(inner, tracerProvider.Tracer("test"), meterProvider.Meter("test")), recorder, reader
+}
+
+// collectSums returns the values of the sum metrics by name and (single) attribute value.
+func collectSums(t *testing.T, reader *sdkmetric.ManualReader) map[string]map[string]int64 {
+	t.Helper()
+
+	var rm metricdata.ResourceMetrics
+	assert.NoError(t, reader.Collect(context.Background(), &rm))
+
+	sums := make(map[string]map[string]int64)
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			data, ok := m.Data.(metricdata.Sum[int64])
+			if !ok {
+				continue
+			}
+			sums[m.Name] = make(map[string]int64)
+			for _, dp := range data.DataPoints {
+				var value string
+				if kvs := dp.Attributes.ToSlice(); len(kvs) > 0 {
+					value = kvs[0].Value.Emit()
+				}
+				sums[m.Name][value] += dp.Value
+			}
+		}
+	}
+
+	return sums
+}
+
+func Test_ObservableService_Download(t *testing.T) {
+	content := bytes.Repeat([]byte("0123456789"), 100)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "digits.txt", time.Time{}, bytes.NewReader(content))
+	}))
+	t.Cleanup(srv.Close)
+	sourceUrl := srv.URL + "/digits.txt"
+
+	var innerStarts, innerProgress atomic.Int32
+	destFilePath := filepath.Join(t.TempDir(), "digits.txt")
+	svc, recorder, reader := newObservableService(download.Options{
+		Connections:  4,
+		ChunkBytes:   250,
+		DestFilePath: destFilePath,
+		OnChunkStart: func(int, string, int64, int64) { innerStarts.Add(1) },
+		OnProgress:   func(int, string, int64, int64) { innerProgress.Add(1) },
+	})
+
+	stats, err := svc.DownloadWithStats([]string{sourceUrl})
+	assert.NoError(t, err)
+
+	downloaded, err := os.ReadFile(destFilePath)
+	assert.NoError(t, err)
+	assert.Equal(t, content, downloaded)
+
+	// the callbacks of the inner service are still called
+	assert.Equal(t, int32(4), innerStarts.Load())
+	assert.Equal(t, int32(4), innerProgress.Load())
+
+	if assert.NotNil(t, stats) && assert.Len(t, stats.Sources, 1) {
+		assert.Equal(t, 4, stats.Sources[0].ChunksDelivered)
+	}
+
+	spans := recorder.Ended()
+	if assert.Len(t, spans, 1) {
+		span := spans[0]
+		assert.Equal(t, "ObservableService.Download", span.Name())
+		assert.Equal(t, codes.Unset, span.Status().Code)
+
+		var started, completed int
+		for _, event := range span.Events() {
+			switch event.Name {
+			case "chunk.started":
+				started++
+			case "chunk.completed":
+				completed++
+			}
+		}
+		assert.Equal(t, 4, started)
+		assert.Equal(t, 4, completed)
+	}
+
+	sums := collectSums(t, reader)
+	assert.Equal(t, map[string]int64{"success": 1}, sums["msdl.downloads"])
+	assert.Equal(t, map[string]int64{"": 0}, sums["msdl.downloads.active"])
+	assert.Equal(t, map[string]int64{sourceUrl: 4}, sums["msdl.chunks.started"])
+	assert.Equal(t, map[string]int64{sourceUrl: 4}, sums["msdl.chunks.completed"])
+	assert.Equal(t, map[string]int64{sourceUrl: int64(len(content))}, sums["msdl.bytes.downloaded"])
+}
+
+func Test_ObservableService_Download_Failure(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	t.Cleanup(srv.Close)
+
+	svc, recorder, reader := newObservableService(download.Options{
+		Connections:  4,
+		DestFilePath: filepath.Join(t.TempDir(), "digits.txt"),
+	})
+
+	err := svc.Download([]string{srv.URL + "/digits.txt"})
+	assert.Error(t, err)
+
+	spans := recorder.Ended()
+	if assert.Len(t, spans, 1) {
+		assert.Equal(t, codes.Error, spans[0].Status().Code)
+	}
+
+	sums := collectSums(t, reader)
+	assert.Equal(t, map[string]int64{"failure": 1}, sums["msdl.downloads"])
+	assert.Empty(t, sums["msdl.chunks.started"])
+}
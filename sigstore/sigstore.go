@@ -0,0 +1,231 @@
+// Package sigstore verifies files against cosign bundles (i.e., from `cosign sign-blob --bundle`)
+// including their entries in the Sigstore transparency log (Rekor).
+package sigstore
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+
+	"github.com/sigstore/cosign/v2/pkg/cosign"
+	"github.com/sigstore/sigstore/pkg/cryptoutils"
+	"github.com/sigstore/sigstore/pkg/signature"
+	"github.com/sigstore/sigstore/pkg/signature/options"
+)
+
+const maxBundleBytes = 1 << 20 // 1 MiB
+
+var (
+	ErrNoTransparencyLogEntry = errors.New("bundle has no transparency log entry")
+	ErrUntrustedLog           = errors.New("transparency log entry not from a trusted log")
+	ErrLogEntryMismatch       = errors.New("transparency log entry does not match the file")
+	ErrSignatureMismatch      = errors.New("signature does not match the file")
+)
+
+// Verifier verifies files signed with a key pair (i.e., `cosign sign-blob --key`).
+type Verifier struct {
+	PublicKey       crypto.PublicKey                      // of the key pair used for signing
+	RekorPublicKeys *cosign.TrustedTransparencyLogPubKeys // defaults to the keys of the public Sigstore instance (see cosign.GetRekorPubs)
+	HTTPClient      *http.Client                          // for fetching the bundles (defaults to http.DefaultClient)
+}
+
+// NewVerifier returns a verifier for the signatures created with the key pair of the given
+// PEM-encoded public key (e.g., the `cosign.pub` file from `cosign generate-key-pair`).
+func NewVerifier(publicKeyPEM []byte) (*Verifier, error) {
+	publicKey, err := cryptoutils.UnmarshalPEMToPublicKey(publicKeyPEM)
+	if err != nil {
+		return nil, fmt.Errorf("invalid public key: %w", err)
+	}
+
+	return &Verifier{PublicKey: publicKey}, nil
+}
+
+// hashedRekordBody represents the relevant parts of a `hashedrekord` transparency log entry.
+type hashedRekordBody struct {
+	Kind string `json:"kind"`
+	Spec struct {
+		Data struct {
+			Hash struct {
+				Algorithm string `json:"algorithm"`
+				Value     string `json:"value"`
+			} `json:"hash"`
+		} `json:"data"`
+		Signature struct {
+			Content   string `json:"content"`
+			PublicKey struct {
+				Content string `json:"content"`
+			} `json:"publicKey"`
+		} `json:"signature"`
+	} `json:"spec"`
+}
+
+// Verify fetches the cosign bundle from the given URL (or local path) and verifies that its signature
+// matches the SHA-256 hash of the file at the given path and that its transparency log entry is signed
+// by a trusted log and refers to the same hash, signature and public key.
+func (v *Verifier) Verify(ctx context.Context, filePath, bundleURL string) error {
+	payload, err := v.fetchBundle(ctx, bundleURL)
+	if err != nil {
+		return fmt.Errorf("failed to fetch bundle %s: %w", bundleURL, err)
+	}
+	if payload.Bundle == nil {
+		return ErrNoTransparencyLogEntry
+	}
+
+	digest, err := fileSHA256(filePath)
+	if err != nil {
+		return err
+	}
+
+	// the file is not read again since the signature is verified against its hash (as in a hashedrekord entry)
+	sig, err := base64.StdEncoding.DecodeString(payload.Base64Signature)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrSignatureMismatch, err)
+	}
+	sigVerifier, err := signature.LoadVerifier(v.PublicKey, crypto.SHA256)
+	if err != nil {
+		return err
+	}
+	if err := sigVerifier.VerifySignature(bytes.NewReader(sig), nil, options.WithDigest(digest), options.WithContext(ctx)); err != nil {
+		return fmt.Errorf("%w: %w", ErrSignatureMismatch, err)
+	}
+
+	rekorPublicKeys := v.RekorPublicKeys
+	if rekorPublicKeys == nil {
+		if rekorPublicKeys, err = cosign.GetRekorPubs(ctx); err != nil {
+			return fmt.Errorf("failed to get transparency log public keys: %w", err)
+		}
+	}
+
+	logPublicKey, ok := rekorPublicKeys.Keys[payload.Bundle.Payload.LogID]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrUntrustedLog, payload.Bundle.Payload.LogID)
+	}
+	ecdsaPublicKey, ok := logPublicKey.PubKey.(*ecdsa.PublicKey)
+	if !ok {
+		return fmt.Errorf("%w: unsupported public key for %s", ErrUntrustedLog, payload.Bundle.Payload.LogID)
+	}
+	if err := cosign.VerifySET(payload.Bundle.Payload, payload.Bundle.SignedEntryTimestamp, ecdsaPublicKey); err != nil {
+		return fmt.Errorf("%w: %w", ErrUntrustedLog, err)
+	}
+
+	return v.verifyLogEntry(payload, digest)
+}
+
+// verifyLogEntry checks that the transparency log entry in the bundle refers to the given hash
+// along with the signature of the bundle and the public key of the verifier.
+func (v *Verifier) verifyLogEntry(payload *cosign.LocalSignedPayload, digest []byte) error {
+	encodedBody, ok := payload.Bundle.Payload.Body.(string)
+	if !ok {
+		return fmt.Errorf("%w: unexpected body", ErrLogEntryMismatch)
+	}
+	rawBody, err := base64.StdEncoding.DecodeString(encodedBody)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrLogEntryMismatch, err)
+	}
+
+	var body hashedRekordBody
+	if err := json.Unmarshal(rawBody, &body); err != nil {
+		return fmt.Errorf("%w: %w", ErrLogEntryMismatch, err)
+	}
+	if body.Kind != "hashedrekord" {
+		return fmt.Errorf("%w: unsupported kind %q", ErrLogEntryMismatch, body.Kind)
+	}
+
+	if hash := body.Spec.Data.Hash; hash.Algorithm != "sha256" || hash.Value != hex.EncodeToString(digest) {
+		return fmt.Errorf("%w: %s hash %s", ErrLogEntryMismatch, hash.Algorithm, hash.Value)
+	}
+
+	if body.Spec.Signature.Content != payload.Base64Signature {
+		return fmt.Errorf("%w: different signature", ErrLogEntryMismatch)
+	}
+
+	publicKeyPEM, err := base64.StdEncoding.DecodeString(body.Spec.Signature.PublicKey.Content)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrLogEntryMismatch, err)
+	}
+	publicKey, err := cryptoutils.UnmarshalPEMToPublicKey(publicKeyPEM)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrLogEntryMismatch, err)
+	}
+	if err := cryptoutils.EqualKeys(v.PublicKey, publicKey); err != nil {
+		return fmt.Errorf("%w: %w", ErrLogEntryMismatch, err)
+	}
+
+	return nil
+}
+
+// fetchBundle retrieves the bundle from the given http(s) URL or local path.
+func (v *Verifier) fetchBundle(ctx context.Context, bundleURL string) (*cosign.LocalSignedPayload, error) {
+	var data []byte
+	if u, err := url.Parse(bundleURL); err == nil && (u.Scheme == "http" || u.Scheme == "https") {
+		if data, err = v.download(ctx, bundleURL); err != nil {
+			return nil, err
+		}
+	} else {
+		if err == nil && u.Scheme == "file" {
+			bundleURL = u.Path
+		}
+		if data, err = os.ReadFile(bundleURL); err != nil {
+			return nil, err
+		}
+	}
+
+	var payload cosign.LocalSignedPayload
+	if err := json.Unmarshal(data, &payload); err != nil {
+		return nil, err
+	}
+
+	return &payload, nil
+}
+
+// download retrieves the contents at the given URL (up to 1 MiB).
+func (v *Verifier) download(ctx context.Context, url string) ([]byte, error) {
+	client := v.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	return io.ReadAll(io.LimitReader(resp.Body, maxBundleBytes))
+}
+
+// fileSHA256 returns the SHA-256 hash of the file at the given path.
+func fileSHA256(filePath string) ([]byte, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	hasher := sha256.New()
+	if _, err := io.Copy(hasher, file); err != nil {
+		return nil, err
+	}
+
+	return hasher.Sum(nil), nil
+}
@@ -0,0 +1,129 @@
+package sigstore_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sigstore/cosign/v2/pkg/cosign"
+	"github.com/sigstore/sigstore/pkg/tuf"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/gkatanacio/multisource-downloader/sigstore"
+)
+
+func readFixture(t *testing.T, name string) []byte {
+	t.Helper()
+
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	assert.NoError(t, err)
+	return data
+}
+
+func newVerifier(t *testing.T) *sigstore.Verifier {
+	t.Helper()
+
+	verifier, err := sigstore.NewVerifier(readFixture(t, "cosign.pub"))
+	assert.NoError(t, err)
+
+	rekorPublicKeys := cosign.NewTrustedTransparencyLogPubKeys()
+	assert.NoError(t, rekorPublicKeys.AddTransparencyLogPubKey(readFixture(t, "rekor.pub"), tuf.Active))
+	verifier.RekorPublicKeys = &rekorPublicKeys
+
+	return verifier
+}
+
+func Test_Verifier_Verify(t *testing.T) {
+	artifact := readFixture(t, "artifact.txt")
+	bundle := readFixture(t, "artifact.txt.bundle")
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/artifact.txt.bundle" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write(bundle)
+	}))
+	t.Cleanup(srv.Close)
+
+	otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+
+	testCases := map[string]struct {
+		content      []byte
+		bundleURL    string
+		modify       func(*sigstore.Verifier)
+		specificErr  error
+		errSubstring string
+	}{
+		"valid": {
+			content:   artifact,
+			bundleURL: srv.URL + "/artifact.txt.bundle",
+		},
+		"valid local bundle": {
+			content:   artifact,
+			bundleURL: filepath.Join("testdata", "artifact.txt.bundle"),
+		},
+		"tampered file": {
+			content:     bytes.ToUpper(artifact),
+			bundleURL:   srv.URL + "/artifact.txt.bundle",
+			specificErr: sigstore.ErrSignatureMismatch,
+		},
+		"wrong public key": {
+			content:   artifact,
+			bundleURL: srv.URL + "/artifact.txt.bundle",
+			modify: func(v *sigstore.Verifier) {
+				v.PublicKey = otherKey.Public()
+			},
+			specificErr: sigstore.ErrSignatureMismatch,
+		},
+		"untrusted log": {
+			content:   artifact,
+			bundleURL: srv.URL + "/artifact.txt.bundle",
+			modify: func(v *sigstore.Verifier) {
+				rekorPublicKeys := cosign.NewTrustedTransparencyLogPubKeys()
+				v.RekorPublicKeys = &rekorPublicKeys
+			},
+			specificErr: sigstore.ErrUntrustedLog,
+		},
+		"missing bundle": {
+			content:      artifact,
+			bundleURL:    srv.URL + "/missing.bundle",
+			errSubstring: "404 Not Found",
+		},
+	}
+
+	for scenario, tc := range testCases {
+		t.Run(scenario, func(t *testing.T) {
+			filePath := filepath.Join(t.TempDir(), "artifact.txt")
+			assert.NoError(t, os.WriteFile(filePath, tc.content, 0644))
+
+			verifier := newVerifier(t)
+			if tc.modify != nil {
+				tc.modify(verifier)
+			}
+
+			err := verifier.Verify(context.Background(), filePath, tc.bundleURL)
+			switch {
+			case tc.specificErr != nil:
+				assert.ErrorIs(t, err, tc.specificErr)
+			case len(tc.errSubstring) > 0:
+				assert.ErrorContains(t, err, tc.errSubstring)
+			default:
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func Test_NewVerifier_InvalidPublicKey(t *testing.T) {
+	_, err := sigstore.NewVerifier([]byte("not a key"))
+	assert.ErrorContains(t, err, "invalid public key")
+}
@@ -0,0 +1,151 @@
+package torrent
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+)
+
+var ErrInvalidBencode = errors.New("invalid bencode")
+
+// decodeBencode parses the given bencoded data into int64, string, []any and map[string]any values.
+func decodeBencode(data []byte) (any, error) {
+	d := bencodeDecoder{data: data}
+
+	value, err := d.decode()
+	if err != nil {
+		return nil, err
+	}
+	if d.pos != len(data) {
+		return nil, fmt.Errorf("%w: trailing data at offset %d", ErrInvalidBencode, d.pos)
+	}
+
+	return value, nil
+}
+
+// bencodeDecoder decodes the values starting at pos.
+type bencodeDecoder struct {
+	data []byte
+	pos  int
+}
+
+func (d *bencodeDecoder) decode() (any, error) {
+	if d.pos >= len(d.data) {
+		return nil, fmt.Errorf("%w: unexpected end of data", ErrInvalidBencode)
+	}
+
+	switch c := d.data[d.pos]; {
+	case c == 'i':
+		d.pos++
+		return d.decodeInt('e')
+	case c >= '0' && c <= '9':
+		return d.decodeString()
+	case c == 'l':
+		d.pos++
+		var list []any
+		for !d.consumeEnd() {
+			value, err := d.decode()
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, value)
+		}
+		return list, nil
+	case c == 'd':
+		d.pos++
+		dict := make(map[string]any)
+		for !d.consumeEnd() {
+			key, err := d.decodeString()
+			if err != nil {
+				return nil, err
+			}
+			value, err := d.decode()
+			if err != nil {
+				return nil, err
+			}
+			dict[key] = value
+		}
+		return dict, nil
+	default:
+		return nil, fmt.Errorf("%w: unexpected %q at offset %d", ErrInvalidBencode, c, d.pos)
+	}
+}
+
+// consumeEnd skips the end marker of a list or dictionary if it is next.
+func (d *bencodeDecoder) consumeEnd() bool {
+	if d.pos < len(d.data) && d.data[d.pos] == 'e' {
+		d.pos++
+		return true
+	}
+	return false
+}
+
+// decodeInt parses the integer up to the given delimiter (which is skipped).
+func (d *bencodeDecoder) decodeInt(delim byte) (int64, error) {
+	end := bytes.IndexByte(d.data[d.pos:], delim)
+	if end < 0 {
+		return 0, fmt.Errorf("%w: unterminated integer at offset %d", ErrInvalidBencode, d.pos)
+	}
+
+	n, err := strconv.ParseInt(string(d.data[d.pos:d.pos+end]), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %w", ErrInvalidBencode, err)
+	}
+
+	d.pos += end + 1
+	return n, nil
+}
+
+func (d *bencodeDecoder) decodeString() (string, error) {
+	length, err := d.decodeInt(':')
+	if err != nil {
+		return "", err
+	}
+	if length < 0 || length > int64(len(d.data)-d.pos) {
+		return "", fmt.Errorf("%w: invalid string length %d at offset %d", ErrInvalidBencode, length, d.pos)
+	}
+
+	s := string(d.data[d.pos : d.pos+int(length)])
+	d.pos += int(length)
+	return s, nil
+}
+
+// encodeBencode appends the bencoding of the given value (i.e., int64, string, []any or map[string]any)
+// to the buffer. Dictionary keys are sorted as required by the format.
+func encodeBencode(buf *bytes.Buffer, value any) error {
+	switch v := value.(type) {
+	case int64:
+		fmt.Fprintf(buf, "i%de", v)
+	case string:
+		fmt.Fprintf(buf, "%d:%s", len(v), v)
+	case []any:
+		buf.WriteByte('l')
+		for _, item := range v {
+			if err := encodeBencode(buf, item); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte('e')
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		slices.Sort(keys)
+
+		buf.WriteByte('d')
+		for _, key := range keys {
+			fmt.Fprintf(buf, "%d:%s", len(key), key)
+			if err := encodeBencode(buf, v[key]); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte('e')
+	default:
+		return fmt.Errorf("%w: unsupported type %T", ErrInvalidBencode, value)
+	}
+
+	return nil
+}
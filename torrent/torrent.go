@@ -0,0 +1,112 @@
+// Package torrent reads and writes the piece hashes of single-file `.torrent` files (BEP 3) so that
+// downloads can be verified piece by piece like BitTorrent does.
+package torrent
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+var (
+	ErrInvalidTorrent   = errors.New("invalid torrent")
+	ErrMultiFileTorrent = errors.New("multi-file torrents are not supported")
+)
+
+// ParseTorrentHashes reads the `.torrent` file at the given path and returns the `piece length`
+// and the SHA-1 hashes of the pieces (from `pieces`) in its info dictionary.
+func ParseTorrentHashes(torrentPath string) (pieceLength int64, pieceHashes [][]byte, err error) {
+	data, err := os.ReadFile(torrentPath)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	value, err := decodeBencode(data)
+	if err != nil {
+		return 0, nil, fmt.Errorf("%w: %w", ErrInvalidTorrent, err)
+	}
+
+	metainfo, ok := value.(map[string]any)
+	if !ok {
+		return 0, nil, fmt.Errorf("%w: not a dictionary", ErrInvalidTorrent)
+	}
+	info, ok := metainfo["info"].(map[string]any)
+	if !ok {
+		return 0, nil, fmt.Errorf("%w: missing info dictionary", ErrInvalidTorrent)
+	}
+	if _, ok := info["files"]; ok {
+		return 0, nil, ErrMultiFileTorrent // the pieces would span the concatenation of the files
+	}
+
+	if pieceLength, ok = info["piece length"].(int64); !ok || pieceLength <= 0 {
+		return 0, nil, fmt.Errorf("%w: missing or invalid piece length", ErrInvalidTorrent)
+	}
+
+	pieces, ok := info["pieces"].(string)
+	if !ok || len(pieces)%sha1.Size != 0 {
+		return 0, nil, fmt.Errorf("%w: missing or invalid pieces", ErrInvalidTorrent)
+	}
+
+	for i := 0; i < len(pieces); i += sha1.Size {
+		pieceHashes = append(pieceHashes, []byte(pieces[i:i+sha1.Size]))
+	}
+
+	if length, ok := info["length"].(int64); ok {
+		if numPieces := (length + pieceLength - 1) / pieceLength; int64(len(pieceHashes)) != numPieces {
+			return 0, nil, fmt.Errorf("%w: expected %d pieces but got %d", ErrInvalidTorrent, numPieces, len(pieceHashes))
+		}
+	}
+
+	return pieceLength, pieceHashes, nil
+}
+
+// CreateTorrent writes a `.torrent` file at the given path for the file at the given path with the
+// SHA-1 hashes of its pieces of the given length (e.g., 256 KiB). The torrent has no trackers since
+// it is only meant for verification.
+func CreateTorrent(torrentPath, filePath string, pieceLength int64) error {
+	if pieceLength <= 0 {
+		return fmt.Errorf("%w: piece length must be positive", ErrInvalidTorrent)
+	}
+
+	file, err := os.Open(filePath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	var pieces bytes.Buffer
+	var length int64
+	for {
+		hasher := sha1.New()
+		n, err := io.CopyN(hasher, file, pieceLength)
+		if n > 0 {
+			pieces.Write(hasher.Sum(nil))
+			length += n
+		}
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return err
+		}
+	}
+
+	metainfo := map[string]any{
+		"info": map[string]any{
+			"name":         filepath.Base(filePath),
+			"length":       length,
+			"piece length": pieceLength,
+			"pieces":       pieces.String(),
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := encodeBencode(&buf, metainfo); err != nil {
+		return err
+	}
+
+	return os.WriteFile(torrentPath, buf.Bytes(), 0644)
+}
@@ -0,0 +1,88 @@
+package torrent_test
+
+import (
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/gkatanacio/multisource-downloader/torrent"
+)
+
+// dummyPieceHashes are the SHA-1 hashes of the 1 KiB pieces of testdata/dummy.txt (at the repo root).
+var dummyPieceHashes = []string{
+	"5e15368d54484c0ab1af899b1dbb764a50ecb4a2",
+	"0e5a590755fb9ac5c8de05cf021cfaae1430f3f9",
+	"f181cc136ef213d4d67eb19d8650a6dc098acbb5",
+	"4f061d37345e367c32bb57cbceb116435cb181eb",
+}
+
+func hexHashes(hashes [][]byte) []string {
+	var hexes []string
+	for _, h := range hashes {
+		hexes = append(hexes, hex.EncodeToString(h))
+	}
+	return hexes
+}
+
+func Test_ParseTorrentHashes(t *testing.T) {
+	pieceLength, pieceHashes, err := torrent.ParseTorrentHashes(filepath.Join("testdata", "dummy.txt.torrent"))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1024), pieceLength)
+	assert.Equal(t, dummyPieceHashes, hexHashes(pieceHashes))
+}
+
+func Test_ParseTorrentHashes_Invalid(t *testing.T) {
+	testCases := map[string]struct {
+		data        string
+		specificErr error
+	}{
+		"not bencode": {
+			data:        "hello",
+			specificErr: torrent.ErrInvalidBencode,
+		},
+		"trailing data": {
+			data:        "de" + "x",
+			specificErr: torrent.ErrInvalidBencode,
+		},
+		"missing info": {
+			data:        "d8:announce3:urle",
+			specificErr: torrent.ErrInvalidTorrent,
+		},
+		"invalid pieces": {
+			data:        "d4:infod12:piece lengthi1024e6:pieces3:abcee",
+			specificErr: torrent.ErrInvalidTorrent,
+		},
+		"piece count mismatch": {
+			data:        "d4:infod6:lengthi2048e12:piece lengthi1024e6:pieces20:aaaaaaaaaaaaaaaaaaaaee",
+			specificErr: torrent.ErrInvalidTorrent,
+		},
+		"multi-file": {
+			data:        "d4:infod5:filesle12:piece lengthi1024e6:pieces0:ee",
+			specificErr: torrent.ErrMultiFileTorrent,
+		},
+	}
+
+	for scenario, tc := range testCases {
+		t.Run(scenario, func(t *testing.T) {
+			torrentPath := filepath.Join(t.TempDir(), "test.torrent")
+			assert.NoError(t, os.WriteFile(torrentPath, []byte(tc.data), 0644))
+
+			_, _, err := torrent.ParseTorrentHashes(torrentPath)
+			assert.ErrorIs(t, err, tc.specificErr)
+		})
+	}
+}
+
+func Test_CreateTorrent(t *testing.T) {
+	torrentPath := filepath.Join(t.TempDir(), "dummy.txt.torrent")
+	err := torrent.CreateTorrent(torrentPath, filepath.Join("..", "testdata", "dummy.txt"), 1024)
+	assert.NoError(t, err)
+
+	pieceLength, pieceHashes, err := torrent.ParseTorrentHashes(torrentPath)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1024), pieceLength)
+	assert.Equal(t, dummyPieceHashes, hexHashes(pieceHashes))
+}
@@ -0,0 +1,197 @@
+// Package transport provides composable http.RoundTripper middlewares which can be chained into
+// a single transport for download.Options.RoundTripper.
+package transport
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/gkatanacio/multisource-downloader/backoff"
+)
+
+const (
+	retryBackoffBase = 100 * time.Millisecond
+	retryBackoffCap  = 10 * time.Second
+)
+
+// RoundTripperMiddleware decorates a round-tripper with additional behaviour.
+type RoundTripperMiddleware func(http.RoundTripper) http.RoundTripper
+
+// roundTripperFunc is an http.RoundTripper implemented by a function.
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+// RoundTrip implements http.RoundTripper.
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// NewChainedRoundTripper returns a round-tripper passing each request through the middlewares in
+// the given order before the given transport (or http.DefaultTransport if nil) sends it. The first
+// middleware is therefore the outermost one which observes the request first and the response last.
+func NewChainedRoundTripper(rt http.RoundTripper, middlewares ...RoundTripperMiddleware) http.RoundTripper {
+	if rt == nil {
+		rt = http.DefaultTransport
+	}
+
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		rt = middlewares[i](rt)
+	}
+
+	return rt
+}
+
+// WithRetry retries requests up to the given total number of attempts on network errors and 429 or
+// 5xx responses, waiting for the delays determined by the given backoff (from 100 milliseconds up to
+// 10 seconds). Requests with a body are only retried if the body can be obtained again (see
+// http.Request.GetBody).
+func WithRetry(maxAttempts int, b backoff.Backoff) RoundTripperMiddleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			for attempt := 0; ; attempt++ {
+				resp, err := next.RoundTrip(req)
+				if attempt+1 >= maxAttempts || !isRetryable(req, resp, err) || (req.Body != nil && req.GetBody == nil) {
+					return resp, err
+				}
+
+				if resp != nil {
+					resp.Body.Close()
+				}
+
+				select {
+				case <-req.Context().Done():
+					return nil, req.Context().Err()
+				case <-time.After(b.Next(attempt, retryBackoffBase, retryBackoffCap)):
+				}
+
+				if req.GetBody != nil {
+					body, err := req.GetBody()
+					if err != nil {
+						return nil, err
+					}
+					req = req.Clone(req.Context())
+					req.Body = body
+				}
+			}
+		})
+	}
+}
+
+// isRetryable returns true if the request failed due to a network error (rather than its context
+// being done) or a 429 or 5xx response.
+func isRetryable(req *http.Request, resp *http.Response, err error) bool {
+	if err != nil {
+		return req.Context().Err() == nil
+	}
+
+	return resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= http.StatusInternalServerError
+}
+
+// WithLogger logs each request along with its outcome and duration at debug level (or at warn level if it failed).
+func WithLogger(l *slog.Logger) RoundTripperMiddleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			start := time.Now()
+			resp, err := next.RoundTrip(req)
+
+			attrs := []slog.Attr{
+				slog.String("method", req.Method),
+				slog.String("url", req.URL.String()),
+				slog.Duration("duration", time.Since(start)),
+			}
+			if err != nil {
+				l.LogAttrs(req.Context(), slog.LevelWarn, "HTTP request failed", append(attrs, slog.Any("error", err))...)
+			} else {
+				l.LogAttrs(req.Context(), slog.LevelDebug, "HTTP request", append(attrs, slog.Int("status", resp.StatusCode))...)
+			}
+
+			return resp, err
+		})
+	}
+}
+
+// WithMetrics records the number of requests per method and status code (or "error" if the request
+// failed) and their durations per method. Metrics already registered (e.g., by another chain using the
+// same registerer) are reused. This panics if the metrics cannot be registered like prometheus.MustRegister.
+func WithMetrics(registerer prometheus.Registerer) RoundTripperMiddleware {
+	requests := mustRegister(registerer, prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "msdl_http_requests_total",
+		Help: "Total number of HTTP requests per method and status code.",
+	}, []string{"method", "code"}))
+	durations := mustRegister(registerer, prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "msdl_http_request_duration_seconds",
+		Help:    "Duration of HTTP requests per method.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method"}))
+
+	return func(next http.RoundTripper) http.RoundTripper {
+		return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			start := time.Now()
+			resp, err := next.RoundTrip(req)
+			durations.WithLabelValues(req.Method).Observe(time.Since(start).Seconds())
+
+			code := "error"
+			if err == nil {
+				code = strconv.Itoa(resp.StatusCode)
+			}
+			requests.WithLabelValues(req.Method, code).Inc()
+
+			return resp, err
+		})
+	}
+}
+
+// mustRegister registers the collector and returns the already registered one if existing.
+func mustRegister[T prometheus.Collector](registerer prometheus.Registerer, collector T) T {
+	err := registerer.Register(collector)
+
+	var alreadyRegisteredErr prometheus.AlreadyRegisteredError
+	if errors.As(err, &alreadyRegisteredErr) {
+		if existing, ok := alreadyRegisteredErr.ExistingCollector.(T); ok {
+			return existing
+		}
+	}
+	if err != nil {
+		panic(err)
+	}
+
+	return collector
+}
+
+// WithRateLimit spaces out the requests going through the middleware so that at most the given
+// number of requests per second are sent (without bursts). Requests wait for their turn unless
+// their context is done first.
+func WithRateLimit(rps float64) RoundTripperMiddleware {
+	interval := time.Duration(float64(time.Second) / rps)
+
+	var mu sync.Mutex
+	var next time.Time // when the next request may be sent
+
+	return func(rt http.RoundTripper) http.RoundTripper {
+		return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			mu.Lock()
+			now := time.Now()
+			at := next
+			if at.Before(now) {
+				at = now
+			}
+			next = at.Add(interval)
+			mu.Unlock()
+
+			if wait := time.Until(at); wait > 0 {
+				select {
+				case <-req.Context().Done():
+					return nil, req.Context().Err()
+				case <-time.After(wait):
+				}
+			}
+
+			return rt.RoundTrip(req)
+		})
+	}
+}
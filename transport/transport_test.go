@@ -0,0 +1,139 @@
+package transport
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/gkatanacio/multisource-downloader/backoff"
+)
+
+// recordingMiddleware appends its name to the given log whenever a request passes through it.
+func recordingMiddleware(name string, mu *sync.Mutex, log *[]string) RoundTripperMiddleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			mu.Lock()
+			*log = append(*log, name)
+			mu.Unlock()
+
+			return next.RoundTrip(req)
+		})
+	}
+}
+
+// noBackoff retries immediately.
+type noBackoff struct{}
+
+func (noBackoff) Next(int, time.Duration, time.Duration) time.Duration { return 0 }
+
+func Test_NewChainedRoundTripper(t *testing.T) {
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	var mu sync.Mutex
+	var observed []string
+
+	var logs bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&logs, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	registry := prometheus.NewRegistry()
+
+	rt := NewChainedRoundTripper(nil,
+		recordingMiddleware("outer", &mu, &observed),
+		WithRetry(3, noBackoff{}),
+		recordingMiddleware("per-attempt", &mu, &observed),
+		WithMetrics(registry),
+		WithLogger(logger),
+	)
+
+	resp, err := (&http.Client{Transport: rt}).Get(srv.URL)
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	// the middlewares after the retry observe each attempt in order
+	assert.Equal(t, []string{"outer", "per-attempt", "per-attempt"}, observed)
+	assert.Equal(t, int32(2), requests.Load())
+
+	assert.Equal(t, 1.0, testutil.ToFloat64(registryCounter(t, registry, "GET", "503")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(registryCounter(t, registry, "GET", "200")))
+
+	assert.Equal(t, 2, strings.Count(logs.String(), "msg=\"HTTP request\""))
+	assert.Contains(t, logs.String(), "status=503")
+	assert.Contains(t, logs.String(), "status=200")
+}
+
+// registryCounter returns the request counter registered by WithMetrics for the given labels.
+func registryCounter(t *testing.T, registry *prometheus.Registry, method, code string) prometheus.Collector {
+	t.Helper()
+
+	counter := mustRegister(registry, prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "msdl_http_requests_total",
+		Help: "Total number of HTTP requests per method and status code.",
+	}, []string{"method", "code"}))
+
+	return counter.WithLabelValues(method, code)
+}
+
+func Test_WithRetry_GivesUp(t *testing.T) {
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer srv.Close()
+
+	rt := NewChainedRoundTripper(nil, WithRetry(3, backoff.FullJitter{}))
+
+	resp, err := (&http.Client{Transport: rt}).Get(srv.URL)
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusBadGateway, resp.StatusCode)
+	assert.Equal(t, int32(3), requests.Load())
+}
+
+func Test_WithRateLimit(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	var mu sync.Mutex
+	var observed []string
+
+	// the rate limit applies before the inner middleware so it only observes the spaced out requests
+	rt := NewChainedRoundTripper(nil, WithRateLimit(20), recordingMiddleware("inner", &mu, &observed))
+	client := &http.Client{Transport: rt}
+
+	start := time.Now()
+	var wg sync.WaitGroup
+	for range 5 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			resp, err := client.Get(srv.URL)
+			if assert.NoError(t, err) {
+				resp.Body.Close()
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.GreaterOrEqual(t, time.Since(start), 200*time.Millisecond, "5 requests at 20 per second take at least 4 intervals")
+	assert.Len(t, observed, 5)
+}